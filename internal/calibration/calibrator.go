@@ -0,0 +1,400 @@
+package calibration
+
+import (
+	"encoding/json"
+	"math/rand"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Generators read complexity as a parameter level from 0 to 100, outcomes are
+// counted per level bucket of bucketWidth, level 100 falls into the last one
+const (
+	bucketWidth = 10
+	bucketCount = 10
+	curveStep   = 10 // Complexity step of the reported curves
+)
+
+// Config contains complexity calibration settings
+type Config struct {
+	MinSamples   int64   // Answers a bucket needs before it shapes the curve
+	MaxSamples   int64   // Bucket counts are halved past it so curves follow recent traffic
+	EasyPassRate float64 // Target pass rate at complexity 0
+	HardPassRate float64 // Target pass rate at complexity 100
+	Exploration  float64 // Fraction of challenges generated at a random level so every bucket stays measured
+	MaxShift     int32   // Furthest a fitted level moves from the requested complexity, bounds what skewed answers can do
+}
+
+// DefaultConfig returns default calibration configuration
+func DefaultConfig() *Config {
+	return &Config{
+		MinSamples:   30,
+		MaxSamples:   5000,
+		EasyPassRate: 0.95,
+		HardPassRate: 0.5,
+		Exploration:  0.05,
+		MaxShift:     40,
+	}
+}
+
+// Report is the observed statistics and derived curves of every challenge type
+type Report struct {
+	EasyPassRate float64                `json:"easy_pass_rate"`
+	HardPassRate float64                `json:"hard_pass_rate"`
+	MinSamples   int64                  `json:"min_samples"`
+	Exploration  float64                `json:"exploration"`
+	Types        map[string]*TypeReport `json:"types"`
+}
+
+// TypeReport is the calibration state of one challenge type. Without two
+// measured buckets the type is not calibrated and levels equal complexities
+type TypeReport struct {
+	Calibrated bool           `json:"calibrated"`
+	Buckets    []BucketReport `json:"buckets"`
+	Curve      []CurvePoint   `json:"curve"`
+}
+
+// BucketReport is the answers observed for one level bucket
+type BucketReport struct {
+	MinLevel       int32   `json:"min_level"`
+	MaxLevel       int32   `json:"max_level"`
+	Attempts       int64   `json:"attempts"`
+	Solved         int64   `json:"solved"`
+	PassRate       float64 `json:"pass_rate"`
+	FittedPassRate float64 `json:"fitted_pass_rate,omitempty"` // Set once the bucket has enough samples
+	MeanSolveMs    float64 `json:"mean_solve_ms,omitempty"`
+}
+
+// CurvePoint maps a requested complexity to the generator level issued for it
+type CurvePoint struct {
+	Complexity       int32   `json:"complexity"`
+	Level            int32   `json:"level"`
+	TargetPassRate   float64 `json:"target_pass_rate"`
+	ExpectedPassRate float64 `json:"expected_pass_rate,omitempty"` // Fitted pass rate at the level
+}
+
+// Calibrator maps requested complexity to generator levels so that the same
+// complexity gives comparable pass rates across challenge types. It counts
+// answers and solve times per type and level bucket, fits a pass rate curve
+// that never rises with the level and inverts it at a target pass rate that
+// falls linearly from EasyPassRate to HardPassRate
+type Calibrator struct {
+	config *Config
+
+	mu      sync.RWMutex
+	buckets map[string]*[bucketCount]bucket
+}
+
+type bucket struct {
+	attempts  int64
+	solved    int64
+	solveTime time.Duration // Total over solved answers
+}
+
+// point is a fitted pass rate at the center level of a bucket
+type point struct {
+	index int
+	level float64
+	rate  float64
+}
+
+// NewCalibrator creates a calibrator with default configuration
+func NewCalibrator() *Calibrator {
+	return NewCalibratorWithConfig(DefaultConfig())
+}
+
+// NewCalibratorWithConfig creates a calibrator with custom configuration
+func NewCalibratorWithConfig(config *Config) *Calibrator {
+	if config == nil {
+		config = DefaultConfig()
+	}
+	defaults := DefaultConfig()
+	if config.MinSamples <= 0 {
+		config.MinSamples = defaults.MinSamples
+	}
+	if config.MaxSamples < 2*config.MinSamples {
+		config.MaxSamples = max(defaults.MaxSamples, 2*config.MinSamples)
+	}
+	if config.EasyPassRate <= 0 || config.EasyPassRate > 1 {
+		config.EasyPassRate = defaults.EasyPassRate
+	}
+	if config.HardPassRate <= 0 || config.HardPassRate > config.EasyPassRate {
+		config.HardPassRate = min(defaults.HardPassRate, config.EasyPassRate)
+	}
+	if config.Exploration < 0 || config.Exploration > 1 {
+		config.Exploration = defaults.Exploration
+	}
+	if config.MaxShift <= 0 {
+		config.MaxShift = defaults.MaxShift
+	}
+
+	return &Calibrator{
+		config:  config,
+		buckets: make(map[string]*[bucketCount]bucket),
+	}
+}
+
+// Level returns the generator level for a challenge of the given type and
+// requested complexity, rng decides exploration and may be nil to disable it
+func (c *Calibrator) Level(challengeType string, complexity int32, rng *rand.Rand) int32 {
+	complexity = clampLevel(complexity)
+	if rng != nil && rng.Float64() < c.config.Exploration {
+		return int32(rng.Intn(101))
+	}
+
+	c.mu.RLock()
+	points := c.fit(challengeType)
+	c.mu.RUnlock()
+
+	if len(points) < 2 {
+		return complexity
+	}
+	return c.bound(complexity, levelFor(points, c.target(complexity)))
+}
+
+// Record counts the answer to a challenge generated at level
+func (c *Calibrator) Record(challengeType string, level int32, solved bool, elapsed time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	buckets, exists := c.buckets[challengeType]
+	if !exists {
+		buckets = &[bucketCount]bucket{}
+		c.buckets[challengeType] = buckets
+	}
+
+	b := &buckets[bucketIndex(level)]
+	b.attempts++
+	if solved {
+		b.solved++
+		b.solveTime += elapsed
+	}
+
+	// Halving keeps pass rate and mean solve time while old answers fade out
+	if b.attempts > c.config.MaxSamples {
+		b.attempts /= 2
+		b.solved /= 2
+		b.solveTime /= 2
+	}
+}
+
+// Report returns the statistics and curves of every type with recorded answers
+func (c *Calibrator) Report() *Report {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	report := &Report{
+		EasyPassRate: c.config.EasyPassRate,
+		HardPassRate: c.config.HardPassRate,
+		MinSamples:   c.config.MinSamples,
+		Exploration:  c.config.Exploration,
+		Types:        make(map[string]*TypeReport, len(c.buckets)),
+	}
+
+	for challengeType, buckets := range c.buckets {
+		points := c.fit(challengeType)
+		fitted := make(map[int]float64, len(points))
+		for _, p := range points {
+			fitted[p.index] = p.rate
+		}
+
+		typeReport := &TypeReport{Calibrated: len(points) >= 2}
+		for i, b := range buckets {
+			bucketReport := BucketReport{
+				MinLevel:       int32(i * bucketWidth),
+				MaxLevel:       int32((i+1)*bucketWidth - 1),
+				Attempts:       b.attempts,
+				Solved:         b.solved,
+				FittedPassRate: fitted[i],
+			}
+			if i == bucketCount-1 {
+				bucketReport.MaxLevel = 100
+			}
+			if b.attempts > 0 {
+				bucketReport.PassRate = float64(b.solved) / float64(b.attempts)
+			}
+			if b.solved > 0 {
+				bucketReport.MeanSolveMs = float64(b.solveTime.Milliseconds()) / float64(b.solved)
+			}
+			typeReport.Buckets = append(typeReport.Buckets, bucketReport)
+		}
+
+		for complexity := int32(0); complexity <= 100; complexity += curveStep {
+			curvePoint := CurvePoint{
+				Complexity:     complexity,
+				Level:          complexity,
+				TargetPassRate: c.target(complexity),
+			}
+			if typeReport.Calibrated {
+				curvePoint.Level = c.bound(complexity, levelFor(points, curvePoint.TargetPassRate))
+				curvePoint.ExpectedPassRate = rateAt(points, float64(curvePoint.Level))
+			}
+			typeReport.Curve = append(typeReport.Curve, curvePoint)
+		}
+
+		report.Types[challengeType] = typeReport
+	}
+
+	return report
+}
+
+// Handler serves the report as JSON
+func (c *Calibrator) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+
+		if err := json.NewEncoder(w).Encode(c.Report()); err != nil {
+			http.Error(w, "Failed to encode calibration report", http.StatusInternalServerError)
+		}
+	})
+}
+
+// GetStats returns calibrator statistics
+func (c *Calibrator) GetStats() map[string]interface{} {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	calibrated := make([]string, 0, len(c.buckets))
+	var answers int64
+	for challengeType, buckets := range c.buckets {
+		for _, b := range buckets {
+			answers += b.attempts
+		}
+		if len(c.fit(challengeType)) >= 2 {
+			calibrated = append(calibrated, challengeType)
+		}
+	}
+	sort.Strings(calibrated)
+
+	return map[string]interface{}{
+		"tracked_types":    len(c.buckets),
+		"calibrated_types": calibrated,
+		"answers":          answers,
+	}
+}
+
+// bound keeps level within MaxShift of complexity, so clients failing or
+// solving on purpose cannot move every challenge to the easiest or hardest level
+func (c *Calibrator) bound(complexity, level int32) int32 {
+	return clampLevel(min(max(level, complexity-c.config.MaxShift), complexity+c.config.MaxShift))
+}
+
+// target returns the pass rate every type should reach at complexity
+func (c *Calibrator) target(complexity int32) float64 {
+	return c.config.EasyPassRate + (c.config.HardPassRate-c.config.EasyPassRate)*float64(complexity)/100
+}
+
+// fit returns the pass rates of the buckets with enough samples, made
+// non-increasing in level by pool adjacent violators weighted by attempts.
+// Callers hold mu
+func (c *Calibrator) fit(challengeType string) []point {
+	buckets, exists := c.buckets[challengeType]
+	if !exists {
+		return nil
+	}
+
+	type block struct {
+		first, last int // Range in points
+		rate        float64
+		weight      float64
+	}
+
+	var points []point
+	var blocks []block
+	for i, b := range buckets {
+		if b.attempts < c.config.MinSamples {
+			continue
+		}
+
+		// Laplace smoothing keeps rates off 0 and 1 for small samples
+		points = append(points, point{
+			index: i,
+			level: float64(i*bucketWidth) + bucketWidth/2,
+		})
+		blocks = append(blocks, block{
+			first:  len(points) - 1,
+			last:   len(points) - 1,
+			rate:   float64(b.solved+1) / float64(b.attempts+2),
+			weight: float64(b.attempts),
+		})
+
+		// A harder bucket passing more often than an easier one is noise, pool them
+		for len(blocks) > 1 && blocks[len(blocks)-1].rate > blocks[len(blocks)-2].rate {
+			last, prev := blocks[len(blocks)-1], blocks[len(blocks)-2]
+			weight := prev.weight + last.weight
+			blocks = blocks[:len(blocks)-1]
+			blocks[len(blocks)-1] = block{
+				first:  prev.first,
+				last:   last.last,
+				rate:   (prev.rate*prev.weight + last.rate*last.weight) / weight,
+				weight: weight,
+			}
+		}
+	}
+
+	for _, b := range blocks {
+		for i := b.first; i <= b.last; i++ {
+			points[i].rate = b.rate
+		}
+	}
+	return points
+}
+
+// levelFor inverts the fitted curve, the lowest level expected to pass at target.
+// Targets above every fitted rate get the easiest level, below every rate the hardest
+func levelFor(points []point, target float64) int32 {
+	if target > points[0].rate {
+		return 0
+	}
+
+	for i := 1; i < len(points); i++ {
+		prev, next := points[i-1], points[i]
+		if next.rate > target {
+			continue
+		}
+		if prev.rate == next.rate {
+			return int32(prev.level)
+		}
+		return int32(prev.level + (next.level-prev.level)*(prev.rate-target)/(prev.rate-next.rate) + 0.5)
+	}
+
+	return 100
+}
+
+// rateAt interpolates the fitted curve at level, constant beyond its ends
+func rateAt(points []point, level float64) float64 {
+	if level <= points[0].level {
+		return points[0].rate
+	}
+
+	for i := 1; i < len(points); i++ {
+		prev, next := points[i-1], points[i]
+		if level <= next.level {
+			return prev.rate + (next.rate-prev.rate)*(level-prev.level)/(next.level-prev.level)
+		}
+	}
+
+	return points[len(points)-1].rate
+}
+
+func bucketIndex(level int32) int {
+	return min(int(clampLevel(level))/bucketWidth, bucketCount-1)
+}
+
+func clampLevel(level int32) int32 {
+	if level < 0 {
+		return 0
+	}
+	if level > 100 {
+		return 100
+	}
+	return level
+}
@@ -0,0 +1,576 @@
+package captcha
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/rand"
+	"strings"
+	"unicode"
+)
+
+// audioSampleRate keeps fricatives up to 5.5 kHz while the payload stays small
+const audioSampleRate = 11025
+
+// AudioCaptcha represents an accessible audio challenge: spoken digits over noise
+type AudioCaptcha struct {
+	ID           string `json:"id"`
+	Audio        string `json:"audio"`  // WAV data URL, 8-bit mono PCM
+	Length       int    `json:"length"` // Number of spoken digits
+	DurationMs   int    `json:"duration_ms"`
+	Instructions string `json:"instructions"`
+}
+
+// AudioAnswer is the transcript of the recording, it never leaves the server
+type AudioAnswer struct {
+	Transcript string `json:"transcript"` // Spoken digits in order, e.g. "4719"
+}
+
+// AudioGenerator generates audio challenges with a built-in formant synthesizer,
+// no external speech service is involved
+type AudioGenerator struct {
+	minDigits int // Digits spoken at the lowest complexity
+	maxDigits int // Digits spoken at the highest complexity
+}
+
+// NewAudioGenerator creates a new audio generator
+func NewAudioGenerator(minDigits, maxDigits int) *AudioGenerator {
+	return &AudioGenerator{
+		minDigits: minDigits,
+		maxDigits: maxDigits,
+	}
+}
+
+// Generate creates a new audio captcha
+func (g *AudioGenerator) Generate(rng *rand.Rand, complexity int32) (*AudioCaptcha, interface{}, error) {
+	digits := make([]byte, g.calculateDigitCount(complexity))
+	for i := range digits {
+		digits[i] = byte('0' + rng.Intn(10))
+	}
+	transcript := string(digits)
+
+	samples := g.synthesize(rng, transcript, complexity)
+	audio, err := encodeWAV(samples)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	captcha := &AudioCaptcha{
+		ID:           fmt.Sprintf("audio_%d", rng.Int63()),
+		Audio:        audio,
+		Length:       len(digits),
+		DurationMs:   len(samples) * 1000 / audioSampleRate,
+		Instructions: fmt.Sprintf("Listen to the recording and type the %d digits you hear", len(digits)),
+	}
+
+	return captcha, &AudioAnswer{Transcript: transcript}, nil
+}
+
+// GenerateHTML generates HTML for the audio captcha, usable with a screen reader and keyboard only
+func (g *AudioGenerator) GenerateHTML(captcha *AudioCaptcha) (string, error) {
+	captchaJSON, err := json.Marshal(captcha)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal captcha: %w", err)
+	}
+
+	html := fmt.Sprintf(`
+<!DOCTYPE html>
+<html lang="en">
+<head>
+    <meta charset="UTF-8">
+    <title>Audio Captcha</title>
+    <style>
+        body {
+            font-family: Arial, sans-serif;
+            margin: 0;
+            padding: 20px;
+            background-color: #f5f5f5;
+        }
+        .captcha-container {
+            max-width: 400px;
+            margin: 0 auto;
+            background: white;
+            border-radius: 8px;
+            box-shadow: 0 2px 10px rgba(0,0,0,0.1);
+            padding: 20px;
+        }
+        .instructions {
+            text-align: center;
+            margin-bottom: 20px;
+            font-size: 18px;
+            color: #222;
+        }
+        audio, .answer {
+            display: block;
+            width: 100%%;
+            box-sizing: border-box;
+            margin: 0 auto 15px;
+        }
+        .answer {
+            padding: 10px;
+            font-size: 20px;
+            letter-spacing: 4px;
+            border: 2px solid #333;
+            border-radius: 4px;
+        }
+        .answer:focus, .submit-btn:focus {
+            outline: 3px solid #ffbf47;
+        }
+        .submit-btn {
+            display: block;
+            margin: 0 auto;
+            padding: 10px 20px;
+            background: #0b5ed7;
+            color: white;
+            border: none;
+            border-radius: 4px;
+            cursor: pointer;
+            font-size: 18px;
+        }
+    </style>
+</head>
+<body>
+    <form class="captcha-container" onsubmit="submitSolution(event)">
+        <label class="instructions" id="instructions" for="answer">%s</label>
+        <audio id="audio" controls aria-describedby="instructions"></audio>
+        <input class="answer" id="answer" type="text" inputmode="numeric" autocomplete="off" autocorrect="off" spellcheck="false" aria-required="true">
+        <button class="submit-btn" type="submit">Verify</button>
+    </form>
+
+    <script>
+        const captchaData = %s;
+
+        function initCaptcha() {
+            document.getElementById('audio').src = captchaData.audio;
+            document.getElementById('answer').focus();
+        }
+
+        function submitSolution(e) {
+            e.preventDefault();
+
+            // Send solution to parent window
+            window.top.postMessage({
+                type: 'captcha:sendData',
+                data: JSON.stringify({
+                    type: 'audio_solution',
+                    solution: document.getElementById('answer').value,
+                    captchaId: captchaData.id
+                })
+            }, '*');
+        }
+
+        // Listen for messages from server
+        window.addEventListener('message', function(e) {
+            if (e.data && e.data.type === 'captcha:serverData') {
+                console.log('Received server data:', e.data.data);
+            }
+        });
+
+        // Initialize when page loads
+        document.addEventListener('DOMContentLoaded', initCaptcha);
+    </script>
+</body>
+</html>`,
+		captcha.Instructions, string(captchaJSON))
+
+	return html, nil
+}
+
+// calculateDigitCount calculates how many digits are spoken based on complexity
+func (g *AudioGenerator) calculateDigitCount(complexity int32) int {
+	if complexity < 0 {
+		complexity = 0
+	}
+	if complexity > 100 {
+		complexity = 100
+	}
+
+	return g.minDigits + int(complexity)*(g.maxDigits-g.minDigits+1)/101
+}
+
+// transcriptWords maps spoken forms users type instead of digits, including
+// common homophones of the English digit names and the Russian ones
+var transcriptWords = map[string]byte{
+	"zero": '0', "oh": '0', "o": '0', "ноль": '0', "нуль": '0',
+	"one": '1', "won": '1', "один": '1', "одна": '1',
+	"two": '2', "to": '2', "too": '2', "два": '2', "две": '2',
+	"three": '3', "три": '3',
+	"four": '4', "for": '4', "четыре": '4',
+	"five": '5', "пять": '5',
+	"six": '6', "шесть": '6',
+	"seven": '7', "семь": '7',
+	"eight": '8', "ate": '8', "восемь": '8',
+	"nine": '9', "девять": '9',
+}
+
+// NormalizeTranscript reduces a typed answer to the digits it names: spelled
+// out numbers become digits, case, spaces and punctuation are ignored
+func NormalizeTranscript(input string) string {
+	var digits, word strings.Builder
+	flush := func() {
+		if digit, ok := transcriptWords[word.String()]; ok {
+			digits.WriteByte(digit)
+		}
+		word.Reset()
+	}
+
+	for _, r := range strings.ToLower(input) {
+		if unicode.IsLetter(r) {
+			word.WriteRune(r)
+			continue
+		}
+		flush()
+		if r >= '0' && r <= '9' {
+			digits.WriteRune(r)
+		}
+	}
+	flush()
+
+	return digits.String()
+}
+
+// Verify checks a typed answer against the transcript. One misheard, missing
+// or extra digit is borderline and earns another round, more fail
+func (a *AudioAnswer) Verify(input string) (bool, int32) {
+	switch editDistance(NormalizeTranscript(input), a.Transcript) {
+	case 0:
+		return true, 100
+	case 1:
+		return false, 60
+	default:
+		return false, 0
+	}
+}
+
+// editDistance returns the Levenshtein distance between two strings
+func editDistance(a, b string) int {
+	s, t := []rune(a), []rune(b)
+	previous := make([]int, len(t)+1)
+	current := make([]int, len(t)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+
+	for i := 1; i <= len(s); i++ {
+		current[0] = i
+		for j := 1; j <= len(t); j++ {
+			cost := 1
+			if s[i-1] == t[j-1] {
+				cost = 0
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous, current = current, previous
+	}
+
+	return previous[len(t)]
+}
+
+// phone is one segment of synthesized speech. Formants move from formants to
+// glide over the segment, zero formants keep the previous ones
+type phone struct {
+	formants  [3]float64 // Hz
+	glide     [3]float64 // Hz at the end of a diphthong, zero for steady vowels
+	voicing   float64    // Amplitude of the glottal source
+	noise     float64    // Amplitude of the frication source
+	noiseFreq float64    // Center of the frication band in Hz
+	noiseBand float64    // Width of the frication band in Hz
+	duration  float64    // Milliseconds at normal speed
+}
+
+func vowel(f1, f2, f3, duration float64) phone {
+	return phone{formants: [3]float64{f1, f2, f3}, voicing: 1, duration: duration}
+}
+
+func diphthong(f1, f2, f3, g1, g2, g3, duration float64) phone {
+	return phone{formants: [3]float64{f1, f2, f3}, glide: [3]float64{g1, g2, g3}, voicing: 1, duration: duration}
+}
+
+func voiced(f1, f2, f3, voicing, duration float64) phone {
+	return phone{formants: [3]float64{f1, f2, f3}, voicing: voicing, duration: duration}
+}
+
+func fricative(freq, band, noise, voicing, duration float64) phone {
+	return phone{voicing: voicing, noise: noise, noiseFreq: freq, noiseBand: band, duration: duration}
+}
+
+func closure(duration float64) phone {
+	return phone{duration: duration}
+}
+
+// Phones shared by several digit names
+var (
+	phoneIH = vowel(390, 1990, 2550, 120)
+	phoneAH = vowel(640, 1190, 2390, 180)
+	phoneAY = diphthong(730, 1090, 2440, 300, 2200, 2900, 240)
+	phoneR  = voiced(310, 1060, 1380, 0.8, 80)
+	phoneN  = voiced(250, 1700, 2600, 0.45, 110)
+	phoneS  = fricative(4500, 1500, 0.45, 0, 130)
+	phoneF  = fricative(3500, 3000, 0.12, 0, 110)
+	phoneV  = fricative(3500, 3000, 0.08, 0.4, 80)
+	phoneT  = []phone{closure(50), fricative(4000, 2500, 0.6, 0, 25)}
+	phoneK  = []phone{closure(50), fricative(1800, 800, 0.7, 0, 35)}
+)
+
+// digitPhones spells the English digit names as phones
+var digitPhones = [10][]phone{
+	join([]phone{fricative(4500, 1500, 0.25, 0.5, 90), phoneIH, phoneR, diphthong(450, 1000, 2400, 350, 800, 2300, 220)}),
+	join([]phone{voiced(290, 610, 2150, 0.8, 90), phoneAH, phoneN}),
+	join(phoneT, []phone{vowel(300, 870, 2240, 260)}),
+	join([]phone{fricative(3500, 3000, 0.1, 0, 120), phoneR, vowel(270, 2290, 3010, 220)}),
+	join([]phone{phoneF, vowel(570, 840, 2410, 200), phoneR}),
+	join([]phone{phoneF, phoneAY, phoneV}),
+	join([]phone{phoneS, phoneIH}, phoneK, []phone{phoneS}),
+	join([]phone{phoneS, vowel(530, 1840, 2480, 130), phoneV, vowel(500, 1400, 2400, 90), phoneN}),
+	join([]phone{diphthong(480, 1900, 2500, 300, 2200, 2900, 260)}, phoneT),
+	join([]phone{phoneN, phoneAY, phoneN}),
+}
+
+func join(parts ...[]phone) []phone {
+	var phones []phone
+	for _, part := range parts {
+		phones = append(phones, part...)
+	}
+	return phones
+}
+
+// babbleVowels are spoken by the background voice at higher complexity
+var babbleVowels = []phone{
+	vowel(270, 2290, 3010, 160),
+	vowel(530, 1840, 2480, 140),
+	vowel(640, 1190, 2390, 180),
+	vowel(570, 840, 2410, 150),
+	vowel(300, 870, 2240, 170),
+	diphthong(730, 1090, 2440, 300, 2200, 2900, 200),
+}
+
+// synthesize speaks the digits with random pauses over background noise. Pitch,
+// tempo and loudness vary per challenge and per digit so recordings of the same
+// digit never match sample for sample
+func (g *AudioGenerator) synthesize(rng *rand.Rand, transcript string, complexity int32) []float64 {
+	speaker := newFormantVoice(rng, 95+rng.Float64()*55, 0.9+rng.Float64()*0.25)
+
+	samples := make([]float64, 0, audioSampleRate*len(transcript))
+	samples = append(samples, make([]float64, audioSampleRate*4/10)...)
+	for _, digit := range transcript {
+		gain := 0.8 + rng.Float64()*0.4
+		for _, sample := range speaker.speak(digitPhones[digit-'0']) {
+			samples = append(samples, sample*gain)
+		}
+		pause := audioSampleRate * (350 + rng.Intn(300)) / 1000
+		samples = append(samples, make([]float64, pause)...)
+	}
+
+	// Noise is scaled against the speech level, from about 16 dB SNR down to 4 dB
+	level := rms(samples) * (0.15 + 0.45*float64(complexity)/100)
+	brown := 0.0
+	for i := range samples {
+		white := rng.Float64()*2 - 1
+		brown = 0.97*brown + 0.03*white
+		samples[i] += level * (0.6*white + 8*brown)
+	}
+
+	// A second, quieter voice babbles vowels at higher complexity
+	if complexity >= 40 {
+		babbler := newFormantVoice(rng, 140+rng.Float64()*80, 1)
+		babbleLevel := 0.15 + 0.25*float64(complexity-40)/60
+		position := rng.Intn(audioSampleRate / 2)
+		for position < len(samples) {
+			for _, sample := range babbler.speak([]phone{babbleVowels[rng.Intn(len(babbleVowels))]}) {
+				if position >= len(samples) {
+					break
+				}
+				samples[position] += sample * babbleLevel
+				position++
+			}
+			position += audioSampleRate * (100 + rng.Intn(400)) / 1000
+		}
+	}
+
+	// Normalize to just below full scale
+	peak := 0.0
+	for _, sample := range samples {
+		peak = math.Max(peak, math.Abs(sample))
+	}
+	if peak > 0 {
+		for i := range samples {
+			samples[i] *= 0.9 / peak
+		}
+	}
+
+	return samples
+}
+
+func rms(samples []float64) float64 {
+	if len(samples) == 0 {
+		return 0
+	}
+	sum := 0.0
+	for _, sample := range samples {
+		sum += sample * sample
+	}
+	return math.Sqrt(sum / float64(len(samples)))
+}
+
+// resonator is a two-pole band-pass filter with unity gain at 0 Hz
+type resonator struct {
+	a, b, c float64
+	y1, y2  float64
+}
+
+func (r *resonator) tune(freq, bandwidth float64) {
+	r.c = -math.Exp(-2 * math.Pi * bandwidth / audioSampleRate)
+	r.b = 2 * math.Exp(-math.Pi*bandwidth/audioSampleRate) * math.Cos(2*math.Pi*freq/audioSampleRate)
+	r.a = 1 - r.b - r.c
+}
+
+// tunePeak tunes the resonator to unity gain at its center frequency instead, so
+// band-passed noise keeps a level independent of the band
+func (r *resonator) tunePeak(freq, bandwidth float64) {
+	r.tune(freq, bandwidth)
+	omega := 2 * math.Pi * freq / audioSampleRate
+	re := 1 - r.b*math.Cos(omega) - r.c*math.Cos(2*omega)
+	im := r.b*math.Sin(omega) + r.c*math.Sin(2*omega)
+	r.a = math.Hypot(re, im)
+}
+
+func (r *resonator) process(x float64) float64 {
+	y := r.a*x + r.b*r.y1 + r.c*r.y2
+	r.y2, r.y1 = r.y1, y
+	return y
+}
+
+// formantVoice is a cascade formant synthesizer: a glottal pulse train through
+// three formant resonators, with band-passed noise in parallel for consonants
+type formantVoice struct {
+	pitch float64 // Mean fundamental frequency in Hz
+	tempo float64 // Duration multiplier
+	rng   *rand.Rand
+
+	phase    float64
+	glottal  float64
+	formants [3]resonator
+	friction resonator
+
+	// Parameters glide towards each phone's targets instead of jumping
+	current [3]float64
+	voicing float64
+	noise   float64
+}
+
+// Formant bandwidths in Hz
+var formantBandwidths = [3]float64{80, 100, 150}
+
+func newFormantVoice(rng *rand.Rand, pitch, tempo float64) *formantVoice {
+	return &formantVoice{
+		pitch:   pitch,
+		tempo:   tempo,
+		rng:     rng,
+		current: [3]float64{500, 1500, 2500},
+	}
+}
+
+// speak renders a word, the pitch falls slightly across it like a spoken list item
+func (v *formantVoice) speak(phones []phone) []float64 {
+	total := 0.0
+	for _, p := range phones {
+		total += p.duration
+	}
+	length := int(total * v.tempo * audioSampleRate / 1000)
+
+	// Formants move with a time constant of 12 ms, amplitudes with 5 ms
+	formantRate := 1 - math.Exp(-1/(0.012*audioSampleRate))
+	amplitudeRate := 1 - math.Exp(-1/(0.005*audioSampleRate))
+
+	out := make([]float64, 0, length)
+	for _, p := range phones {
+		n := int(p.duration * v.tempo * audioSampleRate / 1000)
+		if p.noise > 0 {
+			v.friction.tunePeak(p.noiseFreq, p.noiseBand)
+		}
+
+		for i := 0; i < n; i++ {
+			progress := float64(i) / float64(n)
+
+			for k := range v.current {
+				target := p.formants[k]
+				if target == 0 {
+					continue
+				}
+				if p.glide[k] != 0 {
+					target += (p.glide[k] - target) * progress
+				}
+				v.current[k] += (target - v.current[k]) * formantRate
+				v.formants[k].tune(v.current[k], formantBandwidths[k])
+			}
+			v.voicing += (p.voicing - v.voicing) * amplitudeRate
+			v.noise += (p.noise - v.noise) * amplitudeRate
+
+			// Pitch declines by a fifth over the word, with a little jitter per period
+			wordProgress := float64(len(out)) / float64(length)
+			v.phase += v.pitch * (1.1 - 0.2*wordProgress) / audioSampleRate
+			pulse := 0.0
+			if v.phase >= 1 {
+				v.phase -= 1 + 0.02*(v.rng.Float64()-0.5)
+				pulse = 1
+			}
+			v.glottal = 0.9*v.glottal + pulse
+
+			sample := v.glottal
+			for k := range v.formants {
+				sample = v.formants[k].process(sample)
+			}
+			sample *= v.voicing
+
+			if v.noise > 0.001 {
+				sample += v.noise * v.friction.process(v.rng.Float64()*2-1)
+			}
+
+			out = append(out, sample)
+		}
+	}
+
+	return out
+}
+
+// encodeWAV encodes samples in [-1, 1] as an 8-bit mono WAV data URL
+func encodeWAV(samples []float64) (string, error) {
+	// Chunks are padded to an even size
+	padding := len(samples) % 2
+
+	var buf bytes.Buffer
+	header := []interface{}{
+		[4]byte{'R', 'I', 'F', 'F'},
+		uint32(36 + len(samples) + padding),
+		[4]byte{'W', 'A', 'V', 'E'},
+		[4]byte{'f', 'm', 't', ' '},
+		uint32(16),              // Format chunk size
+		uint16(1),               // PCM
+		uint16(1),               // Mono
+		uint32(audioSampleRate), // Sample rate
+		uint32(audioSampleRate), // Byte rate
+		uint16(1),               // Block align
+		uint16(8),               // Bits per sample
+		[4]byte{'d', 'a', 't', 'a'},
+		uint32(len(samples)),
+	}
+	for _, field := range header {
+		if err := binary.Write(&buf, binary.LittleEndian, field); err != nil {
+			return "", fmt.Errorf("failed to encode audio: %w", err)
+		}
+	}
+
+	// 8-bit PCM is unsigned with silence at 128
+	for _, sample := range samples {
+		sample = math.Max(-1, math.Min(1, sample))
+		buf.WriteByte(uint8(math.Round(128 + sample*127)))
+	}
+	if padding > 0 {
+		buf.WriteByte(128)
+	}
+
+	return "data:audio/wav;base64," + base64.StdEncoding.EncodeToString(buf.Bytes()), nil
+}
@@ -5,7 +5,6 @@ import (
 	"fmt"
 	"math"
 	"math/rand"
-	"time"
 )
 
 // ClickCaptcha represents a click-based captcha
@@ -50,22 +49,22 @@ func NewClickGenerator(canvasWidth, canvasHeight, minClicks, maxClicks, clickRad
 }
 
 // Generate creates a new click captcha
-func (g *ClickGenerator) Generate(complexity int32) (*ClickCaptcha, interface{}, error) {
+func (g *ClickGenerator) Generate(rng *rand.Rand, complexity int32) (*ClickCaptcha, interface{}, error) {
 	// Determine number of clicks based on complexity
 	numClicks := g.calculateClickCount(complexity)
 
 	// Generate click areas
-	clickAreas, correctSequence := g.generateClickAreas(numClicks)
+	clickAreas, correctSequence := g.generateClickAreas(rng, numClicks)
 
 	// Generate image data (base64 encoded simple shapes)
-	imageData := g.generateImage(clickAreas)
+	imageData := g.generateImage(rng, clickAreas)
 
 	// Create captcha
 	captcha := &ClickCaptcha{
-		ID:           fmt.Sprintf("click_%d", time.Now().UnixNano()),
+		ID:           fmt.Sprintf("click_%d", rng.Int63()),
 		Image:        imageData,
 		ClickAreas:   clickAreas,
-		Instructions: g.generateInstructions(complexity),
+		Instructions: g.generateInstructions(rng, complexity),
 		CanvasWidth:  g.canvasWidth,
 		CanvasHeight: g.canvasHeight,
 	}
@@ -308,19 +307,17 @@ func (g *ClickGenerator) calculateClickCount(complexity int32) int {
 }
 
 // generateClickAreas generates click areas for the captcha
-func (g *ClickGenerator) generateClickAreas(numClicks int) ([]ClickArea, []string) {
-	// rand.Seed is deprecated in Go 1.20+, using default random source
-
+func (g *ClickGenerator) generateClickAreas(rng *rand.Rand, numClicks int) ([]ClickArea, []string) {
 	clickAreas := make([]ClickArea, numClicks)
 	correctSequence := make([]string, 0, numClicks)
 
 	for i := 0; i < numClicks; i++ {
 		// Generate random position
-		x := g.clickRadius + rand.Intn(g.canvasWidth-2*g.clickRadius)
-		y := g.clickRadius + rand.Intn(g.canvasHeight-2*g.clickRadius)
+		x := g.clickRadius + rng.Intn(g.canvasWidth-2*g.clickRadius)
+		y := g.clickRadius + rng.Intn(g.canvasHeight-2*g.clickRadius)
 
 		// Ensure areas don't overlap
-		g.avoidOverlap(x, y, clickAreas[:i])
+		g.avoidOverlap(rng, x, y, clickAreas[:i])
 
 		area := ClickArea{
 			ID:       fmt.Sprintf("area_%d", i),
@@ -340,7 +337,7 @@ func (g *ClickGenerator) generateClickAreas(numClicks int) ([]ClickArea, []strin
 }
 
 // avoidOverlap ensures click areas don't overlap
-func (g *ClickGenerator) avoidOverlap(x, y int, existingAreas []ClickArea) {
+func (g *ClickGenerator) avoidOverlap(rng *rand.Rand, x, y int, existingAreas []ClickArea) {
 	maxAttempts := 50
 	attempts := 0
 
@@ -360,8 +357,8 @@ func (g *ClickGenerator) avoidOverlap(x, y int, existingAreas []ClickArea) {
 		}
 
 		// Reposition
-		x = g.clickRadius + rand.Intn(g.canvasWidth-2*g.clickRadius)
-		y = g.clickRadius + rand.Intn(g.canvasHeight-2*g.clickRadius)
+		x = g.clickRadius + rng.Intn(g.canvasWidth-2*g.clickRadius)
+		y = g.clickRadius + rng.Intn(g.canvasHeight-2*g.clickRadius)
 
 		attempts++
 	}
@@ -375,14 +372,14 @@ func (g *ClickGenerator) calculateDistance(x1, y1, x2, y2 int) float64 {
 }
 
 // generateImage generates a simple image with shapes
-func (g *ClickGenerator) generateImage(clickAreas []ClickArea) string {
+func (g *ClickGenerator) generateImage(rng *rand.Rand, clickAreas []ClickArea) string {
 	// For now, return a simple base64 encoded image
 	// In a real implementation, this would generate an actual image
-	return "data:image/svg+xml;base64," + g.generateSVG(clickAreas)
+	return "data:image/svg+xml;base64," + g.generateSVG(rng, clickAreas)
 }
 
 // generateSVG generates an SVG image with shapes
-func (g *ClickGenerator) generateSVG(clickAreas []ClickArea) string {
+func (g *ClickGenerator) generateSVG(rng *rand.Rand, clickAreas []ClickArea) string {
 	// Simple SVG with circles and rectangles
 	svg := fmt.Sprintf(`<svg width="%d" height="%d" xmlns="http://www.w3.org/2000/svg">`, g.canvasWidth, g.canvasHeight)
 
@@ -391,10 +388,10 @@ func (g *ClickGenerator) generateSVG(clickAreas []ClickArea) string {
 	colors := []string{"#e9ecef", "#dee2e6", "#ced4da", "#adb5bd"}
 
 	for i := 0; i < 10; i++ {
-		x := rand.Intn(g.canvasWidth - 50)
-		y := rand.Intn(g.canvasHeight - 50)
-		color := colors[rand.Intn(len(colors))]
-		shape := shapes[rand.Intn(len(shapes))]
+		x := rng.Intn(g.canvasWidth - 50)
+		y := rng.Intn(g.canvasHeight - 50)
+		color := colors[rng.Intn(len(colors))]
+		shape := shapes[rng.Intn(len(shapes))]
 
 		switch shape {
 		case "circle":
@@ -420,7 +417,7 @@ func (g *ClickGenerator) encodeBase64(s string) string {
 }
 
 // generateInstructions generates instructions based on complexity
-func (g *ClickGenerator) generateInstructions(complexity int32) string {
+func (g *ClickGenerator) generateInstructions(rng *rand.Rand, complexity int32) string {
 	instructions := []string{
 		"Click on all the numbered areas in order",
 		"Click on the highlighted regions",
@@ -428,5 +425,5 @@ func (g *ClickGenerator) generateInstructions(complexity int32) string {
 		"Click on the marked spots to complete the challenge",
 	}
 
-	return instructions[rand.Intn(len(instructions))]
+	return instructions[rng.Intn(len(instructions))]
 }
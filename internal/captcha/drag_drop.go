@@ -4,8 +4,6 @@ import (
 	"encoding/json"
 	"fmt"
 	"math/rand"
-	"os"
-	"time"
 )
 
 // DragDropCaptcha represents a drag and drop captcha
@@ -62,19 +60,19 @@ func NewDragDropGenerator(canvasWidth, canvasHeight, minObjects, maxObjects int)
 }
 
 // Generate creates a new drag and drop captcha
-func (g *DragDropGenerator) Generate(complexity int32) (*DragDropCaptcha, interface{}, error) {
+func (g *DragDropGenerator) Generate(rng *rand.Rand, complexity int32) (*DragDropCaptcha, interface{}, error) {
 	// Determine number of objects based on complexity
 	numObjects := g.calculateObjectCount(complexity)
 
 	// Generate objects and targets
-	objects, targets, correctSequence := g.generateObjectsAndTargets(numObjects)
+	objects, targets, correctSequence := g.generateObjectsAndTargets(rng, numObjects)
 
 	// Create captcha
 	captcha := &DragDropCaptcha{
-		ID:           fmt.Sprintf("dragdrop_%d", time.Now().UnixNano()),
+		ID:           fmt.Sprintf("dragdrop_%d", rng.Int63()),
 		Objects:      objects,
 		Targets:      targets,
-		Instructions: g.generateInstructions(complexity),
+		Instructions: g.generateInstructions(rng, complexity),
 		CanvasWidth:  g.canvasWidth,
 		CanvasHeight: g.canvasHeight,
 	}
@@ -477,18 +475,7 @@ func (g *DragDropGenerator) calculateObjectCount(complexity int32) int {
 }
 
 // generateObjectsAndTargets generates objects and targets for the captcha
-func (g *DragDropGenerator) generateObjectsAndTargets(numObjects int) ([]DragObject, []DropTarget, map[string]string) {
-	// Ultra-random seed for infinite variations
-	// Ultra-enhanced seed for infinite variations
-	// Generate entropy for randomness (not used with deprecated rand.Seed)
-	_ = time.Now().UnixNano() + 
-		int64(rand.Intn(1000000)) + 
-		int64(numObjects*9973) + 
-		int64(g.canvasWidth*g.canvasHeight) + 
-		int64(time.Now().Nanosecond()) + 
-		int64(os.Getpid()*13)
-	// rand.Seed is deprecated in Go 1.20+, using default random source
-
+func (g *DragDropGenerator) generateObjectsAndTargets(rng *rand.Rand, numObjects int) ([]DragObject, []DropTarget, map[string]string) {
 	objects := make([]DragObject, numObjects)
 	targets := make([]DropTarget, numObjects)
 	correctSequence := make(map[string]string)
@@ -516,11 +503,11 @@ func (g *DragDropGenerator) generateObjectsAndTargets(numObjects int) ([]DragObj
 	// Advanced shuffling with multiple passes for maximum randomness
 	for pass := 0; pass < 5; pass++ {
 		for i := range shapes {
-			j := rand.Intn(len(shapes))
+			j := rng.Intn(len(shapes))
 			shapes[i], shapes[j] = shapes[j], shapes[i]
 		}
 		for i := range colors {
-			j := rand.Intn(len(colors))
+			j := rng.Intn(len(colors))
 			colors[i], colors[j] = colors[j], colors[i]
 		}
 	}
@@ -529,8 +516,8 @@ func (g *DragDropGenerator) generateObjectsAndTargets(numObjects int) ([]DragObj
 		// Generate target first
 		target := DropTarget{
 			ID:     fmt.Sprintf("target_%d", i),
-			X:      rand.Intn(g.canvasWidth - 60),
-			Y:      rand.Intn(g.canvasHeight - 60),
+			X:      rng.Intn(g.canvasWidth - 60),
+			Y:      rng.Intn(g.canvasHeight - 60),
 			Width:  60,
 			Height: 60,
 			Color:  "#e9ecef",
@@ -541,18 +528,18 @@ func (g *DragDropGenerator) generateObjectsAndTargets(numObjects int) ([]DragObj
 		// Generate object with correct target
 		obj := DragObject{
 			ID:            fmt.Sprintf("obj_%d", i),
-			X:             rand.Intn(g.canvasWidth - 60),
-			Y:             rand.Intn(g.canvasHeight - 60),
+			X:             rng.Intn(g.canvasWidth - 60),
+			Y:             rng.Intn(g.canvasHeight - 60),
 			Width:         50,
 			Height:        50,
-			Color:         colors[rand.Intn(len(colors))],
-			Shape:         shapes[rand.Intn(len(shapes))],
+			Color:         colors[rng.Intn(len(colors))],
+			Shape:         shapes[rng.Intn(len(shapes))],
 			Text:          fmt.Sprintf("%d", i+1),
 			CorrectTarget: target.ID,
 		}
 
 		// Ensure objects and targets don't overlap
-		g.avoidOverlap(&obj, &target, objects, targets)
+		g.avoidOverlap(rng, &obj, &target, objects, targets)
 
 		objects[i] = obj
 		targets[i] = target
@@ -563,7 +550,7 @@ func (g *DragDropGenerator) generateObjectsAndTargets(numObjects int) ([]DragObj
 }
 
 // avoidOverlap ensures objects and targets don't overlap
-func (g *DragDropGenerator) avoidOverlap(obj *DragObject, target *DropTarget, existingObjects []DragObject, existingTargets []DropTarget) {
+func (g *DragDropGenerator) avoidOverlap(rng *rand.Rand, obj *DragObject, target *DropTarget, existingObjects []DragObject, existingTargets []DropTarget) {
 	maxAttempts := 50
 	attempts := 0
 
@@ -593,10 +580,10 @@ func (g *DragDropGenerator) avoidOverlap(obj *DragObject, target *DropTarget, ex
 		}
 
 		// Reposition
-		obj.X = rand.Intn(g.canvasWidth - obj.Width)
-		obj.Y = rand.Intn(g.canvasHeight - obj.Height)
-		target.X = rand.Intn(g.canvasWidth - target.Width)
-		target.Y = rand.Intn(g.canvasHeight - target.Height)
+		obj.X = rng.Intn(g.canvasWidth - obj.Width)
+		obj.Y = rng.Intn(g.canvasHeight - obj.Height)
+		target.X = rng.Intn(g.canvasWidth - target.Width)
+		target.Y = rng.Intn(g.canvasHeight - target.Height)
 
 		attempts++
 	}
@@ -608,7 +595,7 @@ func (g *DragDropGenerator) checkOverlap(x1, y1, w1, h1, x2, y2, w2, h2 int) boo
 }
 
 // generateInstructions generates instructions based on complexity
-func (g *DragDropGenerator) generateInstructions(complexity int32) string {
+func (g *DragDropGenerator) generateInstructions(rng *rand.Rand, complexity int32) string {
 	instructions := []string{
 		"Drag the numbered objects to their correct positions",
 		"Match each colored object with its corresponding target",
@@ -616,5 +603,5 @@ func (g *DragDropGenerator) generateInstructions(complexity int32) string {
 		"Place each numbered item in its designated drop zone",
 	}
 
-	return instructions[rand.Intn(len(instructions))]
+	return instructions[rng.Intn(len(instructions))]
 }
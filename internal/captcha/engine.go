@@ -1,9 +1,15 @@
 package captcha
 
 import (
+	"context"
 	"fmt"
+	"math/rand"
 	"sync"
 	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+
+	"github.com/FlooooowY/SteelMount-Captcha-Service/internal/tracing"
 )
 
 // Engine manages all captcha types and generation
@@ -12,6 +18,13 @@ type Engine struct {
 	clickGenerator    *ClickGenerator
 	swipeGenerator    *SwipeGenerator
 	gameGenerator     *GameGenerator
+	gridGenerator     *GridGenerator
+	sliderGenerator   *SliderGenerator
+	rotateGenerator   *RotateGenerator
+	audioGenerator    *AudioGenerator
+	textGenerator     *TextGenerator
+	probeGenerator    *ProbeGenerator
+	seeds             SeedSource
 
 	// Performance tracking
 	generationCount int64
@@ -19,18 +32,55 @@ type Engine struct {
 	mu              sync.RWMutex
 }
 
-// NewEngine creates a new captcha engine
+// NewEngine creates a new captcha engine seeded from crypto/rand
 func NewEngine(canvasWidth, canvasHeight int) *Engine {
+	return NewEngineWithSeedSource(canvasWidth, canvasHeight, CryptoSeed)
+}
+
+// NewEngineWithSeedSource creates a new captcha engine drawing challenge seeds from seeds
+func NewEngineWithSeedSource(canvasWidth, canvasHeight int, seeds SeedSource) *Engine {
 	return &Engine{
 		dragDropGenerator: NewDragDropGenerator(canvasWidth, canvasHeight, 3, 8),
 		clickGenerator:    NewClickGenerator(canvasWidth, canvasHeight, 2, 5, 20),
 		swipeGenerator:    NewSwipeGenerator(canvasWidth, canvasHeight, 1, 3, 50),
 		gameGenerator:     NewGameGenerator(canvasWidth, canvasHeight),
+		gridGenerator:     NewGridGenerator(canvasWidth, canvasHeight, 3, 4),
+		sliderGenerator:   NewSliderGenerator(canvasWidth, canvasHeight, 44),
+		rotateGenerator:   NewRotateGenerator(canvasWidth, canvasHeight),
+		audioGenerator:    NewAudioGenerator(4, 6),
+		textGenerator:     NewTextGenerator(canvasWidth, canvasHeight, 4, 7),
+		probeGenerator:    NewProbeGenerator(),
+		seeds:             seeds,
 	}
 }
 
-// GenerateChallenge generates a captcha challenge based on type and complexity
+// NextSeed draws the seed for a new challenge
+func (e *Engine) NextSeed() int64 {
+	return e.seeds()
+}
+
+// GenerateChallengeContext generates a captcha challenge inside a span of the caller's trace
+func (e *Engine) GenerateChallengeContext(ctx context.Context, challengeType string, complexity int32, seed int64) (string, interface{}, error) {
+	_, span := tracing.StartSpan(ctx, "captcha.Engine.GenerateChallenge",
+		attribute.String("captcha.type", challengeType),
+		attribute.Int("captcha.complexity", int(complexity)),
+	)
+
+	html, answer, err := e.GenerateChallengeWithSeed(challengeType, complexity, seed)
+	span.SetAttributes(attribute.Int("captcha.html_bytes", len(html)))
+	tracing.EndSpan(span, err)
+
+	return html, answer, err
+}
+
+// GenerateChallenge generates a captcha challenge based on type and complexity from a fresh seed
 func (e *Engine) GenerateChallenge(challengeType string, complexity int32) (string, interface{}, error) {
+	return e.GenerateChallengeWithSeed(challengeType, complexity, e.NextSeed())
+}
+
+// GenerateChallengeWithSeed generates a captcha challenge from the given seed,
+// the same arguments always produce the same HTML and answer
+func (e *Engine) GenerateChallengeWithSeed(challengeType string, complexity int32, seed int64) (string, interface{}, error) {
 	start := time.Now()
 	defer func() {
 		e.mu.Lock()
@@ -41,21 +91,33 @@ func (e *Engine) GenerateChallenge(challengeType string, complexity int32) (stri
 
 	switch challengeType {
 	case "drag_drop":
-		return e.generateDragDrop(complexity)
+		return e.generateDragDrop(newRand(seed), complexity)
 	case "click":
-		return e.generateClick(complexity)
+		return e.generateClick(newRand(seed), complexity)
 	case "swipe":
-		return e.generateSwipe(complexity)
+		return e.generateSwipe(newRand(seed), complexity)
 	case "game":
-		return e.generateGame(complexity)
+		return e.generateGame(newRand(seed), complexity)
+	case "grid":
+		return e.generateGrid(newRand(seed), complexity)
+	case "slider":
+		return e.generateSlider(newRand(seed), complexity)
+	case "rotate":
+		return e.generateRotate(newRand(seed), complexity)
+	case "audio":
+		return e.generateAudio(newRand(seed), complexity)
+	case "text":
+		return e.generateText(newRand(seed), complexity)
+	case "passive":
+		return e.generateProbe(newRand(seed), complexity)
 	default:
 		return "", nil, fmt.Errorf("unknown challenge type: %s", challengeType)
 	}
 }
 
 // generateDragDrop generates a drag and drop captcha
-func (e *Engine) generateDragDrop(complexity int32) (string, interface{}, error) {
-	captcha, answer, err := e.dragDropGenerator.Generate(complexity)
+func (e *Engine) generateDragDrop(rng *rand.Rand, complexity int32) (string, interface{}, error) {
+	captcha, answer, err := e.dragDropGenerator.Generate(rng, complexity)
 	if err != nil {
 		return "", nil, fmt.Errorf("failed to generate drag-drop captcha: %w", err)
 	}
@@ -69,8 +131,8 @@ func (e *Engine) generateDragDrop(complexity int32) (string, interface{}, error)
 }
 
 // generateClick generates a click captcha
-func (e *Engine) generateClick(complexity int32) (string, interface{}, error) {
-	captcha, answer, err := e.clickGenerator.Generate(complexity)
+func (e *Engine) generateClick(rng *rand.Rand, complexity int32) (string, interface{}, error) {
+	captcha, answer, err := e.clickGenerator.Generate(rng, complexity)
 	if err != nil {
 		return "", nil, fmt.Errorf("failed to generate click captcha: %w", err)
 	}
@@ -84,8 +146,8 @@ func (e *Engine) generateClick(complexity int32) (string, interface{}, error) {
 }
 
 // generateSwipe generates a swipe captcha
-func (e *Engine) generateSwipe(complexity int32) (string, interface{}, error) {
-	captcha, answer, err := e.swipeGenerator.Generate(complexity)
+func (e *Engine) generateSwipe(rng *rand.Rand, complexity int32) (string, interface{}, error) {
+	captcha, answer, err := e.swipeGenerator.Generate(rng, complexity)
 	if err != nil {
 		return "", nil, fmt.Errorf("failed to generate swipe captcha: %w", err)
 	}
@@ -99,8 +161,8 @@ func (e *Engine) generateSwipe(complexity int32) (string, interface{}, error) {
 }
 
 // generateGame generates a game captcha
-func (e *Engine) generateGame(complexity int32) (string, interface{}, error) {
-	captcha, answer, err := e.gameGenerator.Generate(complexity)
+func (e *Engine) generateGame(rng *rand.Rand, complexity int32) (string, interface{}, error) {
+	captcha, answer, err := e.gameGenerator.Generate(rng, complexity)
 	if err != nil {
 		return "", nil, fmt.Errorf("failed to generate game captcha: %w", err)
 	}
@@ -113,6 +175,96 @@ func (e *Engine) generateGame(complexity int32) (string, interface{}, error) {
 	return html, answer, nil
 }
 
+// generateGrid generates an image-grid selection captcha
+func (e *Engine) generateGrid(rng *rand.Rand, complexity int32) (string, interface{}, error) {
+	captcha, answer, err := e.gridGenerator.Generate(rng, complexity)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to generate grid captcha: %w", err)
+	}
+
+	html, err := e.gridGenerator.GenerateHTML(captcha)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to generate grid HTML: %w", err)
+	}
+
+	return html, answer, nil
+}
+
+// generateSlider generates a slider puzzle captcha
+func (e *Engine) generateSlider(rng *rand.Rand, complexity int32) (string, interface{}, error) {
+	captcha, answer, err := e.sliderGenerator.Generate(rng, complexity)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to generate slider captcha: %w", err)
+	}
+
+	html, err := e.sliderGenerator.GenerateHTML(captcha)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to generate slider HTML: %w", err)
+	}
+
+	return html, answer, nil
+}
+
+// generateRotate generates a rotate-to-upright captcha
+func (e *Engine) generateRotate(rng *rand.Rand, complexity int32) (string, interface{}, error) {
+	captcha, answer, err := e.rotateGenerator.Generate(rng, complexity)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to generate rotate captcha: %w", err)
+	}
+
+	html, err := e.rotateGenerator.GenerateHTML(captcha)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to generate rotate HTML: %w", err)
+	}
+
+	return html, answer, nil
+}
+
+// generateAudio generates an accessible audio captcha
+func (e *Engine) generateAudio(rng *rand.Rand, complexity int32) (string, interface{}, error) {
+	captcha, answer, err := e.audioGenerator.Generate(rng, complexity)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to generate audio captcha: %w", err)
+	}
+
+	html, err := e.audioGenerator.GenerateHTML(captcha)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to generate audio HTML: %w", err)
+	}
+
+	return html, answer, nil
+}
+
+// generateText generates a distorted-text captcha
+func (e *Engine) generateText(rng *rand.Rand, complexity int32) (string, interface{}, error) {
+	captcha, answer, err := e.textGenerator.Generate(rng, complexity)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to generate text captcha: %w", err)
+	}
+
+	html, err := e.textGenerator.GenerateHTML(captcha)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to generate text HTML: %w", err)
+	}
+
+	return html, answer, nil
+}
+
+// generateProbe generates the invisible probe of a passive challenge
+func (e *Engine) generateProbe(rng *rand.Rand, complexity int32) (string, interface{}, error) {
+	captcha, answer, err := e.probeGenerator.Generate(rng, complexity)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to generate probe: %w", err)
+	}
+
+	html, err := e.probeGenerator.GenerateHTML(captcha)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to generate probe HTML: %w", err)
+	}
+
+	return html, answer, nil
+}
+
 // GetStats returns engine performance statistics
 func (e *Engine) GetStats() map[string]interface{} {
 	e.mu.RLock()
@@ -4,7 +4,6 @@ import (
 	"encoding/json"
 	"fmt"
 	"math/rand"
-	"time"
 )
 
 // GameGenerator generates simple game-based captchas
@@ -26,29 +25,34 @@ type GameCaptcha struct {
 
 // SnakeGameData represents snake game data
 type SnakeGameData struct {
-	FoodX      int     `json:"food_x"`
-	FoodY      int     `json:"food_y"`
-	GridSize   int     `json:"grid_size"`
-	TargetFood int     `json:"target_food"`
-	Speed      int     `json:"speed"`
+	Seed       uint32   `json:"seed"` // Drives food placement, the server replays it
+	GridSize   int      `json:"grid_size"`
+	TargetFood int      `json:"target_food"`
+	Speed      int      `json:"speed"`
 	Colors     []string `json:"colors"`
 }
 
 // MemoryGameData represents memory game data
 type MemoryGameData struct {
-	Sequence    []int   `json:"sequence"`
-	GridSize    int     `json:"grid_size"`
-	Cells       int     `json:"cells"`
-	ShowTime    int     `json:"show_time"`
-	Colors      []string `json:"colors"`
+	Sequence []int    `json:"sequence"`
+	GridSize int      `json:"grid_size"`
+	Cells    int      `json:"cells"`
+	ShowTime int      `json:"show_time"`
+	LeadIn   int      `json:"lead_in"`
+	Pause    int      `json:"pause"`
+	Colors   []string `json:"colors"`
 }
 
 // ReactionGameData represents reaction time game data
 type ReactionGameData struct {
-	TargetTime  int     `json:"target_time"`
-	Tolerance   int     `json:"tolerance"`
-	Colors      []string `json:"colors"`
-	Instructions string `json:"instructions"`
+	Seed         uint32   `json:"seed"` // Drives the signal delays, the server replays it
+	Rounds       int      `json:"rounds"`
+	MinDelay     int      `json:"min_delay"`
+	DelayRange   int      `json:"delay_range"`
+	MinReaction  int      `json:"min_reaction"`
+	MaxReaction  int      `json:"max_reaction"`
+	Colors       []string `json:"colors"`
+	Instructions string   `json:"instructions"`
 }
 
 // NewGameGenerator creates a new game generator
@@ -59,40 +63,59 @@ func NewGameGenerator(canvasWidth, canvasHeight int) *GameGenerator {
 	}
 }
 
-// Generate generates a game captcha based on complexity
-func (g *GameGenerator) Generate(complexity int32) (*GameCaptcha, interface{}, error) {
+// Generate generates a game captcha based on complexity. The answer is a
+// GameAnswer that replays the input log the page submits
+func (g *GameGenerator) Generate(rng *rand.Rand, complexity int32) (*GameCaptcha, interface{}, error) {
 	gameTypes := []string{"snake", "memory", "reaction"}
-	gameType := gameTypes[rand.Intn(len(gameTypes))]
-	
+	gameType := gameTypes[rng.Intn(len(gameTypes))]
+
+	// Game speed and timing limits are only sane within this range
+	if complexity < 0 {
+		complexity = 0
+	}
+	if complexity > 100 {
+		complexity = 100
+	}
+
 	// Adjust game difficulty based on complexity
 	switch gameType {
 	case "snake":
-		return g.generateSnakeGame(complexity)
+		return g.generateSnakeGame(rng, complexity)
 	case "memory":
-		return g.generateMemoryGame(complexity)
+		return g.generateMemoryGame(rng, complexity)
 	case "reaction":
-		return g.generateReactionGame(complexity)
+		return g.generateReactionGame(rng, complexity)
 	default:
-		return g.generateSnakeGame(complexity)
+		return g.generateSnakeGame(rng, complexity)
 	}
 }
 
 // generateSnakeGame generates a simple snake-like game
-func (g *GameGenerator) generateSnakeGame(complexity int32) (*GameCaptcha, interface{}, error) {
+func (g *GameGenerator) generateSnakeGame(rng *rand.Rand, complexity int32) (*GameCaptcha, interface{}, error) {
 	gridSize := 20
 	targetFood := 3 + int(complexity/25) // 3-7 food items based on complexity
-	
+
+	answer := &SnakeGameAnswer{
+		Seed:       rng.Uint32(),
+		Cols:       g.canvasWidth / gridSize,
+		Rows:       g.canvasHeight / gridSize,
+		TargetFood: targetFood,
+		Speed:      200 - int(complexity), // Faster with higher complexity
+	}
+	if answer.Cols < 3 || answer.Rows < 3 {
+		return nil, nil, fmt.Errorf("canvas too small for a snake game")
+	}
+
 	gameData := &SnakeGameData{
-		FoodX:      rand.Intn(g.canvasWidth/gridSize) * gridSize,
-		FoodY:      rand.Intn(g.canvasHeight/gridSize) * gridSize,
+		Seed:       answer.Seed,
 		GridSize:   gridSize,
 		TargetFood: targetFood,
-		Speed:      200 - int(complexity*2), // Faster with higher complexity
+		Speed:      answer.Speed,
 		Colors:     []string{"#ff6b6b", "#4ecdc4", "#45b7d1", "#f9ca24", "#6c5ce7"},
 	}
-	
+
 	captcha := &GameCaptcha{
-		ID:           fmt.Sprintf("game-%d", time.Now().UnixNano()),
+		ID:           fmt.Sprintf("game-%d", rng.Int63()),
 		Type:         "game",
 		GameType:     "snake",
 		Instructions: fmt.Sprintf("Use arrow keys to collect %d food items. Don't hit the walls!", targetFood),
@@ -100,85 +123,74 @@ func (g *GameGenerator) generateSnakeGame(complexity int32) (*GameCaptcha, inter
 		CanvasWidth:  g.canvasWidth,
 		CanvasHeight: g.canvasHeight,
 	}
-	
-	// Expected answer: number of food items collected
-	expectedAnswer := map[string]interface{}{
-		"type":        "snake_completion",
-		"target_food": targetFood,
-		"min_score":   targetFood,
-	}
-	
-	return captcha, expectedAnswer, nil
+
+	return captcha, answer, nil
 }
 
 // generateMemoryGame generates a memory sequence game
-func (g *GameGenerator) generateMemoryGame(complexity int32) (*GameCaptcha, interface{}, error) {
-	sequenceLength := 3 + int(complexity/20) // 3-8 sequence length
+func (g *GameGenerator) generateMemoryGame(rng *rand.Rand, complexity int32) (*GameCaptcha, interface{}, error) {
 	gridSize := 4 // 4x4 grid
-	
-	// Generate random sequence
-	sequence := make([]int, sequenceLength)
-	for i := range sequence {
-		sequence[i] = rand.Intn(gridSize * gridSize)
+
+	answer := &MemoryGameAnswer{
+		Seed:     rng.Uint32(),
+		Cells:    gridSize * gridSize,
+		Length:   3 + int(complexity/20),  // 3-8 sequence length
+		ShowTime: 700 - int(complexity*4), // Shorter show time with higher complexity
 	}
-	
+
 	gameData := &MemoryGameData{
-		Sequence:  sequence,
-		GridSize:  gridSize,
-		Cells:     gridSize * gridSize,
-		ShowTime:  2000 - int(complexity*10), // Shorter show time with higher complexity
-		Colors:    []string{"#3498db", "#e74c3c", "#2ecc71", "#f39c12", "#9b59b6"},
+		Sequence: answer.Sequence(),
+		GridSize: gridSize,
+		Cells:    answer.Cells,
+		ShowTime: answer.ShowTime,
+		LeadIn:   memoryLeadIn,
+		Pause:    memoryPause,
+		Colors:   []string{"#3498db", "#e74c3c", "#2ecc71", "#f39c12", "#9b59b6"},
 	}
-	
+
 	captcha := &GameCaptcha{
-		ID:           fmt.Sprintf("game-%d", time.Now().UnixNano()),
+		ID:           fmt.Sprintf("game-%d", rng.Int63()),
 		Type:         "game",
 		GameType:     "memory",
-		Instructions: fmt.Sprintf("Remember and repeat the sequence of %d highlighted cells", sequenceLength),
+		Instructions: fmt.Sprintf("Remember and repeat the sequence of %d highlighted cells", answer.Length),
 		GameData:     gameData,
 		CanvasWidth:  g.canvasWidth,
 		CanvasHeight: g.canvasHeight,
 	}
-	
-	// Expected answer: the correct sequence
-	expectedAnswer := map[string]interface{}{
-		"type":     "memory_sequence",
-		"sequence": sequence,
-	}
-	
-	return captcha, expectedAnswer, nil
+
+	return captcha, answer, nil
 }
 
 // generateReactionGame generates a reaction time game
-func (g *GameGenerator) generateReactionGame(complexity int32) (*GameCaptcha, interface{}, error) {
-	targetTime := 1000 + rand.Intn(2000) // 1-3 seconds
-	tolerance := 300 - int(complexity*2)  // Stricter tolerance with higher complexity
-	
+func (g *GameGenerator) generateReactionGame(rng *rand.Rand, complexity int32) (*GameCaptcha, interface{}, error) {
+	answer := &ReactionGameAnswer{
+		Seed:        rng.Uint32(),
+		Rounds:      3 + int(complexity/50),  // 3-5 rounds
+		MaxReaction: 900 - int(complexity*4), // Stricter limit with higher complexity
+	}
+
 	gameData := &ReactionGameData{
-		TargetTime:   targetTime,
-		Tolerance:    tolerance,
+		Seed:         answer.Seed,
+		Rounds:       answer.Rounds,
+		MinDelay:     reactionMinDelay,
+		DelayRange:   reactionDelayRange,
+		MinReaction:  reactionMinTime,
+		MaxReaction:  answer.MaxReaction,
 		Colors:       []string{"#e74c3c", "#2ecc71", "#f39c12", "#3498db"},
 		Instructions: "Click when the circle turns green!",
 	}
-	
+
 	captcha := &GameCaptcha{
-		ID:           fmt.Sprintf("game-%d", time.Now().UnixNano()),
+		ID:           fmt.Sprintf("game-%d", rng.Int63()),
 		Type:         "game",
 		GameType:     "reaction",
-		Instructions: fmt.Sprintf("Wait for the green signal, then click as fast as possible! Target: %dms", targetTime),
+		Instructions: fmt.Sprintf("Wait for the green signal, then click as fast as possible! %d rounds, under %dms each", answer.Rounds, answer.MaxReaction),
 		GameData:     gameData,
 		CanvasWidth:  g.canvasWidth,
 		CanvasHeight: g.canvasHeight,
 	}
-	
-	// Expected answer: reaction time within tolerance
-	expectedAnswer := map[string]interface{}{
-		"type":        "reaction_time",
-		"target_time": targetTime,
-		"tolerance":   tolerance,
-	}
-	
-	return captcha, expectedAnswer, nil
+
+	return captcha, answer, nil
 }
 
 // GenerateHTML generates HTML for the game captcha
@@ -189,18 +201,19 @@ func (g *GameGenerator) GenerateHTML(captcha *GameCaptcha) (string, error) {
 		return "", fmt.Errorf("failed to marshal captcha: %w", err)
 	}
 
+	var gameSpecificCSS string
 	var gameSpecificHTML string
 	var gameSpecificJS string
 
 	switch captcha.GameType {
 	case "snake":
-		gameSpecificHTML, gameSpecificJS = g.generateSnakeHTML()
+		gameSpecificCSS, gameSpecificHTML, gameSpecificJS = g.generateSnakeHTML()
 	case "memory":
-		gameSpecificHTML, gameSpecificJS = g.generateMemoryHTML()
+		gameSpecificCSS, gameSpecificHTML, gameSpecificJS = g.generateMemoryHTML()
 	case "reaction":
-		gameSpecificHTML, gameSpecificJS = g.generateReactionHTML()
+		gameSpecificCSS, gameSpecificHTML, gameSpecificJS = g.generateReactionHTML()
 	default:
-		gameSpecificHTML, gameSpecificJS = g.generateSnakeHTML()
+		gameSpecificCSS, gameSpecificHTML, gameSpecificJS = g.generateSnakeHTML()
 	}
 
 	html := fmt.Sprintf(`
@@ -301,13 +314,26 @@ func (g *GameGenerator) GenerateHTML(captcha *GameCaptcha) (string, error) {
             completed: false,
             score: 0,
             result: null,
-            startTime: Date.now()
+            duration: 0,
+            log: [],
+            startTime: 0
         };
-        
+        %s
         // Game-specific variables and functions
         %s
         
         // Common game functions
+        function gameClock() {
+            return performance.now() - gameState.startTime;
+        }
+        
+        // Every input goes into the log, the server replays it to get the result
+        function logInput(input) {
+            input.t = gameClock();
+            gameState.log.push(input);
+            return input.t;
+        }
+        
         function submitSolution() {
             if (!gameState.completed) return;
             
@@ -317,10 +343,13 @@ func (g *GameGenerator) GenerateHTML(captcha *GameCaptcha) (string, error) {
                 data: JSON.stringify({
                     type: 'game_solution',
                     game_type: captchaData.game_type,
-                    result: gameState.result,
-                    score: gameState.score,
-                    captchaId: captchaData.id,
-                    completion_time: Date.now() - gameState.startTime
+                    solution: {
+                        success: gameState.result,
+                        score: gameState.score,
+                        duration: gameState.duration,
+                        log: gameState.log
+                    },
+                    captchaId: captchaData.id
                 })
             }, '*');
         }
@@ -328,6 +357,7 @@ func (g *GameGenerator) GenerateHTML(captcha *GameCaptcha) (string, error) {
         function completeGame(success, message) {
             gameState.completed = true;
             gameState.result = success;
+            gameState.duration = gameClock();
             
             const gameOver = document.getElementById('gameOver');
             const gameResult = document.getElementById('gameResult');
@@ -378,6 +408,7 @@ func (g *GameGenerator) GenerateHTML(captcha *GameCaptcha) (string, error) {
         
         // Initialize game when page loads
         document.addEventListener('DOMContentLoaded', function() {
+            gameState.startTime = performance.now();
             initGame();
             
             // Send game start event
@@ -390,19 +421,21 @@ func (g *GameGenerator) GenerateHTML(captcha *GameCaptcha) (string, error) {
 </body>
 </html>`,
 		g.canvasWidth, g.canvasWidth, g.canvasHeight, // CSS dimensions
-		gameSpecificHTML, // Additional CSS
+		gameSpecificCSS, // Additional CSS
 		captcha.Instructions,
 		g.canvasWidth, g.canvasHeight, // Canvas dimensions
 		gameSpecificHTML, // Additional HTML elements
 		string(captchaJSON),
+		gameRandJS,     // Seeded generator shared with the server replay
 		gameSpecificJS, // Game-specific JavaScript
 	)
 
 	return html, nil
 }
 
-// generateSnakeHTML generates HTML and JS for snake game
-func (g *GameGenerator) generateSnakeHTML() (string, string) {
+// generateSnakeHTML generates CSS, HTML and JS for snake game. Positions are
+// in grid cells and every arrow key is logged with the tick it was pressed in
+func (g *GameGenerator) generateSnakeHTML() (string, string, string) {
 	css := `
         .snake-controls {
             text-align: center;
@@ -411,92 +444,113 @@ func (g *GameGenerator) generateSnakeHTML() (string, string) {
             color: #666;
         }
     `
-	
+
 	html := `<div class="snake-controls">Use arrow keys to move</div>`
-	
+
 	js := `
-        let snake = [{x: 200, y: 200}];
+        let snake = [];
         let direction = {x: 0, y: 0};
         let food = {x: 0, y: 0};
         let foodCollected = 0;
+        let ticks = 0;
+        let cols = 0;
+        let rows = 0;
+        let random = null;
         let gameRunning = false;
         
         function initGame() {
             const gameData = captchaData.game_data;
-            food.x = gameData.food_x;
-            food.y = gameData.food_y;
+            cols = Math.floor(canvas.width / gameData.grid_size);
+            rows = Math.floor(canvas.height / gameData.grid_size);
+            random = gameRandom(gameData.seed);
+            snake = [{x: Math.floor(cols / 2), y: Math.floor(rows / 2)}];
+            placeFood();
             gameRunning = true;
             
             updateGameInfo('Use arrow keys to collect ' + gameData.target_food + ' food items');
+            draw();
             
             // Game loop
-            gameLoop();
+            setTimeout(gameLoop, gameData.speed);
             
             // Keyboard controls
             document.addEventListener('keydown', function(e) {
                 if (!gameRunning) return;
                 
-                sendGameEvent('keypress', {key: e.code});
-                
                 switch(e.code) {
                     case 'ArrowUp':
-                        if (direction.y === 0) direction = {x: 0, y: -gameData.grid_size};
+                        if (direction.y === 0) direction = {x: 0, y: -1};
                         break;
                     case 'ArrowDown':
-                        if (direction.y === 0) direction = {x: 0, y: gameData.grid_size};
+                        if (direction.y === 0) direction = {x: 0, y: 1};
                         break;
                     case 'ArrowLeft':
-                        if (direction.x === 0) direction = {x: -gameData.grid_size, y: 0};
+                        if (direction.x === 0) direction = {x: -1, y: 0};
                         break;
                     case 'ArrowRight':
-                        if (direction.x === 0) direction = {x: gameData.grid_size, y: 0};
+                        if (direction.x === 0) direction = {x: 1, y: 0};
                         break;
+                    default:
+                        return;
                 }
+                logInput({key: e.code, tick: ticks});
+                sendGameEvent('keypress', {key: e.code});
                 e.preventDefault();
             });
         }
         
+        function occupied(cell) {
+            return snake.some(segment => segment.x === cell.x && segment.y === cell.y);
+        }
+        
+        // Food comes from the seeded generator, the server places it the same way
+        function placeFood() {
+            do {
+                food = {x: Math.floor(random() * cols), y: Math.floor(random() * rows)};
+            } while (occupied(food));
+        }
+        
         function gameLoop() {
             if (!gameRunning) return;
+            ticks++;
             
-            // Move snake
-            const head = {x: snake[0].x + direction.x, y: snake[0].y + direction.y};
-            
-            // Check wall collision
-            if (head.x < 0 || head.x >= canvas.width || head.y < 0 || head.y >= canvas.height) {
-                gameRunning = false;
-                completeGame(false, 'Game Over! Hit the wall.');
-                return;
-            }
-            
-            // Check self collision
-            if (snake.some(segment => segment.x === head.x && segment.y === head.y)) {
-                gameRunning = false;
-                completeGame(false, 'Game Over! Hit yourself.');
-                return;
-            }
-            
-            snake.unshift(head);
-            
-            // Check food collision
-            if (head.x === food.x && head.y === food.y) {
-                foodCollected++;
-                gameState.score = foodCollected;
+            // Move snake, it rests until the first key
+            if (direction.x !== 0 || direction.y !== 0) {
+                const head = {x: snake[0].x + direction.x, y: snake[0].y + direction.y};
                 
-                sendGameEvent('food_collected', {count: foodCollected});
+                // Check wall collision
+                if (head.x < 0 || head.x >= cols || head.y < 0 || head.y >= rows) {
+                    gameRunning = false;
+                    completeGame(false, 'Game Over! Hit the wall.');
+                    return;
+                }
                 
-                if (foodCollected >= captchaData.game_data.target_food) {
+                // Check self collision
+                if (occupied(head)) {
                     gameRunning = false;
-                    completeGame(true, 'Success! Collected all food items.');
+                    completeGame(false, 'Game Over! Hit yourself.');
                     return;
+                }
+                
+                snake.unshift(head);
+                
+                // Check food collision
+                if (head.x === food.x && head.y === food.y) {
+                    foodCollected++;
+                    gameState.score = foodCollected;
+                    
+                    sendGameEvent('food_collected', {count: foodCollected});
+                    
+                    if (foodCollected >= captchaData.game_data.target_food) {
+                        gameRunning = false;
+                        draw();
+                        completeGame(true, 'Success! Collected all food items.');
+                        return;
+                    }
+                    placeFood();
                 } else {
-                    // Generate new food
-                    const gameData = captchaData.game_data;
-                    food.x = Math.floor(Math.random() * (canvas.width / gameData.grid_size)) * gameData.grid_size;
-                    food.y = Math.floor(Math.random() * (canvas.height / gameData.grid_size)) * gameData.grid_size;
+                    snake.pop();
                 }
-            } else {
-                snake.pop();
             }
             
             // Draw game
@@ -508,6 +562,8 @@ func (g *GameGenerator) generateSnakeHTML() (string, string) {
         }
         
         function draw() {
+            const size = captchaData.game_data.grid_size;
+            
             // Clear canvas
             ctx.fillStyle = '#fafafa';
             ctx.fillRect(0, 0, canvas.width, canvas.height);
@@ -515,20 +571,21 @@ func (g *GameGenerator) generateSnakeHTML() (string, string) {
             // Draw snake
             ctx.fillStyle = '#4ecdc4';
             snake.forEach(segment => {
-                ctx.fillRect(segment.x, segment.y, captchaData.game_data.grid_size - 2, captchaData.game_data.grid_size - 2);
+                ctx.fillRect(segment.x * size, segment.y * size, size - 2, size - 2);
             });
             
             // Draw food
             ctx.fillStyle = '#ff6b6b';
-            ctx.fillRect(food.x, food.y, captchaData.game_data.grid_size - 2, captchaData.game_data.grid_size - 2);
+            ctx.fillRect(food.x * size, food.y * size, size - 2, size - 2);
         }
     `
-	
-	return css + html, js
+
+	return css, html, js
 }
 
-// generateMemoryHTML generates HTML and JS for memory game
-func (g *GameGenerator) generateMemoryHTML() (string, string) {
+// generateMemoryHTML generates CSS, HTML and JS for memory game, clicks are
+// only taken and logged once the whole sequence has been shown
+func (g *GameGenerator) generateMemoryHTML() (string, string, string) {
 	css := `
         .memory-grid {
             display: grid;
@@ -560,14 +617,14 @@ func (g *GameGenerator) generateMemoryHTML() (string, string) {
             border-color: #dc3545;
         }
     `
-	
+
 	html := `<div class="memory-grid" id="memoryGrid"></div>`
-	
+
 	js := `
         let sequence = [];
-        let userSequence = [];
         let currentStep = 0;
-        let showingSequence = false;
+        let showingSequence = true;
+        let finished = false;
         
         function initGame() {
             const gameData = captchaData.game_data;
@@ -576,7 +633,7 @@ func (g *GameGenerator) generateMemoryHTML() (string, string) {
             createGrid();
             setTimeout(() => {
                 showSequence();
-            }, 1000);
+            }, gameData.lead_in);
         }
         
         function createGrid() {
@@ -593,7 +650,7 @@ func (g *GameGenerator) generateMemoryHTML() (string, string) {
         }
         
         function showSequence() {
-            showingSequence = true;
+            const gameData = captchaData.game_data;
             updateGameInfo('Watch the sequence...');
             
             let index = 0;
@@ -606,8 +663,8 @@ func (g *GameGenerator) generateMemoryHTML() (string, string) {
                     setTimeout(() => {
                         cell.classList.remove('active');
                         index++;
-                        setTimeout(showNext, 200);
-                    }, 500);
+                        setTimeout(showNext, gameData.pause);
+                    }, gameData.show_time);
                 } else {
                     showingSequence = false;
                     updateGameInfo('Now repeat the sequence by clicking the cells');
@@ -618,27 +675,29 @@ func (g *GameGenerator) generateMemoryHTML() (string, string) {
         }
         
         function cellClicked(index) {
-            if (showingSequence || gameState.completed) return;
+            if (showingSequence || finished) return;
             
+            logInput({cell: index});
             sendGameEvent('cell_clicked', {index: index, step: currentStep});
             
             const cell = document.querySelector('[data-index="' + index + '"]');
-            userSequence.push(index);
             
             if (sequence[currentStep] === index) {
                 // Correct
                 cell.classList.add('correct');
                 currentStep++;
+                gameState.score = currentStep;
                 
                 if (currentStep >= sequence.length) {
                     // Sequence completed
-                    gameState.score = sequence.length;
+                    finished = true;
                     setTimeout(() => {
                         completeGame(true, 'Perfect! Sequence completed correctly.');
                     }, 500);
                 }
             } else {
                 // Incorrect
+                finished = true;
                 cell.classList.add('incorrect');
                 setTimeout(() => {
                     completeGame(false, 'Wrong sequence! Try again.');
@@ -646,12 +705,13 @@ func (g *GameGenerator) generateMemoryHTML() (string, string) {
             }
         }
     `
-	
-	return css + html, js
+
+	return css, html, js
 }
 
-// generateReactionHTML generates HTML and JS for reaction game
-func (g *GameGenerator) generateReactionHTML() (string, string) {
+// generateReactionHTML generates CSS, HTML and JS for reaction game. The first
+// click starts the game, each round waits a seeded delay from the previous click
+func (g *GameGenerator) generateReactionHTML() (string, string, string) {
 	css := `
         .reaction-circle {
             width: 200px;
@@ -668,84 +728,99 @@ func (g *GameGenerator) generateReactionHTML() (string, string) {
             color: white;
         }
     `
-	
+
 	html := `<div class="reaction-circle" id="reactionCircle">Wait...</div>`
-	
+
 	js := `
-        let reactionStartTime = 0;
-        let waitingForReaction = false;
+        let random = null;
+        let round = 0;
+        let passed = 0;
+        let roundStart = 0;
+        let delay = 0;
+        let signalTimer = null;
         let gameStarted = false;
+        let signalShown = false;
+        let finished = false;
         
         function initGame() {
             const circle = document.getElementById('reactionCircle');
-            const gameData = captchaData.game_data;
+            random = gameRandom(captchaData.game_data.seed);
             
             circle.style.backgroundColor = '#dc3545';
             circle.textContent = 'Click to start';
-            
-            circle.onclick = function() {
-                if (!gameStarted) {
-                    startReactionTest();
-                } else if (waitingForReaction) {
-                    handleReaction();
-                }
-            };
+            circle.onclick = handleClick;
         }
         
-        function startReactionTest() {
-            gameStarted = true;
-            const circle = document.getElementById('reactionCircle');
+        // The signal is never shown before roundStart + delay, the server replays the same delay
+        function startRound(t) {
             const gameData = captchaData.game_data;
+            const circle = document.getElementById('reactionCircle');
+            
+            roundStart = t;
+            signalShown = false;
+            delay = gameData.min_delay + Math.floor(random() * gameData.delay_range);
             
             circle.style.backgroundColor = '#ffc107';
             circle.textContent = 'Wait for green...';
             
-            updateGameInfo('Wait for the circle to turn green, then click as fast as possible!');
-            
-            // Random delay before showing green
-            const delay = 2000 + Math.random() * 3000;
-            setTimeout(() => {
+            signalTimer = setTimeout(() => {
+                signalShown = true;
                 circle.style.backgroundColor = '#28a745';
                 circle.textContent = 'CLICK NOW!';
-                reactionStartTime = Date.now();
-                waitingForReaction = true;
                 
-                sendGameEvent('green_shown', {timestamp: reactionStartTime});
-            }, delay);
+                sendGameEvent('green_shown', {round: round});
+            }, Math.max(0, roundStart + delay - gameClock()));
         }
         
-        function handleReaction() {
-            if (!waitingForReaction) return;
+        function handleClick() {
+            if (finished) return;
             
-            const reactionTime = Date.now() - reactionStartTime;
             const gameData = captchaData.game_data;
             const circle = document.getElementById('reactionCircle');
+            const t = logInput({});
             
-            waitingForReaction = false;
-            gameState.score = reactionTime;
+            if (!gameStarted) {
+                gameStarted = true;
+                updateGameInfo('Round 1/' + gameData.rounds);
+                startRound(t);
+                return;
+            }
             
-            sendGameEvent('reaction_clicked', {reaction_time: reactionTime});
+            if (!signalShown) {
+                // Clicked before the signal
+                clearTimeout(signalTimer);
+                finished = true;
+                circle.style.backgroundColor = '#dc3545';
+                circle.textContent = 'Too early!';
+                completeGame(false, 'You clicked before the signal.');
+                return;
+            }
             
-            const isWithinTolerance = Math.abs(reactionTime - gameData.target_time) <= gameData.tolerance;
+            const reaction = t - roundStart - delay;
+            const reactionTime = Math.round(reaction);
+            sendGameEvent('reaction_clicked', {reaction_time: reactionTime, round: round});
             
-            if (reactionTime < 150) {
-                // Too fast, probably cheating
-                circle.style.backgroundColor = '#dc3545';
-                circle.textContent = 'Too fast!';
-                completeGame(false, 'Reaction too fast! You clicked before the signal.');
-            } else if (isWithinTolerance) {
-                // Good reaction time
-                circle.style.backgroundColor = '#28a745';
-                circle.textContent = reactionTime + 'ms';
-                completeGame(true, 'Great reaction time! (' + reactionTime + 'ms)');
-            } else {
-                // Too slow or too fast
-                circle.style.backgroundColor = '#ffc107';
+            if (reaction >= gameData.min_reaction && reaction <= gameData.max_reaction) {
+                passed++;
+                gameState.score = passed;
+            }
+            round++;
+            
+            if (round >= gameData.rounds) {
+                finished = true;
                 circle.textContent = reactionTime + 'ms';
-                completeGame(false, 'Reaction time: ' + reactionTime + 'ms (target: ~' + gameData.target_time + 'ms)');
+                if (passed === gameData.rounds) {
+                    completeGame(true, 'Great reactions! All ' + passed + ' rounds passed.');
+                } else {
+                    completeGame(false, passed + ' of ' + gameData.rounds + ' rounds under ' + gameData.max_reaction + 'ms');
+                }
+                return;
             }
+            
+            updateGameInfo('Last: ' + reactionTime + 'ms. Round ' + (round + 1) + '/' + gameData.rounds);
+            startRound(t);
         }
     `
-	
-	return css + html, js
+
+	return css, html, js
 }
@@ -0,0 +1,494 @@
+package captcha
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// GameAnswer is the secret state of a game captcha. The outcome is never taken
+// from the client: the server replays the submitted input log from the seed.
+// Elapsed is the time since the game was issued by the server clock, a log
+// cannot claim a longer game than that
+type GameAnswer interface {
+	Verify(submission *GameSubmission, elapsed time.Duration) *GameVerdict
+}
+
+// SnakeGameAnswer holds what the server needs to replay a snake game
+type SnakeGameAnswer struct {
+	Seed       uint32 `json:"seed"`
+	Cols       int    `json:"cols"`
+	Rows       int    `json:"rows"`
+	TargetFood int    `json:"target_food"`
+	Speed      int    `json:"speed"` // Milliseconds per game loop tick
+}
+
+// MemoryGameAnswer holds what the server needs to replay a memory game
+type MemoryGameAnswer struct {
+	Seed     uint32 `json:"seed"`
+	Cells    int    `json:"cells"`
+	Length   int    `json:"length"`
+	ShowTime int    `json:"show_time"` // Milliseconds each cell of the sequence is highlighted
+}
+
+// ReactionGameAnswer holds what the server needs to replay a reaction game
+type ReactionGameAnswer struct {
+	Seed        uint32 `json:"seed"`
+	Rounds      int    `json:"rounds"`
+	MaxReaction int    `json:"max_reaction"` // Slowest reaction in milliseconds that passes a round
+}
+
+// GameInput is one logged input, T in milliseconds since the game started
+type GameInput struct {
+	T    float64 `json:"t"`
+	Key  string  `json:"key,omitempty"`  // Snake: arrow key code
+	Tick int     `json:"tick,omitempty"` // Snake: game loop ticks completed when the key was pressed
+	Cell int     `json:"cell,omitempty"` // Memory: clicked cell index
+}
+
+// GameSubmission is the client's answer: the outcome it saw and the inputs that led to it
+type GameSubmission struct {
+	Success  bool        `json:"success"`
+	Score    int         `json:"score"`
+	Duration float64     `json:"duration"` // Milliseconds from the start to the end of the game
+	Log      []GameInput `json:"log"`
+}
+
+// GameVerdict is the outcome of replaying a submission
+type GameVerdict struct {
+	Solved     bool
+	Confidence int32
+	Score      int      // Score of the server replay
+	Violations []string // Replay checks that failed, empty for a genuine game
+}
+
+// Replay checks that flag forged game logs
+const (
+	GameViolationEmptyLog        = "empty_log"
+	GameViolationTimestamps      = "non_monotonic_time"
+	GameViolationClockMismatch   = "clock_mismatch"
+	GameViolationInvalidInput    = "invalid_input"
+	GameViolationEarlyInput      = "early_input"
+	GameViolationInputAfterEnd   = "input_after_end"
+	GameViolationTooFast         = "too_fast"
+	GameViolationConstantTiming  = "constant_timing"
+	GameViolationUnfinished      = "unfinished"
+	GameViolationOutcomeMismatch = "outcome_mismatch"
+)
+
+// Replay limits. Browser timers only ever fire late, so a log may lag behind
+// the game clock but never run ahead of it
+const (
+	gameMaxDuration      = 3 * time.Minute
+	gameClockSlack       = 0.95 // Fraction of the game clock a log must at least take
+	gameMinTimingCV      = 0.05 // Coefficient of variation of input intervals, near 0 for scripts
+	gameMinTimingSamples = 4    // Intervals needed before the cadence is judged
+	memoryLeadIn         = 1000 // Milliseconds before the sequence is shown
+	memoryPause          = 200  // Milliseconds between highlighted cells
+	memoryMinInterval    = 120  // Milliseconds between clicks a person needs to move to another cell
+	reactionMinDelay     = 1500 // Signal delay range in milliseconds
+	reactionDelayRange   = 2500
+	reactionMinTime      = 100 // Faster clicks anticipate the signal
+	reactionMinDeviation = 5.0 // Standard deviation of reaction times in milliseconds
+)
+
+// gameTransportLag is how much longer than the server saw a log may claim the
+// game took, the page clock starts after the challenge was issued but the
+// answer reaches the server after transport delay
+const gameTransportLag = 2 * time.Second
+
+// gameRand is the mulberry32 generator. Game pages run the same generator from
+// the seed, so food positions and signal delays match the server replay
+type gameRand struct {
+	state uint32
+}
+
+func newGameRand(seed uint32) *gameRand {
+	return &gameRand{state: seed}
+}
+
+func (r *gameRand) next() uint32 {
+	r.state += 0x6D2B79F5
+	t := r.state
+	t = (t ^ t>>15) * (t | 1)
+	t ^= t + (t^t>>7)*(t|61)
+	return t ^ t>>14
+}
+
+// intn matches Math.floor(random() * n) in the page
+func (r *gameRand) intn(n int) int {
+	return int(uint64(r.next()) * uint64(n) >> 32)
+}
+
+// gameRandJS is the page side of gameRand
+const gameRandJS = `
+        function gameRandom(seed) {
+            let state = seed >>> 0;
+            return function() {
+                state = (state + 0x6D2B79F5) >>> 0;
+                let t = state;
+                t = Math.imul(t ^ t >>> 15, t | 1);
+                t ^= t + Math.imul(t ^ t >>> 7, t | 61);
+                return ((t ^ t >>> 14) >>> 0) / 4294967296;
+            };
+        }
+`
+
+// ParseGameSubmission converts an answer as decoded from JSON into a submission
+func ParseGameSubmission(value interface{}) (*GameSubmission, error) {
+	if submission, ok := value.(*GameSubmission); ok {
+		return submission, nil
+	}
+
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil, fmt.Errorf("invalid game answer: %w", err)
+	}
+
+	var submission GameSubmission
+	if err := json.Unmarshal(data, &submission); err != nil {
+		return nil, fmt.Errorf("invalid game answer: %w", err)
+	}
+
+	return &submission, nil
+}
+
+// checkClock returns the violation of a log that goes back in time, ends after
+// the game or claims a longer game than the server clock allows
+func checkClock(submission *GameSubmission, elapsed time.Duration) string {
+	last := 0.0
+	for _, input := range submission.Log {
+		if input.T < last {
+			return GameViolationTimestamps
+		}
+		last = input.T
+	}
+	if submission.Duration < last {
+		return GameViolationTimestamps
+	}
+	if submission.Duration > float64((elapsed + gameTransportLag).Milliseconds()) {
+		return GameViolationClockMismatch
+	}
+	return ""
+}
+
+// constantCadence reports inputs spaced too evenly to come from a person
+func constantCadence(times []float64) bool {
+	if len(times) <= gameMinTimingSamples {
+		return false
+	}
+
+	intervals := make([]float64, len(times)-1)
+	for i := range intervals {
+		intervals[i] = times[i+1] - times[i]
+	}
+	mean, deviation := meanAndDeviation(intervals)
+	return mean > 0 && deviation/mean < gameMinTimingCV
+}
+
+// conclude fills in the verdict once the replay is done: any violation or a
+// reported outcome that differs from the replay scores 0
+func (v *GameVerdict) conclude(submission *GameSubmission, success bool, confidence int32) *GameVerdict {
+	if len(v.Violations) == 0 && (submission.Success != success || submission.Score != v.Score) {
+		v.Violations = append(v.Violations, GameViolationOutcomeMismatch)
+	}
+	if len(v.Violations) > 0 {
+		return v
+	}
+
+	v.Solved, v.Confidence = success, confidence
+	return v
+}
+
+type gamePoint struct {
+	x, y int
+}
+
+// snakeGame mirrors the snake page: the snake rests until the first key and
+// grows by one segment for every food item
+type snakeGame struct {
+	cols, rows int
+	target     int
+	rng        *gameRand
+	body       []gamePoint // Head first
+	dx, dy     int
+	food       gamePoint
+	score      int
+	over       bool
+}
+
+func newSnakeGame(a *SnakeGameAnswer) *snakeGame {
+	game := &snakeGame{
+		cols:   a.Cols,
+		rows:   a.Rows,
+		target: a.TargetFood,
+		rng:    newGameRand(a.Seed),
+		body:   []gamePoint{{a.Cols / 2, a.Rows / 2}},
+	}
+	game.placeFood()
+	return game
+}
+
+func (g *snakeGame) occupied(p gamePoint) bool {
+	for _, segment := range g.body {
+		if segment == p {
+			return true
+		}
+	}
+	return false
+}
+
+func (g *snakeGame) placeFood() {
+	for {
+		g.food = gamePoint{g.rng.intn(g.cols), g.rng.intn(g.rows)}
+		if !g.occupied(g.food) {
+			return
+		}
+	}
+}
+
+// press turns the snake like the page does, unknown keys are not logged by the page
+func (g *snakeGame) press(key string) bool {
+	switch key {
+	case "ArrowUp":
+		if g.dy == 0 {
+			g.dx, g.dy = 0, -1
+		}
+	case "ArrowDown":
+		if g.dy == 0 {
+			g.dx, g.dy = 0, 1
+		}
+	case "ArrowLeft":
+		if g.dx == 0 {
+			g.dx, g.dy = -1, 0
+		}
+	case "ArrowRight":
+		if g.dx == 0 {
+			g.dx, g.dy = 1, 0
+		}
+	default:
+		return false
+	}
+	return true
+}
+
+func (g *snakeGame) step() {
+	if g.dx == 0 && g.dy == 0 {
+		return
+	}
+
+	head := gamePoint{g.body[0].x + g.dx, g.body[0].y + g.dy}
+	if head.x < 0 || head.x >= g.cols || head.y < 0 || head.y >= g.rows || g.occupied(head) {
+		g.over = true
+		return
+	}
+
+	g.body = append([]gamePoint{head}, g.body...)
+	if head != g.food {
+		g.body = g.body[:len(g.body)-1]
+		return
+	}
+
+	g.score++
+	if g.score >= g.target {
+		g.over = true
+		return
+	}
+	g.placeFood()
+}
+
+// Verify replays the key log tick by tick. Every tick takes Speed milliseconds,
+// so a key pressed at tick n cannot be logged before n*Speed
+func (a *SnakeGameAnswer) Verify(submission *GameSubmission, elapsed time.Duration) *GameVerdict {
+	verdict := &GameVerdict{}
+	if len(submission.Log) == 0 {
+		verdict.Violations = append(verdict.Violations, GameViolationEmptyLog)
+		return verdict
+	}
+	if violation := checkClock(submission, elapsed); violation != "" {
+		verdict.Violations = append(verdict.Violations, violation)
+		return verdict
+	}
+
+	times := make([]float64, len(submission.Log))
+	for i, input := range submission.Log {
+		times[i] = input.T
+		if input.Tick < 0 || (i > 0 && input.Tick < submission.Log[i-1].Tick) {
+			verdict.Violations = append(verdict.Violations, GameViolationTimestamps)
+			return verdict
+		}
+		if input.T < float64(input.Tick*a.Speed)*gameClockSlack {
+			verdict.Violations = append(verdict.Violations, GameViolationTooFast)
+			return verdict
+		}
+	}
+
+	game := newSnakeGame(a)
+	maxTicks := int(gameMaxDuration.Milliseconds()) / a.Speed
+	next, ticks := 0, 0
+	for ; ticks < maxTicks && !game.over; ticks++ {
+		for next < len(submission.Log) && submission.Log[next].Tick == ticks {
+			if !game.press(submission.Log[next].Key) {
+				verdict.Violations = append(verdict.Violations, GameViolationInvalidInput)
+				return verdict
+			}
+			next++
+		}
+		game.step()
+	}
+	verdict.Score = game.score
+
+	switch {
+	case !game.over:
+		verdict.Violations = append(verdict.Violations, GameViolationUnfinished)
+	case next < len(submission.Log):
+		verdict.Violations = append(verdict.Violations, GameViolationInputAfterEnd)
+	case submission.Duration < float64(ticks*a.Speed)*gameClockSlack:
+		verdict.Violations = append(verdict.Violations, GameViolationTooFast)
+	}
+	if constantCadence(times) {
+		verdict.Violations = append(verdict.Violations, GameViolationConstantTiming)
+	}
+
+	success := game.score >= a.TargetFood
+	if success {
+		return verdict.conclude(submission, true, 100)
+	}
+	return verdict.conclude(submission, false, int32(game.score*80/a.TargetFood))
+}
+
+// Sequence returns the cells the memory game highlights
+func (a *MemoryGameAnswer) Sequence() []int {
+	rng := newGameRand(a.Seed)
+	sequence := make([]int, a.Length)
+	for i := range sequence {
+		sequence[i] = rng.intn(a.Cells)
+	}
+	return sequence
+}
+
+// Verify replays the clicks against the sequence. The page ignores clicks
+// while the sequence is shown, and moving between cells takes a person time
+func (a *MemoryGameAnswer) Verify(submission *GameSubmission, elapsed time.Duration) *GameVerdict {
+	verdict := &GameVerdict{}
+	if len(submission.Log) == 0 {
+		verdict.Violations = append(verdict.Violations, GameViolationEmptyLog)
+		return verdict
+	}
+	if violation := checkClock(submission, elapsed); violation != "" {
+		verdict.Violations = append(verdict.Violations, violation)
+		return verdict
+	}
+
+	shown := float64(memoryLeadIn + a.Length*(a.ShowTime+memoryPause))
+	times := make([]float64, len(submission.Log))
+	for i, input := range submission.Log {
+		times[i] = input.T
+		if input.Cell < 0 || input.Cell >= a.Cells {
+			verdict.Violations = append(verdict.Violations, GameViolationInvalidInput)
+			return verdict
+		}
+		if input.T < shown {
+			verdict.Violations = append(verdict.Violations, GameViolationEarlyInput)
+			return verdict
+		}
+		if i > 0 && input.T-times[i-1] < memoryMinInterval {
+			verdict.Violations = append(verdict.Violations, GameViolationTooFast)
+			return verdict
+		}
+	}
+
+	sequence := a.Sequence()
+	over, success := false, false
+	for _, input := range submission.Log {
+		if over {
+			verdict.Violations = append(verdict.Violations, GameViolationInputAfterEnd)
+			break
+		}
+		if input.Cell != sequence[verdict.Score] {
+			over = true
+			continue
+		}
+		verdict.Score++
+		over = verdict.Score == len(sequence)
+		success = over
+	}
+	if !over {
+		verdict.Violations = append(verdict.Violations, GameViolationUnfinished)
+	}
+	if constantCadence(times) {
+		verdict.Violations = append(verdict.Violations, GameViolationConstantTiming)
+	}
+
+	return verdict.conclude(submission, success, int32(verdict.Score*100/len(sequence)))
+}
+
+// Delays returns how long each round waits before the signal, in milliseconds
+func (a *ReactionGameAnswer) Delays() []int {
+	rng := newGameRand(a.Seed)
+	delays := make([]int, a.Rounds)
+	for i := range delays {
+		delays[i] = reactionMinDelay + rng.intn(reactionDelayRange)
+	}
+	return delays
+}
+
+// Verify replays the clicks: the first one starts the game and each round
+// starts at the previous click, so every reaction time follows from the seed
+// instead of being reported by the client
+func (a *ReactionGameAnswer) Verify(submission *GameSubmission, elapsed time.Duration) *GameVerdict {
+	verdict := &GameVerdict{}
+	if len(submission.Log) < 2 {
+		verdict.Violations = append(verdict.Violations, GameViolationEmptyLog)
+		return verdict
+	}
+	if violation := checkClock(submission, elapsed); violation != "" {
+		verdict.Violations = append(verdict.Violations, violation)
+		return verdict
+	}
+	if len(submission.Log)-1 > a.Rounds {
+		verdict.Violations = append(verdict.Violations, GameViolationInputAfterEnd)
+		return verdict
+	}
+
+	delays := a.Delays()
+	var reactions []float64
+	falseStart := false
+	for i, input := range submission.Log[1:] {
+		if falseStart {
+			verdict.Violations = append(verdict.Violations, GameViolationInputAfterEnd)
+			return verdict
+		}
+
+		reaction := input.T - submission.Log[i].T - float64(delays[i])
+		switch {
+		case reaction < 0:
+			// Clicking before the signal ends the game, people do that
+			falseStart = true
+		case reaction < reactionMinTime:
+			verdict.Violations = append(verdict.Violations, GameViolationTooFast)
+		default:
+			reactions = append(reactions, reaction)
+			if reaction <= float64(a.MaxReaction) {
+				verdict.Score++
+			}
+		}
+	}
+
+	if !falseStart && len(submission.Log)-1 < a.Rounds {
+		verdict.Violations = append(verdict.Violations, GameViolationUnfinished)
+	}
+	mean, deviation := meanAndDeviation(reactions)
+	if len(reactions) >= 3 && deviation < reactionMinDeviation {
+		verdict.Violations = append(verdict.Violations, GameViolationConstantTiming)
+	}
+
+	switch {
+	case falseStart:
+		return verdict.conclude(submission, false, 0)
+	case verdict.Score == a.Rounds:
+		return verdict.conclude(submission, true, int32(100-10*mean/float64(a.MaxReaction)))
+	default:
+		return verdict.conclude(submission, false, int32(70*verdict.Score/a.Rounds))
+	}
+}
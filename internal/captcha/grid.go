@@ -0,0 +1,392 @@
+package captcha
+
+import (
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+	"math/rand"
+	"sort"
+)
+
+// Grid shapes, one of them is the target named in the instructions
+var gridShapes = []string{"triangle", "circle", "square", "cross"}
+
+// minGridTileSize keeps shapes recognizable on small canvases
+const minGridTileSize = 24
+
+// GridCaptcha represents an image-grid object selection captcha
+type GridCaptcha struct {
+	ID           string `json:"id"`
+	Image        string `json:"image"` // PNG data URL of the whole grid
+	Rows         int    `json:"rows"`
+	Cols         int    `json:"cols"`
+	TileSize     int    `json:"tile_size"`
+	Instructions string `json:"instructions"`
+	CanvasWidth  int    `json:"canvas_width"`
+	CanvasHeight int    `json:"canvas_height"`
+}
+
+// GridAnswer is the solution of a grid captcha, it never leaves the server
+type GridAnswer struct {
+	Target     string `json:"target"`
+	Tiles      []int  `json:"tiles"` // Row-major indices of the tiles containing the target, ascending
+	TotalTiles int    `json:"total_tiles"`
+}
+
+// GridGenerator generates image-grid selection captchas
+type GridGenerator struct {
+	canvasWidth  int
+	canvasHeight int
+	minSize      int // Tiles per side at the lowest complexity
+	maxSize      int // Tiles per side at the highest complexity
+}
+
+// NewGridGenerator creates a new grid generator
+func NewGridGenerator(canvasWidth, canvasHeight, minSize, maxSize int) *GridGenerator {
+	return &GridGenerator{
+		canvasWidth:  canvasWidth,
+		canvasHeight: canvasHeight,
+		minSize:      minSize,
+		maxSize:      maxSize,
+	}
+}
+
+// Generate creates a new grid captcha
+func (g *GridGenerator) Generate(rng *rand.Rand, complexity int32) (*GridCaptcha, interface{}, error) {
+	size := g.calculateGridSize(complexity)
+	tileSize := min(g.canvasWidth, g.canvasHeight) / size
+	if tileSize < minGridTileSize {
+		return nil, nil, fmt.Errorf("canvas too small for a %dx%d grid", size, size)
+	}
+
+	target := gridShapes[rng.Intn(len(gridShapes))]
+	tiles := g.pickTargetTiles(rng, size*size)
+
+	imageData, err := g.generateImage(rng, size, tileSize, target, tiles, complexity)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	captcha := &GridCaptcha{
+		ID:           fmt.Sprintf("grid_%d", rng.Int63()),
+		Image:        imageData,
+		Rows:         size,
+		Cols:         size,
+		TileSize:     tileSize,
+		Instructions: fmt.Sprintf("Select every tile containing a %s", target),
+		CanvasWidth:  size * tileSize,
+		CanvasHeight: size * tileSize,
+	}
+
+	answer := &GridAnswer{
+		Target:     target,
+		Tiles:      tiles,
+		TotalTiles: size * size,
+	}
+
+	return captcha, answer, nil
+}
+
+// GenerateHTML generates HTML for the grid captcha
+func (g *GridGenerator) GenerateHTML(captcha *GridCaptcha) (string, error) {
+	captchaJSON, err := json.Marshal(captcha)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal captcha: %w", err)
+	}
+
+	html := fmt.Sprintf(`
+<!DOCTYPE html>
+<html>
+<head>
+    <meta charset="UTF-8">
+    <title>Grid Captcha</title>
+    <style>
+        body {
+            font-family: Arial, sans-serif;
+            margin: 0;
+            padding: 20px;
+            background-color: #f5f5f5;
+        }
+        .captcha-container {
+            max-width: %dpx;
+            margin: 0 auto;
+            background: white;
+            border-radius: 8px;
+            box-shadow: 0 2px 10px rgba(0,0,0,0.1);
+            padding: 20px;
+        }
+        .instructions {
+            text-align: center;
+            margin-bottom: 20px;
+            font-size: 16px;
+            color: #333;
+        }
+        .grid {
+            position: relative;
+            display: grid;
+            width: %dpx;
+            height: %dpx;
+            margin: 0 auto;
+            background-size: cover;
+            user-select: none;
+        }
+        .tile {
+            border: 2px solid transparent;
+            box-sizing: border-box;
+            cursor: pointer;
+            transition: all 0.15s ease;
+        }
+        .tile:hover {
+            border-color: #007bff;
+        }
+        .tile.selected {
+            border-color: #28a745;
+            background: rgba(40,167,69,0.25);
+        }
+        .submit-btn {
+            display: block;
+            margin: 20px auto 0;
+            padding: 10px 20px;
+            background: #007bff;
+            color: white;
+            border: none;
+            border-radius: 4px;
+            cursor: pointer;
+            font-size: 16px;
+        }
+        .submit-btn:hover {
+            background: #0056b3;
+        }
+        .submit-btn:disabled {
+            background: #6c757d;
+            cursor: not-allowed;
+        }
+    </style>
+</head>
+<body>
+    <div class="captcha-container">
+        <div class="instructions" id="instructions">%s</div>
+        <div class="grid" id="grid"></div>
+        <button class="submit-btn" id="submitBtn" onclick="submitSolution()" disabled>Verify</button>
+    </div>
+
+    <script>
+        const captchaData = %s;
+        const selected = new Set();
+
+        function initCaptcha() {
+            const grid = document.getElementById('grid');
+            grid.style.backgroundImage = 'url(' + captchaData.image + ')';
+            grid.style.gridTemplateColumns = 'repeat(' + captchaData.cols + ', 1fr)';
+            grid.style.gridTemplateRows = 'repeat(' + captchaData.rows + ', 1fr)';
+
+            for (let i = 0; i < captchaData.rows * captchaData.cols; i++) {
+                const tile = document.createElement('div');
+                tile.className = 'tile';
+                tile.dataset.index = i;
+                tile.addEventListener('click', () => toggleTile(tile));
+                grid.appendChild(tile);
+            }
+        }
+
+        function toggleTile(tile) {
+            const index = parseInt(tile.dataset.index, 10);
+            if (selected.has(index)) {
+                selected.delete(index);
+                tile.classList.remove('selected');
+            } else {
+                selected.add(index);
+                tile.classList.add('selected');
+            }
+            document.getElementById('submitBtn').disabled = selected.size === 0;
+        }
+
+        function submitSolution() {
+            // Send solution to parent window
+            window.top.postMessage({
+                type: 'captcha:sendData',
+                data: JSON.stringify({
+                    type: 'grid_solution',
+                    solution: Array.from(selected).sort((a, b) => a - b),
+                    captchaId: captchaData.id
+                })
+            }, '*');
+        }
+
+        // Listen for messages from server
+        window.addEventListener('message', function(e) {
+            if (e.data && e.data.type === 'captcha:serverData') {
+                console.log('Received server data:', e.data.data);
+            }
+        });
+
+        // Initialize when page loads
+        document.addEventListener('DOMContentLoaded', initCaptcha);
+    </script>
+</body>
+</html>`,
+		captcha.CanvasWidth, captcha.CanvasWidth, captcha.CanvasHeight, captcha.Instructions, string(captchaJSON))
+
+	return html, nil
+}
+
+// calculateGridSize calculates tiles per side based on complexity
+func (g *GridGenerator) calculateGridSize(complexity int32) int {
+	if complexity < 0 {
+		complexity = 0
+	}
+	if complexity > 100 {
+		complexity = 100
+	}
+
+	return g.minSize + int(complexity)*(g.maxSize-g.minSize+1)/101
+}
+
+// pickTargetTiles picks a quarter to a half of the tiles to contain the target
+func (g *GridGenerator) pickTargetTiles(rng *rand.Rand, total int) []int {
+	count := total/4 + rng.Intn(total/4+1)
+	if count < 1 {
+		count = 1
+	}
+
+	tiles := rng.Perm(total)[:count]
+	sort.Ints(tiles)
+	return tiles
+}
+
+// generateImage renders the grid as a PNG data URL
+func (g *GridGenerator) generateImage(rng *rand.Rand, size, tileSize int, target string, tiles []int, complexity int32) (string, error) {
+	return encodeDataURL(g.render(rng, size, tileSize, target, tiles, complexity))
+}
+
+// render draws one shape per tile, the target only in the target tiles, then
+// distractors and noise scaled by complexity so the tiles cannot be matched by pixels
+func (g *GridGenerator) render(rng *rand.Rand, size, tileSize int, target string, tiles []int, complexity int32) *image.Paletted {
+	img := image.NewPaletted(image.Rect(0, 0, size*tileSize, size*tileSize), rasterPalette)
+
+	isTarget := make(map[int]bool, len(tiles))
+	for _, tile := range tiles {
+		isTarget[tile] = true
+	}
+
+	for tile := 0; tile < size*size; tile++ {
+		x0 := (tile % size) * tileSize
+		y0 := (tile / size) * tileSize
+
+		background := randomColor(rng, 190, 250)
+		fillRect(img, x0, y0, tileSize, tileSize, background)
+
+		shape := target
+		if !isTarget[tile] {
+			shape = g.randomShapeExcept(rng, target)
+		}
+		radius := float64(tileSize) * (0.25 + rng.Float64()*0.13)
+		g.drawShape(rng, img, x0, y0, tileSize, shape, radius, randomColor(rng, 20, 140))
+
+		// Smaller distractors share tiles with the main shape at higher complexity
+		if rng.Int31n(100) < complexity {
+			g.drawShape(rng, img, x0, y0, tileSize, g.randomShapeExcept(rng, target), float64(tileSize)*0.12, randomColor(rng, 80, 180))
+		}
+	}
+
+	g.addNoise(rng, img, complexity)
+
+	// Tile separators are drawn last so noise does not blur the boundaries
+	separator := uint8(rasterPalette.Index(color.White))
+	for i := 1; i < size; i++ {
+		fillRect(img, i*tileSize-1, 0, 2, size*tileSize, separator)
+		fillRect(img, 0, i*tileSize-1, size*tileSize, 2, separator)
+	}
+
+	return img
+}
+
+// randomShapeExcept returns a random shape other than the target
+func (g *GridGenerator) randomShapeExcept(rng *rand.Rand, target string) string {
+	for {
+		shape := gridShapes[rng.Intn(len(gridShapes))]
+		if shape != target {
+			return shape
+		}
+	}
+}
+
+// drawShape draws a randomly placed and rotated shape inside a tile
+func (g *GridGenerator) drawShape(rng *rand.Rand, img *image.Paletted, x0, y0, tileSize int, shape string, radius float64, c uint8) {
+	margin := radius + 2
+	span := float64(tileSize) - 2*margin
+	if span < 0 {
+		span = 0
+	}
+	cx := float64(x0) + margin + rng.Float64()*span
+	cy := float64(y0) + margin + rng.Float64()*span
+	angle := rng.Float64() * 2 * math.Pi
+	sin, cos := math.Sincos(-angle)
+
+	for y := int(cy - radius); y <= int(cy+radius); y++ {
+		for x := int(cx - radius); x <= int(cx+radius); x++ {
+			dx, dy := float64(x)-cx, float64(y)-cy
+			// Rotate into the shape's frame
+			u := dx*cos - dy*sin
+			v := dx*sin + dy*cos
+			if insideShape(shape, u, v, radius) {
+				img.SetColorIndex(x, y, c)
+			}
+		}
+	}
+}
+
+// insideShape reports whether a point in shape coordinates lies inside the shape
+func insideShape(shape string, u, v, radius float64) bool {
+	switch shape {
+	case "circle":
+		return u*u+v*v <= radius*radius
+	case "square":
+		side := radius * 0.8
+		return math.Abs(u) <= side && math.Abs(v) <= side
+	case "cross":
+		arm := radius * 0.35
+		return (math.Abs(u) <= arm && math.Abs(v) <= radius) || (math.Abs(v) <= arm && math.Abs(u) <= radius)
+	case "triangle":
+		// Equilateral triangle inscribed in the circle of the given radius
+		side := radius * math.Sqrt(3) / 2
+		return insideTriangle(u, v, 0, -radius, side, radius/2, -side, radius/2)
+	default:
+		return false
+	}
+}
+
+// insideTriangle reports whether (u, v) lies inside the triangle a, b, c
+func insideTriangle(u, v, ax, ay, bx, by, cx, cy float64) bool {
+	d1 := (u-bx)*(ay-by) - (ax-bx)*(v-by)
+	d2 := (u-cx)*(by-cy) - (bx-cx)*(v-cy)
+	d3 := (u-ax)*(cy-ay) - (cx-ax)*(v-ay)
+	negative := d1 < 0 || d2 < 0 || d3 < 0
+	positive := d1 > 0 || d2 > 0 || d3 > 0
+	return !(negative && positive)
+}
+
+// addNoise sprinkles random pixels and strokes over the whole image
+func (g *GridGenerator) addNoise(rng *rand.Rand, img *image.Paletted, complexity int32) {
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+
+	density := 0.02 + float64(complexity)/100*0.08
+	for i := 0; i < int(float64(width*height)*density); i++ {
+		img.SetColorIndex(rng.Intn(width), rng.Intn(height), uint8(rng.Intn(len(rasterPalette))))
+	}
+
+	strokes := 2 + int(complexity)/15
+	for i := 0; i < strokes; i++ {
+		x1, y1 := rng.Float64()*float64(width), rng.Float64()*float64(height)
+		x2, y2 := rng.Float64()*float64(width), rng.Float64()*float64(height)
+		c := randomColor(rng, 60, 200)
+		steps := int(math.Hypot(x2-x1, y2-y1))
+		for step := 0; step <= steps; step++ {
+			t := float64(step) / float64(steps+1)
+			img.SetColorIndex(int(x1+(x2-x1)*t), int(y1+(y2-y1)*t), c)
+		}
+	}
+}
@@ -0,0 +1,264 @@
+package captcha
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/rand"
+	"strings"
+	"time"
+)
+
+// Probe timing, a real browser needs probeFrames animation frames before it
+// reports, so a report arriving sooner than probeMinDwell was not rendered
+const (
+	probeFrames   = 20
+	probeMinDwell = 250 * time.Millisecond
+	probeClockLag = 2 * time.Second // Client dwell may exceed the server's view by transport delay
+	probeTimeout  = 3 * time.Second // Hidden pages get no animation frames and report after it
+)
+
+// probeHeadlessAgents are user agent markers of automated browsers
+var probeHeadlessAgents = []string{"headless", "phantomjs", "selenium", "webdriver", "puppeteer", "playwright"}
+
+// ProbeCaptcha is an invisible page collecting environment and timing signals
+type ProbeCaptcha struct {
+	ID        string `json:"id"`
+	Nonce     string `json:"nonce"`      // Echoed in the report, binds it to this page
+	Frames    int    `json:"frames"`     // Animation frames timed before reporting
+	TimeoutMs int64  `json:"timeout_ms"` // Report with the frames timed so far after it
+}
+
+// ProbeAnswer is what a report has to match, it never leaves the server
+type ProbeAnswer struct {
+	Nonce      string `json:"nonce"`
+	Frames     int    `json:"frames"`
+	MinDwellMs int64  `json:"min_dwell_ms"`
+}
+
+// ProbeReport is the signals the probe page sends in a probe_signals event
+type ProbeReport struct {
+	Nonce               string    `json:"nonce"`
+	Webdriver           bool      `json:"webdriver"` // navigator.webdriver
+	UserAgent           string    `json:"user_agent"`
+	Languages           int       `json:"languages"`
+	Plugins             int       `json:"plugins"`
+	HardwareConcurrency int       `json:"hardware_concurrency"`
+	ScreenWidth         int       `json:"screen_width"`
+	ScreenHeight        int       `json:"screen_height"`
+	Timezone            string    `json:"timezone"`
+	Touch               bool      `json:"touch"`
+	DwellMs             int64     `json:"dwell_ms"`        // From page load to the report
+	FrameIntervals      []float64 `json:"frame_intervals"` // Milliseconds between animation frames
+	Interactions        int       `json:"interactions"`    // Pointer, key and focus events seen
+}
+
+// ProbeVerdict is the risk read from a probe report, 0 (looks like a person's
+// browser) to 1 (automated), with the signals that raised it
+type ProbeVerdict struct {
+	Risk    float64  `json:"risk"`
+	Reasons []string `json:"reasons"`
+}
+
+// Probe verdict reasons
+const (
+	ProbeReasonNonceMismatch    = "nonce_mismatch"
+	ProbeReasonWebdriver        = "webdriver"
+	ProbeReasonHeadlessAgent    = "headless_user_agent"
+	ProbeReasonTooFast          = "too_fast"
+	ProbeReasonClockMismatch    = "clock_mismatch"
+	ProbeReasonEmptyEnvironment = "empty_environment"
+	ProbeReasonNoScreen         = "no_screen"
+	ProbeReasonNoFrames         = "no_animation_frames"
+	ProbeReasonUniformFrames    = "uniform_frames"
+)
+
+// ProbeGenerator generates passive probe pages
+type ProbeGenerator struct{}
+
+// NewProbeGenerator creates a new probe generator
+func NewProbeGenerator() *ProbeGenerator {
+	return &ProbeGenerator{}
+}
+
+// Generate creates a new probe, complexity does not apply to it
+func (g *ProbeGenerator) Generate(rng *rand.Rand, complexity int32) (*ProbeCaptcha, interface{}, error) {
+	nonce := fmt.Sprintf("%016x%016x", rng.Uint64(), rng.Uint64())
+
+	captcha := &ProbeCaptcha{
+		ID:        fmt.Sprintf("probe_%d", rng.Int63()),
+		Nonce:     nonce,
+		Frames:    probeFrames,
+		TimeoutMs: probeTimeout.Milliseconds(),
+	}
+
+	answer := &ProbeAnswer{
+		Nonce:      nonce,
+		Frames:     probeFrames,
+		MinDwellMs: probeMinDwell.Milliseconds(),
+	}
+
+	return captcha, answer, nil
+}
+
+// Evaluate scores a report received elapsed after the probe was issued
+func (a *ProbeAnswer) Evaluate(report *ProbeReport, elapsed time.Duration) *ProbeVerdict {
+	verdict := &ProbeVerdict{Reasons: []string{}}
+	raise := func(risk float64, reason string) {
+		verdict.Risk += risk
+		verdict.Reasons = append(verdict.Reasons, reason)
+	}
+
+	if report.Nonce != a.Nonce {
+		raise(1, ProbeReasonNonceMismatch)
+		return verdict
+	}
+
+	if report.Webdriver {
+		raise(0.6, ProbeReasonWebdriver)
+	}
+	userAgent := strings.ToLower(report.UserAgent)
+	for _, marker := range probeHeadlessAgents {
+		if strings.Contains(userAgent, marker) {
+			raise(0.6, ProbeReasonHeadlessAgent)
+			break
+		}
+	}
+
+	// The server clock decides how fast the report came, the client's claim only has to agree
+	if elapsed.Milliseconds() < a.MinDwellMs || report.DwellMs < a.MinDwellMs {
+		raise(0.3, ProbeReasonTooFast)
+	} else if time.Duration(report.DwellMs)*time.Millisecond > elapsed+probeClockLag {
+		raise(0.3, ProbeReasonClockMismatch)
+	}
+
+	if report.Plugins == 0 && report.Languages == 0 {
+		raise(0.2, ProbeReasonEmptyEnvironment)
+	}
+	if report.ScreenWidth <= 0 || report.ScreenHeight <= 0 {
+		raise(0.2, ProbeReasonNoScreen)
+	}
+
+	// Rendering browsers jitter between frames, replayed or synthesized timings do not
+	if len(report.FrameIntervals) < a.Frames/2 {
+		raise(0.2, ProbeReasonNoFrames)
+	} else if frameJitter(report.FrameIntervals) < 0.01 {
+		raise(0.2, ProbeReasonUniformFrames)
+	}
+
+	verdict.Risk = math.Min(verdict.Risk, 1)
+	return verdict
+}
+
+// frameJitter returns the standard deviation of frame intervals in milliseconds
+func frameJitter(intervals []float64) float64 {
+	mean := 0.0
+	for _, interval := range intervals {
+		mean += interval
+	}
+	mean /= float64(len(intervals))
+
+	variance := 0.0
+	for _, interval := range intervals {
+		variance += (interval - mean) * (interval - mean)
+	}
+	return math.Sqrt(variance / float64(len(intervals)))
+}
+
+// GenerateHTML generates HTML for the probe, it shows no puzzle and reports
+// once enough animation frames were timed
+func (g *ProbeGenerator) GenerateHTML(captcha *ProbeCaptcha) (string, error) {
+	captchaJSON, err := json.Marshal(captcha)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal captcha: %w", err)
+	}
+
+	html := fmt.Sprintf(`
+<!DOCTYPE html>
+<html>
+<head>
+    <meta charset="UTF-8">
+    <title>Browser Check</title>
+    <style>
+        body {
+            font-family: Arial, sans-serif;
+            margin: 0;
+            padding: 12px;
+            color: #666;
+            font-size: 13px;
+        }
+    </style>
+</head>
+<body>
+    <div class="status" role="status">Checking your browser...</div>
+
+    <script>
+        const captchaData = %s;
+        const started = performance.now();
+        const intervals = [];
+        let interactions = 0;
+        let last = null;
+        let reported = false;
+
+        ['mousemove', 'pointerdown', 'keydown', 'touchstart', 'focus', 'scroll'].forEach(function(type) {
+            window.addEventListener(type, function() { interactions++; }, {passive: true, capture: true});
+        });
+
+        function report() {
+            if (reported) {
+                return;
+            }
+            reported = true;
+
+            const nav = window.navigator;
+            window.top.postMessage({
+                type: 'captcha:sendData',
+                data: JSON.stringify({
+                    type: 'probe_signals',
+                    captchaId: captchaData.id,
+                    signals: {
+                        nonce: captchaData.nonce,
+                        webdriver: nav.webdriver === true,
+                        user_agent: nav.userAgent,
+                        languages: (nav.languages || []).length,
+                        plugins: (nav.plugins || []).length,
+                        hardware_concurrency: nav.hardwareConcurrency || 0,
+                        screen_width: window.screen ? window.screen.width : 0,
+                        screen_height: window.screen ? window.screen.height : 0,
+                        timezone: Intl.DateTimeFormat().resolvedOptions().timeZone || '',
+                        touch: 'ontouchstart' in window || (nav.maxTouchPoints || 0) > 0,
+                        dwell_ms: Math.round(performance.now() - started),
+                        frame_intervals: intervals,
+                        interactions: interactions
+                    }
+                })
+            }, '*');
+        }
+
+        function frame(now) {
+            if (last !== null) {
+                intervals.push(Math.round((now - last) * 1000) / 1000);
+            }
+            last = now;
+            if (intervals.length < captchaData.frames) {
+                window.requestAnimationFrame(frame);
+            } else {
+                report();
+            }
+        }
+
+        // Listen for messages from server
+        window.addEventListener('message', function(e) {
+            if (e.data && e.data.type === 'captcha:serverData') {
+                console.log('Received server data:', e.data.data);
+            }
+        });
+
+        window.requestAnimationFrame(frame);
+        setTimeout(report, captchaData.timeout_ms);
+    </script>
+</body>
+</html>`, string(captchaJSON))
+
+	return html, nil
+}
@@ -0,0 +1,320 @@
+package captcha
+
+import (
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+	"math/rand"
+)
+
+// Rotate objects have an obvious upright orientation and no rotational symmetry
+var rotateObjects = []string{"house", "tree", "person"}
+
+// minRotateImageSize keeps the object recognizable on small canvases
+const minRotateImageSize = 80
+
+// RotateCaptcha represents a rotate-to-upright captcha
+type RotateCaptcha struct {
+	ID           string `json:"id"`
+	Image        string `json:"image"` // PNG data URL of the rotated object in a disk
+	Size         int    `json:"size"`
+	Instructions string `json:"instructions"`
+	CanvasWidth  int    `json:"canvas_width"`
+	CanvasHeight int    `json:"canvas_height"`
+}
+
+// RotateAnswer is the secret rotation of the image, it never leaves the server
+type RotateAnswer struct {
+	Object    string `json:"object"`
+	Angle     int    `json:"angle"`     // Degrees the object is rotated clockwise from upright
+	Tolerance int    `json:"tolerance"` // Allowed angular error in degrees
+}
+
+// RotateGenerator generates rotate-to-upright captchas
+type RotateGenerator struct {
+	canvasWidth  int
+	canvasHeight int
+}
+
+// NewRotateGenerator creates a new rotate generator
+func NewRotateGenerator(canvasWidth, canvasHeight int) *RotateGenerator {
+	return &RotateGenerator{
+		canvasWidth:  canvasWidth,
+		canvasHeight: canvasHeight,
+	}
+}
+
+// Generate creates a new rotate captcha
+func (g *RotateGenerator) Generate(rng *rand.Rand, complexity int32) (*RotateCaptcha, interface{}, error) {
+	size := min(g.canvasWidth, g.canvasHeight) * 2 / 3
+	if size < minRotateImageSize {
+		return nil, nil, fmt.Errorf("canvas too small for a %dpx rotate image", minRotateImageSize)
+	}
+
+	tolerance := g.calculateTolerance(complexity)
+	object := rotateObjects[rng.Intn(len(rotateObjects))]
+
+	// The start is never close enough to upright to earn credit without rotating
+	margin := 3*tolerance + 1
+	angle := margin + rng.Intn(360-2*margin+1)
+
+	imageData, err := encodeDataURL(g.render(rng, size, object, angle, complexity))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	captcha := &RotateCaptcha{
+		ID:           fmt.Sprintf("rotate_%d", rng.Int63()),
+		Image:        imageData,
+		Size:         size,
+		Instructions: fmt.Sprintf("Rotate the picture until the %s is upright", object),
+		CanvasWidth:  size,
+		CanvasHeight: size,
+	}
+
+	answer := &RotateAnswer{
+		Object:    object,
+		Angle:     angle,
+		Tolerance: tolerance,
+	}
+
+	return captcha, answer, nil
+}
+
+// GenerateHTML generates HTML for the rotate captcha
+func (g *RotateGenerator) GenerateHTML(captcha *RotateCaptcha) (string, error) {
+	captchaJSON, err := json.Marshal(captcha)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal captcha: %w", err)
+	}
+
+	html := fmt.Sprintf(`
+<!DOCTYPE html>
+<html>
+<head>
+    <meta charset="UTF-8">
+    <title>Rotate Captcha</title>
+    <style>
+        body {
+            font-family: Arial, sans-serif;
+            margin: 0;
+            padding: 20px;
+            background-color: #f5f5f5;
+        }
+        .captcha-container {
+            max-width: %dpx;
+            margin: 0 auto;
+            background: white;
+            border-radius: 8px;
+            box-shadow: 0 2px 10px rgba(0,0,0,0.1);
+            padding: 20px;
+        }
+        .instructions {
+            text-align: center;
+            margin-bottom: 20px;
+            font-size: 16px;
+            color: #333;
+        }
+        .picture {
+            display: block;
+            width: %dpx;
+            height: %dpx;
+            margin: 0 auto;
+            border-radius: 50%%;
+            user-select: none;
+            pointer-events: none;
+        }
+        .dial {
+            display: block;
+            width: 100%%;
+            margin: 20px auto 0;
+        }
+        .submit-btn {
+            display: block;
+            margin: 20px auto 0;
+            padding: 10px 20px;
+            background: #007bff;
+            color: white;
+            border: none;
+            border-radius: 4px;
+            cursor: pointer;
+            font-size: 16px;
+        }
+        .submit-btn:hover {
+            background: #0056b3;
+        }
+    </style>
+</head>
+<body>
+    <div class="captcha-container">
+        <div class="instructions">%s</div>
+        <img class="picture" id="picture">
+        <input class="dial" id="dial" type="range" min="0" max="359" value="0">
+        <button class="submit-btn" onclick="submitSolution()">Verify</button>
+    </div>
+
+    <script>
+        const captchaData = %s;
+
+        function initCaptcha() {
+            document.getElementById('picture').src = captchaData.image;
+            document.getElementById('dial').addEventListener('input', function(e) {
+                document.getElementById('picture').style.transform = 'rotate(' + e.target.value + 'deg)';
+            });
+        }
+
+        function submitSolution() {
+            // Send solution to parent window
+            window.top.postMessage({
+                type: 'captcha:sendData',
+                data: JSON.stringify({
+                    type: 'rotate_solution',
+                    solution: parseInt(document.getElementById('dial').value, 10),
+                    captchaId: captchaData.id
+                })
+            }, '*');
+        }
+
+        // Listen for messages from server
+        window.addEventListener('message', function(e) {
+            if (e.data && e.data.type === 'captcha:serverData') {
+                console.log('Received server data:', e.data.data);
+            }
+        });
+
+        // Initialize when page loads
+        document.addEventListener('DOMContentLoaded', initCaptcha);
+    </script>
+</body>
+</html>`,
+		captcha.CanvasWidth, captcha.CanvasWidth, captcha.CanvasHeight, captcha.Instructions, string(captchaJSON))
+
+	return html, nil
+}
+
+// calculateTolerance narrows the allowed angular error from 20 to 8 degrees with complexity
+func (g *RotateGenerator) calculateTolerance(complexity int32) int {
+	if complexity < 0 {
+		complexity = 0
+	}
+	if complexity > 100 {
+		complexity = 100
+	}
+
+	return 20 - int(complexity)*12/100
+}
+
+// Verify checks the clockwise rotation the user applied, the picture is upright
+// when it cancels the secret angle modulo a full turn
+func (a *RotateAnswer) Verify(rotation float64) (bool, int32) {
+	if math.IsNaN(rotation) || math.IsInf(rotation, 0) {
+		return false, 0
+	}
+
+	deviation := math.Mod(float64(a.Angle)+rotation, 360)
+	if deviation < 0 {
+		deviation += 360
+	}
+	if deviation > 180 {
+		deviation = 360 - deviation
+	}
+
+	return scoreDistance(deviation, float64(a.Tolerance))
+}
+
+// render draws the rotated object over a busy disk, the background is made of
+// round blobs and noise so it gives no hint of the orientation
+func (g *RotateGenerator) render(rng *rand.Rand, size int, object string, angle int, complexity int32) *image.Paletted {
+	img := image.NewPaletted(image.Rect(0, 0, size, size), rasterPalette)
+	fillRect(img, 0, 0, size, size, uint8(rasterPalette.Index(color.White)))
+
+	center := float64(size) / 2
+	inside := func(x, y int) bool {
+		return math.Hypot(float64(x)+0.5-center, float64(y)+0.5-center) <= center
+	}
+
+	background := randomColor(rng, 170, 240)
+	for y := 0; y < size; y++ {
+		for x := 0; x < size; x++ {
+			if inside(x, y) {
+				img.SetColorIndex(x, y, background)
+			}
+		}
+	}
+
+	for i := 0; i < 6+int(complexity)/8; i++ {
+		radius := 4 + rng.Float64()*float64(size)/10
+		cx, cy := rng.Float64()*float64(size), rng.Float64()*float64(size)
+		c := randomColor(rng, 120, 230)
+		for y := int(cy - radius); y <= int(cy+radius); y++ {
+			for x := int(cx - radius); x <= int(cx+radius); x++ {
+				if inside(x, y) && math.Hypot(float64(x)-cx, float64(y)-cy) <= radius {
+					img.SetColorIndex(x, y, c)
+				}
+			}
+		}
+	}
+
+	// Parts of the object get separate colors so its outline stays readable
+	colors := []uint8{randomColor(rng, 20, 110), randomColor(rng, 20, 110), randomColor(rng, 20, 110)}
+	radius := center * 0.7
+	sin, cos := math.Sincos(float64(angle) * math.Pi / 180)
+	for y := 0; y < size; y++ {
+		for x := 0; x < size; x++ {
+			dx, dy := float64(x)+0.5-center, float64(y)+0.5-center
+			// Undo the clockwise rotation to get upright object coordinates
+			u := (dx*cos + dy*sin) / radius
+			v := (-dx*sin + dy*cos) / radius
+			if part := objectPart(object, u, v); part > 0 {
+				img.SetColorIndex(x, y, colors[part-1])
+			}
+		}
+	}
+
+	density := 0.02 + float64(complexity)/100*0.06
+	for i := 0; i < int(float64(size*size)*density); i++ {
+		x, y := rng.Intn(size), rng.Intn(size)
+		if inside(x, y) {
+			img.SetColorIndex(x, y, uint8(rng.Intn(len(rasterPalette))))
+		}
+	}
+
+	return img
+}
+
+// objectPart returns which part of an upright object covers (u, v), 0 for none.
+// Coordinates are scaled to the object's radius with v pointing down
+func objectPart(object string, u, v float64) int {
+	switch object {
+	case "house":
+		if math.Abs(u) <= 0.15 && v >= 0.45 && v <= 0.85 {
+			return 3 // Door
+		}
+		if math.Abs(u) <= 0.6 && v >= -0.1 && v <= 0.85 {
+			return 1 // Walls
+		}
+		if insideTriangle(u, v, 0, -0.85, 0.8, -0.1, -0.8, -0.1) {
+			return 2 // Roof
+		}
+	case "tree":
+		if math.Abs(u) <= 0.12 && v >= 0.35 && v <= 0.9 {
+			return 1 // Trunk
+		}
+		if insideTriangle(u, v, 0, -0.9, 0.65, 0.4, -0.65, 0.4) {
+			return 2 // Crown
+		}
+	case "person":
+		if u*u+(v+0.62)*(v+0.62) <= 0.25*0.25 {
+			return 1 // Head
+		}
+		if math.Abs(u) <= 0.28 && v >= -0.32 && v <= 0.35 {
+			return 2 // Body
+		}
+		if math.Abs(u) >= 0.05 && math.Abs(u) <= 0.25 && v > 0.35 && v <= 0.9 {
+			return 3 // Legs
+		}
+	}
+	return 0
+}
@@ -0,0 +1,28 @@
+package captcha
+
+import (
+	cryptorand "crypto/rand"
+	"encoding/binary"
+	"math/rand"
+)
+
+// SeedSource returns the seed of the next challenge, it must be safe for
+// concurrent use. Every challenge is generated from its own *rand.Rand, so the
+// same type, complexity and seed regenerate exactly the same challenge
+type SeedSource func() int64
+
+// CryptoSeed draws a seed from crypto/rand so challenges cannot be predicted
+// from the ones before them
+func CryptoSeed() int64 {
+	var b [8]byte
+	if _, err := cryptorand.Read(b[:]); err != nil {
+		// The global source is randomly seeded too, just not cryptographically
+		return rand.Int63()
+	}
+	return int64(binary.LittleEndian.Uint64(b[:]))
+}
+
+// newRand returns the generator a challenge is built from
+func newRand(seed int64) *rand.Rand {
+	return rand.New(rand.NewSource(seed))
+}
@@ -0,0 +1,569 @@
+package captcha
+
+import (
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+	"math/rand"
+	"time"
+)
+
+// SliderCaptcha represents a slider puzzle: a piece is dragged horizontally into a gap
+type SliderCaptcha struct {
+	ID           string `json:"id"`
+	Background   string `json:"background"` // PNG data URL with the gap cut out
+	Piece        string `json:"piece"`      // PNG data URL of the piece, transparent around its outline
+	PieceY       int    `json:"piece_y"`    // The piece only moves horizontally, starting at x = 0
+	PieceWidth   int    `json:"piece_width"`
+	PieceHeight  int    `json:"piece_height"`
+	Instructions string `json:"instructions"`
+	CanvasWidth  int    `json:"canvas_width"`
+	CanvasHeight int    `json:"canvas_height"`
+}
+
+// SliderAnswer is the secret gap position and the limits the drag is checked against
+type SliderAnswer struct {
+	GapX        int           `json:"gap_x"`
+	Tolerance   int           `json:"tolerance"`    // Allowed final position error in pixels
+	MinDuration time.Duration `json:"min_duration"` // Faster drags are scripted
+	TrackWidth  int           `json:"track_width"`
+}
+
+// SliderPoint is one sample of the drag, X and Y in pixels relative to the
+// piece's start and T in milliseconds since the drag started
+type SliderPoint struct {
+	X float64 `json:"x"`
+	Y float64 `json:"y"`
+	T float64 `json:"t"`
+}
+
+// SliderSubmission is the client's answer: the final piece offset and the drag that led to it
+type SliderSubmission struct {
+	Position   float64       `json:"position"`
+	Trajectory []SliderPoint `json:"trajectory"`
+}
+
+// SliderVerdict is the outcome of checking a submission
+type SliderVerdict struct {
+	Solved     bool
+	Confidence int32
+	Distance   float64  // Final position error in pixels
+	Violations []string // Trajectory checks that failed, empty for a human-looking drag
+}
+
+// Trajectory checks that flag scripted drags
+const (
+	SliderViolationTooFewPoints     = "too_few_points"
+	SliderViolationTimestamps       = "non_monotonic_time"
+	SliderViolationTooFast          = "too_fast"
+	SliderViolationTooSlow          = "too_slow"
+	SliderViolationStart            = "bad_start"
+	SliderViolationEnd              = "end_mismatch"
+	SliderViolationJump             = "jump"
+	SliderViolationConstantVelocity = "constant_velocity"
+	SliderViolationNoJitter         = "no_jitter"
+	SliderViolationNoDeceleration   = "no_deceleration"
+)
+
+// Trajectory limits for drags sampled by pointermove, typically at 60-120 Hz
+const (
+	sliderMinPoints       = 10
+	sliderMaxDuration     = 20 * time.Second
+	sliderMaxStartOffset  = 5.0  // Pixels the first sample may be away from the start
+	sliderMaxEndMismatch  = 3.0  // Pixels between the last sample and the reported position
+	sliderMaxJumpFraction = 0.35 // Largest single step as a fraction of the track
+	sliderMinVelocityCV   = 0.2  // Coefficient of variation of speed, near 0 for linear drags
+	sliderMinJitter       = 0.3  // Standard deviation of the vertical position in pixels
+	sliderEndSpeedRatio   = 0.7  // Speed entering the gap relative to the peak
+)
+
+// SliderGenerator generates slider puzzle captchas
+type SliderGenerator struct {
+	canvasWidth  int
+	canvasHeight int
+	pieceSize    int
+}
+
+// NewSliderGenerator creates a new slider generator
+func NewSliderGenerator(canvasWidth, canvasHeight, pieceSize int) *SliderGenerator {
+	return &SliderGenerator{
+		canvasWidth:  canvasWidth,
+		canvasHeight: canvasHeight,
+		pieceSize:    pieceSize,
+	}
+}
+
+// Generate creates a new slider captcha
+func (g *SliderGenerator) Generate(rng *rand.Rand, complexity int32) (*SliderCaptcha, interface{}, error) {
+	width, height := g.canvasWidth, g.canvasHeight*2/3
+	shape := newPuzzleShape(g.pieceSize)
+
+	// The gap is at least one piece away from the start so it cannot be solved by a nudge
+	minX := shape.width + g.pieceSize/2
+	maxX := width - shape.width - 4
+	if maxX <= minX || height < shape.height+8 {
+		return nil, nil, fmt.Errorf("canvas too small for a %dpx slider piece", g.pieceSize)
+	}
+	gapX := minX + rng.Intn(maxX-minX+1)
+	gapY := 4 + rng.Intn(height-shape.height-7)
+
+	background := g.renderBackground(rng, width, height, complexity)
+	piece := cutPiece(background, shape, gapX, gapY)
+
+	// A fainter decoy gap at higher complexity defeats naive edge detection
+	if complexity >= 60 {
+		decoyX := minX + rng.Intn(maxX-minX+1)
+		if math.Abs(float64(decoyX-gapX)) > float64(shape.width) {
+			shadeGap(background, shape, decoyX, 4+rng.Intn(height-shape.height-7), 0.75)
+		}
+	}
+	shadeGap(background, shape, gapX, gapY, 0.45)
+
+	backgroundData, err := encodeDataURL(background)
+	if err != nil {
+		return nil, nil, err
+	}
+	pieceData, err := encodeDataURL(piece)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	captcha := &SliderCaptcha{
+		ID:           fmt.Sprintf("slider_%d", rng.Int63()),
+		Background:   backgroundData,
+		Piece:        pieceData,
+		PieceY:       gapY,
+		PieceWidth:   shape.width,
+		PieceHeight:  shape.height,
+		Instructions: "Drag the slider to fit the puzzle piece into the gap",
+		CanvasWidth:  width,
+		CanvasHeight: height,
+	}
+
+	answer := &SliderAnswer{
+		GapX:        gapX,
+		Tolerance:   g.calculateTolerance(complexity),
+		MinDuration: 300 * time.Millisecond,
+		TrackWidth:  width - shape.width,
+	}
+
+	return captcha, answer, nil
+}
+
+// GenerateHTML generates HTML for the slider captcha
+func (g *SliderGenerator) GenerateHTML(captcha *SliderCaptcha) (string, error) {
+	captchaJSON, err := json.Marshal(captcha)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal captcha: %w", err)
+	}
+
+	html := fmt.Sprintf(`
+<!DOCTYPE html>
+<html>
+<head>
+    <meta charset="UTF-8">
+    <title>Slider Captcha</title>
+    <style>
+        body {
+            font-family: Arial, sans-serif;
+            margin: 0;
+            padding: 20px;
+            background-color: #f5f5f5;
+        }
+        .captcha-container {
+            max-width: %dpx;
+            margin: 0 auto;
+            background: white;
+            border-radius: 8px;
+            box-shadow: 0 2px 10px rgba(0,0,0,0.1);
+            padding: 20px;
+        }
+        .instructions {
+            text-align: center;
+            margin-bottom: 20px;
+            font-size: 16px;
+            color: #333;
+        }
+        .canvas {
+            position: relative;
+            width: %dpx;
+            height: %dpx;
+            margin: 0 auto;
+            border-radius: 4px;
+            overflow: hidden;
+        }
+        .canvas img {
+            position: absolute;
+            top: 0;
+            left: 0;
+            user-select: none;
+            pointer-events: none;
+        }
+        .track {
+            position: relative;
+            height: 40px;
+            margin: 15px auto 0;
+            background: #e9ecef;
+            border-radius: 20px;
+            touch-action: none;
+        }
+        .handle {
+            position: absolute;
+            top: 0;
+            left: 0;
+            height: 40px;
+            background: #007bff;
+            border-radius: 20px;
+            cursor: grab;
+        }
+        .handle:active {
+            cursor: grabbing;
+        }
+    </style>
+</head>
+<body>
+    <div class="captcha-container">
+        <div class="instructions">%s</div>
+        <div class="canvas" id="canvas">
+            <img id="background">
+            <img id="piece">
+        </div>
+        <div class="track" id="track"><div class="handle" id="handle"></div></div>
+    </div>
+
+    <script>
+        const captchaData = %s;
+        const maxOffset = captchaData.canvas_width - captchaData.piece_width;
+        let dragging = false;
+        let startX = 0, startY = 0, startTime = 0, offset = 0;
+        let trajectory = [];
+
+        function initCaptcha() {
+            document.getElementById('background').src = captchaData.background;
+            const piece = document.getElementById('piece');
+            piece.src = captchaData.piece;
+            piece.style.top = captchaData.piece_y + 'px';
+
+            const track = document.getElementById('track');
+            track.style.width = captchaData.canvas_width + 'px';
+            const handle = document.getElementById('handle');
+            handle.style.width = captchaData.piece_width + 'px';
+            handle.addEventListener('pointerdown', startDrag);
+            window.addEventListener('pointermove', moveDrag);
+            window.addEventListener('pointerup', endDrag);
+        }
+
+        function startDrag(e) {
+            dragging = true;
+            startX = e.clientX;
+            startY = e.clientY;
+            startTime = performance.now();
+            trajectory = [{x: 0, y: 0, t: 0}];
+        }
+
+        function moveDrag(e) {
+            if (!dragging) return;
+            offset = Math.max(0, Math.min(maxOffset, e.clientX - startX));
+            trajectory.push({x: offset, y: e.clientY - startY, t: performance.now() - startTime});
+            document.getElementById('piece').style.left = offset + 'px';
+            document.getElementById('handle').style.left = offset + 'px';
+        }
+
+        function endDrag() {
+            if (!dragging) return;
+            dragging = false;
+
+            // Send solution to parent window
+            window.top.postMessage({
+                type: 'captcha:sendData',
+                data: JSON.stringify({
+                    type: 'slider_solution',
+                    solution: {position: offset, trajectory: trajectory},
+                    captchaId: captchaData.id
+                })
+            }, '*');
+        }
+
+        // Listen for messages from server
+        window.addEventListener('message', function(e) {
+            if (e.data && e.data.type === 'captcha:serverData') {
+                console.log('Received server data:', e.data.data);
+            }
+        });
+
+        // Initialize when page loads
+        document.addEventListener('DOMContentLoaded', initCaptcha);
+    </script>
+</body>
+</html>`,
+		captcha.CanvasWidth, captcha.CanvasWidth, captcha.CanvasHeight, captcha.Instructions, string(captchaJSON))
+
+	return html, nil
+}
+
+// calculateTolerance calculates the allowed position error based on complexity
+func (g *SliderGenerator) calculateTolerance(complexity int32) int {
+	if complexity < 30 {
+		return 6
+	} else if complexity < 70 {
+		return 5
+	}
+	return 4
+}
+
+// ParseSliderSubmission converts an answer as decoded from JSON into a submission
+func ParseSliderSubmission(value interface{}) (*SliderSubmission, error) {
+	if submission, ok := value.(*SliderSubmission); ok {
+		return submission, nil
+	}
+
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil, fmt.Errorf("invalid slider answer: %w", err)
+	}
+
+	var submission SliderSubmission
+	if err := json.Unmarshal(data, &submission); err != nil {
+		return nil, fmt.Errorf("invalid slider answer: %w", err)
+	}
+
+	return &submission, nil
+}
+
+// Verify checks the final position against the gap and the drag against the
+// trajectory limits. A drag that looks scripted scores 0 however precise it is,
+// a human drag that misses narrowly scores as borderline
+func (a *SliderAnswer) Verify(submission *SliderSubmission) *SliderVerdict {
+	verdict := &SliderVerdict{
+		Distance:   math.Abs(submission.Position - float64(a.GapX)),
+		Violations: a.checkTrajectory(submission),
+	}
+
+	if len(verdict.Violations) > 0 {
+		return verdict
+	}
+
+	verdict.Solved, verdict.Confidence = scoreDistance(verdict.Distance, float64(a.Tolerance))
+	return verdict
+}
+
+// scoreDistance scores an error against a tolerance: within it the answer is
+// solved with 90-100 confidence, near misses within three tolerances fall from 70
+// to 50 so they earn another round, anything further scores 0
+func scoreDistance(distance, tolerance float64) (bool, int32) {
+	if distance <= tolerance {
+		return true, int32(100 - 10*distance/tolerance)
+	}
+	if distance <= 3*tolerance {
+		return false, int32(70 - 20*(distance-tolerance)/(2*tolerance))
+	}
+	return false, 0
+}
+
+// checkTrajectory returns the trajectory checks the submission fails
+func (a *SliderAnswer) checkTrajectory(submission *SliderSubmission) []string {
+	points := submission.Trajectory
+	if len(points) < sliderMinPoints {
+		return []string{SliderViolationTooFewPoints}
+	}
+
+	var violations []string
+	for i := 1; i < len(points); i++ {
+		if points[i].T < points[i-1].T {
+			return []string{SliderViolationTimestamps}
+		}
+	}
+
+	duration := time.Duration((points[len(points)-1].T - points[0].T) * float64(time.Millisecond))
+	if duration < a.MinDuration {
+		violations = append(violations, SliderViolationTooFast)
+	}
+	if duration > sliderMaxDuration {
+		violations = append(violations, SliderViolationTooSlow)
+	}
+
+	if math.Abs(points[0].X) > sliderMaxStartOffset {
+		violations = append(violations, SliderViolationStart)
+	}
+	if math.Abs(points[len(points)-1].X-submission.Position) > sliderMaxEndMismatch {
+		violations = append(violations, SliderViolationEnd)
+	}
+
+	// Speeds between samples, samples without elapsed time are merged into the next
+	var speeds []float64
+	maxStep := 0.0
+	last := points[0]
+	for _, point := range points[1:] {
+		step := math.Abs(point.X - last.X)
+		maxStep = math.Max(maxStep, step)
+		if point.T > last.T {
+			speeds = append(speeds, step/(point.T-last.T))
+			last = point
+		}
+	}
+	if a.TrackWidth > 0 && maxStep > float64(a.TrackWidth)*sliderMaxJumpFraction {
+		violations = append(violations, SliderViolationJump)
+	}
+
+	if len(speeds) >= 3 {
+		mean, deviation := meanAndDeviation(speeds)
+		if mean > 0 && deviation/mean < sliderMinVelocityCV {
+			violations = append(violations, SliderViolationConstantVelocity)
+		}
+
+		// People accelerate and slow down to aim at the gap, a script often stops dead at full speed
+		peak := 0.0
+		for _, speed := range speeds {
+			peak = math.Max(peak, speed)
+		}
+		tail := speeds[len(speeds)*3/4:]
+		tailMean, _ := meanAndDeviation(tail)
+		if peak > 0 && tailMean > peak*sliderEndSpeedRatio {
+			violations = append(violations, SliderViolationNoDeceleration)
+		}
+	}
+
+	ys := make([]float64, len(points))
+	for i, point := range points {
+		ys[i] = point.Y
+	}
+	if _, jitter := meanAndDeviation(ys); jitter < sliderMinJitter {
+		violations = append(violations, SliderViolationNoJitter)
+	}
+
+	return violations
+}
+
+// meanAndDeviation returns the mean and population standard deviation of values
+func meanAndDeviation(values []float64) (float64, float64) {
+	if len(values) == 0 {
+		return 0, 0
+	}
+
+	sum := 0.0
+	for _, value := range values {
+		sum += value
+	}
+	mean := sum / float64(len(values))
+
+	variance := 0.0
+	for _, value := range values {
+		variance += (value - mean) * (value - mean)
+	}
+
+	return mean, math.Sqrt(variance / float64(len(values)))
+}
+
+// puzzleShape is a jigsaw piece: a square with round tabs on its top and right
+type puzzleShape struct {
+	size   int // Side of the square body
+	tab    int // Tab radius
+	width  int // Bounding box including the tabs
+	height int
+}
+
+func newPuzzleShape(size int) puzzleShape {
+	tab := size / 5
+	return puzzleShape{size: size, tab: tab, width: size + tab, height: size + tab}
+}
+
+// contains reports whether a point of the bounding box belongs to the piece
+func (s puzzleShape) contains(x, y int) bool {
+	fx, fy := float64(x)+0.5, float64(y)+0.5
+	size, tab := float64(s.size), float64(s.tab)
+
+	if fx < size && fy >= tab && fy < tab+size {
+		return true
+	}
+	// Right tab centred on the right edge, top tab centred on the top edge
+	if math.Hypot(fx-size, fy-(tab+size/2)) <= tab {
+		return true
+	}
+	return math.Hypot(fx-size/2, fy-tab) <= tab
+}
+
+// onOutline reports whether a piece point borders a point outside the piece
+func (s puzzleShape) onOutline(x, y int) bool {
+	return !s.contains(x-1, y) || !s.contains(x+1, y) || !s.contains(x, y-1) || !s.contains(x, y+1)
+}
+
+// renderBackground paints a gradient with random shapes and noise
+func (g *SliderGenerator) renderBackground(rng *rand.Rand, width, height int, complexity int32) *image.Paletted {
+	img := image.NewPaletted(image.Rect(0, 0, width, height), rasterPalette)
+
+	from, to := randomRGB(rng, 60, 200), randomRGB(rng, 60, 200)
+	for x := 0; x < width; x++ {
+		t := float64(x) / float64(width)
+		c := uint8(rasterPalette.Index(color.RGBA{
+			uint8(float64(from.R)*(1-t) + float64(to.R)*t),
+			uint8(float64(from.G)*(1-t) + float64(to.G)*t),
+			uint8(float64(from.B)*(1-t) + float64(to.B)*t),
+			255,
+		}))
+		for y := 0; y < height; y++ {
+			img.SetColorIndex(x, y, c)
+		}
+	}
+
+	for i := 0; i < 8+int(complexity)/10; i++ {
+		radius := 10 + rng.Float64()*30
+		cx, cy := rng.Float64()*float64(width), rng.Float64()*float64(height)
+		c := randomColor(rng, 30, 230)
+		for y := int(cy - radius); y <= int(cy+radius); y++ {
+			for x := int(cx - radius); x <= int(cx+radius); x++ {
+				if math.Hypot(float64(x)-cx, float64(y)-cy) <= radius {
+					img.SetColorIndex(x, y, c)
+				}
+			}
+		}
+	}
+
+	density := 0.01 + float64(complexity)/100*0.04
+	for i := 0; i < int(float64(width*height)*density); i++ {
+		img.SetColorIndex(rng.Intn(width), rng.Intn(height), uint8(rng.Intn(len(rasterPalette))))
+	}
+
+	return img
+}
+
+// cutPiece copies the piece at the gap out of the background with a light outline
+func cutPiece(background *image.Paletted, shape puzzleShape, gapX, gapY int) *image.NRGBA {
+	piece := image.NewNRGBA(image.Rect(0, 0, shape.width, shape.height))
+	outline := color.NRGBA{255, 255, 255, 230}
+
+	for y := 0; y < shape.height; y++ {
+		for x := 0; x < shape.width; x++ {
+			if !shape.contains(x, y) {
+				continue
+			}
+			if shape.onOutline(x, y) {
+				piece.SetNRGBA(x, y, outline)
+				continue
+			}
+			r, g, b, _ := background.At(gapX+x, gapY+y).RGBA()
+			piece.SetNRGBA(x, y, color.NRGBA{uint8(r >> 8), uint8(g >> 8), uint8(b >> 8), 255})
+		}
+	}
+
+	return piece
+}
+
+// shadeGap darkens the piece outline on the background by factor
+func shadeGap(background *image.Paletted, shape puzzleShape, gapX, gapY int, factor float64) {
+	for y := 0; y < shape.height; y++ {
+		for x := 0; x < shape.width; x++ {
+			if !shape.contains(x, y) {
+				continue
+			}
+			r, g, b, _ := background.At(gapX+x, gapY+y).RGBA()
+			background.SetColorIndex(gapX+x, gapY+y, uint8(rasterPalette.Index(color.RGBA{
+				uint8(float64(r>>8) * factor),
+				uint8(float64(g>>8) * factor),
+				uint8(float64(b>>8) * factor),
+				255,
+			})))
+		}
+	}
+}
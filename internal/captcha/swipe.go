@@ -4,8 +4,6 @@ import (
 	"encoding/json"
 	"fmt"
 	"math/rand"
-	"os"
-	"time"
 )
 
 // SwipeCaptcha represents a swipe-based captcha
@@ -51,22 +49,22 @@ func NewSwipeGenerator(canvasWidth, canvasHeight, minSwipes, maxSwipes, swipeThr
 }
 
 // Generate creates a new swipe captcha
-func (g *SwipeGenerator) Generate(complexity int32) (*SwipeCaptcha, interface{}, error) {
+func (g *SwipeGenerator) Generate(rng *rand.Rand, complexity int32) (*SwipeCaptcha, interface{}, error) {
 	// Determine number of swipes based on complexity
 	numSwipes := g.calculateSwipeCount(complexity)
 
 	// Generate swipe areas
-	swipeAreas, correctSequence := g.generateSwipeAreas(numSwipes)
+	swipeAreas, correctSequence := g.generateSwipeAreas(rng, numSwipes)
 
 	// Generate image data
 	imageData := g.generateImage(swipeAreas)
 
 	// Create captcha
 	captcha := &SwipeCaptcha{
-		ID:           fmt.Sprintf("swipe_%d", time.Now().UnixNano()),
+		ID:           fmt.Sprintf("swipe_%d", rng.Int63()),
 		Image:        imageData,
 		SwipeAreas:   swipeAreas,
-		Instructions: g.generateInstructions(complexity),
+		Instructions: g.generateInstructions(rng, complexity),
 		CanvasWidth:  g.canvasWidth,
 		CanvasHeight: g.canvasHeight,
 	}
@@ -500,18 +498,7 @@ func (g *SwipeGenerator) calculateSwipeCount(complexity int32) int {
 }
 
 // generateSwipeAreas generates swipe areas for the captcha
-func (g *SwipeGenerator) generateSwipeAreas(numSwipes int) ([]SwipeArea, []map[string]interface{}) {
-	// Ultra-random seed for infinite variations
-	// Ultra-enhanced seed for infinite variations
-	// Generate entropy for randomness (not used with deprecated rand.Seed)
-	_ = time.Now().UnixNano() + 
-		int64(rand.Intn(1000000)) + 
-		int64(numSwipes*11117) + 
-		int64(g.canvasWidth*g.canvasHeight) + 
-		int64(time.Now().Nanosecond()) + 
-		int64(os.Getpid()*17)
-	// rand.Seed is deprecated in Go 1.20+, using default random source
-
+func (g *SwipeGenerator) generateSwipeAreas(rng *rand.Rand, numSwipes int) ([]SwipeArea, []map[string]interface{}) {
 	swipeAreas := make([]SwipeArea, numSwipes)
 	correctSequence := make([]map[string]interface{}, 0, numSwipes)
 
@@ -525,20 +512,20 @@ func (g *SwipeGenerator) generateSwipeAreas(numSwipes int) ([]SwipeArea, []map[s
 	// Advanced shuffling with multiple passes for maximum randomness
 	for pass := 0; pass < 7; pass++ {
 		for i := range directions {
-			j := rand.Intn(len(directions))
+			j := rng.Intn(len(directions))
 			directions[i], directions[j] = directions[j], directions[i]
 		}
 	}
 
 	for i := 0; i < numSwipes; i++ {
 		// Generate random position
-		x := rand.Intn(g.canvasWidth - 100)
-		y := rand.Intn(g.canvasHeight - 100)
+		x := rng.Intn(g.canvasWidth - 100)
+		y := rng.Intn(g.canvasHeight - 100)
 
 		// Ensure areas don't overlap
-		g.avoidOverlap(x, y, swipeAreas[:i])
+		g.avoidOverlap(rng, x, y, swipeAreas[:i])
 
-		direction := directions[rand.Intn(len(directions))]
+		direction := directions[rng.Intn(len(directions))]
 
 		area := SwipeArea{
 			ID:        fmt.Sprintf("area_%d", i),
@@ -562,7 +549,7 @@ func (g *SwipeGenerator) generateSwipeAreas(numSwipes int) ([]SwipeArea, []map[s
 }
 
 // avoidOverlap ensures swipe areas don't overlap
-func (g *SwipeGenerator) avoidOverlap(x, y int, existingAreas []SwipeArea) {
+func (g *SwipeGenerator) avoidOverlap(rng *rand.Rand, x, y int, existingAreas []SwipeArea) {
 	maxAttempts := 50
 	attempts := 0
 
@@ -581,8 +568,8 @@ func (g *SwipeGenerator) avoidOverlap(x, y int, existingAreas []SwipeArea) {
 		}
 
 		// Reposition
-		x = rand.Intn(g.canvasWidth - 100)
-		y = rand.Intn(g.canvasHeight - 100)
+		x = rng.Intn(g.canvasWidth - 100)
+		y = rng.Intn(g.canvasHeight - 100)
 
 		attempts++
 	}
@@ -600,7 +587,7 @@ func (g *SwipeGenerator) generateImage(swipeAreas []SwipeArea) string {
 }
 
 // generateInstructions generates instructions based on complexity
-func (g *SwipeGenerator) generateInstructions(complexity int32) string {
+func (g *SwipeGenerator) generateInstructions(rng *rand.Rand, complexity int32) string {
 	instructions := []string{
 		"Swipe each area in the direction shown by the arrow",
 		"Drag the elements in the correct direction",
@@ -608,5 +595,5 @@ func (g *SwipeGenerator) generateInstructions(complexity int32) string {
 		"Swipe the highlighted areas according to their arrows",
 	}
 
-	return instructions[rand.Intn(len(instructions))]
+	return instructions[rng.Intn(len(instructions))]
 }
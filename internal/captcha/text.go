@@ -0,0 +1,369 @@
+package captcha
+
+import (
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+	"math/rand"
+	"strings"
+	"sync"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/gofont/gobold"
+	"golang.org/x/image/font/opentype"
+	"golang.org/x/image/math/fixed"
+)
+
+// textAlphabet leaves out the letters users confuse with digits, answers are
+// folded the same way so either spelling of the rest is accepted
+const textAlphabet = "ACDEFGHJKMNPRTUVWXY0123456789"
+
+// textConfusables maps characters that look alike to the one they are compared as
+var textConfusables = map[rune]rune{
+	'O': '0', 'Q': '0',
+	'I': '1', 'L': '1', '|': '1', '!': '1',
+	'S': '5',
+	'Z': '2',
+	'B': '8',
+}
+
+// textFont is the bundled Go Bold typeface, parsed once on first use
+var textFont = sync.OnceValues(func() (*opentype.Font, error) {
+	return opentype.Parse(gobold.TTF)
+})
+
+// TextCaptcha represents a typed-text captcha for clients without pointer input
+type TextCaptcha struct {
+	ID           string `json:"id"`
+	Image        string `json:"image"` // PNG data URL of the distorted text
+	Length       int    `json:"length"`
+	Instructions string `json:"instructions"`
+	CanvasWidth  int    `json:"canvas_width"`
+	CanvasHeight int    `json:"canvas_height"`
+}
+
+// TextAnswer is the rendered text, it never leaves the server
+type TextAnswer struct {
+	Text string `json:"text"`
+}
+
+// TextGenerator generates distorted-text captchas
+type TextGenerator struct {
+	canvasWidth  int
+	canvasHeight int
+	minLength    int // Characters at the lowest complexity
+	maxLength    int // Characters at the highest complexity
+}
+
+// NewTextGenerator creates a new text generator
+func NewTextGenerator(canvasWidth, canvasHeight, minLength, maxLength int) *TextGenerator {
+	return &TextGenerator{
+		canvasWidth:  canvasWidth,
+		canvasHeight: canvasHeight,
+		minLength:    minLength,
+		maxLength:    maxLength,
+	}
+}
+
+// Generate creates a new text captcha
+func (g *TextGenerator) Generate(rng *rand.Rand, complexity int32) (*TextCaptcha, interface{}, error) {
+	length := g.calculateLength(complexity)
+	width, height := g.canvasWidth, g.canvasHeight*2/5
+	if width < length*20 || height < 40 {
+		return nil, nil, fmt.Errorf("canvas too small for %d characters", length)
+	}
+
+	text := make([]byte, length)
+	for i := range text {
+		text[i] = textAlphabet[rng.Intn(len(textAlphabet))]
+	}
+
+	img, err := g.render(rng, string(text), width, height, complexity)
+	if err != nil {
+		return nil, nil, err
+	}
+	imageData, err := encodeDataURL(img)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	captcha := &TextCaptcha{
+		ID:           fmt.Sprintf("text_%d", rng.Int63()),
+		Image:        imageData,
+		Length:       length,
+		Instructions: fmt.Sprintf("Type the %d characters shown in the picture", length),
+		CanvasWidth:  width,
+		CanvasHeight: height,
+	}
+
+	return captcha, &TextAnswer{Text: string(text)}, nil
+}
+
+// GenerateHTML generates HTML for the text captcha, it only needs a keyboard
+func (g *TextGenerator) GenerateHTML(captcha *TextCaptcha) (string, error) {
+	captchaJSON, err := json.Marshal(captcha)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal captcha: %w", err)
+	}
+
+	html := fmt.Sprintf(`
+<!DOCTYPE html>
+<html>
+<head>
+    <meta charset="UTF-8">
+    <title>Text Captcha</title>
+    <style>
+        body {
+            font-family: Arial, sans-serif;
+            margin: 0;
+            padding: 20px;
+            background-color: #f5f5f5;
+        }
+        .captcha-container {
+            max-width: %dpx;
+            margin: 0 auto;
+            background: white;
+            border-radius: 8px;
+            box-shadow: 0 2px 10px rgba(0,0,0,0.1);
+            padding: 20px;
+        }
+        .instructions {
+            display: block;
+            text-align: center;
+            margin-bottom: 20px;
+            font-size: 16px;
+            color: #333;
+        }
+        .picture {
+            display: block;
+            width: %dpx;
+            height: %dpx;
+            margin: 0 auto 15px;
+            user-select: none;
+        }
+        .answer {
+            display: block;
+            width: 100%%;
+            box-sizing: border-box;
+            padding: 10px;
+            font-size: 20px;
+            letter-spacing: 4px;
+            text-transform: uppercase;
+            border: 2px solid #333;
+            border-radius: 4px;
+            margin-bottom: 15px;
+        }
+        .answer:focus, .submit-btn:focus {
+            outline: 3px solid #ffbf47;
+        }
+        .submit-btn {
+            display: block;
+            margin: 0 auto;
+            padding: 10px 20px;
+            background: #007bff;
+            color: white;
+            border: none;
+            border-radius: 4px;
+            cursor: pointer;
+            font-size: 16px;
+        }
+    </style>
+</head>
+<body>
+    <form class="captcha-container" onsubmit="submitSolution(event)">
+        <label class="instructions" for="answer">%s</label>
+        <img class="picture" id="picture" alt="">
+        <input class="answer" id="answer" type="text" autocomplete="off" autocapitalize="off" autocorrect="off" spellcheck="false">
+        <button class="submit-btn" type="submit">Verify</button>
+    </form>
+
+    <script>
+        const captchaData = %s;
+
+        function initCaptcha() {
+            document.getElementById('picture').src = captchaData.image;
+            document.getElementById('answer').focus();
+        }
+
+        function submitSolution(e) {
+            e.preventDefault();
+
+            // Send solution to parent window
+            window.top.postMessage({
+                type: 'captcha:sendData',
+                data: JSON.stringify({
+                    type: 'text_solution',
+                    solution: document.getElementById('answer').value,
+                    captchaId: captchaData.id
+                })
+            }, '*');
+        }
+
+        // Listen for messages from server
+        window.addEventListener('message', function(e) {
+            if (e.data && e.data.type === 'captcha:serverData') {
+                console.log('Received server data:', e.data.data);
+            }
+        });
+
+        // Initialize when page loads
+        document.addEventListener('DOMContentLoaded', initCaptcha);
+    </script>
+</body>
+</html>`,
+		captcha.CanvasWidth, captcha.CanvasWidth, captcha.CanvasHeight, captcha.Instructions, string(captchaJSON))
+
+	return html, nil
+}
+
+// calculateLength calculates the number of characters based on complexity
+func (g *TextGenerator) calculateLength(complexity int32) int {
+	if complexity < 0 {
+		complexity = 0
+	}
+	if complexity > 100 {
+		complexity = 100
+	}
+
+	return g.minLength + int(complexity)*(g.maxLength-g.minLength+1)/101
+}
+
+// NormalizeText folds a typed answer for comparison: case, whitespace and
+// separators are ignored and look-alike characters compare equal
+func NormalizeText(input string) string {
+	var normalized strings.Builder
+	for _, r := range strings.ToUpper(input) {
+		if folded, ok := textConfusables[r]; ok {
+			r = folded
+		}
+		if (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') {
+			normalized.WriteRune(r)
+		}
+	}
+	return normalized.String()
+}
+
+// Verify checks a typed answer against the text. One wrong, missing or extra
+// character is borderline and earns another round, more fail
+func (a *TextAnswer) Verify(input string) (bool, int32) {
+	switch editDistance(NormalizeText(input), NormalizeText(a.Text)) {
+	case 0:
+		return true, 100
+	case 1:
+		return false, 60
+	default:
+		return false, 0
+	}
+}
+
+// render draws the text with per-glyph rotation, scale and offset, warps the
+// whole line with crossing sine waves, then strikes it with lines and noise in
+// the text colors so strokes cannot be separated from glyphs by color
+func (g *TextGenerator) render(rng *rand.Rand, text string, width, height int, complexity int32) (*image.Paletted, error) {
+	parsed, err := textFont()
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse font: %w", err)
+	}
+
+	distortion := float64(complexity) / 100
+	size := float64(height) * 0.62
+	face, err := opentype.NewFace(parsed, &opentype.FaceOptions{Size: size, DPI: 72, Hinting: font.HintingNone})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create font face: %w", err)
+	}
+	defer face.Close()
+
+	// ink holds which glyph covers a pixel, 0 for none, before the warp
+	ink := make([]uint8, width*height)
+	colors := make([]uint8, len(text)+1)
+
+	// Glyphs crowd closer together as complexity grows
+	advance := float64(width-16) / float64(len(text))
+	spacing := advance * (1 - 0.25*distortion)
+	start := (float64(width) - spacing*float64(len(text)-1)) / 2
+
+	for i, char := range text {
+		colors[i+1] = randomColor(rng, 10, 120)
+
+		mask, origin := glyphMask(face, char)
+		angle := (rng.Float64()*2 - 1) * (10 + 25*distortion) * math.Pi / 180
+		scale := 0.85 + rng.Float64()*0.3
+		cx := start + spacing*float64(i) + (rng.Float64()*2-1)*spacing*0.1
+		cy := float64(height)/2 + (rng.Float64()*2-1)*float64(height)*0.12
+		sin, cos := math.Sincos(angle)
+
+		bounds := mask.Bounds()
+		reach := math.Hypot(float64(bounds.Dx()), float64(bounds.Dy())) * scale / 2
+		for y := int(cy - reach); y <= int(cy+reach); y++ {
+			for x := int(cx - reach); x <= int(cx+reach); x++ {
+				if x < 0 || y < 0 || x >= width || y >= height {
+					continue
+				}
+				// Map back into the upright, unscaled glyph
+				dx, dy := (float64(x)-cx)/scale, (float64(y)-cy)/scale
+				u := int(dx*cos+dy*sin) + origin.X
+				v := int(-dx*sin+dy*cos) + origin.Y
+				if mask.AlphaAt(u, v).A > 127 {
+					ink[y*width+x] = uint8(i + 1)
+				}
+			}
+		}
+	}
+
+	img := image.NewPaletted(image.Rect(0, 0, width, height), rasterPalette)
+	fillRect(img, 0, 0, width, height, randomColor(rng, 215, 255))
+
+	amplitudeX := 1 + 3*distortion
+	amplitudeY := 2 + 5*distortion
+	periodX := 30 + rng.Float64()*30
+	periodY := 60 + rng.Float64()*60
+	phaseX, phaseY := rng.Float64()*2*math.Pi, rng.Float64()*2*math.Pi
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			sx := x + int(amplitudeX*math.Sin(2*math.Pi*float64(y)/periodX+phaseX))
+			sy := y + int(amplitudeY*math.Sin(2*math.Pi*float64(x)/periodY+phaseY))
+			if sx < 0 || sy < 0 || sx >= width || sy >= height {
+				continue
+			}
+			if glyph := ink[sy*width+sx]; glyph > 0 {
+				img.SetColorIndex(x, y, colors[glyph])
+			}
+		}
+	}
+
+	// Wavy strike-through lines across the text
+	for i := 0; i < 1+int(complexity)/20; i++ {
+		c := colors[1+rng.Intn(len(text))]
+		y0 := float64(height) * (0.3 + rng.Float64()*0.4)
+		amplitude := float64(height) * (0.05 + rng.Float64()*0.15)
+		period := float64(width) * (0.3 + rng.Float64()*0.7)
+		phase := rng.Float64() * 2 * math.Pi
+		thickness := 1 + rng.Intn(2)
+		for x := 0; x < width; x++ {
+			y := int(y0 + amplitude*math.Sin(2*math.Pi*float64(x)/period+phase))
+			fillRect(img, x, y, 1, thickness, c)
+		}
+	}
+
+	density := 0.02 + 0.08*distortion
+	for i := 0; i < int(float64(width*height)*density); i++ {
+		img.SetColorIndex(rng.Intn(width), rng.Intn(height), colors[rng.Intn(len(colors))])
+	}
+
+	return img, nil
+}
+
+// glyphMask rasterizes a single character and returns it with the point of the
+// mask that is the center of the glyph
+func glyphMask(face font.Face, char rune) (*image.Alpha, image.Point) {
+	bounds, _, _ := face.GlyphBounds(char)
+	rect := image.Rect(bounds.Min.X.Floor()-1, bounds.Min.Y.Floor()-1, bounds.Max.X.Ceil()+1, bounds.Max.Y.Ceil()+1)
+
+	mask := image.NewAlpha(rect)
+	drawer := &font.Drawer{Dst: mask, Src: image.NewUniform(color.Alpha{255}), Face: face, Dot: fixed.Point26_6{}}
+	drawer.DrawString(string(char))
+
+	return mask, image.Pt((rect.Min.X+rect.Max.X)/2, (rect.Min.Y+rect.Max.Y)/2)
+}
@@ -2,6 +2,8 @@ package config
 
 import (
 	"fmt"
+	"net"
+	"net/url"
 	"os"
 	"strconv"
 	"time"
@@ -17,6 +19,9 @@ type Config struct {
 	Security   SecurityConfig   `yaml:"security"`
 	Monitoring MonitoringConfig `yaml:"monitoring"`
 	Balancer   BalancerConfig   `yaml:"balancer"`
+	Routing    RoutingConfig    `yaml:"routing"`
+	WebSocket  WebSocketConfig  `yaml:"websocket"`
+	Gateway    GatewayConfig    `yaml:"gateway"`
 }
 
 // ServerConfig contains server-related configuration
@@ -28,6 +33,16 @@ type ServerConfig struct {
 	WriteTimeout    time.Duration `yaml:"write_timeout"`
 	StartupTimeout  time.Duration `yaml:"startup_timeout"`
 	InitTimeout     time.Duration `yaml:"init_timeout"`
+	TLS             TLSConfig     `yaml:"tls"`
+}
+
+// TLSConfig contains gRPC transport security settings, shared by the server
+// and its connections to peer instances
+type TLSConfig struct {
+	Enabled  bool   `yaml:"enabled"`
+	CertFile string `yaml:"cert_file"`
+	KeyFile  string `yaml:"key_file"`
+	CAFile   string `yaml:"ca_file"` // Verifies peer certificates, system roots when empty
 }
 
 // RedisConfig contains Redis-related configuration
@@ -43,14 +58,40 @@ type RedisConfig struct {
 
 // CaptchaConfig contains captcha-related configuration
 type CaptchaConfig struct {
-	MaxActiveChallenges int            `yaml:"max_active_challenges"`
-	MemoryLimitGB       int            `yaml:"memory_limit_gb"`
-	TargetRPS           int            `yaml:"target_rps"`
-	ChallengeTimeout    time.Duration  `yaml:"challenge_timeout"`
-	CleanupInterval     time.Duration  `yaml:"cleanup_interval"`
-	DragDrop            DragDropConfig `yaml:"drag_drop"`
-	Click               ClickConfig    `yaml:"click"`
-	Swipe               SwipeConfig    `yaml:"swipe"`
+	MaxActiveChallenges int               `yaml:"max_active_challenges"`
+	MemoryLimitGB       int               `yaml:"memory_limit_gb"`
+	MemoryHighWatermark float64           `yaml:"memory_high_watermark"` // Fraction of the limit at which new challenges are rejected, 0 uses 0.9
+	TargetRPS           int               `yaml:"target_rps"`
+	ChallengeTimeout    time.Duration     `yaml:"challenge_timeout"`
+	CleanupInterval     time.Duration     `yaml:"cleanup_interval"`
+	MaxStages           int               `yaml:"max_stages"`   // Rounds of progressive challenges, 0 uses the default
+	MaxAttempts         int               `yaml:"max_attempts"` // Single answers to a challenge, 0 uses the default
+	Calibration         CalibrationConfig `yaml:"calibration"`
+	Auto                AutoConfig        `yaml:"auto"`
+	DragDrop            DragDropConfig    `yaml:"drag_drop"`
+	Click               ClickConfig       `yaml:"click"`
+	Swipe               SwipeConfig       `yaml:"swipe"`
+}
+
+// CalibrationConfig contains complexity calibration settings, zero values use the defaults
+type CalibrationConfig struct {
+	Enabled      bool    `yaml:"enabled"`
+	MinSamples   int64   `yaml:"min_samples"`    // Answers a level bucket needs before it shapes the curve
+	MaxSamples   int64   `yaml:"max_samples"`    // Bucket counts are halved past it
+	EasyPassRate float64 `yaml:"easy_pass_rate"` // Target pass rate at complexity 0
+	HardPassRate float64 `yaml:"hard_pass_rate"` // Target pass rate at complexity 100
+	Exploration  float64 `yaml:"exploration"`    // Fraction of challenges generated at a random level
+	MaxShift     int32   `yaml:"max_shift"`      // Furthest a level moves from the requested complexity
+	MaxRisk      float64 `yaml:"max_risk"`       // Answers of clients at or above this risk are not counted
+}
+
+// AutoConfig maps caller risk to challenges in auto complexity mode, zero values use the defaults
+type AutoConfig struct {
+	LowRisk        float64 `yaml:"low_risk"`         // Risk below it gets quick single-interaction challenges
+	HighRisk       float64 `yaml:"high_risk"`        // Risk at or above it gets the hardest types over several rounds
+	MinComplexity  int32   `yaml:"min_complexity"`   // Complexity at risk 0
+	MaxComplexity  int32   `yaml:"max_complexity"`   // Complexity at risk 1
+	HighRiskStages int     `yaml:"high_risk_stages"` // Rounds required at high risk
 }
 
 // DragDropConfig contains drag & drop captcha settings
@@ -80,6 +121,7 @@ type SecurityConfig struct {
 	RateLimit    RateLimitConfig    `yaml:"rate_limit"`
 	IPBlocking   IPBlockingConfig   `yaml:"ip_blocking"`
 	BotDetection BotDetectionConfig `yaml:"bot_detection"`
+	Risk         RiskConfig         `yaml:"risk"`
 }
 
 // RateLimitConfig contains rate limiting settings
@@ -103,13 +145,33 @@ type BotDetectionConfig struct {
 	SuspiciousPatterns []string `yaml:"suspicious_patterns"`
 }
 
+// RiskConfig weighs the signals of a caller's risk score, zero values use the defaults
+type RiskConfig struct {
+	BotWeight         float64       `yaml:"bot_weight"`
+	DistrustWeight    float64       `yaml:"distrust_weight"`
+	BlockWeight       float64       `yaml:"block_weight"`
+	FailureWeight     float64       `yaml:"failure_weight"`
+	BlockSaturation   int           `yaml:"block_saturation"`   // Past blocks counted as full block risk
+	FailureSaturation int           `yaml:"failure_saturation"` // Recent failures counted as full failure risk
+	FailureWindow     time.Duration `yaml:"failure_window"`     // How long failed challenges count
+}
+
 // MonitoringConfig contains monitoring-related configuration
 type MonitoringConfig struct {
-	PrometheusPort  int           `yaml:"prometheus_port"`
-	MetricsPath     string        `yaml:"metrics_path"`
-	HealthCheckPath string        `yaml:"health_check_path"`
-	Logging         LoggingConfig `yaml:"logging"`
-	Tracing         TracingConfig `yaml:"tracing"`
+	PrometheusPort  int                 `yaml:"prometheus_port"`
+	MetricsPath     string              `yaml:"metrics_path"`
+	HealthCheckPath string              `yaml:"health_check_path"`
+	ConstLabels     map[string]string   `yaml:"const_labels"`    // Added to every metric next to instance_id
+	NetworkBuckets  map[string][]string `yaml:"network_buckets"` // Bucket name to CIDRs, e.g. per ASN or country
+	TopOffenders    TopOffendersConfig  `yaml:"top_offenders"`
+	Logging         LoggingConfig       `yaml:"logging"`
+	Tracing         TracingConfig       `yaml:"tracing"`
+}
+
+// TopOffendersConfig contains top offender tracking settings
+type TopOffendersConfig struct {
+	Capacity int           `yaml:"capacity"` // IPs tracked per offense category
+	Window   time.Duration `yaml:"window"`
 }
 
 // LoggingConfig contains logging settings
@@ -121,10 +183,30 @@ type LoggingConfig struct {
 
 // TracingConfig contains tracing settings
 type TracingConfig struct {
-	Enabled        bool   `yaml:"enabled"`
+	Enabled      bool    `yaml:"enabled"`
+	OTLPEndpoint string  `yaml:"otlp_endpoint"` // OTLP gRPC collector, host:port or URL
+	Insecure     bool    `yaml:"insecure"`
+	ServiceName  string  `yaml:"service_name"`
+	SampleRatio  float64 `yaml:"sample_ratio"` // Fraction of new traces to sample, 0 samples all
+
+	// Deprecated: Jaeger collectors accept OTLP, use OTLPEndpoint. When
+	// OTLPEndpoint is empty the OTLP port 4317 on this host is used.
 	JaegerEndpoint string `yaml:"jaeger_endpoint"`
 }
 
+// Endpoint returns the OTLP collector address
+func (c TracingConfig) Endpoint() string {
+	if c.OTLPEndpoint != "" || c.JaegerEndpoint == "" {
+		return c.OTLPEndpoint
+	}
+
+	jaegerURL, err := url.Parse(c.JaegerEndpoint)
+	if err != nil || jaegerURL.Hostname() == "" {
+		return ""
+	}
+	return net.JoinHostPort(jaegerURL.Hostname(), "4317")
+}
+
 // BalancerConfig contains balancer-related configuration
 type BalancerConfig struct {
 	URL                  string        `yaml:"url"`
@@ -135,6 +217,36 @@ type BalancerConfig struct {
 	RetryDelay           time.Duration `yaml:"retry_delay"`
 }
 
+// RoutingConfig contains challenge affinity routing settings
+type RoutingConfig struct {
+	Enabled    bool              `yaml:"enabled"`
+	InstanceID string            `yaml:"instance_id"`
+	Secret     string            `yaml:"secret"`
+	Peers      map[string]string `yaml:"peers"` // instance ID -> gRPC address
+}
+
+// WebSocketConfig contains WebSocket endpoint protection settings
+type WebSocketConfig struct {
+	AllowedOrigins          []string `yaml:"allowed_origins"`
+	AllowMissingOrigin      bool     `yaml:"allow_missing_origin"` // Accept clients without Origin despite allowed_origins
+	TokenSecret             string   `yaml:"token_secret"`
+	RequireToken            bool     `yaml:"require_token"`
+	MaxMessageBytes         int64    `yaml:"max_message_bytes"`
+	MaxConnectionsPerIP     int      `yaml:"max_connections_per_ip"`
+	MaxConnectionsPerClient int      `yaml:"max_connections_per_client"`
+	TrustForwardedFor       bool     `yaml:"trust_forwarded_for"`
+	SendQueueSize           int      `yaml:"send_queue_size"`
+	SlowConsumerPolicy      string   `yaml:"slow_consumer_policy"` // drop_newest, drop_oldest or disconnect
+}
+
+// GatewayConfig contains REST/JSON gateway settings
+type GatewayConfig struct {
+	Enabled           bool     `yaml:"enabled"`
+	AllowedOrigins    []string `yaml:"allowed_origins"` // CORS origins, "*" allows any
+	MaxBodyBytes      int64    `yaml:"max_body_bytes"`
+	TrustForwardedFor bool     `yaml:"trust_forwarded_for"`
+}
+
 // LoadConfig loads configuration from file and environment variables
 func LoadConfig(configPath string) (*Config, error) {
 	config := &Config{}
@@ -202,6 +314,24 @@ func overrideWithEnv(config *Config) {
 	if balancerURL := os.Getenv("BALANCER_URL"); balancerURL != "" {
 		config.Balancer.URL = balancerURL
 	}
+
+	// Routing configuration
+	if instanceID := os.Getenv("INSTANCE_ID"); instanceID != "" {
+		config.Routing.InstanceID = instanceID
+	}
+	if secret := os.Getenv("ROUTING_SECRET"); secret != "" {
+		config.Routing.Secret = secret
+	}
+
+	// WebSocket configuration
+	if secret := os.Getenv("WS_TOKEN_SECRET"); secret != "" {
+		config.WebSocket.TokenSecret = secret
+	}
+
+	// Tracing configuration
+	if endpoint := os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT"); endpoint != "" {
+		config.Monitoring.Tracing.OTLPEndpoint = endpoint
+	}
 }
 
 // validateConfig validates the configuration
@@ -213,6 +343,9 @@ func validateConfig(config *Config) error {
 	if config.Server.MinPort >= config.Server.MaxPort {
 		return fmt.Errorf("min port must be less than max port: min=%d, max=%d", config.Server.MinPort, config.Server.MaxPort)
 	}
+	if config.Server.TLS.Enabled && (config.Server.TLS.CertFile == "" || config.Server.TLS.KeyFile == "") {
+		return fmt.Errorf("TLS certificate and key files are required when TLS is enabled")
+	}
 
 	// Validate captcha configuration
 	if config.Captcha.MaxActiveChallenges <= 0 {
@@ -224,11 +357,81 @@ func validateConfig(config *Config) error {
 	if config.Captcha.TargetRPS <= 0 {
 		return fmt.Errorf("target RPS must be positive: %d", config.Captcha.TargetRPS)
 	}
+	calibration := config.Captcha.Calibration
+	if calibration.EasyPassRate < 0 || calibration.EasyPassRate > 1 || calibration.HardPassRate < 0 || calibration.HardPassRate > 1 {
+		return fmt.Errorf("calibration pass rates must be between 0 and 1: easy=%v, hard=%v", calibration.EasyPassRate, calibration.HardPassRate)
+	}
+	if calibration.EasyPassRate > 0 && calibration.HardPassRate > calibration.EasyPassRate {
+		return fmt.Errorf("calibration hard pass rate must not exceed the easy one: easy=%v, hard=%v", calibration.EasyPassRate, calibration.HardPassRate)
+	}
+	if calibration.Exploration < 0 || calibration.Exploration > 1 {
+		return fmt.Errorf("calibration exploration must be between 0 and 1: %v", calibration.Exploration)
+	}
+	if calibration.MaxShift < 0 || calibration.MaxShift > 100 {
+		return fmt.Errorf("calibration max shift must be between 0 and 100: %d", calibration.MaxShift)
+	}
+	if calibration.MaxRisk < 0 || calibration.MaxRisk > 1 {
+		return fmt.Errorf("calibration max risk must be between 0 and 1: %v", calibration.MaxRisk)
+	}
+	auto := config.Captcha.Auto
+	if auto.LowRisk < 0 || auto.LowRisk > 1 || auto.HighRisk < 0 || auto.HighRisk > 1 {
+		return fmt.Errorf("auto risk thresholds must be between 0 and 1: low=%v, high=%v", auto.LowRisk, auto.HighRisk)
+	}
+	if auto.HighRisk > 0 && auto.LowRisk > auto.HighRisk {
+		return fmt.Errorf("auto low risk must not exceed high risk: low=%v, high=%v", auto.LowRisk, auto.HighRisk)
+	}
+	if auto.MinComplexity < 0 || auto.MaxComplexity > 100 || (auto.MaxComplexity > 0 && auto.MinComplexity > auto.MaxComplexity) {
+		return fmt.Errorf("auto complexity range must be within 0 and 100: min=%d, max=%d", auto.MinComplexity, auto.MaxComplexity)
+	}
+	risk := config.Security.Risk
+	if risk.BotWeight < 0 || risk.DistrustWeight < 0 || risk.BlockWeight < 0 || risk.FailureWeight < 0 {
+		return fmt.Errorf("risk weights must not be negative")
+	}
 
 	// Validate Redis configuration
 	if config.Redis.URL == "" {
 		return fmt.Errorf("redis URL is required")
 	}
 
+	// Validate routing configuration
+	if config.Routing.Enabled && config.Routing.Secret == "" {
+		return fmt.Errorf("routing secret is required when routing is enabled")
+	}
+
+	// Validate WebSocket configuration
+	if config.WebSocket.RequireToken && config.WebSocket.TokenSecret == "" {
+		return fmt.Errorf("websocket token secret is required when tokens are required")
+	}
+	if config.WebSocket.MaxMessageBytes < 0 {
+		return fmt.Errorf("websocket max message bytes must not be negative: %d", config.WebSocket.MaxMessageBytes)
+	}
+	switch config.WebSocket.SlowConsumerPolicy {
+	case "", "drop_newest", "drop_oldest", "disconnect":
+	default:
+		return fmt.Errorf("invalid websocket slow consumer policy: %s", config.WebSocket.SlowConsumerPolicy)
+	}
+
+	if ratio := config.Captcha.MemoryHighWatermark; ratio < 0 || ratio > 1 {
+		return fmt.Errorf("memory high watermark must be between 0 and 1: %v", ratio)
+	}
+
+	// Validate tracing configuration
+	if ratio := config.Monitoring.Tracing.SampleRatio; ratio < 0 || ratio > 1 {
+		return fmt.Errorf("tracing sample ratio must be between 0 and 1: %v", ratio)
+	}
+
+	// Validate top offenders configuration
+	if config.Monitoring.TopOffenders.Capacity < 0 {
+		return fmt.Errorf("top offenders capacity must not be negative: %d", config.Monitoring.TopOffenders.Capacity)
+	}
+	if config.Monitoring.TopOffenders.Window < 0 {
+		return fmt.Errorf("top offenders window must not be negative: %v", config.Monitoring.TopOffenders.Window)
+	}
+
+	// Validate gateway configuration
+	if config.Gateway.MaxBodyBytes < 0 {
+		return fmt.Errorf("gateway max body bytes must not be negative: %d", config.Gateway.MaxBodyBytes)
+	}
+
 	return nil
 }
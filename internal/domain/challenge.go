@@ -14,7 +14,25 @@ type Challenge struct {
 	CreatedAt  time.Time         `json:"created_at"`
 	ExpiresAt  time.Time         `json:"expires_at"`
 	Solved     bool              `json:"solved"`
+	Abandoned  bool              `json:"abandoned"` // Owner went away before solving
 	Metadata   map[string]string `json:"metadata"`
+	Stages     []ChallengeStage  `json:"stages,omitempty"` // Rounds of a progressive challenge
+}
+
+// Clone returns a copy of the challenge that shares no metadata or stages with
+// it, the generated answers are never modified and stay shared
+func (c *Challenge) Clone() *Challenge {
+	clone := *c
+	if c.Metadata != nil {
+		clone.Metadata = make(map[string]string, len(c.Metadata))
+		for key, value := range c.Metadata {
+			clone.Metadata[key] = value
+		}
+	}
+	if c.Stages != nil {
+		clone.Stages = append([]ChallengeStage(nil), c.Stages...)
+	}
+	return &clone
 }
 
 // ChallengeType represents the type of captcha challenge
@@ -25,8 +43,33 @@ const (
 	ChallengeTypeClick    ChallengeType = "click"
 	ChallengeTypeSwipe    ChallengeType = "swipe"
 	ChallengeTypeGame     ChallengeType = "game"
+	ChallengeTypeGrid     ChallengeType = "grid"
+	ChallengeTypeSlider   ChallengeType = "slider"
+	ChallengeTypeRotate   ChallengeType = "rotate"
+	ChallengeTypeAudio    ChallengeType = "audio"   // Only issued on request, see ChallengeOptions
+	ChallengeTypeText     ChallengeType = "text"    // Only issued on request, see ChallengeOptions
+	ChallengeTypePassive  ChallengeType = "passive" // Invisible probe, only issued on request, see ChallengeOptions
 )
 
+// ChallengeOptions are the caller's requirements for a new challenge
+type ChallengeOptions struct {
+	// Accessible requests a challenge usable without sight, currently always audio
+	Accessible bool
+
+	// KeyboardOnly requests a challenge for clients without pointer or touch
+	// input, currently always text. Accessible takes precedence, audio only needs a keyboard too
+	KeyboardOnly bool
+
+	// Auto derives complexity and type from the caller's risk, the requested
+	// complexity is ignored. Accessible and KeyboardOnly still fix the type
+	Auto bool
+
+	// Passive starts with an invisible probe instead of a puzzle, a low risk
+	// client passes on its signals alone and others get a visible challenge
+	// chosen like in Auto mode. Accessible and KeyboardOnly fix the visible type
+	Passive bool
+}
+
 // ChallengeResult represents the result of solving a challenge
 type ChallengeResult struct {
 	ChallengeID       string `json:"challenge_id"`
@@ -37,6 +80,33 @@ type ChallengeResult struct {
 	Error             string `json:"error,omitempty"`
 }
 
+// Result errors reported in ChallengeResult.Error
+const (
+	ResultErrorExpired        = "challenge expired"
+	ResultErrorAbandoned      = "challenge abandoned"
+	ResultErrorStagesRequired = "challenge requires staged answers"
+	ResultErrorExhausted      = "no answer attempts left"
+)
+
+// Err returns the result error as a classified error, nil when there is none
+func (r *ChallengeResult) Err() error {
+	switch r.Error {
+	case "":
+		return nil
+	case ResultErrorExpired:
+		return ExpiredError(r.ChallengeID)
+	case ResultErrorAbandoned:
+		return AbandonedError(r.ChallengeID)
+	case ResultErrorStagesRequired:
+		return StagesRequiredError(r.ChallengeID)
+	case ResultErrorExhausted:
+		return ExhaustedError(r.ChallengeID)
+	default:
+		// A result error without a mapping is a bug, not a client mistake
+		return NewError(ErrorKindInternal, ReasonUnknownResult, r.Error).WithMetadata("challenge_id", r.ChallengeID)
+	}
+}
+
 // Event represents a client or server event
 type Event struct {
 	Type        EventType `json:"type"`
@@ -61,6 +131,7 @@ type ServerEvent struct {
 	Data              []byte          `json:"data"`
 	JSCode            string          `json:"js_code,omitempty"`
 	ConfidencePercent int32           `json:"confidence_percent,omitempty"`
+	Solved            bool            `json:"solved,omitempty"`
 	Timestamp         time.Time       `json:"timestamp"`
 }
 
@@ -0,0 +1,165 @@
+package domain
+
+import (
+	"errors"
+	"time"
+)
+
+// ErrorKind classifies errors so every transport reports them the same way
+type ErrorKind string
+
+const (
+	ErrorKindNotFound     ErrorKind = "not_found"    // Challenge does not exist
+	ErrorKindExpired      ErrorKind = "expired"      // Challenge expired or was abandoned
+	ErrorKindExhausted    ErrorKind = "exhausted"    // No answer attempts left
+	ErrorKindPrecondition ErrorKind = "precondition" // Challenge cannot be answered this way
+	ErrorKindRateLimited  ErrorKind = "rate_limited" // Client exceeded its request rate
+	ErrorKindBlocked      ErrorKind = "blocked"      // Client is blocked or detected as a bot
+	ErrorKindCapacity     ErrorKind = "capacity"     // Service is at its challenge capacity
+	ErrorKindInternal     ErrorKind = "internal"     // Unexpected failure inside the service
+)
+
+// Machine readable error reasons, stable across transports
+const (
+	ReasonChallengeNotFound  = "CHALLENGE_NOT_FOUND"
+	ReasonChallengeExpired   = "CHALLENGE_EXPIRED"
+	ReasonChallengeAbandoned = "CHALLENGE_ABANDONED"
+	ReasonChallengeConsumed  = "CHALLENGE_CONSUMED"
+	ReasonAttemptsExhausted  = "ATTEMPTS_EXHAUSTED"
+	ReasonStagesRequired     = "STAGES_REQUIRED"
+	ReasonRateLimited        = "RATE_LIMITED"
+	ReasonIPBlocked          = "IP_BLOCKED"
+	ReasonBotDetected        = "BOT_DETECTED"
+	ReasonCapacityReached    = "CAPACITY_REACHED"
+	ReasonMemoryPressure     = "MEMORY_PRESSURE"
+	ReasonUnknownResult      = "UNKNOWN_RESULT"
+	ReasonSessionExpired     = "SESSION_EXPIRED"
+)
+
+// ErrorDomain identifies the service in structured error details
+const ErrorDomain = "captcha.steelmount"
+
+// Sentinel errors to match kinds with errors.Is
+var (
+	ErrNotFound     = &Error{Kind: ErrorKindNotFound}
+	ErrExpired      = &Error{Kind: ErrorKindExpired}
+	ErrExhausted    = &Error{Kind: ErrorKindExhausted}
+	ErrPrecondition = &Error{Kind: ErrorKindPrecondition}
+	ErrRateLimited  = &Error{Kind: ErrorKindRateLimited}
+	ErrBlocked      = &Error{Kind: ErrorKindBlocked}
+	ErrCapacity     = &Error{Kind: ErrorKindCapacity}
+	ErrInternal     = &Error{Kind: ErrorKindInternal}
+)
+
+// Error is a classified service error
+type Error struct {
+	Kind         ErrorKind
+	Reason       string                 // One of the Reason constants
+	Message      string                 // Human readable description
+	RetryAfter   time.Duration          // When retrying makes sense, zero otherwise
+	Quota        *QuotaViolation        // Limit that was hit, if any
+	Precondition *PreconditionViolation // Unmet requirement, if any
+	Metadata     map[string]string      // Extra context such as the challenge ID
+}
+
+// QuotaViolation describes an exceeded limit
+type QuotaViolation struct {
+	Subject     string // What the limit applies to, e.g. "ip:1.2.3.4"
+	Description string
+}
+
+// PreconditionViolation describes a requirement the request did not meet
+type PreconditionViolation struct {
+	Type        string // Kind of requirement, e.g. "EVENT_STREAM"
+	Subject     string // What the requirement applies to, e.g. "challenge:<id>"
+	Description string
+}
+
+// NewError creates a classified error
+func NewError(kind ErrorKind, reason, message string) *Error {
+	return &Error{Kind: kind, Reason: reason, Message: message}
+}
+
+// Error implements the error interface
+func (e *Error) Error() string {
+	if e.Message != "" {
+		return e.Message
+	}
+	return string(e.Kind)
+}
+
+// Is matches errors of the same kind, so errors.Is(err, ErrNotFound) works for any not found error
+func (e *Error) Is(target error) bool {
+	t, ok := target.(*Error)
+	if !ok {
+		return false
+	}
+	return t.Kind == e.Kind && (t.Reason == "" || t.Reason == e.Reason)
+}
+
+// WithRetryAfter sets the retry delay
+func (e *Error) WithRetryAfter(delay time.Duration) *Error {
+	e.RetryAfter = delay
+	return e
+}
+
+// WithQuota sets the exceeded limit
+func (e *Error) WithQuota(subject, description string) *Error {
+	e.Quota = &QuotaViolation{Subject: subject, Description: description}
+	return e
+}
+
+// WithPrecondition sets the unmet requirement
+func (e *Error) WithPrecondition(violationType, subject, description string) *Error {
+	e.Precondition = &PreconditionViolation{Type: violationType, Subject: subject, Description: description}
+	return e
+}
+
+// WithMetadata adds context to the error
+func (e *Error) WithMetadata(key, value string) *Error {
+	if e.Metadata == nil {
+		e.Metadata = make(map[string]string)
+	}
+	e.Metadata[key] = value
+	return e
+}
+
+// AsError extracts a classified error from an error chain
+func AsError(err error) (*Error, bool) {
+	var domainErr *Error
+	if errors.As(err, &domainErr) {
+		return domainErr, true
+	}
+	return nil, false
+}
+
+// ExpiredError reports a challenge past its expiry time
+func ExpiredError(challengeID string) *Error {
+	return NewError(ErrorKindExpired, ReasonChallengeExpired, ResultErrorExpired).WithMetadata("challenge_id", challengeID)
+}
+
+// AbandonedError reports a challenge whose owner went away
+func AbandonedError(challengeID string) *Error {
+	return NewError(ErrorKindExpired, ReasonChallengeAbandoned, ResultErrorAbandoned).WithMetadata("challenge_id", challengeID)
+}
+
+// ConsumedError reports a solved challenge a backend already verified, one
+// solution vouches for one request
+func ConsumedError(challengeID string) *Error {
+	return NewError(ErrorKindExpired, ReasonChallengeConsumed, "challenge already verified").WithMetadata("challenge_id", challengeID)
+}
+
+// ExhaustedError reports a challenge that was failed and accepts no more answers
+func ExhaustedError(challengeID string) *Error {
+	return NewError(ErrorKindExhausted, ReasonAttemptsExhausted, ResultErrorExhausted).
+		WithQuota("challenge:"+challengeID, "answer attempts").
+		WithMetadata("challenge_id", challengeID)
+}
+
+// StagesRequiredError reports a single answer to a challenge that has to be
+// answered round by round over the event stream
+func StagesRequiredError(challengeID string) *Error {
+	return NewError(ErrorKindPrecondition, ReasonStagesRequired, ResultErrorStagesRequired).
+		WithPrecondition("EVENT_STREAM", "challenge:"+challengeID, "answer round by round over the event stream").
+		WithMetadata("challenge_id", challengeID)
+}
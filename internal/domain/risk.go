@@ -0,0 +1,54 @@
+package domain
+
+// RiskTier groups risk scores into the kinds of challenge auto mode issues
+type RiskTier string
+
+const (
+	RiskTierLow    RiskTier = "low"    // Quick single-interaction challenges
+	RiskTierMedium RiskTier = "medium" // Regular type selection by complexity
+	RiskTierHigh   RiskTier = "high"   // The hardest types over several rounds
+)
+
+// RiskPolicy maps a client's risk, 0 (trusted) to 1 (almost certainly
+// automated), to the challenge it gets in auto mode
+type RiskPolicy struct {
+	LowRisk        float64 // Risk below it is the low tier
+	HighRisk       float64 // Risk at or above it is the high tier
+	MinComplexity  int32   // Complexity at risk 0
+	MaxComplexity  int32   // Complexity at risk 1
+	HighRiskStages int     // Rounds required in the high tier
+}
+
+// DefaultRiskPolicy returns the default auto mode policy
+func DefaultRiskPolicy() RiskPolicy {
+	return RiskPolicy{
+		LowRisk:        0.3,
+		HighRisk:       0.6,
+		MinComplexity:  10,
+		MaxComplexity:  90,
+		HighRiskStages: 2,
+	}
+}
+
+// Tier returns the tier of risk
+func (p RiskPolicy) Tier(risk float64) RiskTier {
+	switch {
+	case risk < p.LowRisk:
+		return RiskTierLow
+	case risk >= p.HighRisk:
+		return RiskTierHigh
+	default:
+		return RiskTierMedium
+	}
+}
+
+// Complexity returns the complexity for risk, linear from MinComplexity to MaxComplexity
+func (p RiskPolicy) Complexity(risk float64) int32 {
+	if risk < 0 {
+		risk = 0
+	}
+	if risk > 1 {
+		risk = 1
+	}
+	return p.MinComplexity + int32(float64(p.MaxComplexity-p.MinComplexity)*risk+0.5)
+}
@@ -0,0 +1,189 @@
+package domain
+
+import (
+	"strconv"
+	"time"
+)
+
+// ChallengeStage is one round of a progressive challenge
+type ChallengeStage struct {
+	Index      int           `json:"index"`
+	Type       ChallengeType `json:"type"`
+	Complexity int32         `json:"complexity"`
+	Level      int32         `json:"level"`       // Generator level the complexity was calibrated to
+	Seed       int64         `json:"seed,string"` // Regenerates the stage content with Level
+	Answer     interface{}   `json:"-"`           // Hidden from JSON
+	StartedAt  time.Time     `json:"started_at"`
+	Confidence int32         `json:"confidence"`
+	Completed  bool          `json:"completed"`
+	Reason     string        `json:"reason,omitempty"` // Why the stage was pushed
+}
+
+// StagePolicy decides how a progressive challenge advances
+type StagePolicy struct {
+	MaxStages          int   // Total rounds including the first, 1 disables follow-ups
+	MinStages          int   // Rounds a challenge needs before it can pass, 0 or 1 lets the first pass
+	PassConfidence     int32 // A correct answer at or above this finishes the challenge
+	BorderlineMinimum  int32 // Answers at or above this earn a follow-up round
+	ComplexityIncrease int32 // Added to the complexity of every follow-up round
+}
+
+// DefaultStagePolicy returns the default progressive challenge policy
+func DefaultStagePolicy() StagePolicy {
+	return StagePolicy{
+		MaxStages:          3,
+		PassConfidence:     90,
+		BorderlineMinimum:  50,
+		ComplexityIncrease: 20,
+	}
+}
+
+// StageOutcome is the decision taken after a stage answer
+type StageOutcome string
+
+const (
+	StageOutcomePassed StageOutcome = "passed" // Challenge solved
+	StageOutcomeFailed StageOutcome = "failed" // Challenge failed
+	StageOutcomeNext   StageOutcome = "next"   // A follow-up round is pushed
+)
+
+// Stage reasons
+const (
+	StageReasonInitial    = "initial"
+	StageReasonBorderline = "borderline_answer"
+	StageReasonRequired   = "required_stage"     // The challenge needs more rounds than were answered
+	StageReasonEscalated  = "passive_escalation" // The passive probe did not clear the client
+)
+
+// CurrentStage returns the stage awaiting an answer, starting the stage
+// machine from the challenge itself on first use
+func (c *Challenge) CurrentStage() *ChallengeStage {
+	if len(c.Stages) == 0 {
+		c.Stages = []ChallengeStage{{
+			Index:      0,
+			Type:       c.Type,
+			Complexity: c.Complexity,
+			Level:      c.Level(),
+			Seed:       c.Seed(),
+			Answer:     c.Answer,
+			StartedAt:  c.CreatedAt,
+			Reason:     StageReasonInitial,
+		}}
+	}
+
+	return &c.Stages[len(c.Stages)-1]
+}
+
+// RecordStageAnswer completes the current stage and decides what happens next
+func (c *Challenge) RecordStageAnswer(valid bool, confidence int32, policy StagePolicy) StageOutcome {
+	stage := c.CurrentStage()
+	stage.Confidence = confidence
+	stage.Completed = true
+
+	if valid && confidence >= policy.PassConfidence {
+		if len(c.Stages) < policy.MinStages {
+			return StageOutcomeNext
+		}
+		c.Solved = true
+		return StageOutcomePassed
+	}
+
+	if confidence < policy.BorderlineMinimum {
+		return StageOutcomeFailed
+	}
+
+	if len(c.Stages) >= policy.MaxStages {
+		// Out of rounds, a correct but borderline final answer still counts
+		c.Solved = valid
+		if c.Solved {
+			return StageOutcomePassed
+		}
+		return StageOutcomeFailed
+	}
+
+	// Borderline answer, ask for another harder round
+	return StageOutcomeNext
+}
+
+// AddStage appends a follow-up stage, it becomes the current stage
+func (c *Challenge) AddStage(stage ChallengeStage) *ChallengeStage {
+	c.CurrentStage()
+	stage.Index = len(c.Stages)
+	if stage.StartedAt.IsZero() {
+		stage.StartedAt = time.Now()
+	}
+	c.Stages = append(c.Stages, stage)
+
+	return &c.Stages[len(c.Stages)-1]
+}
+
+// Seed returns the seed the challenge was generated from, 0 when none was recorded
+func (c *Challenge) Seed() int64 {
+	seed, err := strconv.ParseInt(c.Metadata["seed"], 10, 64)
+	if err != nil {
+		return 0
+	}
+	return seed
+}
+
+// Level returns the generator level the challenge was generated at, the
+// complexity when none was recorded
+func (c *Challenge) Level() int32 {
+	level, err := strconv.ParseInt(c.Metadata["level"], 10, 32)
+	if err != nil {
+		return c.Complexity
+	}
+	return int32(level)
+}
+
+// Attempts returns the single answers the challenge received
+func (c *Challenge) Attempts() int {
+	attempts, err := strconv.Atoi(c.Metadata["attempts"])
+	if err != nil {
+		return 0
+	}
+	return attempts
+}
+
+// Verified reports whether a backend already consumed the solved challenge
+func (c *Challenge) Verified() bool {
+	return c.Metadata["verified"] == "true"
+}
+
+// MinStages returns the rounds the challenge needs before it can pass, 0 when
+// none were recorded
+func (c *Challenge) MinStages() int {
+	stages, err := strconv.Atoi(c.Metadata["min_stages"])
+	if err != nil {
+		return 0
+	}
+	return stages
+}
+
+// StageConfidence returns the mean confidence over completed stages
+func (c *Challenge) StageConfidence() int32 {
+	var total int32
+	completed := 0
+	for _, stage := range c.Stages {
+		if stage.Completed {
+			total += stage.Confidence
+			completed++
+		}
+	}
+
+	if completed == 0 {
+		return 0
+	}
+	return total / int32(completed)
+}
+
+// StagesFinished reports whether the stage machine reached a final outcome
+func (c *Challenge) StagesFinished() bool {
+	return len(c.Stages) > 0 && c.Stages[len(c.Stages)-1].Completed
+}
+
+// StagesAnswered reports whether a stage was answered over the event stream,
+// from then on the stage machine alone takes answers
+func (c *Challenge) StagesAnswered() bool {
+	return len(c.Stages) > 0 && c.Stages[0].Completed
+}
@@ -1,9 +1,11 @@
 package monitoring
 
 import (
+	"context"
 	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // Metrics holds all Prometheus metrics
@@ -17,6 +19,7 @@ type Metrics struct {
 	CaptchaGenerated  *prometheus.CounterVec
 	CaptchaValidated  *prometheus.CounterVec
 	CaptchaErrors     *prometheus.CounterVec
+	CaptchaAbandoned  *prometheus.CounterVec
 	ActiveChallenges  prometheus.Gauge
 	ChallengeDuration *prometheus.HistogramVec
 
@@ -27,17 +30,35 @@ type Metrics struct {
 	BlockedIPs     prometheus.Gauge
 
 	// Performance metrics
-	MemoryUsage  prometheus.Gauge
-	CPUUsage     prometheus.Gauge
-	RPS          prometheus.Gauge
-	ResponseTime *prometheus.HistogramVec
+	MemoryUsage      prometheus.Gauge
+	MemoryLimit      prometheus.Gauge
+	MemoryRejections prometheus.Counter
+	CPUUsage         prometheus.Gauge
+	RPS              prometheus.Gauge
+	ResponseTime     *prometheus.HistogramVec
 
 	// WebSocket metrics
 	WebSocketConnections prometheus.Gauge
 	WebSocketEvents      *prometheus.CounterVec
 	WebSocketErrors      *prometheus.CounterVec
+	WebSocketQueueDepth  prometheus.Histogram
+	WebSocketDropped     *prometheus.CounterVec
+	WebSocketSlowClients prometheus.Counter
+
+	// gRPC stream metrics
+	GRPCStreamsActive      *prometheus.GaugeVec
+	GRPCStreamsTotal       *prometheus.CounterVec
+	GRPCStreamDuration     *prometheus.HistogramVec
+	GRPCStreamMessages     *prometheus.CounterVec
+	GRPCStreamEventLatency *prometheus.HistogramVec
+
+	// requestRate feeds the RPS gauge from recorded requests and stream events
+	requestRate *RateWindow
 }
 
+// rpsWindow is the sliding window of the requests per second gauge
+const rpsWindow = 10 * time.Second
+
 // NewMetrics creates a new metrics instance
 func NewMetrics() *Metrics {
 	return newMetricsWithRegistry(prometheus.DefaultRegisterer)
@@ -51,6 +72,8 @@ func NewMetricsWithRegistry(registry prometheus.Registerer) *Metrics {
 // newMetricsWithRegistry creates metrics with specified registry
 func newMetricsWithRegistry(registry prometheus.Registerer) *Metrics {
 	metrics := &Metrics{
+		requestRate: NewRateWindow(rpsWindow),
+
 		// Request metrics
 		RequestsTotal: prometheus.NewCounterVec(
 			prometheus.CounterOpts{
@@ -96,6 +119,13 @@ func newMetricsWithRegistry(registry prometheus.Registerer) *Metrics {
 			},
 			[]string{"type", "error"},
 		),
+		CaptchaAbandoned: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "captcha_abandoned_total",
+				Help: "Total number of challenges abandoned by their owner before solving",
+			},
+			[]string{"type", "reason"},
+		),
 		ActiveChallenges: prometheus.NewGauge(
 			prometheus.GaugeOpts{
 				Name: "captcha_active_challenges",
@@ -122,16 +152,16 @@ func newMetricsWithRegistry(registry prometheus.Registerer) *Metrics {
 		RateLimitHits: prometheus.NewCounterVec(
 			prometheus.CounterOpts{
 				Name: "captcha_rate_limit_hits_total",
-				Help: "Total number of rate limit hits",
+				Help: "Total number of rate limit hits by client network bucket",
 			},
-			[]string{"ip", "endpoint"},
+			[]string{"network", "endpoint"},
 		),
 		BotDetections: prometheus.NewCounterVec(
 			prometheus.CounterOpts{
 				Name: "captcha_bot_detections_total",
-				Help: "Total number of bot detections",
+				Help: "Total number of bot detections by client network bucket and dominant signal",
 			},
-			[]string{"ip", "reason"},
+			[]string{"network", "signal"},
 		),
 		BlockedIPs: prometheus.NewGauge(
 			prometheus.GaugeOpts{
@@ -144,19 +174,31 @@ func newMetricsWithRegistry(registry prometheus.Registerer) *Metrics {
 		MemoryUsage: prometheus.NewGauge(
 			prometheus.GaugeOpts{
 				Name: "captcha_memory_usage_bytes",
-				Help: "Current memory usage in bytes",
+				Help: "Resident memory of the process in bytes",
+			},
+		),
+		MemoryLimit: prometheus.NewGauge(
+			prometheus.GaugeOpts{
+				Name: "captcha_memory_limit_bytes",
+				Help: "Configured memory limit, new challenges are rejected near it",
+			},
+		),
+		MemoryRejections: prometheus.NewCounter(
+			prometheus.CounterOpts{
+				Name: "captcha_memory_rejections_total",
+				Help: "New challenges rejected because memory usage approached the limit",
 			},
 		),
 		CPUUsage: prometheus.NewGauge(
 			prometheus.GaugeOpts{
 				Name: "captcha_cpu_usage_percent",
-				Help: "Current CPU usage percentage",
+				Help: "Process CPU usage in percent of one core",
 			},
 		),
 		RPS: prometheus.NewGauge(
 			prometheus.GaugeOpts{
 				Name: "captcha_requests_per_second",
-				Help: "Current requests per second",
+				Help: "Requests and stream events per second over a 10 second window",
 			},
 		),
 		ResponseTime: prometheus.NewHistogramVec(
@@ -180,7 +222,7 @@ func newMetricsWithRegistry(registry prometheus.Registerer) *Metrics {
 				Name: "captcha_websocket_events_total",
 				Help: "Total number of WebSocket events",
 			},
-			[]string{"type", "client_id"},
+			[]string{"type"},
 		),
 		WebSocketErrors: prometheus.NewCounterVec(
 			prometheus.CounterOpts{
@@ -189,6 +231,65 @@ func newMetricsWithRegistry(registry prometheus.Registerer) *Metrics {
 			},
 			[]string{"type", "error"},
 		),
+		WebSocketQueueDepth: prometheus.NewHistogram(
+			prometheus.HistogramOpts{
+				Name:    "captcha_websocket_send_queue_depth",
+				Help:    "Per-connection send queue depth after enqueue",
+				Buckets: []float64{1, 2, 5, 10, 25, 50, 100, 250, 500, 1000},
+			},
+		),
+		WebSocketDropped: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "captcha_websocket_dropped_events_total",
+				Help: "Total number of WebSocket events dropped because of full send queues",
+			},
+			[]string{"type", "policy"},
+		),
+		WebSocketSlowClients: prometheus.NewCounter(
+			prometheus.CounterOpts{
+				Name: "captcha_websocket_slow_consumer_disconnects_total",
+				Help: "Total number of WebSocket connections closed as slow consumers",
+			},
+		),
+
+		// gRPC stream metrics
+		GRPCStreamsActive: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "captcha_grpc_streams_active",
+				Help: "Number of open gRPC streams",
+			},
+			[]string{"method"},
+		),
+		GRPCStreamsTotal: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "captcha_grpc_streams_total",
+				Help: "Total number of finished gRPC streams by terminal status code",
+			},
+			[]string{"method", "code"},
+		),
+		GRPCStreamDuration: prometheus.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Name:    "captcha_grpc_stream_duration_seconds",
+				Help:    "Lifetime of gRPC streams in seconds",
+				Buckets: []float64{0.1, 0.5, 1, 5, 15, 30, 60, 120, 300, 600, 1800},
+			},
+			[]string{"method", "code"},
+		),
+		GRPCStreamMessages: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "captcha_grpc_stream_messages_total",
+				Help: "Total number of gRPC stream messages by direction and event type",
+			},
+			[]string{"method", "direction", "type"},
+		),
+		GRPCStreamEventLatency: prometheus.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Name:    "captcha_grpc_stream_event_duration_seconds",
+				Help:    "Time from receiving a client stream event until its replies are sent",
+				Buckets: []float64{0.0005, 0.001, 0.0025, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1},
+			},
+			[]string{"type", "outcome"},
+		),
 	}
 
 	// Register all metrics with the registry
@@ -199,6 +300,7 @@ func newMetricsWithRegistry(registry prometheus.Registerer) *Metrics {
 		metrics.CaptchaGenerated,
 		metrics.CaptchaValidated,
 		metrics.CaptchaErrors,
+		metrics.CaptchaAbandoned,
 		metrics.ActiveChallenges,
 		metrics.ChallengeDuration,
 		metrics.SecurityBlocks,
@@ -206,21 +308,37 @@ func newMetricsWithRegistry(registry prometheus.Registerer) *Metrics {
 		metrics.BotDetections,
 		metrics.BlockedIPs,
 		metrics.MemoryUsage,
+		metrics.MemoryLimit,
+		metrics.MemoryRejections,
 		metrics.CPUUsage,
 		metrics.RPS,
 		metrics.ResponseTime,
 		metrics.WebSocketConnections,
 		metrics.WebSocketEvents,
 		metrics.WebSocketErrors,
+		metrics.WebSocketQueueDepth,
+		metrics.WebSocketDropped,
+		metrics.WebSocketSlowClients,
+		metrics.GRPCStreamsActive,
+		metrics.GRPCStreamsTotal,
+		metrics.GRPCStreamDuration,
+		metrics.GRPCStreamMessages,
+		metrics.GRPCStreamEventLatency,
 	)
 
 	return metrics
 }
 
-// RecordRequest records a request metric
-func (m *Metrics) RecordRequest(method, endpoint, status string, duration time.Duration) {
+// RecordRequest records a request metric, a sampled span in ctx becomes the exemplar
+func (m *Metrics) RecordRequest(ctx context.Context, method, endpoint, status string, duration time.Duration) {
 	m.RequestsTotal.WithLabelValues(method, endpoint, status).Inc()
-	m.RequestDuration.WithLabelValues(method, endpoint).Observe(duration.Seconds())
+	observeWithExemplar(ctx, m.RequestDuration.WithLabelValues(method, endpoint), duration)
+	m.requestRate.Observe()
+}
+
+// RequestRate returns requests per second over the RPS window
+func (m *Metrics) RequestRate() float64 {
+	return m.requestRate.Rate()
 }
 
 // RecordCaptchaGenerated records a captcha generation
@@ -238,6 +356,11 @@ func (m *Metrics) RecordCaptchaError(captchaType, errorType string) {
 	m.CaptchaErrors.WithLabelValues(captchaType, errorType).Inc()
 }
 
+// RecordCaptchaAbandoned records a challenge abandoned by its owner
+func (m *Metrics) RecordCaptchaAbandoned(captchaType, reason string) {
+	m.CaptchaAbandoned.WithLabelValues(captchaType, reason).Inc()
+}
+
 // SetActiveChallenges sets the number of active challenges
 func (m *Metrics) SetActiveChallenges(count int) {
 	m.ActiveChallenges.Set(float64(count))
@@ -253,14 +376,16 @@ func (m *Metrics) RecordSecurityBlock(reason, blockType string) {
 	m.SecurityBlocks.WithLabelValues(reason, blockType).Inc()
 }
 
-// RecordRateLimitHit records a rate limit hit
-func (m *Metrics) RecordRateLimitHit(ip, endpoint string) {
-	m.RateLimitHits.WithLabelValues(ip, endpoint).Inc()
+// RecordRateLimitHit records a rate limit hit, network is a bucket from
+// NetworkClassifier and never a raw address
+func (m *Metrics) RecordRateLimitHit(network, endpoint string) {
+	m.RateLimitHits.WithLabelValues(network, endpoint).Inc()
 }
 
-// RecordBotDetection records a bot detection
-func (m *Metrics) RecordBotDetection(ip, reason string) {
-	m.BotDetections.WithLabelValues(ip, reason).Inc()
+// RecordBotDetection records a bot detection, network is a bucket from
+// NetworkClassifier and signal one of the security.Signal constants
+func (m *Metrics) RecordBotDetection(network, signal string) {
+	m.BotDetections.WithLabelValues(network, signal).Inc()
 }
 
 // SetBlockedIPs sets the number of blocked IPs
@@ -273,6 +398,16 @@ func (m *Metrics) SetMemoryUsage(bytes int64) {
 	m.MemoryUsage.Set(float64(bytes))
 }
 
+// SetMemoryLimit sets the enforced memory limit
+func (m *Metrics) SetMemoryLimit(bytes int64) {
+	m.MemoryLimit.Set(float64(bytes))
+}
+
+// RecordMemoryRejection records a challenge rejected under memory pressure
+func (m *Metrics) RecordMemoryRejection() {
+	m.MemoryRejections.Inc()
+}
+
 // SetCPUUsage sets CPU usage
 func (m *Metrics) SetCPUUsage(percent float64) {
 	m.CPUUsage.Set(percent)
@@ -284,8 +419,8 @@ func (m *Metrics) SetRPS(rps float64) {
 }
 
 // RecordResponseTime records response time
-func (m *Metrics) RecordResponseTime(endpoint string, duration time.Duration) {
-	m.ResponseTime.WithLabelValues(endpoint).Observe(duration.Seconds())
+func (m *Metrics) RecordResponseTime(ctx context.Context, endpoint string, duration time.Duration) {
+	observeWithExemplar(ctx, m.ResponseTime.WithLabelValues(endpoint), duration)
 }
 
 // SetWebSocketConnections sets WebSocket connections count
@@ -294,11 +429,63 @@ func (m *Metrics) SetWebSocketConnections(count int) {
 }
 
 // RecordWebSocketEvent records a WebSocket event
-func (m *Metrics) RecordWebSocketEvent(eventType, clientID string) {
-	m.WebSocketEvents.WithLabelValues(eventType, clientID).Inc()
+func (m *Metrics) RecordWebSocketEvent(eventType string) {
+	m.WebSocketEvents.WithLabelValues(eventType).Inc()
 }
 
 // RecordWebSocketError records a WebSocket error
 func (m *Metrics) RecordWebSocketError(eventType, errorType string) {
 	m.WebSocketErrors.WithLabelValues(eventType, errorType).Inc()
 }
+
+// RecordWebSocketQueueDepth records a send queue depth sample
+func (m *Metrics) RecordWebSocketQueueDepth(depth int) {
+	m.WebSocketQueueDepth.Observe(float64(depth))
+}
+
+// RecordWebSocketDroppedEvent records an event dropped by the slow consumer policy
+func (m *Metrics) RecordWebSocketDroppedEvent(eventType, policy string) {
+	m.WebSocketDropped.WithLabelValues(eventType, policy).Inc()
+}
+
+// RecordWebSocketSlowConsumer records a slow consumer disconnect
+func (m *Metrics) RecordWebSocketSlowConsumer() {
+	m.WebSocketSlowClients.Inc()
+}
+
+// RecordGRPCStreamStarted records an opened gRPC stream
+func (m *Metrics) RecordGRPCStreamStarted(method string) {
+	m.GRPCStreamsActive.WithLabelValues(method).Inc()
+}
+
+// RecordGRPCStreamFinished records a finished gRPC stream with its terminal status code
+func (m *Metrics) RecordGRPCStreamFinished(ctx context.Context, method, code string, duration time.Duration) {
+	m.GRPCStreamsActive.WithLabelValues(method).Dec()
+	m.GRPCStreamsTotal.WithLabelValues(method, code).Inc()
+	observeWithExemplar(ctx, m.GRPCStreamDuration.WithLabelValues(method, code), duration)
+}
+
+// RecordGRPCStreamMessage records a message received ("in") or sent ("out") on a gRPC stream
+func (m *Metrics) RecordGRPCStreamMessage(method, direction, eventType string) {
+	m.GRPCStreamMessages.WithLabelValues(method, direction, eventType).Inc()
+}
+
+// RecordStreamEventLatency records how long a client stream event took to handle
+func (m *Metrics) RecordStreamEventLatency(ctx context.Context, eventType, outcome string, duration time.Duration) {
+	observeWithExemplar(ctx, m.GRPCStreamEventLatency.WithLabelValues(eventType, outcome), duration)
+	m.requestRate.Observe()
+}
+
+// observeWithExemplar observes a duration in seconds, attaching the trace ID of
+// a sampled span in ctx as an exemplar so dashboards can link to the trace
+func observeWithExemplar(ctx context.Context, observer prometheus.Observer, duration time.Duration) {
+	if ctx != nil {
+		if spanContext := trace.SpanContextFromContext(ctx); spanContext.IsSampled() {
+			if exemplarObserver, ok := observer.(prometheus.ExemplarObserver); ok {
+				exemplarObserver.ObserveWithExemplar(duration.Seconds(), prometheus.Labels{"trace_id": spanContext.TraceID().String()})
+				return
+			}
+		}
+	}
+	observer.Observe(duration.Seconds())
+}
@@ -6,6 +6,7 @@ import (
 	"time"
 
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
 )
 
 // MetricsMiddleware provides middleware for collecting metrics
@@ -39,8 +40,8 @@ func (mm *MetricsMiddleware) HTTPMiddleware(next http.Handler) http.Handler {
 		duration := time.Since(start)
 		status := http.StatusText(wrapped.statusCode)
 
-		mm.metrics.RecordRequest(r.Method, r.URL.Path, status, duration)
-		mm.metrics.RecordResponseTime(r.URL.Path, duration)
+		mm.metrics.RecordRequest(r.Context(), r.Method, r.URL.Path, status, duration)
+		mm.metrics.RecordResponseTime(r.Context(), r.URL.Path, duration)
 	})
 }
 
@@ -63,13 +64,70 @@ func (mm *MetricsMiddleware) GRPCMetricsInterceptor() func(ctx context.Context,
 			status = "error"
 		}
 
-		mm.metrics.RecordRequest("grpc", info.FullMethod, status, duration)
-		mm.metrics.RecordResponseTime(info.FullMethod, duration)
+		mm.metrics.RecordRequest(ctx, "grpc", info.FullMethod, status, duration)
+		mm.metrics.RecordResponseTime(ctx, info.FullMethod, duration)
 
 		return resp, err
 	}
 }
 
+// MessageTypeFunc names the event type of a stream message for metric labels,
+// it must return a small fixed set of values
+type MessageTypeFunc func(msg interface{}) string
+
+// GRPCStreamMetricsInterceptor creates gRPC stream interceptor recording stream
+// lifetimes, messages in and out by event type and the terminal status code
+func (mm *MetricsMiddleware) GRPCStreamMetricsInterceptor(messageType MessageTypeFunc) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		start := time.Now()
+		mm.metrics.RecordGRPCStreamStarted(info.FullMethod)
+
+		err := handler(srv, &metricsServerStream{
+			ServerStream: ss,
+			metrics:      mm.metrics,
+			method:       info.FullMethod,
+			messageType:  messageType,
+		})
+
+		mm.metrics.RecordGRPCStreamFinished(ss.Context(), info.FullMethod, status.Code(err).String(), time.Since(start))
+		return err
+	}
+}
+
+// metricsServerStream counts messages passing through a server stream
+type metricsServerStream struct {
+	grpc.ServerStream
+	metrics     *Metrics
+	method      string
+	messageType MessageTypeFunc
+}
+
+// RecvMsg counts received messages
+func (s *metricsServerStream) RecvMsg(m interface{}) error {
+	if err := s.ServerStream.RecvMsg(m); err != nil {
+		return err
+	}
+	s.metrics.RecordGRPCStreamMessage(s.method, "in", s.typeOf(m))
+	return nil
+}
+
+// SendMsg counts sent messages
+func (s *metricsServerStream) SendMsg(m interface{}) error {
+	if err := s.ServerStream.SendMsg(m); err != nil {
+		return err
+	}
+	s.metrics.RecordGRPCStreamMessage(s.method, "out", s.typeOf(m))
+	return nil
+}
+
+// typeOf returns the event type label of a message
+func (s *metricsServerStream) typeOf(m interface{}) string {
+	if s.messageType == nil {
+		return "unknown"
+	}
+	return s.messageType(m)
+}
+
 // WebSocketMetricsInterceptor creates WebSocket interceptor for metrics collection
 func (mm *MetricsMiddleware) WebSocketMetricsInterceptor() func(next http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
@@ -85,7 +143,7 @@ func (mm *MetricsMiddleware) WebSocketMetricsInterceptor() func(next http.Handle
 
 			// Record WebSocket connection duration
 			duration := time.Since(start)
-			mm.metrics.RecordResponseTime("websocket", duration)
+			mm.metrics.RecordResponseTime(r.Context(), "websocket", duration)
 		})
 	}
 }
@@ -118,15 +176,16 @@ func (mm *MetricsMiddleware) RecordCaptchaMetrics(captchaType string, action str
 	}
 }
 
-// RecordSecurityMetrics records security-related metrics
+// RecordSecurityMetrics records security-related metrics, the IP is reduced
+// to its address class and reason must come from a fixed set
 func (mm *MetricsMiddleware) RecordSecurityMetrics(blockType, reason, ip, endpoint string) {
 	switch blockType {
 	case "block":
 		mm.metrics.RecordSecurityBlock(reason, "ip_block")
 	case "rate_limit":
-		mm.metrics.RecordRateLimitHit(ip, endpoint)
+		mm.metrics.RecordRateLimitHit(AddressClass(ip), endpoint)
 	case "bot_detection":
-		mm.metrics.RecordBotDetection(ip, reason)
+		mm.metrics.RecordBotDetection(AddressClass(ip), reason)
 	}
 }
 
@@ -146,8 +205,8 @@ func (mm *MetricsMiddleware) UpdateRPS(rps float64) {
 }
 
 // RecordWebSocketEvent records a WebSocket event
-func (mm *MetricsMiddleware) RecordWebSocketEvent(eventType, clientID string) {
-	mm.metrics.RecordWebSocketEvent(eventType, clientID)
+func (mm *MetricsMiddleware) RecordWebSocketEvent(eventType string) {
+	mm.metrics.RecordWebSocketEvent(eventType)
 }
 
 // RecordWebSocketError records a WebSocket error
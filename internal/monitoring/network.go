@@ -0,0 +1,105 @@
+package monitoring
+
+import (
+	"fmt"
+	"net/netip"
+	"sort"
+)
+
+// Address classes used as network buckets for addresses outside configured networks
+const (
+	NetworkInvalid    = "invalid"
+	NetworkLoopback   = "loopback"
+	NetworkPrivate    = "private"
+	NetworkPublicIPv4 = "public_ipv4"
+	NetworkPublicIPv6 = "public_ipv6"
+)
+
+// NetworkClassifier maps client IPs to a bounded set of network buckets, e.g.
+// an ASN or a country, so metrics can break attacks down by origin without a
+// series per address
+type NetworkClassifier struct {
+	networks []bucketNetwork // Longest prefix first
+}
+
+type bucketNetwork struct {
+	prefix netip.Prefix
+	bucket string
+}
+
+// NewNetworkClassifier creates a classifier from bucket names to CIDRs, the
+// longest matching prefix wins; addresses in no bucket fall back to AddressClass
+func NewNetworkClassifier(buckets map[string][]string) (*NetworkClassifier, error) {
+	classifier := &NetworkClassifier{}
+
+	for bucket, cidrs := range buckets {
+		if bucket == "" {
+			return nil, fmt.Errorf("network bucket name must not be empty")
+		}
+		for _, cidr := range cidrs {
+			prefix, err := netip.ParsePrefix(cidr)
+			if err != nil {
+				return nil, fmt.Errorf("invalid CIDR %q in network bucket %s: %w", cidr, bucket, err)
+			}
+			classifier.networks = append(classifier.networks, bucketNetwork{prefix: prefix.Masked(), bucket: bucket})
+		}
+	}
+
+	sort.SliceStable(classifier.networks, func(i, j int) bool {
+		return classifier.networks[i].prefix.Bits() > classifier.networks[j].prefix.Bits()
+	})
+
+	return classifier, nil
+}
+
+// Classify returns the network bucket of an IP, a linear scan meant for the
+// few dozen prefixes an operator maps by hand
+func (nc *NetworkClassifier) Classify(ip string) string {
+	addr, ok := parseAddr(ip)
+	if !ok {
+		return NetworkInvalid
+	}
+
+	for _, network := range nc.networks {
+		if network.prefix.Contains(addr) {
+			return network.bucket
+		}
+	}
+
+	return classifyAddr(addr)
+}
+
+// AddressClass returns the address class bucket of an IP, one of the Network constants
+func AddressClass(ip string) string {
+	addr, ok := parseAddr(ip)
+	if !ok {
+		return NetworkInvalid
+	}
+	return classifyAddr(addr)
+}
+
+// parseAddr parses an IP with or without a port, IPv4-mapped IPv6 is unmapped
+func parseAddr(ip string) (netip.Addr, bool) {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		addrPort, portErr := netip.ParseAddrPort(ip)
+		if portErr != nil {
+			return netip.Addr{}, false
+		}
+		addr = addrPort.Addr()
+	}
+	return addr.Unmap(), true
+}
+
+func classifyAddr(addr netip.Addr) string {
+	switch {
+	case addr.IsLoopback():
+		return NetworkLoopback
+	case addr.IsPrivate(), addr.IsLinkLocalUnicast():
+		return NetworkPrivate
+	case addr.Is4():
+		return NetworkPublicIPv4
+	default:
+		return NetworkPublicIPv6
+	}
+}
@@ -0,0 +1,311 @@
+package monitoring
+
+import (
+	"container/heap"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Offense categories tracked by OffenderTracker
+const (
+	OffenseBlocked     = "blocked"
+	OffenseRateLimited = "rate_limited"
+	OffenseBot         = "bot"
+)
+
+// defaultTopOffenders is the number of offenders reported when no limit is requested
+const defaultTopOffenders = 20
+
+// OffenderConfig contains top offender tracking settings
+type OffenderConfig struct {
+	Capacity int           // Counters kept per category, bounds memory regardless of attacker count
+	Window   time.Duration // Counts restart every window, the previous one stays readable
+}
+
+// DefaultOffenderConfig returns default offender tracking configuration
+func DefaultOffenderConfig() *OffenderConfig {
+	return &OffenderConfig{
+		Capacity: 100,
+		Window:   10 * time.Minute,
+	}
+}
+
+// Offender is an estimated offense count of one key, usually an IP. The space
+// saving sketch never undercounts: the true count lies in [Count-Error, Count]
+type Offender struct {
+	Key   string `json:"key"`
+	Count int64  `json:"count"`
+	Error int64  `json:"error"`
+}
+
+// OffenderWindow is the top offenders of one window per category
+type OffenderWindow struct {
+	Start      time.Time             `json:"start"`
+	Categories map[string][]Offender `json:"categories"`
+}
+
+// OffenderSnapshot is the top offenders of the current and the previous window
+type OffenderSnapshot struct {
+	WindowSeconds float64         `json:"window_seconds"`
+	Current       *OffenderWindow `json:"current"`
+	Previous      *OffenderWindow `json:"previous,omitempty"`
+}
+
+// OffenderTracker keeps the heaviest offenders per category in fixed memory
+// with the space saving algorithm, so raw IPs never reach metric labels
+type OffenderTracker struct {
+	config *OffenderConfig
+	now    func() time.Time
+
+	mu            sync.Mutex
+	current       map[string]*spaceSaving
+	previous      map[string]*spaceSaving
+	currentStart  time.Time
+	previousStart time.Time
+}
+
+// NewOffenderTracker creates an offender tracker with default configuration
+func NewOffenderTracker() *OffenderTracker {
+	return NewOffenderTrackerWithConfig(DefaultOffenderConfig())
+}
+
+// NewOffenderTrackerWithConfig creates an offender tracker with custom configuration
+func NewOffenderTrackerWithConfig(config *OffenderConfig) *OffenderTracker {
+	return NewOffenderTrackerWithClock(config, time.Now)
+}
+
+// NewOffenderTrackerWithClock creates an offender tracker reading the time from now
+func NewOffenderTrackerWithClock(config *OffenderConfig, now func() time.Time) *OffenderTracker {
+	if config == nil {
+		config = DefaultOffenderConfig()
+	}
+	if config.Capacity <= 0 {
+		config.Capacity = DefaultOffenderConfig().Capacity
+	}
+	if config.Window <= 0 {
+		config.Window = DefaultOffenderConfig().Window
+	}
+
+	return &OffenderTracker{
+		config:       config,
+		now:          now,
+		current:      make(map[string]*spaceSaving),
+		currentStart: now(),
+	}
+}
+
+// Record counts one offense of key in category
+func (ot *OffenderTracker) Record(category, key string) {
+	ot.mu.Lock()
+	defer ot.mu.Unlock()
+
+	ot.rotate()
+
+	sketch, exists := ot.current[category]
+	if !exists {
+		sketch = newSpaceSaving(ot.config.Capacity)
+		ot.current[category] = sketch
+	}
+	sketch.add(key)
+}
+
+// Top returns up to n offenders of category in the current window, heaviest first
+func (ot *OffenderTracker) Top(category string, n int) []Offender {
+	ot.mu.Lock()
+	defer ot.mu.Unlock()
+
+	ot.rotate()
+
+	sketch, exists := ot.current[category]
+	if !exists {
+		return []Offender{}
+	}
+	return sketch.top(n)
+}
+
+// Snapshot returns up to n offenders per category of the current and previous window
+func (ot *OffenderTracker) Snapshot(n int) *OffenderSnapshot {
+	ot.mu.Lock()
+	defer ot.mu.Unlock()
+
+	ot.rotate()
+
+	snapshot := &OffenderSnapshot{
+		WindowSeconds: ot.config.Window.Seconds(),
+		Current:       windowTop(ot.currentStart, ot.current, n),
+	}
+	if ot.previous != nil {
+		snapshot.Previous = windowTop(ot.previousStart, ot.previous, n)
+	}
+
+	return snapshot
+}
+
+// Handler serves the snapshot as JSON, ?limit= caps offenders per category
+func (ot *OffenderTracker) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		limit := defaultTopOffenders
+		if value := r.URL.Query().Get("limit"); value != "" {
+			parsed, err := strconv.Atoi(value)
+			if err != nil || parsed <= 0 {
+				http.Error(w, "Invalid limit", http.StatusBadRequest)
+				return
+			}
+			limit = parsed
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+
+		if err := json.NewEncoder(w).Encode(ot.Snapshot(limit)); err != nil {
+			http.Error(w, "Failed to encode offenders", http.StatusInternalServerError)
+		}
+	})
+}
+
+// GetStats returns offender tracker statistics
+func (ot *OffenderTracker) GetStats() map[string]interface{} {
+	ot.mu.Lock()
+	defer ot.mu.Unlock()
+
+	ot.rotate()
+
+	tracked := make(map[string]int, len(ot.current))
+	for category, sketch := range ot.current {
+		tracked[category] = len(sketch.entries)
+	}
+
+	return map[string]interface{}{
+		"capacity":       ot.config.Capacity,
+		"window_seconds": ot.config.Window.Seconds(),
+		"tracked":        tracked,
+	}
+}
+
+// rotate starts a new window once the current one has elapsed, callers hold mu
+func (ot *OffenderTracker) rotate() {
+	now := ot.now()
+	elapsed := now.Sub(ot.currentStart)
+	if elapsed < ot.config.Window {
+		return
+	}
+
+	// Without offenses for a whole window the previous one is empty too
+	if elapsed < 2*ot.config.Window {
+		ot.previous, ot.previousStart = ot.current, ot.currentStart
+	} else {
+		ot.previous, ot.previousStart = nil, time.Time{}
+	}
+	ot.current = make(map[string]*spaceSaving)
+	ot.currentStart = now
+}
+
+func windowTop(start time.Time, sketches map[string]*spaceSaving, n int) *OffenderWindow {
+	window := &OffenderWindow{
+		Start:      start,
+		Categories: make(map[string][]Offender, len(sketches)),
+	}
+	for category, sketch := range sketches {
+		window.Categories[category] = sketch.top(n)
+	}
+	return window
+}
+
+// spaceSaving is the space saving heavy hitter sketch (Metwally et al.): with
+// k counters every key occurring more than total/k times is guaranteed to be
+// tracked. When full, the smallest counter is taken over by the new key
+type spaceSaving struct {
+	capacity int
+	entries  counterHeap
+	index    map[string]*counter
+}
+
+type counter struct {
+	key   string
+	count int64
+	error int64
+	pos   int
+}
+
+func newSpaceSaving(capacity int) *spaceSaving {
+	return &spaceSaving{
+		capacity: capacity,
+		index:    make(map[string]*counter, capacity),
+	}
+}
+
+func (ss *spaceSaving) add(key string) {
+	if c, exists := ss.index[key]; exists {
+		c.count++
+		heap.Fix(&ss.entries, c.pos)
+		return
+	}
+
+	if len(ss.entries) < ss.capacity {
+		c := &counter{key: key, count: 1}
+		heap.Push(&ss.entries, c)
+		ss.index[key] = c
+		return
+	}
+
+	// Replace the minimum, the newcomer may have occurred up to min times unseen
+	c := ss.entries[0]
+	delete(ss.index, c.key)
+	c.key, c.error = key, c.count
+	c.count++
+	ss.index[key] = c
+	heap.Fix(&ss.entries, 0)
+}
+
+func (ss *spaceSaving) top(n int) []Offender {
+	offenders := make([]Offender, 0, len(ss.entries))
+	for _, c := range ss.entries {
+		offenders = append(offenders, Offender{Key: c.key, Count: c.count, Error: c.error})
+	}
+
+	sort.Slice(offenders, func(i, j int) bool {
+		if offenders[i].Count != offenders[j].Count {
+			return offenders[i].Count > offenders[j].Count
+		}
+		return offenders[i].Key < offenders[j].Key
+	})
+
+	if n > 0 && len(offenders) > n {
+		offenders = offenders[:n]
+	}
+	return offenders
+}
+
+// counterHeap is a min-heap of counters by count
+type counterHeap []*counter
+
+func (h counterHeap) Len() int           { return len(h) }
+func (h counterHeap) Less(i, j int) bool { return h[i].count < h[j].count }
+
+func (h counterHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].pos = i
+	h[j].pos = j
+}
+
+func (h *counterHeap) Push(x interface{}) {
+	c := x.(*counter)
+	c.pos = len(*h)
+	*h = append(*h, c)
+}
+
+func (h *counterHeap) Pop() interface{} {
+	old := *h
+	c := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return c
+}
@@ -0,0 +1,104 @@
+package monitoring
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+	runtimemetrics "runtime/metrics"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// clockTicksPerSecond is USER_HZ, the unit of CPU times in /proc, 100 on all supported Linux platforms
+const clockTicksPerSecond = 100
+
+// procStatPath is the process status file read for CPU time and resident memory
+const procStatPath = "/proc/self/stat"
+
+// ProcessStats is a point-in-time reading of process resource usage
+type ProcessStats struct {
+	CPUTime       time.Duration // User and system CPU time consumed since start
+	ResidentBytes int64         // Resident set size
+	Time          time.Time
+}
+
+// ReadProcessStats reads CPU time and resident memory of the current process from /proc
+func ReadProcessStats() (*ProcessStats, error) {
+	data, err := os.ReadFile(procStatPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", procStatPath, err)
+	}
+
+	return ParseProcStat(string(data), time.Now())
+}
+
+// ParseProcStat parses /proc/<pid>/stat, see proc(5)
+func ParseProcStat(stat string, now time.Time) (*ProcessStats, error) {
+	// The command name may contain spaces and parentheses, fields follow the last ')'
+	end := strings.LastIndexByte(stat, ')')
+	if end < 0 {
+		return nil, fmt.Errorf("malformed process stat")
+	}
+	fields := strings.Fields(stat[end+1:])
+
+	// fields[0] is field 3 (state): utime is 14, stime 15, rss 24
+	if len(fields) < 22 {
+		return nil, fmt.Errorf("malformed process stat: %d fields", len(fields))
+	}
+
+	utime, err := strconv.ParseInt(fields[11], 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid utime: %w", err)
+	}
+	stime, err := strconv.ParseInt(fields[12], 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid stime: %w", err)
+	}
+	rssPages, err := strconv.ParseInt(fields[21], 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid rss: %w", err)
+	}
+
+	return &ProcessStats{
+		CPUTime:       time.Duration(utime+stime) * time.Second / clockTicksPerSecond,
+		ResidentBytes: rssPages * int64(os.Getpagesize()),
+		Time:          now,
+	}, nil
+}
+
+// CPUPercent returns the CPU usage between two readings as a percentage of
+// one core, like top; a process saturating four cores reports 400
+func CPUPercent(previous, current *ProcessStats) float64 {
+	if previous == nil || current == nil {
+		return 0
+	}
+
+	wall := current.Time.Sub(previous.Time)
+	if wall <= 0 {
+		return 0
+	}
+
+	return float64(current.CPUTime-previous.CPUTime) / float64(wall) * 100
+}
+
+// runtimeMemoryBytes returns memory obtained by the Go runtime and not returned
+// to the OS, used where /proc is unavailable
+func runtimeMemoryBytes() int64 {
+	samples := []runtimemetrics.Sample{
+		{Name: "/memory/classes/total:bytes"},
+		{Name: "/memory/classes/heap/released:bytes"},
+	}
+	runtimemetrics.Read(samples)
+
+	for _, sample := range samples {
+		if sample.Value.Kind() != runtimemetrics.KindUint64 {
+			// Unsupported by this runtime, fall back to MemStats
+			var m runtime.MemStats
+			runtime.ReadMemStats(&m)
+			return int64(m.Sys - m.HeapReleased)
+		}
+	}
+
+	return int64(samples[0].Value.Uint64() - samples[1].Value.Uint64())
+}
@@ -7,30 +7,49 @@ import (
 	"runtime"
 	"time"
 
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
 // PrometheusServer provides Prometheus metrics server
 type PrometheusServer struct {
-	server  *http.Server
-	port    int
-	metrics *Metrics
+	server   *http.Server
+	port     int
+	metrics  *Metrics
+	gatherer prometheus.Gatherer
+	handlers map[string]http.Handler
 }
 
-// NewPrometheusServer creates a new Prometheus server
+// NewPrometheusServer creates a Prometheus server serving the default registry
 func NewPrometheusServer(port int, metrics *Metrics) *PrometheusServer {
+	return NewPrometheusServerWithRegistry(port, metrics, prometheus.DefaultGatherer)
+}
+
+// NewPrometheusServerWithRegistry creates a Prometheus server serving the given
+// registry, the one the metrics were registered with
+func NewPrometheusServerWithRegistry(port int, metrics *Metrics, gatherer prometheus.Gatherer) *PrometheusServer {
 	return &PrometheusServer{
-		port:    port,
-		metrics: metrics,
+		port:     port,
+		metrics:  metrics,
+		gatherer: gatherer,
+		handlers: make(map[string]http.Handler),
 	}
 }
 
-// Start starts the Prometheus server
-func (ps *PrometheusServer) Start(ctx context.Context) error {
+// Handle adds an endpoint next to the metrics, must be called before Start
+func (ps *PrometheusServer) Handle(pattern string, handler http.Handler) {
+	ps.handlers[pattern] = handler
+}
+
+// Handler returns the HTTP handler of the metrics endpoints
+func (ps *PrometheusServer) Handler() http.Handler {
 	mux := http.NewServeMux()
 
-	// Metrics endpoint
-	mux.Handle("/metrics", promhttp.Handler())
+	// Metrics endpoint, OpenMetrics is negotiated via Accept and carries exemplars
+	mux.Handle("/metrics", promhttp.HandlerFor(ps.gatherer, promhttp.HandlerOpts{
+		EnableOpenMetrics: true,
+		ErrorHandling:     promhttp.ContinueOnError,
+	}))
 
 	// Health check endpoint
 	mux.HandleFunc("/health", ps.healthHandler)
@@ -38,14 +57,20 @@ func (ps *PrometheusServer) Start(ctx context.Context) error {
 	// Custom metrics endpoint
 	mux.HandleFunc("/custom-metrics", ps.customMetricsHandler)
 
+	for pattern, handler := range ps.handlers {
+		mux.Handle(pattern, handler)
+	}
+
+	return mux
+}
+
+// Start starts the Prometheus server
+func (ps *PrometheusServer) Start(ctx context.Context) error {
 	ps.server = &http.Server{
 		Addr:    fmt.Sprintf(":%d", ps.port),
-		Handler: mux,
+		Handler: ps.Handler(),
 	}
 
-	// Start metrics collection
-	go ps.collectSystemMetrics(ctx)
-
 	// Start server in goroutine
 	go func() {
 		if err := ps.server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
@@ -101,31 +126,3 @@ func (ps *PrometheusServer) customMetricsHandler(w http.ResponseWriter, r *http.
 		metrics["memory_usage_bytes"], metrics["memory_sys_bytes"],
 		metrics["gc_runs"], metrics["goroutines"], metrics["timestamp"])
 }
-
-// collectSystemMetrics collects system metrics periodically
-func (ps *PrometheusServer) collectSystemMetrics(ctx context.Context) {
-	ticker := time.NewTicker(5 * time.Second)
-	defer ticker.Stop()
-
-	for {
-		select {
-		case <-ctx.Done():
-			return
-		case <-ticker.C:
-			ps.updateSystemMetrics()
-		}
-	}
-}
-
-// updateSystemMetrics updates system metrics
-func (ps *PrometheusServer) updateSystemMetrics() {
-	var m runtime.MemStats
-	runtime.ReadMemStats(&m)
-
-	// Update memory usage
-	ps.metrics.SetMemoryUsage(int64(m.Alloc))
-
-	// Update CPU usage (simplified)
-	cpuUsage := float64(runtime.NumGoroutine()) * 0.1 // Rough estimate
-	ps.metrics.SetCPUUsage(cpuUsage)
-}
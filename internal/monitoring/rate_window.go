@@ -0,0 +1,73 @@
+package monitoring
+
+import (
+	"sync"
+	"time"
+)
+
+// RateWindow counts events over a sliding window of one-second buckets
+type RateWindow struct {
+	mu      sync.Mutex
+	buckets []int64
+	seconds []int64 // Unix second each bucket was last reset for
+	now     func() time.Time
+}
+
+// NewRateWindow creates a rate window spanning the given duration, at least one second
+func NewRateWindow(window time.Duration) *RateWindow {
+	return NewRateWindowWithClock(window, time.Now)
+}
+
+// NewRateWindowWithClock creates a rate window reading the time from now
+func NewRateWindowWithClock(window time.Duration, now func() time.Time) *RateWindow {
+	size := int(window / time.Second)
+	if size < 1 {
+		size = 1
+	}
+
+	// One extra bucket holds the current partial second
+	return &RateWindow{
+		buckets: make([]int64, size+1),
+		seconds: make([]int64, size+1),
+		now:     now,
+	}
+}
+
+// Observe counts one event at the current time
+func (rw *RateWindow) Observe() {
+	rw.Add(1)
+}
+
+// Add counts n events at the current time
+func (rw *RateWindow) Add(n int64) {
+	second := rw.now().Unix()
+	index := int(second % int64(len(rw.buckets)))
+
+	rw.mu.Lock()
+	if rw.seconds[index] != second {
+		rw.seconds[index] = second
+		rw.buckets[index] = 0
+	}
+	rw.buckets[index] += n
+	rw.mu.Unlock()
+}
+
+// Rate returns events per second over the completed seconds of the window,
+// the current partial second is excluded so the rate does not dip at its start
+func (rw *RateWindow) Rate() float64 {
+	size := len(rw.buckets) - 1
+	current := rw.now().Unix()
+	oldest := current - int64(size)
+
+	rw.mu.Lock()
+	defer rw.mu.Unlock()
+
+	var total int64
+	for i, second := range rw.seconds {
+		if second >= oldest && second < current {
+			total += rw.buckets[i]
+		}
+	}
+
+	return float64(total) / float64(size)
+}
@@ -0,0 +1,44 @@
+package monitoring
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+)
+
+// InstanceLabelName is the constant label identifying the service instance
+const InstanceLabelName = "instance_id"
+
+// labelNamePattern matches valid Prometheus label names
+var labelNamePattern = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+// InstanceLabels returns the constant labels of every metric of an instance,
+// extra labels such as region or zone are added next to instance_id
+func InstanceLabels(instanceID string, extra map[string]string) (prometheus.Labels, error) {
+	labels := prometheus.Labels{InstanceLabelName: instanceID}
+	for name, value := range extra {
+		if !labelNamePattern.MatchString(name) || strings.HasPrefix(name, "__") {
+			return nil, fmt.Errorf("invalid constant label name: %q", name)
+		}
+		if name == InstanceLabelName {
+			return nil, fmt.Errorf("constant label %s is set from the instance ID", InstanceLabelName)
+		}
+		labels[name] = value
+	}
+	return labels, nil
+}
+
+// RegisterRuntimeCollectors registers the Go runtime and process collectors,
+// which a custom registry does not include by default
+func RegisterRuntimeCollectors(registry prometheus.Registerer) error {
+	if err := registry.Register(collectors.NewGoCollector()); err != nil {
+		return fmt.Errorf("failed to register Go collector: %w", err)
+	}
+	if err := registry.Register(collectors.NewProcessCollector(collectors.ProcessCollectorOpts{})); err != nil {
+		return fmt.Errorf("failed to register process collector: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,173 @@
+package monitoring
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"runtime/debug"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/FlooooowY/SteelMount-Captcha-Service/internal/domain"
+)
+
+// memoryRetryAfter is suggested to clients rejected under memory pressure
+const memoryRetryAfter = 5 * time.Second
+
+// ResourceMonitorConfig contains resource sampling and memory enforcement settings
+type ResourceMonitorConfig struct {
+	Interval         time.Duration // Sampling interval
+	MemoryLimitBytes int64         // Memory limit, 0 disables enforcement
+	HighWatermark    float64       // Fraction of the limit at which new challenges are rejected
+	SetGoMemoryLimit bool          // Also set the Go soft memory limit so the GC works harder near it
+}
+
+// DefaultResourceMonitorConfig returns default resource monitor configuration
+func DefaultResourceMonitorConfig() *ResourceMonitorConfig {
+	return &ResourceMonitorConfig{
+		Interval:         time.Second,
+		HighWatermark:    0.9,
+		SetGoMemoryLimit: true,
+	}
+}
+
+// ResourceMonitor samples process CPU, memory and request rate into metrics
+// and rejects new challenges when memory approaches the configured limit
+type ResourceMonitor struct {
+	config  *ResourceMonitorConfig
+	metrics *Metrics
+	logger  *logrus.Logger
+
+	mu         sync.Mutex
+	last       *ProcessStats
+	procFailed bool // /proc is unavailable, memory falls back to runtime accounting
+
+	memoryBytes atomic.Int64
+	cpuPercent  atomic.Uint64 // math.Float64bits
+	rejections  atomic.Int64
+}
+
+// NewResourceMonitor creates a resource monitor with default configuration
+func NewResourceMonitor(metrics *Metrics) *ResourceMonitor {
+	return NewResourceMonitorWithConfig(metrics, DefaultResourceMonitorConfig())
+}
+
+// NewResourceMonitorWithConfig creates a resource monitor with custom configuration
+func NewResourceMonitorWithConfig(metrics *Metrics, config *ResourceMonitorConfig) *ResourceMonitor {
+	if config == nil {
+		config = DefaultResourceMonitorConfig()
+	}
+	if config.Interval <= 0 {
+		config.Interval = time.Second
+	}
+	if config.HighWatermark <= 0 || config.HighWatermark > 1 {
+		config.HighWatermark = 0.9
+	}
+
+	rm := &ResourceMonitor{
+		config:  config,
+		metrics: metrics,
+		logger:  logrus.New(),
+	}
+	if config.MemoryLimitBytes > 0 {
+		metrics.SetMemoryLimit(config.MemoryLimitBytes)
+	}
+
+	return rm
+}
+
+// Start samples resources until the context is cancelled
+func (rm *ResourceMonitor) Start(ctx context.Context) {
+	if rm.config.SetGoMemoryLimit && rm.config.MemoryLimitBytes > 0 {
+		debug.SetMemoryLimit(rm.config.MemoryLimitBytes)
+	}
+
+	rm.Sample()
+
+	go func() {
+		ticker := time.NewTicker(rm.config.Interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				rm.Sample()
+			}
+		}
+	}()
+}
+
+// Sample reads current resource usage and updates the metrics
+func (rm *ResourceMonitor) Sample() {
+	rm.mu.Lock()
+	defer rm.mu.Unlock()
+
+	memory := int64(0)
+	stats, err := ReadProcessStats()
+	if err != nil {
+		if !rm.procFailed {
+			rm.logger.Warnf("Process stats unavailable, CPU usage is not reported: %v", err)
+			rm.procFailed = true
+		}
+		memory = runtimeMemoryBytes()
+	} else {
+		if rm.last != nil {
+			cpu := CPUPercent(rm.last, stats)
+			rm.cpuPercent.Store(math.Float64bits(cpu))
+			rm.metrics.SetCPUUsage(cpu)
+		}
+		rm.last = stats
+		memory = stats.ResidentBytes
+	}
+
+	rm.memoryBytes.Store(memory)
+	rm.metrics.SetMemoryUsage(memory)
+	rm.metrics.SetRPS(rm.metrics.RequestRate())
+}
+
+// MemoryUsage returns the memory usage of the last sample in bytes
+func (rm *ResourceMonitor) MemoryUsage() int64 {
+	return rm.memoryBytes.Load()
+}
+
+// CPUUsage returns the CPU usage of the last sample in percent of one core
+func (rm *ResourceMonitor) CPUUsage() float64 {
+	return math.Float64frombits(rm.cpuPercent.Load())
+}
+
+// AdmitChallenge rejects new challenges while memory usage is above the high
+// watermark of the limit, it reads the last sample and never blocks
+func (rm *ResourceMonitor) AdmitChallenge(ctx context.Context) error {
+	limit := rm.config.MemoryLimitBytes
+	if limit <= 0 {
+		return nil
+	}
+
+	usage := rm.memoryBytes.Load()
+	if float64(usage) < float64(limit)*rm.config.HighWatermark {
+		return nil
+	}
+
+	rm.rejections.Add(1)
+	rm.metrics.RecordMemoryRejection()
+
+	return domain.NewError(domain.ErrorKindCapacity, domain.ReasonMemoryPressure,
+		fmt.Sprintf("memory usage %d of %d bytes is above %.0f%% of the limit", usage, limit, rm.config.HighWatermark*100)).
+		WithRetryAfter(memoryRetryAfter)
+}
+
+// GetStats returns resource monitor statistics
+func (rm *ResourceMonitor) GetStats() map[string]interface{} {
+	return map[string]interface{}{
+		"cpu_usage_percent":   rm.CPUUsage(),
+		"memory_usage_bytes":  rm.MemoryUsage(),
+		"memory_limit_bytes":  rm.config.MemoryLimitBytes,
+		"memory_rejections":   rm.rejections.Load(),
+		"requests_per_second": rm.metrics.RequestRate(),
+	}
+}
@@ -0,0 +1,52 @@
+package monitoring
+
+import (
+	"github.com/FlooooowY/SteelMount-Captcha-Service/internal/security"
+)
+
+// SecurityRecorder records security denials as metrics labelled by network
+// bucket and feeds the offending IPs to the top offender tracker
+type SecurityRecorder struct {
+	metrics    *Metrics
+	classifier *NetworkClassifier
+	offenders  *OffenderTracker
+}
+
+var _ security.Observer = (*SecurityRecorder)(nil)
+
+// NewSecurityRecorder creates a security recorder, offenders is optional
+func NewSecurityRecorder(metrics *Metrics, classifier *NetworkClassifier, offenders *OffenderTracker) *SecurityRecorder {
+	if classifier == nil {
+		classifier = &NetworkClassifier{}
+	}
+
+	return &SecurityRecorder{
+		metrics:    metrics,
+		classifier: classifier,
+		offenders:  offenders,
+	}
+}
+
+// RecordBlockedRequest records a request from a blocked IP
+func (sr *SecurityRecorder) RecordBlockedRequest(ip, path string) {
+	sr.metrics.RecordSecurityBlock("ip_blocked", "ip_block")
+	sr.recordOffense(OffenseBlocked, ip)
+}
+
+// RecordRateLimited records a rate limited request
+func (sr *SecurityRecorder) RecordRateLimited(ip, path string) {
+	sr.metrics.RecordRateLimitHit(sr.classifier.Classify(ip), path)
+	sr.recordOffense(OffenseRateLimited, ip)
+}
+
+// RecordBotDetected records a bot detection by its dominant signal
+func (sr *SecurityRecorder) RecordBotDetected(ip, signal string) {
+	sr.metrics.RecordBotDetection(sr.classifier.Classify(ip), signal)
+	sr.recordOffense(OffenseBot, ip)
+}
+
+func (sr *SecurityRecorder) recordOffense(category, ip string) {
+	if sr.offenders != nil {
+		sr.offenders.Record(category, ip)
+	}
+}
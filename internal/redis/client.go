@@ -37,6 +37,7 @@ func NewClient(cfg *config.RedisConfig) (*Client, error) {
 
 	// Create Redis client
 	client := redis.NewClient(opt)
+	client.AddHook(TracingHook{})
 
 	// Test connection
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
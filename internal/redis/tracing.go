@@ -0,0 +1,73 @@
+package redis
+
+import (
+	"context"
+	"strings"
+
+	"github.com/go-redis/redis/v8"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/FlooooowY/SteelMount-Captcha-Service/internal/tracing"
+)
+
+// TracingHook creates a client span for every Redis command and pipeline
+type TracingHook struct{}
+
+var _ redis.Hook = TracingHook{}
+
+// BeforeProcess starts a span for a command
+func (TracingHook) BeforeProcess(ctx context.Context, cmd redis.Cmder) (context.Context, error) {
+	ctx, _ = tracing.Tracer().Start(ctx, "redis."+cmd.Name(),
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("db.system", "redis"),
+			attribute.String("db.operation", cmd.Name()),
+		),
+	)
+	return ctx, nil
+}
+
+// AfterProcess ends the command span
+func (TracingHook) AfterProcess(ctx context.Context, cmd redis.Cmder) error {
+	tracing.EndSpan(trace.SpanFromContext(ctx), commandError(cmd.Err()))
+	return nil
+}
+
+// BeforeProcessPipeline starts a span for a pipeline
+func (TracingHook) BeforeProcessPipeline(ctx context.Context, cmds []redis.Cmder) (context.Context, error) {
+	names := make([]string, 0, len(cmds))
+	for _, cmd := range cmds {
+		names = append(names, cmd.Name())
+	}
+
+	ctx, _ = tracing.Tracer().Start(ctx, "redis.pipeline",
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("db.system", "redis"),
+			attribute.String("db.operation", strings.Join(names, " ")),
+			attribute.Int("db.redis.pipeline_length", len(cmds)),
+		),
+	)
+	return ctx, nil
+}
+
+// AfterProcessPipeline ends the pipeline span
+func (TracingHook) AfterProcessPipeline(ctx context.Context, cmds []redis.Cmder) error {
+	var err error
+	for _, cmd := range cmds {
+		if err = commandError(cmd.Err()); err != nil {
+			break
+		}
+	}
+	tracing.EndSpan(trace.SpanFromContext(ctx), err)
+	return nil
+}
+
+// commandError ignores redis.Nil, a missing key is not a failure
+func commandError(err error) error {
+	if err == redis.Nil {
+		return nil
+	}
+	return err
+}
@@ -18,7 +18,8 @@ type ChallengeRepository interface {
 	CleanupExpired(ctx context.Context) error
 }
 
-// InMemoryChallengeRepository implements ChallengeRepository using in-memory storage
+// InMemoryChallengeRepository implements ChallengeRepository using in-memory
+// storage, it keeps and hands out copies so callers never share a challenge
 type InMemoryChallengeRepository struct {
 	challenges map[string]*domain.Challenge
 	mu         sync.RWMutex
@@ -36,7 +37,7 @@ func (r *InMemoryChallengeRepository) Create(ctx context.Context, challenge *dom
 	r.mu.Lock()
 	defer r.mu.Unlock()
 	
-	r.challenges[challenge.ID] = challenge
+	r.challenges[challenge.ID] = challenge.Clone()
 	return nil
 }
 
@@ -50,7 +51,7 @@ func (r *InMemoryChallengeRepository) Get(ctx context.Context, id string) (*doma
 		return nil, ErrChallengeNotFound
 	}
 	
-	return challenge, nil
+	return challenge.Clone(), nil
 }
 
 // Update updates an existing challenge
@@ -62,7 +63,7 @@ func (r *InMemoryChallengeRepository) Update(ctx context.Context, challenge *dom
 		return ErrChallengeNotFound
 	}
 	
-	r.challenges[challenge.ID] = challenge
+	r.challenges[challenge.ID] = challenge.Clone()
 	return nil
 }
 
@@ -112,9 +113,9 @@ func (r *InMemoryChallengeRepository) CleanupExpired(ctx context.Context) error
 	return nil
 }
 
-// Repository errors
+// Repository errors, classified so transports can map them to status codes
 var (
-	ErrChallengeNotFound = &RepositoryError{Message: "challenge not found"}
+	ErrChallengeNotFound = domain.NewError(domain.ErrorKindNotFound, domain.ReasonChallengeNotFound, "challenge not found")
 )
 
 // RepositoryError represents a repository error
@@ -0,0 +1,133 @@
+package routing
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// idSeparator separates the parts of an encoded challenge ID
+const idSeparator = "."
+
+// macSize is the number of HMAC bytes kept in a challenge ID
+const macSize = 8
+
+// IDCodec encodes the issuing instance into challenge IDs
+//
+// Encoded IDs have the form <uuid>.<instance>.<mac>, where <instance> is the
+// base64url-encoded instance ID and <mac> is a truncated HMAC-SHA256 over the
+// first two parts. The MAC stops clients from steering requests to arbitrary
+// instances by forging the instance part.
+type IDCodec struct {
+	instanceID string
+	secret     []byte
+}
+
+// ChallengeIDInfo holds the decoded parts of a challenge ID
+type ChallengeIDInfo struct {
+	UUID       string
+	InstanceID string
+	Encoded    bool // false for plain UUIDs issued without affinity
+}
+
+// NewIDCodec creates a new challenge ID codec for the given instance
+func NewIDCodec(instanceID string, secret []byte) *IDCodec {
+	return &IDCodec{
+		instanceID: instanceID,
+		secret:     secret,
+	}
+}
+
+// InstanceID returns the instance ID embedded into new challenge IDs
+func (c *IDCodec) InstanceID() string {
+	return c.instanceID
+}
+
+// NewID generates a new challenge ID bound to this instance
+func (c *IDCodec) NewID() string {
+	return c.encode(uuid.New().String(), c.instanceID)
+}
+
+// Parse decodes a challenge ID and verifies its checksum
+func (c *IDCodec) Parse(id string) (*ChallengeIDInfo, error) {
+	parts := strings.Split(id, idSeparator)
+	if len(parts) == 1 {
+		// Plain UUIDs predate affinity encoding and are always treated as local
+		if _, err := uuid.Parse(id); err != nil {
+			return nil, ErrInvalidChallengeID
+		}
+		return &ChallengeIDInfo{UUID: id, Encoded: false}, nil
+	}
+
+	if len(parts) != 3 {
+		return nil, ErrInvalidChallengeID
+	}
+
+	if _, err := uuid.Parse(parts[0]); err != nil {
+		return nil, ErrInvalidChallengeID
+	}
+
+	instanceID, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil || len(instanceID) == 0 {
+		return nil, ErrInvalidChallengeID
+	}
+
+	mac, err := hex.DecodeString(parts[2])
+	if err != nil || !hmac.Equal(mac, c.sign(parts[0], string(instanceID))) {
+		return nil, ErrChecksumMismatch
+	}
+
+	return &ChallengeIDInfo{
+		UUID:       parts[0],
+		InstanceID: string(instanceID),
+		Encoded:    true,
+	}, nil
+}
+
+// IsLocal reports whether a challenge ID was issued by this instance
+func (c *IDCodec) IsLocal(id string) (bool, error) {
+	info, err := c.Parse(id)
+	if err != nil {
+		return false, err
+	}
+
+	return !info.Encoded || info.InstanceID == c.instanceID, nil
+}
+
+// encode builds an encoded challenge ID
+func (c *IDCodec) encode(id, instanceID string) string {
+	return fmt.Sprintf("%s%s%s%s%s",
+		id, idSeparator,
+		base64.RawURLEncoding.EncodeToString([]byte(instanceID)), idSeparator,
+		hex.EncodeToString(c.sign(id, instanceID)))
+}
+
+// sign computes the truncated HMAC for an ID and instance pair
+func (c *IDCodec) sign(id, instanceID string) []byte {
+	h := hmac.New(sha256.New, c.secret)
+	h.Write([]byte(id))
+	h.Write([]byte(idSeparator))
+	h.Write([]byte(instanceID))
+	return h.Sum(nil)[:macSize]
+}
+
+// Routing errors
+var (
+	ErrInvalidChallengeID = &RoutingError{Message: "invalid challenge id"}
+	ErrChecksumMismatch   = &RoutingError{Message: "challenge id checksum mismatch"}
+	ErrUnknownInstance    = &RoutingError{Message: "challenge owner instance is unknown"}
+)
+
+// RoutingError represents a routing error
+type RoutingError struct {
+	Message string
+}
+
+func (e *RoutingError) Error() string {
+	return e.Message
+}
@@ -0,0 +1,106 @@
+package routing
+
+import (
+	"sync"
+)
+
+// Router resolves which instance owns a challenge
+type Router struct {
+	codec *IDCodec
+	peers map[string]string
+	mu    sync.RWMutex
+}
+
+// Route describes where a challenge lives
+type Route struct {
+	ChallengeID string
+	InstanceID  string
+	Address     string
+	Local       bool
+}
+
+// NewRouter creates a new router with a static set of peer addresses
+func NewRouter(codec *IDCodec, peers map[string]string) *Router {
+	r := &Router{
+		codec: codec,
+		peers: make(map[string]string, len(peers)),
+	}
+
+	for instanceID, address := range peers {
+		r.peers[instanceID] = address
+	}
+
+	return r
+}
+
+// Codec returns the challenge ID codec used by the router
+func (r *Router) Codec() *IDCodec {
+	return r.codec
+}
+
+// SetPeer registers or updates the address of a peer instance
+func (r *Router) SetPeer(instanceID, address string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.peers[instanceID] = address
+}
+
+// RemovePeer forgets a peer instance
+func (r *Router) RemovePeer(instanceID string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delete(r.peers, instanceID)
+}
+
+// Route resolves the owner of a challenge ID
+//
+// Empty IDs and plain UUIDs are routed locally. Foreign IDs resolve to the
+// peer address when it is known and return ErrUnknownInstance otherwise; the
+// returned route still names the owner so callers can report it.
+func (r *Router) Route(challengeID string) (*Route, error) {
+	if challengeID == "" {
+		return &Route{Local: true, InstanceID: r.codec.InstanceID()}, nil
+	}
+
+	info, err := r.codec.Parse(challengeID)
+	if err != nil {
+		return nil, err
+	}
+
+	if !info.Encoded || info.InstanceID == r.codec.InstanceID() {
+		return &Route{
+			ChallengeID: challengeID,
+			InstanceID:  r.codec.InstanceID(),
+			Local:       true,
+		}, nil
+	}
+
+	r.mu.RLock()
+	address, exists := r.peers[info.InstanceID]
+	r.mu.RUnlock()
+
+	route := &Route{
+		ChallengeID: challengeID,
+		InstanceID:  info.InstanceID,
+		Address:     address,
+	}
+
+	if !exists {
+		return route, ErrUnknownInstance
+	}
+
+	return route, nil
+}
+
+// GetStats returns router statistics
+func (r *Router) GetStats() map[string]interface{} {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return map[string]interface{}{
+		"instance_id": r.codec.InstanceID(),
+		"peers":       len(r.peers),
+	}
+}
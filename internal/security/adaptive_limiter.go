@@ -417,6 +417,24 @@ func (al *AdaptiveLimiter) sendSuspiciousUserAlert(behavior *UserBehavior) {
 	}
 }
 
+// RecordCaptchaSolve добавляет время решения капчи в поведение пользователя
+func (al *AdaptiveLimiter) RecordCaptchaSolve(ip string, solveTime time.Duration) {
+	al.mutex.Lock()
+	defer al.mutex.Unlock()
+
+	behavior, exists := al.behaviors[ip]
+	if !exists {
+		return // Решение без запросов не анализируем
+	}
+
+	behavior.CaptchaSolveTime = append(behavior.CaptchaSolveTime, solveTime)
+
+	// Ограничиваем размер истории
+	if len(behavior.CaptchaSolveTime) > 100 {
+		behavior.CaptchaSolveTime = behavior.CaptchaSolveTime[len(behavior.CaptchaSolveTime)-100:]
+	}
+}
+
 // GetUserBehavior возвращает поведение пользователя
 func (al *AdaptiveLimiter) GetUserBehavior(ip string) *UserBehavior {
 	al.mutex.RLock()
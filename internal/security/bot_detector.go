@@ -36,14 +36,26 @@ type RequestPattern struct {
 	RequestPaths    map[string]int
 	ResponseTimes   []time.Duration
 	ErrorCount      int
+	LastScore       float64 // Score of the latest request
 }
 
+// Bot detection signals, a small fixed set naming the analyzer behind a score
+const (
+	SignalUserAgent        = "user_agent"
+	SignalRequestFrequency = "request_frequency"
+	SignalResponseTime     = "response_time"
+	SignalErrorRate        = "error_rate"
+	SignalPath             = "path"
+	SignalNone             = "none"
+)
+
 // BotScore represents a bot detection score
 type BotScore struct {
 	IP          string
 	Score       float64
 	Confidence  float64
 	Reasons     []string
+	Signal      string // Signal contributing most to the score, safe as a metric label
 	Timestamp   time.Time
 }
 
@@ -99,10 +111,23 @@ func (bd *BotDetector) AnalyzeRequest(ctx context.Context, ip string, userAgent
 	
 	// Calculate bot score
 	score := bd.calculateBotScore(ip, userAgent, path, pattern)
+	pattern.LastScore = score.Score
 	
 	return score, nil
 }
 
+// Score returns the bot score of the latest request from ip, 0 for unseen IPs
+func (bd *BotDetector) Score(ip string) float64 {
+	bd.mu.RLock()
+	defer bd.mu.RUnlock()
+
+	pattern, exists := bd.requestPatterns[ip]
+	if !exists {
+		return 0
+	}
+	return pattern.LastScore
+}
+
 // calculateBotScore calculates the bot probability score
 func (bd *BotDetector) calculateBotScore(ip string, userAgent string, path string, pattern *RequestPattern) *BotScore {
 	score := 0.0
@@ -133,6 +158,23 @@ func (bd *BotDetector) calculateBotScore(ip string, userAgent string, path strin
 	score += pathScore
 	reasons = append(reasons, pathReasons...)
 	
+	// Name the dominant signal, reasons carry free text and cannot be labels
+	signal, strongest := SignalNone, 0.0
+	for _, contribution := range []struct {
+		signal string
+		score  float64
+	}{
+		{SignalUserAgent, uaScore},
+		{SignalRequestFrequency, freqScore},
+		{SignalResponseTime, timeScore},
+		{SignalErrorRate, errorScore},
+		{SignalPath, pathScore},
+	} {
+		if contribution.score > strongest {
+			signal, strongest = contribution.signal, contribution.score
+		}
+	}
+	
 	// Calculate confidence based on data points
 	confidence := bd.calculateConfidence(pattern)
 	
@@ -141,6 +183,7 @@ func (bd *BotDetector) calculateBotScore(ip string, userAgent string, path strin
 		Score:      score,
 		Confidence: confidence,
 		Reasons:    reasons,
+		Signal:     signal,
 		Timestamp:  time.Now(),
 	}
 }
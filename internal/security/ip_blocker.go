@@ -3,6 +3,7 @@ package security
 import (
 	"context"
 	"fmt"
+	"strconv"
 	"sync"
 	"time"
 
@@ -15,9 +16,19 @@ type IPBlocker struct {
 	mu            sync.RWMutex
 	localBlocks   map[string]*BlockInfo
 	failedAttempts map[string]*AttemptInfo
+	blockHistory   map[string]*BlockHistory
 	maxFailedAttempts int
 }
 
+// blockHistoryRetention is how long past blocks of an IP are remembered
+const blockHistoryRetention = 24 * time.Hour
+
+// BlockHistory counts the blocks of an IP, kept after the blocks expire
+type BlockHistory struct {
+	Count         int
+	LastBlockedAt time.Time
+}
+
 // BlockInfo represents information about a blocked IP
 type BlockInfo struct {
 	IP        string
@@ -41,6 +52,7 @@ func NewIPBlocker(redisClient *redis.Client) *IPBlocker {
 		redis:              redisClient,
 		localBlocks:        make(map[string]*BlockInfo),
 		failedAttempts:     make(map[string]*AttemptInfo),
+		blockHistory:       make(map[string]*BlockHistory),
 		maxFailedAttempts:  5, // Default value
 	}
 }
@@ -51,6 +63,7 @@ func NewIPBlockerWithConfig(redisClient *redis.Client, maxFailedAttempts int) *I
 		redis:              redisClient,
 		localBlocks:        make(map[string]*BlockInfo),
 		failedAttempts:     make(map[string]*AttemptInfo),
+		blockHistory:       make(map[string]*BlockHistory),
 		maxFailedAttempts:  maxFailedAttempts,
 	}
 }
@@ -133,6 +146,10 @@ func (ib *IPBlocker) BlockIP(ctx context.Context, ip string, reason string, dura
 		ExpiresAt: time.Now().Add(duration),
 		Attempts:  0,
 	}
+
+	ib.mu.Lock()
+	ib.recordBlock(ip)
+	ib.mu.Unlock()
 	
 	// Block in Redis if available
 	if ib.redis != nil {
@@ -207,6 +224,61 @@ func (ib *IPBlocker) CleanupExpiredBlocks() {
 			delete(ib.failedAttempts, ip)
 		}
 	}
+
+	// Clean up block history past its retention
+	for ip, history := range ib.blockHistory {
+		if now.Sub(history.LastBlockedAt) > blockHistoryRetention {
+			delete(ib.blockHistory, ip)
+		}
+	}
+}
+
+// BlockCount returns how often ip was blocked within the block history retention
+func (ib *IPBlocker) BlockCount(ip string) int {
+	ib.mu.RLock()
+	defer ib.mu.RUnlock()
+
+	history, exists := ib.blockHistory[ip]
+	if !exists || time.Since(history.LastBlockedAt) > blockHistoryRetention {
+		return 0
+	}
+	return history.Count
+}
+
+// FailedAttempts returns the failed attempts of ip counted towards a block
+func (ib *IPBlocker) FailedAttempts(ctx context.Context, ip string) int {
+	if ib.redis != nil {
+		count, err := ib.redis.Get(ctx, fmt.Sprintf("failed_attempts:%s", ip)).Result()
+		if err == redis.Nil {
+			return 0
+		}
+		if err == nil {
+			if n, err := strconv.Atoi(count); err == nil {
+				return n
+			}
+		}
+		// Fall back to local attempts if Redis fails
+	}
+
+	ib.mu.RLock()
+	defer ib.mu.RUnlock()
+
+	attemptInfo, exists := ib.failedAttempts[ip]
+	if !exists {
+		return 0
+	}
+	return attemptInfo.Count
+}
+
+// recordBlock adds a block to the history of ip, callers hold mu
+func (ib *IPBlocker) recordBlock(ip string) {
+	history, exists := ib.blockHistory[ip]
+	if !exists {
+		history = &BlockHistory{}
+		ib.blockHistory[ip] = history
+	}
+	history.Count++
+	history.LastBlockedAt = time.Now()
 }
 
 // checkRedisBlock checks if IP is blocked in Redis
@@ -262,6 +334,9 @@ func (ib *IPBlocker) recordRedisAttempt(ctx context.Context, ip string, reason s
 			ExpiresAt: time.Now().Add(time.Hour),
 			Attempts:  int(count),
 		}
+		ib.mu.Lock()
+		ib.recordBlock(ip)
+		ib.mu.Unlock()
 		return ib.blockRedisIP(ctx, ip, blockInfo)
 	}
 	
@@ -300,6 +375,7 @@ func (ib *IPBlocker) blockIP(ip string, reason string, attempts int) {
 	}
 	
 	ib.localBlocks[ip] = blockInfo
+	ib.recordBlock(ip)
 }
 
 // GetStats returns IP blocker statistics
@@ -310,6 +386,7 @@ func (ib *IPBlocker) GetStats() map[string]interface{} {
 	return map[string]interface{}{
 		"blocked_ips":      len(ib.localBlocks),
 		"failed_attempts":  len(ib.failedAttempts),
+		"block_history":    len(ib.blockHistory),
 		"redis_available":  ib.redis != nil,
 	}
 }
@@ -0,0 +1,208 @@
+package security
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"time"
+)
+
+// maxTrackedFailures bounds the failed challenges kept per IP, far past any saturation
+const maxTrackedFailures = 100
+
+// RiskConfig weighs the signals combined into a client's risk score
+type RiskConfig struct {
+	BotWeight         float64       // Weight of the bot detector score
+	DistrustWeight    float64       // Weight of the adaptive limiter's distrust, 1 - trust score
+	BlockWeight       float64       // Weight of the IP's block history
+	FailureWeight     float64       // Weight of recent failed attempts and failed challenges
+	BlockSaturation   int           // Past blocks counted as full block risk
+	FailureSaturation int           // Recent failures counted as full failure risk
+	FailureWindow     time.Duration // How long failed challenges count
+}
+
+// DefaultRiskConfig returns default risk weights
+func DefaultRiskConfig() RiskConfig {
+	return RiskConfig{
+		BotWeight:         0.35,
+		DistrustWeight:    0.25,
+		BlockWeight:       0.2,
+		FailureWeight:     0.2,
+		BlockSaturation:   3,
+		FailureSaturation: 5,
+		FailureWindow:     15 * time.Minute,
+	}
+}
+
+// withRiskDefaults fills unset fields of config from DefaultRiskConfig, the
+// weights only all together so a single weight can be switched off
+func withRiskDefaults(config RiskConfig) RiskConfig {
+	defaults := DefaultRiskConfig()
+	if config.BotWeight == 0 && config.DistrustWeight == 0 && config.BlockWeight == 0 && config.FailureWeight == 0 {
+		config.BotWeight = defaults.BotWeight
+		config.DistrustWeight = defaults.DistrustWeight
+		config.BlockWeight = defaults.BlockWeight
+		config.FailureWeight = defaults.FailureWeight
+	}
+	if config.BlockSaturation <= 0 {
+		config.BlockSaturation = defaults.BlockSaturation
+	}
+	if config.FailureSaturation <= 0 {
+		config.FailureSaturation = defaults.FailureSaturation
+	}
+	if config.FailureWindow <= 0 {
+		config.FailureWindow = defaults.FailureWindow
+	}
+	return config
+}
+
+// RiskAssessment is what the service knows about a client, Score combines
+// the signals from 0 (trusted) to 1 (almost certainly automated)
+type RiskAssessment struct {
+	IP                string
+	Score             float64
+	BotScore          float64 // Last bot detector score, 0 for unseen clients
+	TrustScore        float64 // Adaptive limiter trust, 0.5 for unseen clients
+	Blocked           bool    // Currently blocked, counts as full block risk
+	PastBlocks        int     // Blocks within the block history retention
+	FailedAttempts    int     // Failed attempts counted by the IP blocker
+	ChallengeFailures int     // Failed challenge answers within the failure window
+	Reasons           []string
+}
+
+// AssessRisk combines bot score, trust score, block history and recent
+// failures of ip into a risk score. An empty ip is an unknown client and gets
+// the risk of one seen for the first time
+func (ss *SecurityService) AssessRisk(ctx context.Context, ip string) *RiskAssessment {
+	assessment := &RiskAssessment{
+		IP:         ip,
+		TrustScore: 0.5,
+		Reasons:    []string{},
+	}
+
+	if ip != "" {
+		assessment.BotScore = ss.botDetector.Score(ip)
+		if behavior := ss.adaptiveLimiter.GetUserBehavior(ip); behavior != nil {
+			assessment.TrustScore = behavior.TrustScore
+		}
+
+		blocked, _, err := ss.ipBlocker.IsBlocked(ctx, ip)
+		if err != nil {
+			ss.logger.Errorf("Failed to check IP block for risk assessment: %v", err)
+		}
+		assessment.Blocked = blocked
+		assessment.PastBlocks = ss.ipBlocker.BlockCount(ip)
+		assessment.FailedAttempts = ss.ipBlocker.FailedAttempts(ctx, ip)
+		assessment.ChallengeFailures = ss.challengeFailures(ip)
+	}
+
+	config := ss.config.RiskConfig
+	blockRisk := saturate(assessment.PastBlocks, config.BlockSaturation)
+	if assessment.Blocked {
+		blockRisk = 1
+	}
+	failureRisk := saturate(assessment.FailedAttempts+assessment.ChallengeFailures, config.FailureSaturation)
+	distrust := 1 - assessment.TrustScore
+
+	total := config.BotWeight + config.DistrustWeight + config.BlockWeight + config.FailureWeight
+	if total > 0 {
+		assessment.Score = (config.BotWeight*math.Min(assessment.BotScore, 1) +
+			config.DistrustWeight*distrust +
+			config.BlockWeight*blockRisk +
+			config.FailureWeight*failureRisk) / total
+	}
+
+	if assessment.BotScore > 0.4 {
+		assessment.Reasons = append(assessment.Reasons, fmt.Sprintf("Suspicious behavior (score: %.2f)", assessment.BotScore))
+	}
+	if assessment.TrustScore < 0.3 {
+		assessment.Reasons = append(assessment.Reasons, fmt.Sprintf("Low trust (score: %.2f)", assessment.TrustScore))
+	}
+	if assessment.Blocked || assessment.PastBlocks > 0 {
+		assessment.Reasons = append(assessment.Reasons, fmt.Sprintf("Blocked %d times", assessment.PastBlocks))
+	}
+	if failures := assessment.FailedAttempts + assessment.ChallengeFailures; failures > 0 {
+		assessment.Reasons = append(assessment.Reasons, fmt.Sprintf("%d recent failures", failures))
+	}
+
+	return assessment
+}
+
+// RecordChallengeResult records a challenge answer of ip: failures raise its
+// risk for the failure window, solve times feed the adaptive limiter's trust
+func (ss *SecurityService) RecordChallengeResult(ip string, solved bool, solveTime time.Duration) {
+	if ip == "" {
+		return
+	}
+
+	if solved {
+		ss.adaptiveLimiter.RecordCaptchaSolve(ip, solveTime)
+		return
+	}
+
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+
+	now := time.Now()
+	failures := append(recentFailures(ss.failures[ip], now.Add(-ss.config.RiskConfig.FailureWindow)), now)
+	if len(failures) > maxTrackedFailures {
+		failures = failures[len(failures)-maxTrackedFailures:]
+	}
+	ss.failures[ip] = failures
+}
+
+// challengeFailures returns the failed challenges of ip within the failure window
+func (ss *SecurityService) challengeFailures(ip string) int {
+	ss.mu.RLock()
+	defer ss.mu.RUnlock()
+
+	return len(recentFailures(ss.failures[ip], time.Now().Add(-ss.config.RiskConfig.FailureWindow)))
+}
+
+// cleanupChallengeFailures forgets failures older than the failure window
+func (ss *SecurityService) cleanupChallengeFailures() {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+
+	cutoff := time.Now().Add(-ss.config.RiskConfig.FailureWindow)
+	for ip, failures := range ss.failures {
+		if recent := recentFailures(failures, cutoff); len(recent) > 0 {
+			ss.failures[ip] = recent
+		} else {
+			delete(ss.failures, ip)
+		}
+	}
+}
+
+// recentFailures drops failures before cutoff, failures are in time order
+func recentFailures(failures []time.Time, cutoff time.Time) []time.Time {
+	for i, failure := range failures {
+		if failure.After(cutoff) {
+			return failures[i:]
+		}
+	}
+	return nil
+}
+
+// saturate maps count to [0, 1], reaching 1 at limit
+func saturate(count, limit int) float64 {
+	if limit <= 0 || count <= 0 {
+		return 0
+	}
+	return math.Min(float64(count)/float64(limit), 1)
+}
+
+// clientKey is the context key for the client a request came from
+type clientKey struct{}
+
+// client is the address and user agent the transports saw
+type client struct {
+	ip        string
+	userAgent string
+}
+
+// ContextWithClient returns ctx carrying the client address and user agent,
+// transports set it so risk assessment and challenge results reach the client
+func ContextWithClient(ctx context.Context, ip, userAgent string) context.Context {
+	return context.WithValue(ctx, clientKey{}, client{ip: ip, userAgent: userAgent})
+}
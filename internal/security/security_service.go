@@ -4,22 +4,38 @@ import (
 	"context"
 	"fmt"
 	"net"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/go-redis/redis/v8"
 	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/attribute"
+
+	"github.com/FlooooowY/SteelMount-Captcha-Service/internal/domain"
+	"github.com/FlooooowY/SteelMount-Captcha-Service/internal/tracing"
 )
 
 // SecurityService provides comprehensive security features
 type SecurityService struct {
-	rateLimiter *RateLimiter
-	ipBlocker   *IPBlocker
-	botDetector *BotDetector
-	config      *SecurityConfig
-	logger      *logrus.Logger
-	mu          sync.RWMutex
-	stats       *SecurityStats
+	rateLimiter     *RateLimiter
+	ipBlocker       *IPBlocker
+	botDetector     *BotDetector
+	adaptiveLimiter *AdaptiveLimiter
+	config          *SecurityConfig
+	logger          *logrus.Logger
+	mu              sync.RWMutex
+	stats           *SecurityStats
+	failures        map[string][]time.Time // Failed challenge answers per IP, oldest first
+	observer        Observer
+}
+
+// Observer receives security denials, e.g. to record metrics and track top
+// offenders; it is called on the request path and must not block
+type Observer interface {
+	RecordBlockedRequest(ip, path string)
+	RecordRateLimited(ip, path string)
+	RecordBotDetected(ip, signal string)
 }
 
 // SecurityStats tracks security metrics
@@ -37,6 +53,7 @@ type SecurityConfig struct {
 	RateLimitConfig    RateLimitConfig
 	IPBlockingConfig   IPBlockingConfig
 	BotDetectionConfig BotDetectionConfig
+	RiskConfig         RiskConfig // Zero fields use DefaultRiskConfig
 }
 
 // RateLimitConfig represents rate limiting configuration
@@ -67,21 +84,49 @@ type BotDetectionConfig struct {
 func NewSecurityService(redisClient *redis.Client, config *SecurityConfig) *SecurityService {
 	logger := logrus.New()
 	logger.SetLevel(logrus.InfoLevel)
-	
+
+	config.RiskConfig = withRiskDefaults(config.RiskConfig)
+
 	return &SecurityService{
-		rateLimiter: NewRateLimiter(redisClient),
-		ipBlocker:   NewIPBlockerWithConfig(redisClient, config.IPBlockingConfig.MaxFailedAttempts),
-		botDetector: NewBotDetector(),
-		config:      config,
-		logger:      logger,
+		rateLimiter:     NewRateLimiter(redisClient),
+		ipBlocker:       NewIPBlockerWithConfig(redisClient, config.IPBlockingConfig.MaxFailedAttempts),
+		botDetector:     NewBotDetector(),
+		adaptiveLimiter: NewAdaptiveLimiter(nil, nil),
+		config:          config,
+		logger:          logger,
 		stats: &SecurityStats{
 			StartTime: time.Now(),
 		},
+		failures: make(map[string][]time.Time),
 	}
 }
 
+// SetObserver sets the observer of security denials, optional
+func (ss *SecurityService) SetObserver(observer Observer) {
+	ss.observer = observer
+}
+
 // CheckRequest performs comprehensive security checks on a request
 func (ss *SecurityService) CheckRequest(ctx context.Context, ip string, userAgent string, path string, responseTime time.Duration, isError bool) (*SecurityResult, error) {
+	ctx, span := tracing.StartSpan(ctx, "security.CheckRequest",
+		attribute.String("client.address", ip),
+		attribute.String("security.path", path),
+	)
+
+	result, err := ss.checkRequest(ctx, ip, userAgent, path, responseTime, isError)
+	if result != nil {
+		span.SetAttributes(attribute.Bool("security.allowed", result.Allowed))
+		if result.Denial != nil {
+			span.SetAttributes(attribute.String("security.denial_reason", result.Denial.Reason))
+		}
+	}
+	tracing.EndSpan(span, err)
+
+	return result, err
+}
+
+// checkRequest runs the block, rate limit and bot checks
+func (ss *SecurityService) checkRequest(ctx context.Context, ip string, userAgent string, path string, responseTime time.Duration, isError bool) (*SecurityResult, error) {
 	ss.mu.Lock()
 	ss.stats.TotalRequests++
 	ss.mu.Unlock()
@@ -102,9 +147,13 @@ func (ss *SecurityService) CheckRequest(ctx context.Context, ip string, userAgen
 	if blocked {
 		result.Allowed = false
 		result.Reasons = append(result.Reasons, fmt.Sprintf("IP blocked: %s", blockInfo.Reason))
+		result.Denial = blockedError(ip, blockInfo)
 		ss.mu.Lock()
 		ss.stats.BlockedRequests++
 		ss.mu.Unlock()
+		if ss.observer != nil {
+			ss.observer.RecordBlockedRequest(ip, path)
+		}
 		return result, nil
 	}
 
@@ -117,12 +166,21 @@ func (ss *SecurityService) CheckRequest(ctx context.Context, ip string, userAgen
 	if !allowed {
 		result.Allowed = false
 		result.Reasons = append(result.Reasons, "Rate limit exceeded")
+		result.Denial = domain.NewError(domain.ErrorKindRateLimited, domain.ReasonRateLimited, "rate limit exceeded").
+			WithRetryAfter(time.Minute).
+			WithQuota("ip:"+ip, fmt.Sprintf("%d requests per minute", ss.config.RateLimitConfig.RequestsPerMinute))
 		ss.mu.Lock()
 		ss.stats.RateLimitedRequests++
 		ss.mu.Unlock()
+		if ss.observer != nil {
+			ss.observer.RecordRateLimited(ip, path)
+		}
 		return result, nil
 	}
 
+	// Track behavior for the trust score used in risk assessment
+	ss.adaptiveLimiter.AnalyzeRequest(ctx, ip, userAgent, path, responseTime, !isError)
+
 	// Analyze for bot behavior
 	botScore, err := ss.botDetector.AnalyzeRequest(ctx, ip, userAgent, path, responseTime, isError)
 	if err != nil {
@@ -134,6 +192,8 @@ func (ss *SecurityService) CheckRequest(ctx context.Context, ip string, userAgen
 		result.Allowed = false
 		result.Reasons = append(result.Reasons, fmt.Sprintf("Bot detected (score: %.2f)", botScore.Score))
 		result.Reasons = append(result.Reasons, botScore.Reasons...)
+		result.Denial = domain.NewError(domain.ErrorKindBlocked, domain.ReasonBotDetected,
+			fmt.Sprintf("bot detected (score: %.2f)", botScore.Score)).WithMetadata("ip", ip)
 
 		// Record failed attempt
 			if err := ss.ipBlocker.RecordFailedAttempt(ctx, ip, "Bot behavior detected"); err != nil {
@@ -143,6 +203,9 @@ func (ss *SecurityService) CheckRequest(ctx context.Context, ip string, userAgen
 		ss.mu.Lock()
 		ss.stats.BotDetections++
 		ss.mu.Unlock()
+		if ss.observer != nil {
+			ss.observer.RecordBotDetected(ip, botScore.Signal)
+		}
 		return result, nil
 	} else if botScore.Score > 0.4 { // Medium bot probability
 		result.Reasons = append(result.Reasons, fmt.Sprintf("Suspicious behavior (score: %.2f)", botScore.Score))
@@ -164,9 +227,13 @@ func (ss *SecurityService) CheckRequest(ctx context.Context, ip string, userAgen
 		if blocked {
 			result.Allowed = false
 			result.Reasons = append(result.Reasons, fmt.Sprintf("IP blocked: %s", blockInfo.Reason))
+			result.Denial = blockedError(ip, blockInfo)
 			ss.mu.Lock()
 			ss.stats.BlockedRequests++
 			ss.mu.Unlock()
+			if ss.observer != nil {
+				ss.observer.RecordBlockedRequest(ip, path)
+			}
 			return result, nil
 		}
 	}
@@ -219,10 +286,13 @@ func (ss *SecurityService) GetStats() map[string]interface{} {
 	rateLimiterStats := ss.rateLimiter.GetStats()
 	ipBlockerStats := ss.ipBlocker.GetStats()
 	botDetectorStats := ss.botDetector.GetStats()
+	adaptiveLimiterStats := ss.adaptiveLimiter.GetStats()
 
 	stats["rate_limiter"] = rateLimiterStats
 	stats["ip_blocker"] = ipBlockerStats
 	stats["bot_detector"] = botDetectorStats
+	stats["adaptive_limiter"] = adaptiveLimiterStats
+	stats["clients_with_failures"] = len(ss.failures)
 
 	return stats
 }
@@ -237,6 +307,10 @@ func (ss *SecurityService) Cleanup() {
 
 	// Cleanup bot detector
 	ss.botDetector.CleanupExpiredPatterns()
+
+	// Cleanup behavior and failure tracking behind risk assessment
+	ss.adaptiveLimiter.CleanupExpiredBehaviors()
+	ss.cleanupChallengeFailures()
 }
 
 // StartCleanupRoutine starts a background cleanup routine
@@ -259,24 +333,52 @@ type SecurityResult struct {
 	IP        string
 	Allowed   bool
 	Reasons   []string
+	Denial    *domain.Error // Classified reason when the request is not allowed
 	Timestamp time.Time
 }
 
+// Err returns the denial as an error, nil when the request is allowed
+func (r *SecurityResult) Err() error {
+	if r.Allowed {
+		return nil
+	}
+	if r.Denial != nil {
+		return r.Denial
+	}
+	return domain.NewError(domain.ErrorKindBlocked, "", "request blocked: "+strings.Join(r.Reasons, ", "))
+}
+
+// blockedError describes a blocked IP, retrying makes sense once the block expires
+func blockedError(ip string, blockInfo *BlockInfo) *domain.Error {
+	err := domain.NewError(domain.ErrorKindBlocked, domain.ReasonIPBlocked, "IP blocked").WithMetadata("ip", ip)
+	if blockInfo == nil {
+		return err
+	}
+
+	if blockInfo.Reason != "" {
+		err.Message = "IP blocked: " + blockInfo.Reason
+	}
+	if remaining := time.Until(blockInfo.ExpiresAt); remaining > 0 {
+		err.WithRetryAfter(remaining)
+	}
+	return err
+}
+
 // IsValidIP checks if an IP address is valid
 func IsValidIP(ip string) bool {
 	return net.ParseIP(ip) != nil
 }
 
-// ExtractIPFromRequest extracts IP from request context
+// ExtractIPFromRequest extracts IP from request context, empty when no
+// transport recorded the client with ContextWithClient
 func ExtractIPFromRequest(ctx context.Context) string {
-	// This would typically extract IP from gRPC context
-	// For now, return a placeholder
-	return "127.0.0.1"
+	c, _ := ctx.Value(clientKey{}).(client)
+	return c.ip
 }
 
-// ExtractUserAgentFromRequest extracts user agent from request context
+// ExtractUserAgentFromRequest extracts user agent from request context, empty
+// when no transport recorded the client with ContextWithClient
 func ExtractUserAgentFromRequest(ctx context.Context) string {
-	// This would typically extract user agent from gRPC context
-	// For now, return a placeholder
-	return "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36"
+	c, _ := ctx.Value(clientKey{}).(client)
+	return c.userAgent
 }
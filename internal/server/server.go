@@ -10,13 +10,18 @@ import (
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/sirupsen/logrus"
 
+	"github.com/FlooooowY/SteelMount-Captcha-Service/internal/calibration"
 	"github.com/FlooooowY/SteelMount-Captcha-Service/internal/config"
+	"github.com/FlooooowY/SteelMount-Captcha-Service/internal/domain"
 	"github.com/FlooooowY/SteelMount-Captcha-Service/internal/logger"
 	"github.com/FlooooowY/SteelMount-Captcha-Service/internal/monitoring"
 	"github.com/FlooooowY/SteelMount-Captcha-Service/internal/redis"
 	"github.com/FlooooowY/SteelMount-Captcha-Service/internal/repository"
+	"github.com/FlooooowY/SteelMount-Captcha-Service/internal/routing"
 	"github.com/FlooooowY/SteelMount-Captcha-Service/internal/security"
+	"github.com/FlooooowY/SteelMount-Captcha-Service/internal/tracing"
 	"github.com/FlooooowY/SteelMount-Captcha-Service/internal/transport/grpc"
+	httpTransport "github.com/FlooooowY/SteelMount-Captcha-Service/internal/transport/http"
 	"github.com/FlooooowY/SteelMount-Captcha-Service/internal/usecase"
 	"github.com/FlooooowY/SteelMount-Captcha-Service/internal/websocket"
 	pb "github.com/FlooooowY/SteelMount-Captcha-Service/proto/captcha/v1"
@@ -30,23 +35,37 @@ type Server struct {
 	logger *logrus.Logger
 
 	// gRPC server
-	grpcServer *grpcLib.Server
-	listener   net.Listener
+	grpcServer     *grpcLib.Server
+	listener       net.Listener
+	captchaService *grpc.CaptchaService
 
 	// WebSocket server
 	wsService *websocket.WebSocketService
 	wsServer  *websocket.HTTPServer
 
+	// REST gateway, nil when disabled
+	gateway *httpTransport.Gateway
+
 	// Security
 	redisClient     *redis.Client
 	securityService *security.SecurityService
 	securityMW      *grpc.SecurityMiddleware
 
+	// Challenge affinity routing
+	router     *routing.Router
+	affinityMW *grpc.AffinityMiddleware
+
 	// Monitoring
+	tracing          *tracing.Provider
 	metrics          *monitoring.Metrics
 	metricsMW        *monitoring.MetricsMiddleware
+	resources        *monitoring.ResourceMonitor
+	offenders        *monitoring.OffenderTracker
 	prometheusServer *monitoring.PrometheusServer
 
+	// Complexity calibration, nil when disabled
+	calibrator *calibration.Calibrator
+
 	// Balancer integration
 	balancerClient *grpc.BalancerClient
 
@@ -54,6 +73,7 @@ type Server struct {
 	port        int
 	wsPort      int
 	metricsPort int
+	gatewayPort int
 	instanceID  string
 
 	// Graceful shutdown
@@ -69,8 +89,11 @@ func New(cfg *config.Config) (*Server, error) {
 
 	log := logger.GetLogger()
 
-	// Generate instance ID
-	instanceID := generateInstanceID()
+	// Generate instance ID, a configured one keeps challenge IDs routable across restarts
+	instanceID := cfg.Routing.InstanceID
+	if instanceID == "" {
+		instanceID = generateInstanceID()
+	}
 
 	srv := &Server{
 		config:     cfg,
@@ -79,14 +102,38 @@ func New(cfg *config.Config) (*Server, error) {
 		shutdownCh: make(chan struct{}),
 	}
 
-	// Find available ports for gRPC, WebSocket, and metrics in one pass
-	ports, err := srv.findAvailablePorts(3)
+	// Set up tracing before any instrumented client is created
+	tracingConfig := tracing.DefaultConfig()
+	tracingConfig.Enabled = cfg.Monitoring.Tracing.Enabled
+	tracingConfig.Endpoint = cfg.Monitoring.Tracing.Endpoint()
+	tracingConfig.Insecure = cfg.Monitoring.Tracing.Insecure
+	if cfg.Monitoring.Tracing.ServiceName != "" {
+		tracingConfig.ServiceName = cfg.Monitoring.Tracing.ServiceName
+	}
+	if cfg.Monitoring.Tracing.SampleRatio > 0 {
+		tracingConfig.SampleRatio = cfg.Monitoring.Tracing.SampleRatio
+	}
+	tracingProvider, err := tracing.Setup(context.Background(), tracingConfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to set up tracing: %w", err)
+	}
+	srv.tracing = tracingProvider
+
+	// Find available ports for gRPC, WebSocket, metrics and the optional gateway in one pass
+	portCount := 3
+	if cfg.Gateway.Enabled {
+		portCount = 4
+	}
+	ports, err := srv.findAvailablePorts(portCount)
 	if err != nil {
 		return nil, fmt.Errorf("failed to find available ports: %w", err)
 	}
 	srv.port = ports[0]
 	srv.wsPort = ports[1] 
 	srv.metricsPort = ports[2]
+	if cfg.Gateway.Enabled {
+		srv.gatewayPort = ports[3]
+	}
 
 	// Create Redis client with timeout
 	redisClient, err := srv.createRedisClientWithTimeout(cfg)
@@ -116,6 +163,15 @@ func New(cfg *config.Config) (*Server, error) {
 			HighBotScore:    0.7,
 			CleanupInterval: time.Hour,
 		},
+		RiskConfig: security.RiskConfig{
+			BotWeight:         cfg.Security.Risk.BotWeight,
+			DistrustWeight:    cfg.Security.Risk.DistrustWeight,
+			BlockWeight:       cfg.Security.Risk.BlockWeight,
+			FailureWeight:     cfg.Security.Risk.FailureWeight,
+			BlockSaturation:   cfg.Security.Risk.BlockSaturation,
+			FailureSaturation: cfg.Security.Risk.FailureSaturation,
+			FailureWindow:     cfg.Security.Risk.FailureWindow,
+		},
 	}
 
 	var redisClientForSecurity *redisLib.Client
@@ -128,31 +184,128 @@ func New(cfg *config.Config) (*Server, error) {
 	// Create security middleware
 	srv.securityMW = grpc.NewSecurityMiddleware(srv.securityService)
 
+	// Load TLS credentials, peer instances are dialed with the server's certificate
+	serverOptions := []grpcLib.ServerOption{}
+	affinityConfig := grpc.DefaultAffinityConfig()
+	if cfg.Server.TLS.Enabled {
+		serverCreds, peerCreds, err := grpc.LoadTLSCredentials(grpc.TLSConfig{
+			CertFile: cfg.Server.TLS.CertFile,
+			KeyFile:  cfg.Server.TLS.KeyFile,
+			CAFile:   cfg.Server.TLS.CAFile,
+		})
+		if err != nil {
+			return nil, err
+		}
+		serverOptions = append(serverOptions, grpcLib.Creds(serverCreds))
+		affinityConfig.PeerCredentials = peerCreds
+	}
+
+	// Create affinity routing so challenges can be routed to the issuing instance
+	if cfg.Routing.Enabled {
+		codec := routing.NewIDCodec(instanceID, []byte(cfg.Routing.Secret))
+		srv.router = routing.NewRouter(codec, cfg.Routing.Peers)
+		srv.affinityMW = grpc.NewAffinityMiddlewareWithConfig(srv.router, affinityConfig)
+	}
+
 	// Create monitoring with custom registry to avoid duplicate registration
+	// and label every series with the instance ID
+	constLabels, err := monitoring.InstanceLabels(instanceID, cfg.Monitoring.ConstLabels)
+	if err != nil {
+		return nil, err
+	}
 	registry := prometheus.NewRegistry()
-	srv.metrics = monitoring.NewMetricsWithRegistry(registry)
+	registerer := prometheus.WrapRegistererWith(constLabels, registry)
+	srv.metrics = monitoring.NewMetricsWithRegistry(registerer)
+	if err := monitoring.RegisterRuntimeCollectors(registerer); err != nil {
+		return nil, err
+	}
 	srv.metricsMW = monitoring.NewMetricsMiddleware(srv.metrics)
-	srv.prometheusServer = monitoring.NewPrometheusServer(srv.metricsPort, srv.metrics)
+
+	// Sample process resources and enforce the memory limit on new challenges
+	resourceConfig := monitoring.DefaultResourceMonitorConfig()
+	resourceConfig.MemoryLimitBytes = int64(cfg.Captcha.MemoryLimitGB) << 30
+	if cfg.Captcha.MemoryHighWatermark > 0 {
+		resourceConfig.HighWatermark = cfg.Captcha.MemoryHighWatermark
+	}
+	srv.resources = monitoring.NewResourceMonitorWithConfig(srv.metrics, resourceConfig)
+	srv.prometheusServer = monitoring.NewPrometheusServerWithRegistry(srv.metricsPort, srv.metrics, registry)
+
+	// Security metrics are labelled by network bucket, offending IPs go to the top-K tracker
+	classifier, err := monitoring.NewNetworkClassifier(cfg.Monitoring.NetworkBuckets)
+	if err != nil {
+		return nil, err
+	}
+	offenderConfig := monitoring.DefaultOffenderConfig()
+	if cfg.Monitoring.TopOffenders.Capacity > 0 {
+		offenderConfig.Capacity = cfg.Monitoring.TopOffenders.Capacity
+	}
+	if cfg.Monitoring.TopOffenders.Window > 0 {
+		offenderConfig.Window = cfg.Monitoring.TopOffenders.Window
+	}
+	srv.offenders = monitoring.NewOffenderTrackerWithConfig(offenderConfig)
+	srv.securityService.SetObserver(monitoring.NewSecurityRecorder(srv.metrics, classifier, srv.offenders))
+	srv.prometheusServer.Handle("/security/top-offenders", srv.offenders.Handler())
+
+	// Learn per-type difficulty from answers and report the derived curves
+	if cfg.Captcha.Calibration.Enabled {
+		srv.calibrator = calibration.NewCalibratorWithConfig(&calibration.Config{
+			MinSamples:   cfg.Captcha.Calibration.MinSamples,
+			MaxSamples:   cfg.Captcha.Calibration.MaxSamples,
+			EasyPassRate: cfg.Captcha.Calibration.EasyPassRate,
+			HardPassRate: cfg.Captcha.Calibration.HardPassRate,
+			Exploration:  cfg.Captcha.Calibration.Exploration,
+			MaxShift:     cfg.Captcha.Calibration.MaxShift,
+		})
+		srv.prometheusServer.Handle("/captcha/calibration", srv.calibrator.Handler())
+	}
 
 	// Create WebSocket service
-	srv.wsService = websocket.NewWebSocketService()
+	wsServiceConfig := websocket.DefaultServiceConfig()
+	wsServiceConfig.Observer = srv.metrics
+	wsServiceConfig.Logger = log
+	if cfg.WebSocket.SendQueueSize > 0 {
+		wsServiceConfig.SendQueueSize = cfg.WebSocket.SendQueueSize
+	}
+	if cfg.WebSocket.SlowConsumerPolicy != "" {
+		policy, err := websocket.ParseSlowConsumerPolicy(cfg.WebSocket.SlowConsumerPolicy)
+		if err != nil {
+			return nil, err
+		}
+		wsServiceConfig.SlowConsumerPolicy = policy
+	}
+	srv.wsService = websocket.NewWebSocketServiceWithConfig(wsServiceConfig)
 
 	// Create WebSocket HTTP server
-	srv.wsServer = websocket.NewHTTPServer(srv.wsService, srv.wsPort)
-
-	// Create gRPC server with security and metrics middleware
-	srv.grpcServer = grpcLib.NewServer(
-		grpcLib.ChainUnaryInterceptor(
-			srv.securityMW.UnaryInterceptor(),
-			srv.metricsMW.GRPCMetricsInterceptor(),
-		),
-		grpcLib.ChainStreamInterceptor(
-			srv.securityMW.StreamInterceptor(),
-			// Add stream metrics interceptor here if needed
-		),
+	wsConfig := websocket.DefaultHTTPServerConfig()
+	wsConfig.AllowedOrigins = cfg.WebSocket.AllowedOrigins
+	wsConfig.AllowMissingOrigin = cfg.WebSocket.AllowMissingOrigin
+	wsConfig.TokenSecret = cfg.WebSocket.TokenSecret
+	wsConfig.RequireToken = cfg.WebSocket.RequireToken
+	wsConfig.MaxConnectionsPerIP = cfg.WebSocket.MaxConnectionsPerIP
+	wsConfig.MaxConnectionsPerClient = cfg.WebSocket.MaxConnectionsPerClient
+	wsConfig.TrustForwardedFor = cfg.WebSocket.TrustForwardedFor
+	if cfg.WebSocket.MaxMessageBytes > 0 {
+		wsConfig.MaxMessageBytes = cfg.WebSocket.MaxMessageBytes
+	}
+	srv.wsServer = websocket.NewHTTPServerWithConfig(srv.wsService, srv.wsPort, wsConfig, srv.securityService)
+
+	// Create gRPC server with tracing, security, affinity and metrics middleware
+	unaryInterceptors := []grpcLib.UnaryServerInterceptor{tracing.UnaryServerInterceptor(), srv.securityMW.UnaryInterceptor()}
+	streamInterceptors := []grpcLib.StreamServerInterceptor{tracing.StreamServerInterceptor(), srv.securityMW.StreamInterceptor()}
+	if srv.affinityMW != nil {
+		unaryInterceptors = append(unaryInterceptors, srv.affinityMW.UnaryInterceptor())
+		streamInterceptors = append(streamInterceptors, srv.affinityMW.StreamInterceptor())
+	}
+	unaryInterceptors = append(unaryInterceptors, srv.metricsMW.GRPCMetricsInterceptor())
+	streamInterceptors = append(streamInterceptors, srv.metricsMW.GRPCStreamMetricsInterceptor(grpc.StreamMessageType))
+
+	serverOptions = append(serverOptions,
+		grpcLib.ChainUnaryInterceptor(unaryInterceptors...),
+		grpcLib.ChainStreamInterceptor(streamInterceptors...),
 		grpcLib.MaxRecvMsgSize(4*1024*1024), // 4MB
 		grpcLib.MaxSendMsgSize(4*1024*1024), // 4MB
 	)
+	srv.grpcServer = grpcLib.NewServer(serverOptions...)
 
 	// Create balancer client if enabled (with timeout)
 	if cfg.Balancer.Enabled && cfg.Balancer.URL != "" {
@@ -206,6 +359,17 @@ func (s *Server) Start(ctx context.Context) error {
 		}
 	}()
 
+	// Start REST gateway
+	if s.gateway != nil {
+		s.logger.Infof("Starting REST gateway on port %d", s.gatewayPort)
+		if err := s.gateway.Start(ctx); err != nil {
+			s.logger.Errorf("REST gateway error: %v", err)
+		}
+	}
+
+	// Start resource sampling
+	s.resources.Start(ctx)
+
 	// Start Prometheus server in a goroutine
 	s.shutdownWG.Add(1)
 	go func() {
@@ -224,6 +388,24 @@ func (s *Server) Start(ctx context.Context) error {
 		s.startSecurityCleanup(ctx)
 	}()
 
+	// Expire event stream sessions that were not resumed, abandoning their challenges
+	s.shutdownWG.Add(1)
+	go func() {
+		defer s.shutdownWG.Done()
+
+		cleanupCtx, cancel := context.WithCancel(ctx)
+		defer cancel()
+		go func() {
+			select {
+			case <-s.shutdownCh:
+				cancel()
+			case <-cleanupCtx.Done():
+			}
+		}()
+
+		s.captchaService.GetSessionStore().StartCleanupRoutine(cleanupCtx, 30*time.Second)
+	}()
+
 	// Start balancer registration
 	s.shutdownWG.Add(1)
 	go func() {
@@ -260,6 +442,13 @@ func (s *Server) Stop(ctx context.Context) error {
 		close(wsDone)
 	}()
 
+	// Stop REST gateway
+	if s.gateway != nil {
+		if err := s.gateway.Stop(ctx); err != nil {
+			s.logger.Errorf("Error stopping REST gateway: %v", err)
+		}
+	}
+
 	// Stop Prometheus server gracefully
 	prometheusDone := make(chan struct{})
 	go func() {
@@ -279,6 +468,13 @@ func (s *Server) Stop(ctx context.Context) error {
 		}
 	}
 
+	// Close peer connections used for challenge forwarding
+	if s.affinityMW != nil {
+		if err := s.affinityMW.Close(); err != nil {
+			s.logger.Errorf("Error closing affinity peer connections: %v", err)
+		}
+	}
+
 	// Close Redis client
 	if s.redisClient != nil {
 		if err := s.redisClient.Close(); err != nil {
@@ -286,6 +482,11 @@ func (s *Server) Stop(ctx context.Context) error {
 		}
 	}
 
+	// Flush pending spans
+	if err := s.tracing.Shutdown(ctx); err != nil {
+		s.logger.Errorf("Error shutting down tracing: %v", err)
+	}
+
 	// Wait for graceful stop or timeout
 	select {
 	case <-grpcDone:
@@ -336,6 +537,11 @@ func (s *Server) GetWebSocketPort() int {
 	return s.wsPort
 }
 
+// GetGatewayPort returns the REST gateway port, 0 when the gateway is disabled
+func (s *Server) GetGatewayPort() int {
+	return s.gatewayPort
+}
+
 // GetInstanceID returns the server instance ID
 func (s *Server) GetInstanceID() string {
 	return s.instanceID
@@ -362,6 +568,21 @@ func (s *Server) GetSecurityStats() map[string]interface{} {
 	return stats
 }
 
+// GetOffenderTracker returns the top offender tracker
+func (s *Server) GetOffenderTracker() *monitoring.OffenderTracker {
+	return s.offenders
+}
+
+// GetCalibrator returns the complexity calibrator, nil when calibration is disabled
+func (s *Server) GetCalibrator() *calibration.Calibrator {
+	return s.calibrator
+}
+
+// GetRouter returns the challenge affinity router, nil when routing is disabled
+func (s *Server) GetRouter() *routing.Router {
+	return s.router
+}
+
 // GetMetricsPort returns the metrics server port
 func (s *Server) GetMetricsPort() int {
 	return s.metricsPort
@@ -377,6 +598,11 @@ func (s *Server) GetMetricsMiddleware() *monitoring.MetricsMiddleware {
 	return s.metricsMW
 }
 
+// GetResourceMonitor returns the process resource monitor
+func (s *Server) GetResourceMonitor() *monitoring.ResourceMonitor {
+	return s.resources
+}
+
 // registerWebSocketHandlers registers WebSocket event handlers
 func (s *Server) registerWebSocketHandlers(captchaUsecase usecase.CaptchaUsecase) {
 	if s.wsServer == nil {
@@ -386,75 +612,86 @@ func (s *Server) registerWebSocketHandlers(captchaUsecase usecase.CaptchaUsecase
 	wsService := s.wsServer.GetWebSocketService()
 	
 	// Register create_challenge handler
-	wsService.RegisterHandler("create_challenge", func(ctx context.Context, event *websocket.Event) error {
-		// Extract challenge type and complexity from event data
-		challengeType, ok := event.Data["challenge_type"].(string)
-		if !ok {
-			return fmt.Errorf("invalid challenge_type")
-		}
-		
-		complexity, ok := event.Data["complexity"].(float64)
-		if !ok {
-			return fmt.Errorf("invalid complexity")
+	wsService.RegisterHandler(websocket.EventTypeCreateChallenge, func(ctx context.Context, event *websocket.Event) (*websocket.Event, error) {
+		var request websocket.CreateChallengePayload
+		if err := event.DecodeData(&request); err != nil {
+			return nil, err
 		}
 		
 		// Create challenge
-		challenge, err := captchaUsecase.CreateChallenge(ctx, int32(complexity))
+		challenge, err := captchaUsecase.CreateChallengeWithOptions(ctx, request.Complexity, domain.ChallengeOptions{
+			Accessible:   request.Accessible,
+			KeyboardOnly: request.KeyboardOnly,
+		})
 		if err != nil {
-			return fmt.Errorf("failed to create challenge: %w", err)
+			return nil, fmt.Errorf("failed to create challenge: %w", err)
 		}
 		
-		// Use HTML content from challenge
-		htmlContent := challenge.HTML
+		// The creating connection owns the challenge until it closes
+		if session, ok := websocket.SessionFromContext(ctx); ok {
+			wsService.BindChallenge(session, challenge.ID)
+		}
 		
-		// Send response event
-		responseEvent := &websocket.Event{
-			ID:        fmt.Sprintf("resp_%d", time.Now().UnixNano()),
-			Type:      "challenge_created",
-			Data: map[string]interface{}{
-				"challenge_id":   challenge.ID,
-				"challenge_type": challengeType,
-				"html_content":   htmlContent,
-				"complexity":     complexity,
-			},
-			Timestamp: time.Now(),
-			ClientID:  event.ClientID,
+		// Build response event
+		responseEvent := websocket.NewEvent(websocket.EventTypeChallengeCreated)
+		responseEvent.ClientID = event.ClientID
+		if err := responseEvent.SetData(&websocket.ChallengeCreatedPayload{
+			ChallengeID:   challenge.ID,
+			ChallengeType: string(challenge.Type),
+			HTMLContent:   challenge.HTML,
+			Complexity:    challenge.Complexity,
+		}); err != nil {
+			return nil, err
 		}
 		
-		return wsService.SendEvent(event.ClientID, responseEvent)
+		return responseEvent, nil
 	})
 	
 	// Register validate_challenge handler
-	wsService.RegisterHandler("validate_challenge", func(ctx context.Context, event *websocket.Event) error {
-		// Extract challenge ID and answer from event data
-		challengeID, ok := event.Data["challenge_id"].(string)
-		if !ok {
-			return fmt.Errorf("invalid challenge_id")
+	wsService.RegisterHandler(websocket.EventTypeValidateChallenge, func(ctx context.Context, event *websocket.Event) (*websocket.Event, error) {
+		var request websocket.ValidateChallengePayload
+		if err := event.DecodeData(&request); err != nil {
+			return nil, err
 		}
 		
-		answer := event.Data["answer"]
+		// Challenges created over WebSocket may only be validated by their own connection
+		if owner, owned := wsService.ChallengeOwner(request.ChallengeID); owned && owner != event.ConnectionID {
+			return nil, websocket.NewProtocolError(websocket.ErrorCodeChallengeNotOwned, "challenge %s belongs to another connection", request.ChallengeID)
+		}
 		
 		// Validate challenge
-		result, err := captchaUsecase.ValidateChallenge(ctx, challengeID, answer)
+		result, err := captchaUsecase.ValidateChallenge(ctx, request.ChallengeID, request.Answer)
 		if err != nil {
-			return fmt.Errorf("failed to validate challenge: %w", err)
+			return nil, fmt.Errorf("failed to validate challenge: %w", err)
 		}
 		
-		// Send response event
-		responseEvent := &websocket.Event{
-			ID:        fmt.Sprintf("resp_%d", time.Now().UnixNano()),
-			Type:      "challenge_validated",
-			Data: map[string]interface{}{
-				"challenge_id": result.ChallengeID,
-				"solved":       result.Solved,
-				"confidence":   result.ConfidencePercent,
-				"time_taken":   result.TimeToSolve,
-			},
-			Timestamp: time.Now(),
-			ClientID:  event.ClientID,
+		// Failed results are sent as error events with the mapped error code
+		if err := result.Err(); err != nil {
+			return nil, err
 		}
 		
-		return wsService.SendEvent(event.ClientID, responseEvent)
+		// Build response event
+		responseEvent := websocket.NewEvent(websocket.EventTypeChallengeValidated)
+		responseEvent.ClientID = event.ClientID
+		if err := responseEvent.SetData(&websocket.ChallengeValidatedPayload{
+			ChallengeID: result.ChallengeID,
+			Solved:      result.Solved,
+			Confidence:  result.ConfidencePercent,
+			TimeTaken:   result.TimeToSolve,
+		}); err != nil {
+			return nil, err
+		}
+		
+		return responseEvent, nil
+	})
+	
+	// Abandon unsolved challenges when their connection goes away
+	wsService.OnSessionClosed(func(ctx context.Context, session *websocket.Session) {
+		for _, challengeID := range session.Challenges() {
+			if err := captchaUsecase.AbandonChallenge(ctx, challengeID, usecase.AbandonReasonWebSocketClosed); err != nil {
+				s.logger.Debugf("Failed to abandon challenge %s: %v", challengeID, err)
+			}
+		}
 	})
 }
 
@@ -469,10 +706,52 @@ func (s *Server) registerServices() {
 		ChallengeTimeout:    time.Minute * 5,  // 5 minutes timeout
 		CleanupInterval:     time.Minute * 10, // Cleanup every 10 minutes
 	}
-	captchaUsecase := usecase.NewCaptchaUsecase(challengeRepo, usecaseConfig)
-	captchaService := grpc.NewCaptchaService(captchaUsecase)
+	if s.router != nil {
+		usecaseConfig.IDCodec = s.router.Codec()
+	}
+	if s.config.Captcha.MaxStages > 0 {
+		usecaseConfig.StagePolicy = domain.DefaultStagePolicy()
+		usecaseConfig.StagePolicy.MaxStages = s.config.Captcha.MaxStages
+	}
+	usecaseConfig.MaxAttempts = s.config.Captcha.MaxAttempts
+	usecaseConfig.OnAbandon = func(challenge *domain.Challenge, reason string) {
+		s.metrics.RecordCaptchaAbandoned(string(challenge.Type), reason)
+	}
+	usecaseConfig.Admit = s.resources.AdmitChallenge
+	if s.calibrator != nil {
+		usecaseConfig.Calibrator = s.calibrator
+		usecaseConfig.CalibrationMaxRisk = s.config.Captcha.Calibration.MaxRisk
+	}
 
-	pb.RegisterCaptchaServiceServer(s.grpcServer, captchaService)
+	// Auto complexity follows the security service's view of the client, and
+	// every answer feeds back into it
+	usecaseConfig.RiskPolicy = riskPolicy(s.config.Captcha.Auto)
+	usecaseConfig.AssessRisk = func(ctx context.Context) float64 {
+		return s.securityService.AssessRisk(ctx, security.ExtractIPFromRequest(ctx)).Score
+	}
+	usecaseConfig.OnAnswer = func(ctx context.Context, challenge *domain.Challenge, solved bool, elapsed time.Duration) {
+		s.securityService.RecordChallengeResult(security.ExtractIPFromRequest(ctx), solved, elapsed)
+	}
+	captchaUsecase := usecase.NewCaptchaUsecase(challengeRepo, usecaseConfig)
+	sessionConfig := grpc.DefaultStreamSessionConfig()
+	if s.router != nil {
+		sessionConfig.IDCodec = s.router.Codec()
+	}
+	s.captchaService = grpc.NewCaptchaServiceWithConfig(captchaUsecase, sessionConfig)
+	s.captchaService.SetEventObserver(s.metrics)
+
+	pb.RegisterCaptchaServiceServer(s.grpcServer, s.captchaService)
+
+	// Create REST gateway over the same usecase with the same security and metrics
+	if s.config.Gateway.Enabled {
+		gatewayConfig := httpTransport.DefaultGatewayConfig()
+		gatewayConfig.AllowedOrigins = s.config.Gateway.AllowedOrigins
+		gatewayConfig.TrustForwardedFor = s.config.Gateway.TrustForwardedFor
+		if s.config.Gateway.MaxBodyBytes > 0 {
+			gatewayConfig.MaxBodyBytes = s.config.Gateway.MaxBodyBytes
+		}
+		s.gateway = httpTransport.NewGateway(captchaUsecase, s.securityService, s.metrics, s.gatewayPort, gatewayConfig)
+	}
 	
 	// Register WebSocket event handlers
 	s.registerWebSocketHandlers(captchaUsecase)
@@ -637,3 +916,24 @@ func (s *Server) isPortAvailable(port int) bool {
 	listener.Close()
 	return true
 }
+
+// riskPolicy returns the auto complexity policy, unset fields keep their defaults
+func riskPolicy(auto config.AutoConfig) domain.RiskPolicy {
+	policy := domain.DefaultRiskPolicy()
+	if auto.LowRisk > 0 {
+		policy.LowRisk = auto.LowRisk
+	}
+	if auto.HighRisk > 0 {
+		policy.HighRisk = auto.HighRisk
+	}
+	if auto.MinComplexity > 0 {
+		policy.MinComplexity = auto.MinComplexity
+	}
+	if auto.MaxComplexity > 0 {
+		policy.MaxComplexity = auto.MaxComplexity
+	}
+	if auto.HighRiskStages > 0 {
+		policy.HighRiskStages = auto.HighRiskStages
+	}
+	return policy
+}
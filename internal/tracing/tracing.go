@@ -0,0 +1,144 @@
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// instrumentationName identifies spans created by this service
+const instrumentationName = "github.com/FlooooowY/SteelMount-Captcha-Service"
+
+// Config contains tracing settings
+type Config struct {
+	Enabled     bool
+	Endpoint    string  // OTLP gRPC collector, localhost:4317 or http://localhost:4317, empty uses OTEL_EXPORTER_OTLP_ENDPOINT
+	Insecure    bool    // Plaintext connection to the collector
+	ServiceName string  // service.name resource attribute
+	SampleRatio float64 // Fraction of new traces to sample, parent decisions are always respected
+}
+
+// DefaultConfig returns the default tracing settings
+func DefaultConfig() *Config {
+	return &Config{
+		Insecure:    true,
+		ServiceName: "captcha-service",
+		SampleRatio: 1,
+	}
+}
+
+// Provider owns the tracer provider and its exporter
+type Provider struct {
+	provider *sdktrace.TracerProvider
+}
+
+// Setup installs the global tracer provider and W3C propagators, a disabled
+// config keeps the no-op provider and only installs propagation
+func Setup(ctx context.Context, config *Config) (*Provider, error) {
+	if config == nil {
+		config = DefaultConfig()
+	}
+
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+	if !config.Enabled {
+		return &Provider{}, nil
+	}
+
+	options := []otlptracegrpc.Option{}
+	switch {
+	case strings.Contains(config.Endpoint, "://"):
+		options = append(options, otlptracegrpc.WithEndpointURL(config.Endpoint))
+	case config.Endpoint != "":
+		options = append(options, otlptracegrpc.WithEndpoint(config.Endpoint))
+	}
+	if config.Insecure {
+		options = append(options, otlptracegrpc.WithInsecure())
+	}
+
+	exporter, err := otlptracegrpc.New(ctx, options...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create OTLP exporter: %w", err)
+	}
+
+	provider := newTracerProvider(config, sdktrace.WithBatcher(exporter))
+	otel.SetTracerProvider(provider)
+
+	return &Provider{provider: provider}, nil
+}
+
+// NewProviderWithExporter installs a global tracer provider exporting
+// synchronously to the given exporter, e.g. tracetest.InMemoryExporter in tests
+func NewProviderWithExporter(exporter sdktrace.SpanExporter, serviceName string) *Provider {
+	config := DefaultConfig()
+	if serviceName != "" {
+		config.ServiceName = serviceName
+	}
+
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+	provider := newTracerProvider(config, sdktrace.WithSyncer(exporter))
+	otel.SetTracerProvider(provider)
+
+	return &Provider{provider: provider}
+}
+
+// newTracerProvider creates an SDK tracer provider
+func newTracerProvider(config *Config, exportOption sdktrace.TracerProviderOption) *sdktrace.TracerProvider {
+	ratio := config.SampleRatio
+	if ratio <= 0 || ratio > 1 {
+		ratio = 1
+	}
+
+	return sdktrace.NewTracerProvider(
+		exportOption,
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(ratio))),
+		sdktrace.WithResource(resource.NewSchemaless(semconv.ServiceName(config.ServiceName))),
+	)
+}
+
+// Shutdown flushes pending spans and stops the exporter
+func (p *Provider) Shutdown(ctx context.Context) error {
+	if p == nil || p.provider == nil {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	return p.provider.Shutdown(ctx)
+}
+
+// Tracer returns the service tracer from the global provider
+func Tracer() trace.Tracer {
+	return otel.Tracer(instrumentationName)
+}
+
+// StartSpan starts an internal span
+func StartSpan(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return Tracer().Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// EndSpan records the error, if any, and ends the span
+func EndSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// ExtractHTTP returns a context carrying the remote span context of HTTP headers
+func ExtractHTTP(ctx context.Context, header http.Header) context.Context {
+	return otel.GetTextMapPropagator().Extract(ctx, propagation.HeaderCarrier(header))
+}
@@ -0,0 +1,320 @@
+package grpc
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sync"
+
+	"github.com/FlooooowY/SteelMount-Captcha-Service/internal/routing"
+	"github.com/FlooooowY/SteelMount-Captcha-Service/internal/tracing"
+	pb "github.com/FlooooowY/SteelMount-Captcha-Service/proto/captcha/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	// ownerInstanceHeader names the instance that owns a rejected challenge
+	ownerInstanceHeader = "x-captcha-owner-instance"
+	// forwardedByHeader marks calls already forwarded by a peer instance
+	forwardedByHeader = "x-captcha-forwarded-by"
+)
+
+// challengeIDGetter is implemented by requests that reference a challenge
+type challengeIDGetter interface {
+	GetChallengeId() string
+}
+
+// AffinityConfig contains affinity middleware settings
+type AffinityConfig struct {
+	PeerCredentials credentials.TransportCredentials // Used to dial owner instances
+}
+
+// DefaultAffinityConfig returns default affinity middleware settings
+func DefaultAffinityConfig() AffinityConfig {
+	return AffinityConfig{
+		PeerCredentials: insecure.NewCredentials(),
+	}
+}
+
+// AffinityMiddleware routes calls for foreign challenges to their owner instance
+type AffinityMiddleware struct {
+	router *routing.Router
+	config AffinityConfig
+
+	conns map[string]*grpc.ClientConn
+	mu    sync.Mutex
+}
+
+// NewAffinityMiddleware creates a new affinity middleware
+func NewAffinityMiddleware(router *routing.Router) *AffinityMiddleware {
+	return NewAffinityMiddlewareWithConfig(router, DefaultAffinityConfig())
+}
+
+// NewAffinityMiddlewareWithConfig creates a new affinity middleware with custom settings
+func NewAffinityMiddlewareWithConfig(router *routing.Router, config AffinityConfig) *AffinityMiddleware {
+	if config.PeerCredentials == nil {
+		config.PeerCredentials = insecure.NewCredentials()
+	}
+
+	return &AffinityMiddleware{
+		router: router,
+		config: config,
+		conns:  make(map[string]*grpc.ClientConn),
+	}
+}
+
+// UnaryInterceptor creates a unary interceptor that rejects foreign challenges
+func (am *AffinityMiddleware) UnaryInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		getter, ok := req.(challengeIDGetter)
+		if !ok {
+			return handler(ctx, req)
+		}
+
+		route, err := am.router.Route(getter.GetChallengeId())
+		if err != nil {
+			return nil, am.routeError(ctx, route, err)
+		}
+
+		if !route.Local {
+			return nil, am.routeError(ctx, route, errForeignChallenge)
+		}
+
+		return handler(ctx, req)
+	}
+}
+
+// StreamInterceptor creates a stream interceptor that forwards event streams
+// for foreign challenges to their owner instance
+func (am *AffinityMiddleware) StreamInterceptor() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if info.FullMethod != pb.CaptchaService_MakeEventStream_FullMethodName {
+			return handler(srv, ss)
+		}
+
+		// Peek at the first event to learn which challenge the stream is for
+		first := &pb.ClientEvent{}
+		if err := ss.RecvMsg(first); err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+
+		// A resumed session is served by the instance it was created on
+		routeID := first.ChallengeId
+		if first.EventType == pb.ClientEvent_RESUME {
+			routeID = first.SessionId
+		}
+
+		ctx := ss.Context()
+		route, err := am.router.Route(routeID)
+		if err != nil {
+			return am.routeError(ctx, route, err)
+		}
+
+		if route.Local {
+			return handler(srv, &affinityServerStream{
+				ServerStream: ss,
+				ctx:          context.WithValue(ctx, routeCheckKey{}, routeCheck(am.checkRoute)),
+				first:        first,
+			})
+		}
+
+		// Never forward twice, peers disagreeing on ownership would loop
+		if am.isForwarded(ctx) {
+			return am.routeError(ctx, route, errForeignChallenge)
+		}
+
+		return am.forwardEventStream(ss, route, first)
+	}
+}
+
+// forwardEventStream proxies an event stream to the owner instance
+func (am *AffinityMiddleware) forwardEventStream(ss grpc.ServerStream, route *routing.Route, first *pb.ClientEvent) error {
+	conn, err := am.getConn(route.Address)
+	if err != nil {
+		return status.Errorf(codes.Unavailable, "failed to connect to owner instance %s: %v", route.InstanceID, err)
+	}
+
+	ctx, cancel := context.WithCancel(ss.Context())
+	defer cancel()
+
+	md, _ := metadata.FromIncomingContext(ctx)
+	md = md.Copy()
+	md.Set(forwardedByHeader, am.router.Codec().InstanceID())
+
+	// The owner continues this instance's stream span rather than the span the
+	// client sent, which the copied metadata still carries
+	ctx = tracing.InjectOutgoing(metadata.NewOutgoingContext(ctx, md))
+
+	upstream, err := pb.NewCaptchaServiceClient(conn).MakeEventStream(ctx)
+	if err != nil {
+		return status.Errorf(codes.Unavailable, "failed to open stream to owner instance %s: %v", route.InstanceID, err)
+	}
+
+	if err := upstream.Send(first); err != nil {
+		return status.Errorf(codes.Unavailable, "failed to forward event: %v", err)
+	}
+
+	// Client -> owner
+	go func() {
+		for {
+			event := &pb.ClientEvent{}
+			if err := ss.RecvMsg(event); err != nil {
+				upstream.CloseSend()
+				return
+			}
+			if err := upstream.Send(event); err != nil {
+				cancel()
+				return
+			}
+		}
+	}()
+
+	// Owner -> client
+	for {
+		event, err := upstream.Recv()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := ss.SendMsg(event); err != nil {
+			return err
+		}
+	}
+}
+
+// getConn returns a cached client connection to a peer
+func (am *AffinityMiddleware) getConn(address string) (*grpc.ClientConn, error) {
+	am.mu.Lock()
+	defer am.mu.Unlock()
+
+	if conn, exists := am.conns[address]; exists {
+		return conn, nil
+	}
+
+	conn, err := grpc.NewClient(address, grpc.WithTransportCredentials(am.config.PeerCredentials))
+	if err != nil {
+		return nil, err
+	}
+
+	am.conns[address] = conn
+	return conn, nil
+}
+
+// isForwarded reports whether the call was already forwarded by a peer
+func (am *AffinityMiddleware) isForwarded(ctx context.Context) bool {
+	md, ok := metadata.FromIncomingContext(ctx)
+	return ok && len(md.Get(forwardedByHeader)) > 0
+}
+
+// routeError converts a routing failure into a gRPC status and reports the
+// owner instance in the response header so callers can retry there
+func (am *AffinityMiddleware) routeError(ctx context.Context, route *routing.Route, err error) error {
+	if route != nil && route.InstanceID != "" {
+		grpc.SetHeader(ctx, metadata.Pairs(ownerInstanceHeader, route.InstanceID))
+	}
+
+	return routeStatus(route, err)
+}
+
+// checkRoute returns the owner instance and a status error for a challenge
+// that is not served locally, nil for a local one
+func (am *AffinityMiddleware) checkRoute(challengeID string) (string, error) {
+	route, err := am.router.Route(challengeID)
+	if err == nil && route.Local {
+		return "", nil
+	}
+	if err == nil {
+		err = errForeignChallenge
+	}
+
+	owner := ""
+	if route != nil {
+		owner = route.InstanceID
+	}
+	return owner, routeStatus(route, err)
+}
+
+// routeStatus converts a routing failure into a gRPC status
+func routeStatus(route *routing.Route, err error) error {
+	switch {
+	case errors.Is(err, routing.ErrInvalidChallengeID), errors.Is(err, routing.ErrChecksumMismatch):
+		return status.Errorf(codes.InvalidArgument, "%v", err)
+	case route == nil:
+		return status.Errorf(codes.FailedPrecondition, "%v", err)
+	default:
+		return status.Errorf(codes.FailedPrecondition, "challenge is owned by instance %s: %v", route.InstanceID, err)
+	}
+}
+
+// Close closes all peer connections
+func (am *AffinityMiddleware) Close() error {
+	am.mu.Lock()
+	defer am.mu.Unlock()
+
+	var firstErr error
+	for address, conn := range am.conns {
+		if err := conn.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+		delete(am.conns, address)
+	}
+
+	return firstErr
+}
+
+// errForeignChallenge is returned when a challenge cannot be served locally
+var errForeignChallenge = errors.New("challenge belongs to another instance")
+
+// routeCheck returns the owner instance and an error for a challenge another
+// instance serves
+type routeCheck func(challengeID string) (string, error)
+
+// routeCheckKey carries the routeCheck of a local stream in its context
+type routeCheckKey struct{}
+
+// routeCheckFromContext returns the routeCheck of a stream behind the affinity middleware
+func routeCheckFromContext(ctx context.Context) (routeCheck, bool) {
+	check, ok := ctx.Value(routeCheckKey{}).(routeCheck)
+	return check, ok
+}
+
+// affinityServerStream replays the peeked event and lets the handler check
+// the challenges of later events, a foreign challenge is answered with an
+// error frame and the other challenges of the stream go on
+type affinityServerStream struct {
+	grpc.ServerStream
+	ctx   context.Context
+	first *pb.ClientEvent
+}
+
+// Context returns the stream context carrying the routeCheck
+func (s *affinityServerStream) Context() context.Context {
+	return s.ctx
+}
+
+// RecvMsg returns the peeked event first, then the following events
+func (s *affinityServerStream) RecvMsg(m interface{}) error {
+	if s.first != nil {
+		event, ok := m.(*pb.ClientEvent)
+		if !ok {
+			return status.Errorf(codes.Internal, "unexpected message type %T", m)
+		}
+		proto.Reset(event)
+		proto.Merge(event, s.first)
+		s.first = nil
+		return nil
+	}
+
+	return s.ServerStream.RecvMsg(m)
+}
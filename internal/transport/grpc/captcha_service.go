@@ -2,10 +2,20 @@ package grpc
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"io"
+	"strings"
 	"time"
 
+	"go.opentelemetry.io/otel/attribute"
+	otelcodes "go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
 	"github.com/FlooooowY/SteelMount-Captcha-Service/internal/domain"
+	"github.com/FlooooowY/SteelMount-Captcha-Service/internal/tracing"
 	"github.com/FlooooowY/SteelMount-Captcha-Service/internal/usecase"
 	pb "github.com/FlooooowY/SteelMount-Captcha-Service/proto/captcha/v1"
 )
@@ -14,23 +24,84 @@ import (
 type CaptchaService struct {
 	pb.UnimplementedCaptchaServiceServer
 	captchaUsecase usecase.CaptchaUsecase
+	sessions       *StreamSessionStore
+	sessionConfig  *StreamSessionConfig
+	observer       StreamEventObserver
+}
+
+// StreamEventObserver records how long client stream events take to handle
+type StreamEventObserver interface {
+	RecordStreamEventLatency(ctx context.Context, eventType, outcome string, duration time.Duration)
 }
 
+// Stream event outcomes
+const (
+	EventOutcomeOK        = "ok"
+	EventOutcomeError     = "error"     // Answered with an error frame
+	EventOutcomeDuplicate = "duplicate" // Resent after a reconnect and skipped
+)
+
 // NewCaptchaService creates a new captcha service
 func NewCaptchaService(captchaUsecase usecase.CaptchaUsecase) *CaptchaService {
-	return &CaptchaService{
+	return NewCaptchaServiceWithConfig(captchaUsecase, DefaultStreamSessionConfig())
+}
+
+// NewCaptchaServiceWithConfig creates a new captcha service with custom stream session settings
+func NewCaptchaServiceWithConfig(captchaUsecase usecase.CaptchaUsecase, sessionConfig *StreamSessionConfig) *CaptchaService {
+	if sessionConfig == nil {
+		sessionConfig = DefaultStreamSessionConfig()
+	}
+
+	s := &CaptchaService{
 		captchaUsecase: captchaUsecase,
+		sessions:       NewStreamSessionStore(sessionConfig),
+		sessionConfig:  sessionConfig,
 	}
+
+	// Challenges left behind by an ended stream are abandoned
+	s.sessions.OnSessionClosed(s.abandonChallenges)
+
+	return s
+}
+
+// SetEventObserver sets the observer of per-event latencies, optional
+func (s *CaptchaService) SetEventObserver(observer StreamEventObserver) {
+	s.observer = observer
+}
+
+// GetSessionStore returns the event stream session store
+func (s *CaptchaService) GetSessionStore() *StreamSessionStore {
+	return s.sessions
 }
 
 // NewChallenge creates a new captcha challenge
 func (s *CaptchaService) NewChallenge(ctx context.Context, req *pb.ChallengeRequest) (*pb.ChallengeResponse, error) {
+	span := trace.SpanFromContext(ctx)
+	span.SetAttributes(
+		attribute.Int("captcha.complexity", int(req.Complexity)),
+		attribute.Bool("captcha.accessible", req.Accessible),
+		attribute.Bool("captcha.keyboard_only", req.KeyboardOnly),
+		attribute.Bool("captcha.auto_complexity", req.AutoComplexity),
+		attribute.Bool("captcha.passive", req.Passive),
+	)
+
 	// Create challenge using usecase
-	challenge, err := s.captchaUsecase.CreateChallenge(ctx, req.Complexity)
+	challenge, err := s.captchaUsecase.CreateChallengeWithOptions(ctx, req.Complexity, domain.ChallengeOptions{
+		Accessible:   req.Accessible,
+		KeyboardOnly: req.KeyboardOnly,
+		Auto:         req.AutoComplexity,
+		Passive:      req.Passive,
+	})
 	if err != nil {
-		return nil, fmt.Errorf("failed to create challenge: %w", err)
+		return nil, toStatusError(err)
 	}
 
+	span.SetAttributes(
+		attribute.String("captcha.challenge_id", challenge.ID),
+		attribute.String("captcha.type", string(challenge.Type)),
+		attribute.Int("captcha.issued_complexity", int(challenge.Complexity)),
+	)
+
 	// Return response
 	return &pb.ChallengeResponse{
 		ChallengeId: challenge.ID,
@@ -39,35 +110,389 @@ func (s *CaptchaService) NewChallenge(ctx context.Context, req *pb.ChallengeRequ
 }
 
 // MakeEventStream handles bidirectional event streaming
+//
+// Every stream belongs to a session announced in the session_id of server
+// events. Failed client events are answered with error frames and the stream
+// stays open. When the connection drops, the client opens a new stream whose
+// first event is RESUME with the session ID and the last received server
+// sequence number; the server answers with SessionResumed and replays the
+// events the client missed.
 func (s *CaptchaService) MakeEventStream(stream pb.CaptchaService_MakeEventStreamServer) error {
 	ctx := stream.Context()
 
+	var session *streamSession
+	var generation uint64
+	defer func() {
+		// Keep the session resumable unless it ended cleanly
+		if session != nil {
+			s.sessions.detach(session, generation)
+		}
+	}()
+
 	for {
 		// Receive client event
 		clientEvent, err := stream.Recv()
+		if err == io.EOF {
+			if session != nil {
+				s.sessions.remove(session)
+				session = nil
+			}
+			return nil
+		}
 		if err != nil {
 			return fmt.Errorf("failed to receive client event: %w", err)
 		}
 
-		// Convert to domain event
-		domainEvent := &domain.Event{
-			Type:        s.convertEventType(clientEvent.EventType),
-			ChallengeID: clientEvent.ChallengeId,
-			Data:        clientEvent.Data,
-			Timestamp:   time.Now(),
+		if clientEvent.EventType == pb.ClientEvent_RESUME {
+			if session != nil {
+				if err := s.send(stream, session, generation, s.errorFrame(clientEvent,
+					status.Error(codes.FailedPrecondition, "stream is already attached to a session"))); err != nil {
+					return err
+				}
+				continue
+			}
+
+			resumed, resumedGeneration, resumeErr := s.sessions.resume(clientEvent.SessionId)
+			if resumeErr == nil {
+				session, generation = resumed, resumedGeneration
+				if err := s.resume(stream, session, clientEvent.Ack); err != nil {
+					return err
+				}
+				continue
+			}
+
+			// An expired session is not fatal, the stream goes on with a fresh one
+			if session, generation, err = s.establish(stream); err != nil {
+				return err
+			}
+			if err := s.send(stream, session, generation, s.errorFrame(clientEvent, resumeErr)); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if session == nil {
+			if session, generation, err = s.establish(stream); err != nil {
+				return err
+			}
+		}
+
+		received := time.Now()
+		eventCtx, span := tracing.StartSpan(ctx, "MakeEventStream/"+clientEventType(clientEvent),
+			attribute.String("captcha.session_id", session.id),
+			attribute.String("captcha.challenge_id", clientEvent.ChallengeId),
+			attribute.Int64("captcha.seq", int64(clientEvent.Seq)),
+		)
+
+		session.ack(clientEvent.Ack)
+		if !session.acceptClientSeq(clientEvent.Seq) {
+			// Already processed before the client reconnected
+			s.observeEvent(eventCtx, clientEvent, EventOutcomeDuplicate, received)
+			span.SetAttributes(attribute.String("captcha.outcome", EventOutcomeDuplicate))
+			span.End()
+			continue
+		}
+
+		outcome := EventOutcomeOK
+		for _, reply := range s.processClientEvent(eventCtx, session, clientEvent) {
+			if reply.GetError() != nil {
+				outcome = EventOutcomeError
+				span.SetStatus(otelcodes.Error, reply.GetError().Message)
+			}
+			if err := s.send(stream, session, generation, reply); err != nil {
+				tracing.EndSpan(span, err)
+				return err
+			}
+		}
+		s.observeEvent(eventCtx, clientEvent, outcome, received)
+		span.SetAttributes(attribute.String("captcha.outcome", outcome))
+		span.End()
+	}
+}
+
+// observeEvent records the handling latency of a client event
+func (s *CaptchaService) observeEvent(ctx context.Context, clientEvent *pb.ClientEvent, outcome string, received time.Time) {
+	if s.observer != nil {
+		s.observer.RecordStreamEventLatency(ctx, clientEventType(clientEvent), outcome, time.Since(received))
+	}
+}
+
+// StreamMessageType names the event type of a stream message for metric labels
+func StreamMessageType(msg interface{}) string {
+	switch m := msg.(type) {
+	case *pb.ClientEvent:
+		return clientEventType(m)
+	case *pb.ServerEvent:
+		switch m.Event.(type) {
+		case *pb.ServerEvent_Result:
+			return "result"
+		case *pb.ServerEvent_ClientJs:
+			return "client_js"
+		case *pb.ServerEvent_ClientData:
+			return "client_data"
+		case *pb.ServerEvent_Error_:
+			return "error"
+		case *pb.ServerEvent_Resumed:
+			return "resumed"
+		case *pb.ServerEvent_Created:
+			return "created"
+		case *pb.ServerEvent_Established:
+			return "established"
+		}
+	}
+	return "unknown"
+}
+
+// clientEventType returns the lower case event type of a client event, values
+// missing from the enum map to "unknown" to keep span and metric labels bounded
+func clientEventType(clientEvent *pb.ClientEvent) string {
+	name, known := pb.ClientEvent_EventType_name[int32(clientEvent.EventType)]
+	if !known {
+		return "unknown"
+	}
+	return strings.ToLower(name)
+}
+
+// processClientEvent processes a client event and returns the replies or an error frame,
+// progressive challenges may answer one event with several server events
+func (s *CaptchaService) processClientEvent(ctx context.Context, session *streamSession, clientEvent *pb.ClientEvent) []*pb.ServerEvent {
+	if frame := s.foreignChallengeFrame(ctx, clientEvent); frame != nil {
+		return []*pb.ServerEvent{frame}
+	}
+
+	switch clientEvent.EventType {
+	case pb.ClientEvent_CREATE_CHALLENGE:
+		return []*pb.ServerEvent{s.createChallenge(ctx, session, clientEvent)}
+	case pb.ClientEvent_VALIDATE_CHALLENGE:
+		return []*pb.ServerEvent{s.validateChallenge(ctx, session, clientEvent)}
+	case pb.ClientEvent_BALANCER_EVENT:
+		// Balancer events are not tied to a client's challenges
+	default:
+		// Frontend events may only touch challenges of this stream, a challenge
+		// created by NewChallenge is bound to the first stream driving it
+		if err := s.claimChallenge(ctx, session, clientEvent.ChallengeId); err != nil {
+			return []*pb.ServerEvent{s.errorFrame(clientEvent, err)}
+		}
+		if clientEvent.EventType == pb.ClientEvent_CONNECTION_CLOSED {
+			defer s.sessions.release(session, clientEvent.ChallengeId)
 		}
+	}
+
+	// Convert to domain event
+	domainEvent := &domain.Event{
+		Type:        s.convertEventType(clientEvent.EventType),
+		ChallengeID: clientEvent.ChallengeId,
+		Data:        clientEvent.Data,
+		Timestamp:   time.Now(),
+	}
+
+	// Process event
+	serverEvents, err := s.captchaUsecase.ProcessEvent(ctx, domainEvent)
+	if err != nil {
+		return []*pb.ServerEvent{s.errorFrame(clientEvent, fmt.Errorf("failed to process event: %w", err))}
+	}
 
-		// Process event
-		serverEvent, err := s.captchaUsecase.ProcessEvent(ctx, domainEvent)
+	replies := make([]*pb.ServerEvent, 0, len(serverEvents))
+	for _, serverEvent := range serverEvents {
+		reply, err := s.convertServerEvent(serverEvent)
 		if err != nil {
-			return fmt.Errorf("failed to process event: %w", err)
+			return []*pb.ServerEvent{s.errorFrame(clientEvent, err)}
 		}
+		replies = append(replies, reply)
+	}
+
+	return replies
+}
+
+// createChallenge creates a challenge owned by the stream session
+func (s *CaptchaService) createChallenge(ctx context.Context, session *streamSession, clientEvent *pb.ClientEvent) *pb.ServerEvent {
+	// Auto and passive mode ignore the requested complexity
+	if !clientEvent.AutoComplexity && !clientEvent.Passive && (clientEvent.Complexity < 0 || clientEvent.Complexity > 100) {
+		return s.errorFrame(clientEvent, status.Errorf(codes.InvalidArgument, "complexity must be between 0 and 100"))
+	}
+
+	challenge, err := s.captchaUsecase.CreateChallengeWithOptions(ctx, clientEvent.Complexity, domain.ChallengeOptions{
+		Accessible:   clientEvent.Accessible,
+		KeyboardOnly: clientEvent.KeyboardOnly,
+		Auto:         clientEvent.AutoComplexity,
+		Passive:      clientEvent.Passive,
+	})
+	if err != nil {
+		return s.errorFrame(clientEvent, fmt.Errorf("failed to create challenge: %w", err))
+	}
+
+	if err := s.sessions.claim(session, challenge.ID); err != nil {
+		return s.errorFrame(clientEvent, err)
+	}
 
-		// Send server event
-		if err := s.sendServerEvent(stream, serverEvent); err != nil {
-			return fmt.Errorf("failed to send server event: %w", err)
+	return &pb.ServerEvent{
+		Event: &pb.ServerEvent_Created{
+			Created: &pb.ServerEvent_ChallengeCreated{
+				ChallengeId: challenge.ID,
+				Html:        challenge.HTML,
+			},
+		},
+	}
+}
+
+// validateChallenge validates an answer for a challenge owned by the stream session
+func (s *CaptchaService) validateChallenge(ctx context.Context, session *streamSession, clientEvent *pb.ClientEvent) *pb.ServerEvent {
+	if err := s.claimChallenge(ctx, session, clientEvent.ChallengeId); err != nil {
+		return s.errorFrame(clientEvent, err)
+	}
+
+	var answer interface{}
+	if err := json.Unmarshal(clientEvent.Data, &answer); err != nil {
+		return s.errorFrame(clientEvent, status.Errorf(codes.InvalidArgument, "answer must be JSON: %v", err))
+	}
+
+	result, err := s.captchaUsecase.ValidateChallenge(ctx, clientEvent.ChallengeId, answer)
+	if err != nil {
+		return s.errorFrame(clientEvent, fmt.Errorf("failed to validate challenge: %w", err))
+	}
+	if err := result.Err(); err != nil {
+		return s.errorFrame(clientEvent, err)
+	}
+
+	return &pb.ServerEvent{
+		Event: &pb.ServerEvent_Result{
+			Result: &pb.ServerEvent_ChallengeResult{
+				ChallengeId:       result.ChallengeID,
+				ConfidencePercent: result.ConfidencePercent,
+				Solved:            result.Solved,
+			},
+		},
+	}
+}
+
+// foreignChallengeFrame answers an event for a challenge another instance
+// owns with an error frame naming the owner, nil when the challenge is served
+// here or the stream is not behind the affinity middleware
+func (s *CaptchaService) foreignChallengeFrame(ctx context.Context, clientEvent *pb.ClientEvent) *pb.ServerEvent {
+	check, ok := routeCheckFromContext(ctx)
+	if !ok {
+		return nil
+	}
+
+	owner, err := check(clientEvent.ChallengeId)
+	if err == nil {
+		return nil
+	}
+
+	frame := s.errorFrame(clientEvent, err)
+	if owner != "" {
+		frame.GetError().Metadata = map[string]string{"owner_instance": owner}
+	}
+	return frame
+}
+
+// claimChallenge binds an existing challenge to a stream session, unknown IDs
+// are refused before they reach the owners table
+func (s *CaptchaService) claimChallenge(ctx context.Context, session *streamSession, challengeID string) error {
+	if challengeID == "" {
+		return status.Error(codes.InvalidArgument, "challenge_id is required")
+	}
+
+	if _, err := s.captchaUsecase.GetChallenge(ctx, challengeID); err != nil {
+		return err
+	}
+
+	return s.sessions.claim(session, challengeID)
+}
+
+// abandonChallenges abandons challenges of an ended stream session
+func (s *CaptchaService) abandonChallenges(challengeIDs []string) {
+	ctx := context.Background()
+	for _, challengeID := range challengeIDs {
+		// Errors mean the challenge is already gone, nothing left to abandon
+		_ = s.captchaUsecase.AbandonChallenge(ctx, challengeID, usecase.AbandonReasonStreamClosed)
+	}
+}
+
+// establish starts a new session for the stream and sends its ID to the
+// client right away, so it can resume the session before any reply arrives
+func (s *CaptchaService) establish(stream pb.CaptchaService_MakeEventStreamServer) (*streamSession, uint64, error) {
+	session, generation := s.sessions.create()
+
+	// Like the resume handshake, this is a control frame without a sequence number
+	established := &pb.ServerEvent{
+		SessionId: session.id,
+		Event: &pb.ServerEvent_Established{
+			Established: &pb.ServerEvent_SessionEstablished{
+				ResumeTtlMs: s.sessionConfig.TTL.Milliseconds(),
+			},
+		},
+	}
+	if err := stream.Send(established); err != nil {
+		return session, generation, fmt.Errorf("failed to send session handshake: %w", err)
+	}
+
+	return session, generation, nil
+}
+
+// resume answers a RESUME event and replays unacknowledged server events
+func (s *CaptchaService) resume(stream pb.CaptchaService_MakeEventStreamServer, session *streamSession, ack uint64) error {
+	session.ack(ack)
+
+	events, lastClientSeq, err := session.replay(ack)
+	if err != nil {
+		return err
+	}
+
+	// The handshake reply is a control frame without a sequence number, so it
+	// is neither buffered nor replayed
+	resumed := &pb.ServerEvent{
+		SessionId: session.id,
+		Event: &pb.ServerEvent_Resumed{
+			Resumed: &pb.ServerEvent_SessionResumed{
+				LastClientSeq: lastClientSeq,
+				Replayed:      uint32(len(events)),
+			},
+		},
+	}
+	if err := stream.Send(resumed); err != nil {
+		return fmt.Errorf("failed to send resume reply: %w", err)
+	}
+
+	for _, event := range events {
+		if err := stream.Send(event); err != nil {
+			return fmt.Errorf("failed to replay server event: %w", err)
 		}
 	}
+
+	return nil
+}
+
+// send stamps a server event with the session sequence and sends it
+func (s *CaptchaService) send(stream pb.CaptchaService_MakeEventStreamServer, session *streamSession, generation uint64, event *pb.ServerEvent) error {
+	if !session.stamp(event, generation, s.sessionConfig.BufferSize) {
+		return status.Error(codes.Aborted, "stream session was resumed on another stream")
+	}
+
+	if err := stream.Send(event); err != nil {
+		return fmt.Errorf("failed to send server event: %w", err)
+	}
+
+	return nil
+}
+
+// errorFrame builds an error frame answering a client event, carrying the
+// same code and details a unary call would return
+func (s *CaptchaService) errorFrame(clientEvent *pb.ClientEvent, err error) *pb.ServerEvent {
+	frame := &pb.ServerEvent_Error{
+		ChallengeId: clientEvent.ChallengeId,
+		ClientSeq:   clientEvent.Seq,
+		Code:        int32(StatusFromError(err).Code()),
+		Message:     err.Error(),
+	}
+
+	if domainErr, ok := domain.AsError(err); ok {
+		frame.Reason = errorInfo(domainErr).Reason
+		frame.RetryAfterMs = retryAfter(err).Milliseconds()
+		frame.Metadata = domainErr.Metadata
+	}
+
+	return &pb.ServerEvent{Event: &pb.ServerEvent_Error_{Error: frame}}
 }
 
 // convertEventType converts protobuf event type to domain event type
@@ -84,8 +509,12 @@ func (s *CaptchaService) convertEventType(eventType pb.ClientEvent_EventType) do
 	}
 }
 
-// sendServerEvent sends a server event to the client
-func (s *CaptchaService) sendServerEvent(stream pb.CaptchaService_MakeEventStreamServer, event *domain.ServerEvent) error {
+// convertServerEvent converts a domain server event to protobuf
+func (s *CaptchaService) convertServerEvent(event *domain.ServerEvent) (*pb.ServerEvent, error) {
+	if event == nil {
+		return nil, fmt.Errorf("no server event produced")
+	}
+
 	serverEvent := &pb.ServerEvent{}
 
 	switch event.Type {
@@ -94,6 +523,7 @@ func (s *CaptchaService) sendServerEvent(stream pb.CaptchaService_MakeEventStrea
 			Result: &pb.ServerEvent_ChallengeResult{
 				ChallengeId:       event.ChallengeID,
 				ConfidencePercent: event.ConfidencePercent,
+				Solved:            event.Solved,
 			},
 		}
 	case domain.ServerEventTypeRunClientJS:
@@ -111,8 +541,8 @@ func (s *CaptchaService) sendServerEvent(stream pb.CaptchaService_MakeEventStrea
 			},
 		}
 	default:
-		return fmt.Errorf("unknown server event type: %s", event.Type)
+		return nil, fmt.Errorf("unknown server event type: %s", event.Type)
 	}
 
-	return stream.Send(serverEvent)
+	return serverEvent, nil
 }
@@ -0,0 +1,107 @@
+package grpc
+
+import (
+	"time"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/protoadapt"
+	"google.golang.org/protobuf/types/known/durationpb"
+
+	"github.com/FlooooowY/SteelMount-Captcha-Service/internal/domain"
+)
+
+// CodeForKind maps an error kind to its gRPC status code
+func CodeForKind(kind domain.ErrorKind) codes.Code {
+	switch kind {
+	case domain.ErrorKindNotFound:
+		return codes.NotFound
+	case domain.ErrorKindExpired, domain.ErrorKindPrecondition:
+		return codes.FailedPrecondition
+	case domain.ErrorKindExhausted, domain.ErrorKindRateLimited:
+		return codes.ResourceExhausted
+	case domain.ErrorKindBlocked:
+		return codes.PermissionDenied
+	case domain.ErrorKindCapacity:
+		return codes.Unavailable
+	default:
+		return codes.Internal
+	}
+}
+
+// StatusFromError converts an error to a gRPC status, classified errors carry
+// ErrorInfo, RetryInfo, QuotaFailure and PreconditionFailure details
+func StatusFromError(err error) *status.Status {
+	if err == nil {
+		return nil
+	}
+
+	domainErr, ok := domain.AsError(err)
+	if !ok {
+		if st, isStatus := status.FromError(err); isStatus {
+			return st
+		}
+		return status.New(codes.Internal, err.Error())
+	}
+
+	st := status.New(CodeForKind(domainErr.Kind), err.Error())
+
+	details := []protoadapt.MessageV1{errorInfo(domainErr)}
+	if domainErr.RetryAfter > 0 {
+		details = append(details, &errdetails.RetryInfo{RetryDelay: durationpb.New(domainErr.RetryAfter)})
+	}
+	if domainErr.Quota != nil {
+		details = append(details, &errdetails.QuotaFailure{
+			Violations: []*errdetails.QuotaFailure_Violation{{
+				Subject:     domainErr.Quota.Subject,
+				Description: domainErr.Quota.Description,
+			}},
+		})
+	}
+
+	if domainErr.Precondition != nil {
+		details = append(details, &errdetails.PreconditionFailure{
+			Violations: []*errdetails.PreconditionFailure_Violation{{
+				Type:        domainErr.Precondition.Type,
+				Subject:     domainErr.Precondition.Subject,
+				Description: domainErr.Precondition.Description,
+			}},
+		})
+	}
+
+	if withDetails, detailsErr := st.WithDetails(details...); detailsErr == nil {
+		return withDetails
+	}
+	return st
+}
+
+// toStatusError converts an error to a gRPC status error
+func toStatusError(err error) error {
+	if err == nil {
+		return nil
+	}
+	return StatusFromError(err).Err()
+}
+
+// errorInfo builds the ErrorInfo detail of a classified error
+func errorInfo(err *domain.Error) *errdetails.ErrorInfo {
+	reason := err.Reason
+	if reason == "" {
+		reason = string(err.Kind)
+	}
+
+	return &errdetails.ErrorInfo{
+		Reason:   reason,
+		Domain:   domain.ErrorDomain,
+		Metadata: err.Metadata,
+	}
+}
+
+// retryAfter returns the retry delay of a classified error
+func retryAfter(err error) time.Duration {
+	if domainErr, ok := domain.AsError(err); ok {
+		return domainErr.RetryAfter
+	}
+	return 0
+}
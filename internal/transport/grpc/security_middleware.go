@@ -3,7 +3,6 @@ package grpc
 import (
 	"context"
 	"net"
-	"strings"
 
 	"github.com/FlooooowY/SteelMount-Captcha-Service/internal/security"
 	"google.golang.org/grpc"
@@ -38,11 +37,11 @@ func (sm *SecurityMiddleware) UnaryInterceptor() grpc.UnaryServerInterceptor {
 		}
 
 		if !result.Allowed {
-			return nil, status.Errorf(codes.PermissionDenied, "request blocked: %s", strings.Join(result.Reasons, ", "))
+			return nil, toStatusError(result.Err())
 		}
 
-		// Call the actual handler
-		return handler(ctx, req)
+		// Call the actual handler, it reaches the client for risk assessment and challenge results
+		return handler(security.ContextWithClient(ctx, ip, userAgent), req)
 	}
 }
 
@@ -59,14 +58,28 @@ func (sm *SecurityMiddleware) StreamInterceptor() grpc.StreamServerInterceptor {
 		}
 
 		if !result.Allowed {
-			return status.Errorf(codes.PermissionDenied, "request blocked: %s", strings.Join(result.Reasons, ", "))
+			return toStatusError(result.Err())
 		}
 
-		// Call the actual handler
-		return handler(srv, ss)
+		// Call the actual handler, it reaches the client for risk assessment and challenge results
+		return handler(srv, &clientServerStream{
+			ServerStream: ss,
+			ctx:          security.ContextWithClient(ss.Context(), ip, userAgent),
+		})
 	}
 }
 
+// clientServerStream exposes the client to the stream handler
+type clientServerStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+// Context returns the context carrying the client
+func (s *clientServerStream) Context() context.Context {
+	return s.ctx
+}
+
 // extractClientInfo extracts IP address and user agent from gRPC context
 func (sm *SecurityMiddleware) extractClientInfo(ctx context.Context) (string, string) {
 	// Extract IP address
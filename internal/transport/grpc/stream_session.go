@@ -0,0 +1,349 @@
+package grpc
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/FlooooowY/SteelMount-Captcha-Service/internal/domain"
+	"github.com/FlooooowY/SteelMount-Captcha-Service/internal/routing"
+	pb "github.com/FlooooowY/SteelMount-Captcha-Service/proto/captcha/v1"
+)
+
+// StreamSessionConfig contains event stream session settings
+type StreamSessionConfig struct {
+	TTL        time.Duration    // How long a detached session can be resumed
+	BufferSize int              // Unacknowledged server events kept for replay
+	IDCodec    *routing.IDCodec // Optional, encodes the owner instance into session IDs so resumes are routed to it
+}
+
+// DefaultStreamSessionConfig returns the default session settings
+func DefaultStreamSessionConfig() *StreamSessionConfig {
+	return &StreamSessionConfig{
+		TTL:        2 * time.Minute,
+		BufferSize: 256,
+	}
+}
+
+// streamSession tracks sequence numbers and unacknowledged events of one logical stream
+type streamSession struct {
+	id string
+
+	mu            sync.Mutex
+	nextSeq       uint64
+	lastClientSeq uint64
+	unacked       []*pb.ServerEvent
+	generation    uint64 // Incremented on every attach, older streams stop sending
+	attached      bool
+	detachedAt    time.Time
+	challenges    map[string]struct{}
+}
+
+// SessionClosedHandler is called with the challenges owned by a session that ended or expired
+type SessionClosedHandler func(challengeIDs []string)
+
+// StreamSessionStore keeps event stream sessions so clients can resume after reconnecting
+type StreamSessionStore struct {
+	config   *StreamSessionConfig
+	onClosed SessionClosedHandler
+
+	mu       sync.Mutex
+	sessions map[string]*streamSession
+	owners   map[string]string // challenge ID -> session ID
+}
+
+// NewStreamSessionStore creates a new session store
+func NewStreamSessionStore(config *StreamSessionConfig) *StreamSessionStore {
+	if config == nil {
+		config = DefaultStreamSessionConfig()
+	}
+
+	return &StreamSessionStore{
+		config:   config,
+		sessions: make(map[string]*streamSession),
+		owners:   make(map[string]string),
+	}
+}
+
+// OnSessionClosed sets the handler for challenges left behind by ended sessions
+func (s *StreamSessionStore) OnSessionClosed(handler SessionClosedHandler) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.onClosed = handler
+}
+
+// create starts a new session attached to the calling stream
+func (s *StreamSessionStore) create() (*streamSession, uint64) {
+	session := &streamSession{
+		id:         s.newSessionID(),
+		generation: 1,
+		attached:   true,
+		challenges: make(map[string]struct{}),
+	}
+
+	s.mu.Lock()
+	orphaned := s.cleanupLocked(time.Now())
+	s.sessions[session.id] = session
+	s.mu.Unlock()
+
+	s.notifyClosed(orphaned)
+	return session, session.generation
+}
+
+// newSessionID generates a session ID, bound to this instance when affinity is enabled
+func (s *StreamSessionStore) newSessionID() string {
+	if s.config.IDCodec != nil {
+		return s.config.IDCodec.NewID()
+	}
+
+	return uuid.New().String()
+}
+
+// resume attaches the calling stream to an existing session, taking it over
+// from a previous stream that may not have noticed its connection dropped
+func (s *StreamSessionStore) resume(id string) (*streamSession, uint64, error) {
+	s.mu.Lock()
+	orphaned := s.cleanupLocked(time.Now())
+	session, exists := s.sessions[id]
+	s.mu.Unlock()
+
+	s.notifyClosed(orphaned)
+	if !exists {
+		return nil, 0, domain.NewError(domain.ErrorKindNotFound, domain.ReasonSessionExpired,
+			fmt.Sprintf("stream session %s not found or expired", id)).WithMetadata("session_id", id)
+	}
+
+	session.mu.Lock()
+	defer session.mu.Unlock()
+
+	session.generation++
+	session.attached = true
+	return session, session.generation, nil
+}
+
+// detach marks the session as resumable when its stream ends
+func (s *StreamSessionStore) detach(session *streamSession, generation uint64) {
+	session.mu.Lock()
+	defer session.mu.Unlock()
+
+	if session.generation == generation {
+		session.attached = false
+		session.detachedAt = time.Now()
+	}
+}
+
+// remove deletes a session that ended normally
+func (s *StreamSessionStore) remove(session *streamSession) {
+	s.mu.Lock()
+	orphaned := s.removeLocked(session)
+	s.mu.Unlock()
+
+	s.notifyClosed(orphaned)
+}
+
+// claim binds a challenge to a session, failing when another session owns it
+func (s *StreamSessionStore) claim(session *streamSession, challengeID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if owner, owned := s.owners[challengeID]; owned && owner != session.id {
+		return status.Errorf(codes.PermissionDenied, "challenge %s belongs to another stream", challengeID)
+	}
+
+	s.owners[challengeID] = session.id
+
+	session.mu.Lock()
+	session.challenges[challengeID] = struct{}{}
+	session.mu.Unlock()
+
+	return nil
+}
+
+// release unbinds a challenge from its session
+func (s *StreamSessionStore) release(session *streamSession, challengeID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.owners[challengeID] == session.id {
+		delete(s.owners, challengeID)
+	}
+
+	session.mu.Lock()
+	delete(session.challenges, challengeID)
+	session.mu.Unlock()
+}
+
+// Cleanup removes sessions detached for longer than the TTL
+func (s *StreamSessionStore) Cleanup() {
+	s.mu.Lock()
+	orphaned := s.cleanupLocked(time.Now())
+	s.mu.Unlock()
+
+	s.notifyClosed(orphaned)
+}
+
+// StartCleanupRoutine starts a background cleanup routine
+func (s *StreamSessionStore) StartCleanupRoutine(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.Cleanup()
+		}
+	}
+}
+
+// cleanupLocked removes expired sessions and returns their challenges, the caller holds s.mu
+func (s *StreamSessionStore) cleanupLocked(now time.Time) []string {
+	var orphaned []string
+	for _, session := range s.sessions {
+		session.mu.Lock()
+		expired := !session.attached && now.Sub(session.detachedAt) > s.config.TTL
+		session.mu.Unlock()
+
+		if expired {
+			orphaned = append(orphaned, s.removeLocked(session)...)
+		}
+	}
+
+	return orphaned
+}
+
+// removeLocked deletes a session with its ownerships and returns its challenges, the caller holds s.mu
+func (s *StreamSessionStore) removeLocked(session *streamSession) []string {
+	delete(s.sessions, session.id)
+
+	session.mu.Lock()
+	defer session.mu.Unlock()
+
+	challenges := make([]string, 0, len(session.challenges))
+	for challengeID := range session.challenges {
+		if s.owners[challengeID] == session.id {
+			delete(s.owners, challengeID)
+		}
+		challenges = append(challenges, challengeID)
+	}
+	session.challenges = make(map[string]struct{})
+
+	return challenges
+}
+
+// notifyClosed hands challenges of ended sessions to the close handler
+func (s *StreamSessionStore) notifyClosed(challenges []string) {
+	s.mu.Lock()
+	handler := s.onClosed
+	s.mu.Unlock()
+
+	if handler != nil && len(challenges) > 0 {
+		handler(challenges)
+	}
+}
+
+// GetStats returns session store statistics
+func (s *StreamSessionStore) GetStats() map[string]interface{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	attached := 0
+	for _, session := range s.sessions {
+		session.mu.Lock()
+		if session.attached {
+			attached++
+		}
+		session.mu.Unlock()
+	}
+
+	return map[string]interface{}{
+		"sessions":          len(s.sessions),
+		"attached_sessions": attached,
+		"owned_challenges":  len(s.owners),
+		"ttl":               s.config.TTL.String(),
+		"buffer_size":       s.config.BufferSize,
+	}
+}
+
+// acceptClientSeq records a client sequence number, reporting false for
+// duplicates resent after a reconnect
+func (s *streamSession) acceptClientSeq(seq uint64) bool {
+	if seq == 0 {
+		return true
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if seq <= s.lastClientSeq {
+		return false
+	}
+
+	s.lastClientSeq = seq
+	return true
+}
+
+// ack drops buffered events the client confirmed
+func (s *streamSession) ack(seq uint64) {
+	if seq == 0 {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i := 0
+	for i < len(s.unacked) && s.unacked[i].Seq <= seq {
+		i++
+	}
+	s.unacked = s.unacked[i:]
+}
+
+// stamp assigns the next sequence number and buffers the event for replay,
+// reporting false when a newer stream took the session over
+func (s *streamSession) stamp(event *pb.ServerEvent, generation uint64, bufferSize int) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.generation != generation {
+		return false
+	}
+
+	s.nextSeq++
+	event.SessionId = s.id
+	event.Seq = s.nextSeq
+
+	s.unacked = append(s.unacked, event)
+	if len(s.unacked) > bufferSize {
+		s.unacked = s.unacked[len(s.unacked)-bufferSize:]
+	}
+
+	return true
+}
+
+// replay returns buffered events after ack and the last processed client sequence number
+func (s *streamSession) replay(ack uint64) ([]*pb.ServerEvent, uint64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(s.unacked) > 0 && s.unacked[0].Seq > ack+1 {
+		return nil, s.lastClientSeq, status.Errorf(codes.DataLoss,
+			"events after %d are no longer buffered, oldest is %d", ack, s.unacked[0].Seq)
+	}
+
+	var events []*pb.ServerEvent
+	for _, event := range s.unacked {
+		if event.Seq > ack {
+			events = append(events, event)
+		}
+	}
+
+	return events, s.lastClientSeq, nil
+}
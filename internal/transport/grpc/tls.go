@@ -0,0 +1,50 @@
+package grpc
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+
+	"google.golang.org/grpc/credentials"
+)
+
+// TLSConfig contains the certificate files used to serve and dial peers over TLS
+type TLSConfig struct {
+	CertFile string
+	KeyFile  string
+	CAFile   string // Verifies peer certificates, system roots are used when empty
+}
+
+// LoadTLSCredentials loads the server credentials and the credentials for
+// dialing peer instances, which present the same certificate
+func LoadTLSCredentials(config TLSConfig) (server, peer credentials.TransportCredentials, err error) {
+	cert, err := tls.LoadX509KeyPair(config.CertFile, config.KeyFile)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to load TLS key pair: %w", err)
+	}
+
+	var roots *x509.CertPool
+	if config.CAFile != "" {
+		pem, err := os.ReadFile(config.CAFile)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to read TLS CA file: %w", err)
+		}
+		roots = x509.NewCertPool()
+		if !roots.AppendCertsFromPEM(pem) {
+			return nil, nil, fmt.Errorf("no certificates found in TLS CA file %s", config.CAFile)
+		}
+	}
+
+	server = credentials.NewTLS(&tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	})
+	peer = credentials.NewTLS(&tls.Config{
+		Certificates: []tls.Certificate{cert},
+		RootCAs:      roots,
+		MinVersion:   tls.VersionTLS12,
+	})
+
+	return server, peer, nil
+}
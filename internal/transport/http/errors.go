@@ -0,0 +1,91 @@
+package http
+
+import (
+	"encoding/json"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+
+	"github.com/FlooooowY/SteelMount-Captcha-Service/internal/domain"
+)
+
+// Error codes returned in error bodies
+const (
+	CodeInvalidArgument    = "invalid_argument"
+	CodeNotFound           = "not_found"
+	CodePermissionDenied   = "permission_denied"
+	CodeFailedPrecondition = "failed_precondition"
+	CodeResourceExhausted  = "resource_exhausted"
+	CodeUnavailable        = "unavailable"
+	CodeInternal           = "internal"
+)
+
+// ErrorBody is the body of every error response
+type ErrorBody struct {
+	Error ErrorDetail `json:"error"`
+}
+
+// ErrorDetail describes an API error
+type ErrorDetail struct {
+	Code         string            `json:"code"`
+	Message      string            `json:"message"`
+	Reason       string            `json:"reason,omitempty" description:"Stable reason of a service error, e.g. CHALLENGE_EXPIRED"`
+	RetryAfterMs int64             `json:"retry_after_ms,omitempty" description:"Suggested retry delay, also sent as Retry-After"`
+	Metadata     map[string]string `json:"metadata,omitempty"`
+}
+
+// writeJSON writes a JSON response
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(body); err != nil {
+		log.Printf("Error encoding response: %v", err)
+	}
+}
+
+// writeError writes an error response
+func writeError(w http.ResponseWriter, status int, code, message string) {
+	writeJSON(w, status, &ErrorBody{Error: ErrorDetail{Code: code, Message: message}})
+}
+
+// writeServiceError maps a service error to an error response with the same
+// classification gRPC reports in its status details
+func writeServiceError(w http.ResponseWriter, err error) {
+	domainErr, ok := domain.AsError(err)
+	if !ok {
+		writeError(w, http.StatusInternalServerError, CodeInternal, err.Error())
+		return
+	}
+
+	status, code := statusForKind(domainErr.Kind)
+	if domainErr.RetryAfter > 0 {
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(domainErr.RetryAfter.Seconds()))))
+	}
+
+	writeJSON(w, status, &ErrorBody{Error: ErrorDetail{
+		Code:         code,
+		Message:      err.Error(),
+		Reason:       domainErr.Reason,
+		RetryAfterMs: domainErr.RetryAfter.Milliseconds(),
+		Metadata:     domainErr.Metadata,
+	}})
+}
+
+// statusForKind maps an error kind to an HTTP status and error code
+func statusForKind(kind domain.ErrorKind) (int, string) {
+	switch kind {
+	case domain.ErrorKindNotFound:
+		return http.StatusNotFound, CodeNotFound
+	case domain.ErrorKindExpired, domain.ErrorKindPrecondition:
+		return http.StatusConflict, CodeFailedPrecondition
+	case domain.ErrorKindExhausted, domain.ErrorKindRateLimited:
+		return http.StatusTooManyRequests, CodeResourceExhausted
+	case domain.ErrorKindBlocked:
+		return http.StatusForbidden, CodePermissionDenied
+	case domain.ErrorKindCapacity:
+		return http.StatusServiceUnavailable, CodeUnavailable
+	default:
+		return http.StatusInternalServerError, CodeInternal
+	}
+}
@@ -0,0 +1,99 @@
+package http
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/FlooooowY/SteelMount-Captcha-Service/internal/monitoring"
+	"github.com/FlooooowY/SteelMount-Captcha-Service/internal/security"
+	"github.com/FlooooowY/SteelMount-Captcha-Service/internal/usecase"
+)
+
+// GatewayConfig contains REST gateway settings
+type GatewayConfig struct {
+	AllowedOrigins    []string // CORS origins, empty disables CORS headers, "*" allows any
+	MaxBodyBytes      int64
+	TrustForwardedFor bool
+}
+
+// DefaultGatewayConfig returns the default gateway settings
+func DefaultGatewayConfig() *GatewayConfig {
+	return &GatewayConfig{
+		MaxBodyBytes: 64 * 1024,
+	}
+}
+
+// Gateway serves the captcha API as HTTP/JSON
+type Gateway struct {
+	captchaUsecase  usecase.CaptchaUsecase
+	securityService *security.SecurityService
+	metrics         *monitoring.Metrics
+	config          *GatewayConfig
+	routes          []route
+	port            int
+	server          *http.Server
+}
+
+// NewGateway creates a new REST gateway, securityService and metrics may be nil
+func NewGateway(captchaUsecase usecase.CaptchaUsecase, securityService *security.SecurityService, metrics *monitoring.Metrics, port int, config *GatewayConfig) *Gateway {
+	if config == nil {
+		config = DefaultGatewayConfig()
+	}
+
+	g := &Gateway{
+		captchaUsecase:  captchaUsecase,
+		securityService: securityService,
+		metrics:         metrics,
+		config:          config,
+		port:            port,
+	}
+	g.routes = g.apiRoutes()
+
+	return g
+}
+
+// Handler returns the HTTP handler serving the API
+func (g *Gateway) Handler() http.Handler {
+	mux := http.NewServeMux()
+
+	// Every API route runs through tracing, metrics, security and body limits, like gRPC interceptors
+	for _, rt := range g.routes {
+		mux.Handle(rt.method+" "+rt.pattern, g.tracingMiddleware(rt.pattern,
+			g.metricsMiddleware(rt.pattern, g.securityMiddleware(rt.pattern, g.limitBody(rt.handler)))))
+	}
+
+	// OpenAPI document generated from the route table
+	mux.HandleFunc("GET /openapi.json", g.handleOpenAPI)
+
+	return g.corsMiddleware(mux)
+}
+
+// Start starts the HTTP server
+func (g *Gateway) Start(ctx context.Context) error {
+	g.server = &http.Server{
+		Addr:              fmt.Sprintf(":%d", g.port),
+		Handler:           g.Handler(),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	// Start server in goroutine
+	go func() {
+		if err := g.server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			log.Printf("REST gateway error: %v", err)
+		}
+	}()
+
+	log.Printf("REST gateway started on port %d", g.port)
+	return nil
+}
+
+// Stop stops the HTTP server
+func (g *Gateway) Stop(ctx context.Context) error {
+	if g.server != nil {
+		return g.server.Shutdown(ctx)
+	}
+	return nil
+}
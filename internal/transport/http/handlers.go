@@ -0,0 +1,237 @@
+package http
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/FlooooowY/SteelMount-Captcha-Service/internal/domain"
+)
+
+// CreateChallengeRequest is the body of POST /v1/challenges
+type CreateChallengeRequest struct {
+	Complexity     int32 `json:"complexity" description:"Challenge complexity from 0 to 100"`
+	Accessible     bool  `json:"accessible,omitempty" description:"Request a challenge usable without sight, an audio challenge"`
+	KeyboardOnly   bool  `json:"keyboard_only,omitempty" description:"Request a challenge answered by typing, for clients without pointer or touch input"`
+	AutoComplexity bool  `json:"auto_complexity,omitempty" description:"Not supported over HTTP and rejected when true: high risk auto mode challenges are answered round by round over the gRPC event stream"`
+	Passive        bool  `json:"passive,omitempty" description:"Not supported over HTTP and rejected when true: the passive probe reports and escalates over the gRPC event stream"`
+}
+
+// ChallengeResponse describes a challenge
+type ChallengeResponse struct {
+	ID         string    `json:"id"`
+	Type       string    `json:"type"`
+	Complexity int32     `json:"complexity"`
+	HTML       string    `json:"html,omitempty" description:"Challenge markup, only returned on creation"`
+	CreatedAt  time.Time `json:"created_at"`
+	ExpiresAt  time.Time `json:"expires_at"`
+	Solved     bool      `json:"solved"`
+	Abandoned  bool      `json:"abandoned"`
+}
+
+// SubmitAnswerRequest is the body of POST /v1/challenges/{id}/answer
+type SubmitAnswerRequest struct {
+	Answer interface{} `json:"answer" description:"Answer in the format of the challenge type"`
+}
+
+// AnswerResponse is the result of an answer submission
+type AnswerResponse struct {
+	ChallengeID       string `json:"challenge_id"`
+	Solved            bool   `json:"solved"`
+	ConfidencePercent int32  `json:"confidence_percent"`
+	TimeToSolveMs     int64  `json:"time_to_solve_ms"`
+}
+
+// VerifyResponse tells a backend whether a challenge was solved
+type VerifyResponse struct {
+	ChallengeID string `json:"challenge_id"`
+	Solved      bool   `json:"solved"`
+}
+
+// route is an API operation, it drives both the mux and the OpenAPI document
+type route struct {
+	method      string
+	pattern     string
+	operationID string
+	summary     string
+	request     interface{} // Request body type, nil when there is none
+	response    interface{} // Success response body type
+	status      int         // Success status code
+	errors      []int       // Documented error status codes
+	handler     http.HandlerFunc
+}
+
+// apiRoutes returns the API route table
+func (g *Gateway) apiRoutes() []route {
+	return []route{
+		{
+			method:      http.MethodPost,
+			pattern:     "/v1/challenges",
+			operationID: "createChallenge",
+			summary:     "Create a new challenge",
+			request:     CreateChallengeRequest{},
+			response:    ChallengeResponse{},
+			status:      http.StatusCreated,
+			errors:      []int{http.StatusBadRequest, http.StatusForbidden, http.StatusTooManyRequests, http.StatusInternalServerError, http.StatusServiceUnavailable},
+			handler:     g.handleCreateChallenge,
+		},
+		{
+			method:      http.MethodGet,
+			pattern:     "/v1/challenges/{id}",
+			operationID: "getChallenge",
+			summary:     "Get a challenge",
+			response:    ChallengeResponse{},
+			status:      http.StatusOK,
+			errors:      []int{http.StatusForbidden, http.StatusNotFound, http.StatusTooManyRequests, http.StatusInternalServerError},
+			handler:     g.handleGetChallenge,
+		},
+		{
+			method:      http.MethodPost,
+			pattern:     "/v1/challenges/{id}/answer",
+			operationID: "submitAnswer",
+			summary:     "Submit an answer to a challenge",
+			request:     SubmitAnswerRequest{},
+			response:    AnswerResponse{},
+			status:      http.StatusOK,
+			errors:      []int{http.StatusBadRequest, http.StatusForbidden, http.StatusNotFound, http.StatusConflict, http.StatusTooManyRequests, http.StatusInternalServerError},
+			handler:     g.handleSubmitAnswer,
+		},
+		{
+			method:      http.MethodPost,
+			pattern:     "/v1/challenges/{id}/verify",
+			operationID: "verifyChallenge",
+			summary:     "Verify that a challenge was solved",
+			response:    VerifyResponse{},
+			status:      http.StatusOK,
+			errors:      []int{http.StatusForbidden, http.StatusNotFound, http.StatusConflict, http.StatusTooManyRequests, http.StatusInternalServerError},
+			handler:     g.handleVerifyChallenge,
+		},
+	}
+}
+
+// handleCreateChallenge handles POST /v1/challenges
+func (g *Gateway) handleCreateChallenge(w http.ResponseWriter, r *http.Request) {
+	var req CreateChallengeRequest
+	if !decodeBody(w, r, &req) {
+		return
+	}
+
+	if req.Complexity < 0 || req.Complexity > 100 {
+		writeError(w, http.StatusBadRequest, CodeInvalidArgument, "complexity must be between 0 and 100")
+		return
+	}
+	if req.AutoComplexity {
+		writeError(w, http.StatusBadRequest, CodeInvalidArgument, "auto_complexity requires the gRPC event stream")
+		return
+	}
+	if req.Passive {
+		writeError(w, http.StatusBadRequest, CodeInvalidArgument, "passive requires the gRPC event stream")
+		return
+	}
+
+	challenge, err := g.captchaUsecase.CreateChallengeWithOptions(r.Context(), req.Complexity, domain.ChallengeOptions{
+		Accessible:   req.Accessible,
+		KeyboardOnly: req.KeyboardOnly,
+	})
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	response := toChallengeResponse(challenge)
+	response.HTML = challenge.HTML
+	writeJSON(w, http.StatusCreated, response)
+}
+
+// handleGetChallenge handles GET /v1/challenges/{id}
+func (g *Gateway) handleGetChallenge(w http.ResponseWriter, r *http.Request) {
+	challenge, err := g.captchaUsecase.GetChallenge(r.Context(), r.PathValue("id"))
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, toChallengeResponse(challenge))
+}
+
+// handleSubmitAnswer handles POST /v1/challenges/{id}/answer
+func (g *Gateway) handleSubmitAnswer(w http.ResponseWriter, r *http.Request) {
+	var req SubmitAnswerRequest
+	if !decodeBody(w, r, &req) {
+		return
+	}
+
+	if req.Answer == nil {
+		writeError(w, http.StatusBadRequest, CodeInvalidArgument, "answer is required")
+		return
+	}
+
+	result, err := g.captchaUsecase.ValidateChallenge(r.Context(), r.PathValue("id"), req.Answer)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	if err := result.Err(); err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, &AnswerResponse{
+		ChallengeID:       result.ChallengeID,
+		Solved:            result.Solved,
+		ConfidencePercent: result.ConfidencePercent,
+		TimeToSolveMs:     result.TimeToSolve,
+	})
+}
+
+// handleVerifyChallenge handles POST /v1/challenges/{id}/verify, a solved
+// challenge is consumed and verifying it again answers 409
+func (g *Gateway) handleVerifyChallenge(w http.ResponseWriter, r *http.Request) {
+	challengeID := r.PathValue("id")
+	solved, err := g.captchaUsecase.VerifyChallenge(r.Context(), challengeID)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, &VerifyResponse{
+		ChallengeID: challengeID,
+		Solved:      solved,
+	})
+}
+
+// decodeBody decodes a JSON request body, writing an error response on failure
+func decodeBody(w http.ResponseWriter, r *http.Request, dst interface{}) bool {
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+
+	if err := decoder.Decode(dst); err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			writeError(w, http.StatusRequestEntityTooLarge, CodeInvalidArgument,
+				fmt.Sprintf("request body exceeds %d bytes", maxBytesErr.Limit))
+			return false
+		}
+
+		writeError(w, http.StatusBadRequest, CodeInvalidArgument, "invalid JSON body: "+err.Error())
+		return false
+	}
+
+	return true
+}
+
+// toChallengeResponse converts a domain challenge without its markup
+func toChallengeResponse(challenge *domain.Challenge) *ChallengeResponse {
+	return &ChallengeResponse{
+		ID:         challenge.ID,
+		Type:       string(challenge.Type),
+		Complexity: challenge.Complexity,
+		CreatedAt:  challenge.CreatedAt,
+		ExpiresAt:  challenge.ExpiresAt,
+		Solved:     challenge.Solved,
+		Abandoned:  challenge.Abandoned,
+	}
+}
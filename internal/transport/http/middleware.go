@@ -0,0 +1,152 @@
+package http
+
+import (
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/FlooooowY/SteelMount-Captcha-Service/internal/security"
+	"github.com/FlooooowY/SteelMount-Captcha-Service/internal/tracing"
+)
+
+// corsMiddleware adds CORS headers for allowed origins and answers preflight requests
+func (g *Gateway) corsMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		origin := r.Header.Get("Origin")
+		if origin != "" && g.originAllowed(origin) {
+			w.Header().Set("Access-Control-Allow-Origin", origin)
+			w.Header().Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS")
+			w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, Traceparent, Tracestate")
+			w.Header().Set("Access-Control-Max-Age", "600")
+			w.Header().Add("Vary", "Origin")
+		}
+
+		if r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != "" {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// originAllowed matches an origin against the CORS allow-list
+func (g *Gateway) originAllowed(origin string) bool {
+	for _, allowed := range g.config.AllowedOrigins {
+		if allowed == "*" || strings.EqualFold(allowed, origin) {
+			return true
+		}
+	}
+	return false
+}
+
+// securityMiddleware runs the same security checks as the gRPC interceptors
+func (g *Gateway) securityMiddleware(route string, next http.Handler) http.Handler {
+	if g.securityService == nil {
+		return next
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ip := g.clientIP(r)
+		result, err := g.securityService.CheckRequest(r.Context(), ip, r.UserAgent(), route, 0, false)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, CodeInternal, "security check failed: "+err.Error())
+			return
+		}
+
+		if !result.Allowed {
+			writeServiceError(w, result.Err())
+			return
+		}
+
+		// Handlers reach the client for risk assessment and challenge results
+		next.ServeHTTP(w, r.WithContext(security.ContextWithClient(r.Context(), ip, r.UserAgent())))
+	})
+}
+
+// metricsMiddleware records request metrics labelled with the route pattern
+// rather than the raw path, so challenge IDs do not become label values
+func (g *Gateway) metricsMiddleware(route string, next http.Handler) http.Handler {
+	if g.metrics == nil {
+		return next
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+
+		recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(recorder, r)
+
+		duration := time.Since(start)
+		g.metrics.RecordRequest(r.Context(), r.Method, route, strconv.Itoa(recorder.status), duration)
+		g.metrics.RecordResponseTime(r.Context(), route, duration)
+	})
+}
+
+// tracingMiddleware starts a server span per request, continuing a W3C traceparent header
+func (g *Gateway) tracingMiddleware(route string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx, span := tracing.Tracer().Start(tracing.ExtractHTTP(r.Context(), r.Header), r.Method+" "+route,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				attribute.String("http.request.method", r.Method),
+				attribute.String("http.route", route),
+			),
+		)
+		defer span.End()
+
+		recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(recorder, r.WithContext(ctx))
+
+		span.SetAttributes(attribute.Int("http.response.status_code", recorder.status))
+		if recorder.status >= http.StatusInternalServerError {
+			span.SetStatus(codes.Error, http.StatusText(recorder.status))
+		}
+	})
+}
+
+// limitBody caps the request body size
+func (g *Gateway) limitBody(next http.Handler) http.Handler {
+	if g.config.MaxBodyBytes <= 0 {
+		return next
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.Body = http.MaxBytesReader(w, r.Body, g.config.MaxBodyBytes)
+		next.ServeHTTP(w, r)
+	})
+}
+
+// clientIP returns the client address, honouring X-Forwarded-For when trusted
+func (g *Gateway) clientIP(r *http.Request) string {
+	if g.config.TrustForwardedFor {
+		if forwarded := r.Header.Get("X-Forwarded-For"); forwarded != "" {
+			return strings.TrimSpace(strings.Split(forwarded, ",")[0])
+		}
+	}
+
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+
+	return host
+}
+
+// statusRecorder captures the response status code
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+// WriteHeader records the status code
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
@@ -0,0 +1,181 @@
+package http
+
+import (
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// OpenAPIVersion is the OpenAPI specification version of the generated document
+const OpenAPIVersion = "3.0.3"
+
+// OpenAPISpec builds the OpenAPI document from the route table
+func (g *Gateway) OpenAPISpec() map[string]interface{} {
+	schemas := map[string]interface{}{}
+	paths := map[string]interface{}{}
+
+	errorRef := schemaRef(reflect.TypeOf(ErrorBody{}), schemas)
+
+	for _, rt := range g.routes {
+		operation := map[string]interface{}{
+			"operationId": rt.operationID,
+			"summary":     rt.summary,
+		}
+
+		if params := pathParameters(rt.pattern); len(params) > 0 {
+			operation["parameters"] = params
+		}
+
+		if rt.request != nil {
+			operation["requestBody"] = map[string]interface{}{
+				"required": true,
+				"content":  jsonContent(schemaRef(reflect.TypeOf(rt.request), schemas)),
+			}
+		}
+
+		responses := map[string]interface{}{
+			strconv.Itoa(rt.status): map[string]interface{}{
+				"description": http.StatusText(rt.status),
+				"content":     jsonContent(schemaRef(reflect.TypeOf(rt.response), schemas)),
+			},
+		}
+		for _, code := range rt.errors {
+			responses[strconv.Itoa(code)] = map[string]interface{}{
+				"description": http.StatusText(code),
+				"content":     jsonContent(errorRef),
+			}
+		}
+		operation["responses"] = responses
+
+		item, ok := paths[rt.pattern].(map[string]interface{})
+		if !ok {
+			item = map[string]interface{}{}
+			paths[rt.pattern] = item
+		}
+		item[strings.ToLower(rt.method)] = operation
+	}
+
+	return map[string]interface{}{
+		"openapi": OpenAPIVersion,
+		"info": map[string]interface{}{
+			"title":   "SteelMount Captcha API",
+			"version": "v1",
+		},
+		"paths": paths,
+		"components": map[string]interface{}{
+			"schemas": schemas,
+		},
+	}
+}
+
+// handleOpenAPI serves the generated OpenAPI document
+func (g *Gateway) handleOpenAPI(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, g.OpenAPISpec())
+}
+
+// pathParameters describes the {name} segments of a route pattern
+func pathParameters(pattern string) []interface{} {
+	var params []interface{}
+	for _, segment := range strings.Split(pattern, "/") {
+		if strings.HasPrefix(segment, "{") && strings.HasSuffix(segment, "}") {
+			params = append(params, map[string]interface{}{
+				"name":     strings.Trim(segment, "{}"),
+				"in":       "path",
+				"required": true,
+				"schema":   map[string]interface{}{"type": "string"},
+			})
+		}
+	}
+	return params
+}
+
+// jsonContent wraps a schema as an application/json media type
+func jsonContent(schema map[string]interface{}) map[string]interface{} {
+	return map[string]interface{}{
+		"application/json": map[string]interface{}{"schema": schema},
+	}
+}
+
+// schemaRef registers a named struct schema and returns a reference to it
+func schemaRef(t reflect.Type, schemas map[string]interface{}) map[string]interface{} {
+	if _, exists := schemas[t.Name()]; !exists {
+		schemas[t.Name()] = map[string]interface{}{} // Placeholder for recursive types
+		schemas[t.Name()] = structSchema(t, schemas)
+	}
+	return map[string]interface{}{"$ref": "#/components/schemas/" + t.Name()}
+}
+
+// structSchema builds an object schema from the json tags of a struct
+func structSchema(t reflect.Type, schemas map[string]interface{}) map[string]interface{} {
+	properties := map[string]interface{}{}
+	var required []string
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		name, options, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+
+		schema := typeSchema(field.Type, schemas)
+		if description := field.Tag.Get("description"); description != "" {
+			if _, isRef := schema["$ref"]; !isRef {
+				schema["description"] = description
+			}
+		}
+		properties[name] = schema
+
+		if !strings.Contains(options, "omitempty") {
+			required = append(required, name)
+		}
+	}
+
+	schema := map[string]interface{}{
+		"type":       "object",
+		"properties": properties,
+	}
+	if len(required) > 0 {
+		schema["required"] = required
+	}
+	return schema
+}
+
+// typeSchema maps a Go type to a JSON schema
+func typeSchema(t reflect.Type, schemas map[string]interface{}) map[string]interface{} {
+	if t == reflect.TypeOf(time.Time{}) {
+		return map[string]interface{}{"type": "string", "format": "date-time"}
+	}
+
+	switch t.Kind() {
+	case reflect.Ptr:
+		return typeSchema(t.Elem(), schemas)
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int32, reflect.Uint32:
+		return map[string]interface{}{"type": "integer", "format": "int32"}
+	case reflect.Int, reflect.Int64, reflect.Uint, reflect.Uint64:
+		return map[string]interface{}{"type": "integer", "format": "int64"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		return map[string]interface{}{"type": "array", "items": typeSchema(t.Elem(), schemas)}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": typeSchema(t.Elem(), schemas)}
+	case reflect.Struct:
+		return schemaRef(t, schemas)
+	default:
+		// interface{} accepts any JSON value
+		return map[string]interface{}{}
+	}
+}
@@ -0,0 +1,46 @@
+package usecase
+
+import (
+	"context"
+	"math/rand"
+	"time"
+
+	"github.com/FlooooowY/SteelMount-Captcha-Service/internal/domain"
+)
+
+// Calibrator maps requested complexity to the generator level of a challenge
+// type and learns the mapping from answers, see calibration.Calibrator
+type Calibrator interface {
+	Level(challengeType string, complexity int32, rng *rand.Rand) int32
+	Record(challengeType string, level int32, solved bool, elapsed time.Duration)
+}
+
+// calibratedLevel returns the generator level for the requested complexity,
+// the passive probe has no levels
+func (u *captchaUsecase) calibratedLevel(rng *rand.Rand, challengeType domain.ChallengeType, complexity int32) int32 {
+	if u.config.Calibrator == nil || challengeType == domain.ChallengeTypePassive {
+		return complexity
+	}
+	return u.config.Calibrator.Level(string(challengeType), complexity, rng)
+}
+
+// recordAnswer feeds one answer to the calibrator. Answers of risky clients
+// are left out, bots failing on purpose would otherwise make every level easier
+func (u *captchaUsecase) recordAnswer(ctx context.Context, challengeType domain.ChallengeType, level int32, solved bool, elapsed time.Duration) {
+	if u.config.Calibrator == nil || challengeType == domain.ChallengeTypePassive {
+		return
+	}
+	if u.config.AssessRisk != nil && u.config.AssessRisk(ctx) >= u.config.CalibrationMaxRisk {
+		return
+	}
+	u.config.Calibrator.Record(string(challengeType), level, solved, elapsed)
+}
+
+// recordFirstAnswer feeds only the first answer of a single-shot challenge to
+// the calibrator, so retries do not count as extra failures
+func (u *captchaUsecase) recordFirstAnswer(ctx context.Context, challenge *domain.Challenge, solved bool) {
+	if challenge.Attempts() != 1 {
+		return
+	}
+	u.recordAnswer(ctx, challenge.Type, challenge.Level(), solved, time.Since(challenge.CreatedAt))
+}
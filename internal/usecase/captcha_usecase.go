@@ -5,12 +5,13 @@ import (
 	"encoding/json"
 	"fmt"
 	"math/rand"
-	"os"
+	"strconv"
 	"time"
 
 	"github.com/FlooooowY/SteelMount-Captcha-Service/internal/captcha"
 	"github.com/FlooooowY/SteelMount-Captcha-Service/internal/domain"
 	"github.com/FlooooowY/SteelMount-Captcha-Service/internal/repository"
+	"github.com/FlooooowY/SteelMount-Captcha-Service/internal/routing"
 	"github.com/google/uuid"
 	"github.com/sirupsen/logrus"
 )
@@ -18,9 +19,12 @@ import (
 // CaptchaUsecase defines the interface for captcha business logic
 type CaptchaUsecase interface {
 	CreateChallenge(ctx context.Context, complexity int32) (*domain.Challenge, error)
+	CreateChallengeWithOptions(ctx context.Context, complexity int32, options domain.ChallengeOptions) (*domain.Challenge, error)
 	ValidateChallenge(ctx context.Context, challengeID string, answer interface{}) (*domain.ChallengeResult, error)
 	GetChallenge(ctx context.Context, challengeID string) (*domain.Challenge, error)
-	ProcessEvent(ctx context.Context, event *domain.Event) (*domain.ServerEvent, error)
+	VerifyChallenge(ctx context.Context, challengeID string) (bool, error)
+	AbandonChallenge(ctx context.Context, challengeID, reason string) error
+	ProcessEvent(ctx context.Context, event *domain.Event) ([]*domain.ServerEvent, error)
 	CleanupExpiredChallenges(ctx context.Context) error
 	GetActiveChallengesCount(ctx context.Context) int
 }
@@ -31,6 +35,7 @@ type captchaUsecase struct {
 	config        *Config
 	engine        *captcha.Engine
 	logger        *logrus.Logger
+	locks         *challengeLocks
 }
 
 // Config represents the usecase configuration
@@ -38,37 +43,147 @@ type Config struct {
 	MaxActiveChallenges int
 	ChallengeTimeout    time.Duration
 	CleanupInterval     time.Duration
+	IDCodec             *routing.IDCodec // Optional, encodes the owner instance into challenge IDs
+
+	// OnAbandon is called for every challenge abandoned by its owner, optional
+	OnAbandon func(challenge *domain.Challenge, reason string)
+
+	// Admit is consulted before every new challenge, an error rejects it, optional.
+	// Follow-up stages of existing challenges are not subject to it.
+	Admit func(ctx context.Context) error
+
+	// StagePolicy drives progressive challenges, zero value uses domain.DefaultStagePolicy
+	StagePolicy domain.StagePolicy
+
+	// MaxAttempts limits single answers to a challenge, zero uses defaultMaxAttempts
+	MaxAttempts int
+
+	// Seeds draws the seed every challenge is generated from, nil uses captcha.CryptoSeed.
+	// Tests inject a fixed source to get the same challenges on every run
+	Seeds captcha.SeedSource
+
+	// Calibrator maps complexity to generator levels and learns from answers,
+	// optional, without it challenges are generated at the requested complexity
+	Calibrator Calibrator
+
+	// CalibrationMaxRisk keeps answers of clients at or above this risk away
+	// from the calibrator, zero uses RiskPolicy.LowRisk. Without AssessRisk
+	// every answer is recorded
+	CalibrationMaxRisk float64
+
+	// AssessRisk returns the risk of the client behind ctx from 0 (trusted) to 1,
+	// it drives auto mode challenges, optional, without it they get medium risk
+	AssessRisk func(ctx context.Context) float64
+
+	// RiskPolicy maps risk to auto mode challenges, zero value uses domain.DefaultRiskPolicy
+	RiskPolicy domain.RiskPolicy
+
+	// OnAnswer is called for every answer to a challenge or one of its stages, optional
+	OnAnswer func(ctx context.Context, challenge *domain.Challenge, solved bool, elapsed time.Duration)
 }
 
+// Abandon reasons recorded in challenge metadata
+const (
+	AbandonReasonWebSocketClosed  = "websocket_closed"
+	AbandonReasonStreamClosed     = "stream_closed"
+	AbandonReasonConnectionClosed = "connection_closed"
+)
+
+// capacityRetryAfter is suggested to clients when the challenge capacity is reached
+const capacityRetryAfter = 5 * time.Second
+
+// defaultMaxAttempts is how many single answers a challenge takes. Types with
+// a small answer space, like a rotation angle, could otherwise be stepped through
+const defaultMaxAttempts = 3
+
 // NewCaptchaUsecase creates a new captcha usecase
 func NewCaptchaUsecase(challengeRepo repository.ChallengeRepository, config *Config) CaptchaUsecase {
 	logger := logrus.New()
 	logger.SetLevel(logrus.InfoLevel)
 
+	if config.StagePolicy.MaxStages == 0 {
+		config.StagePolicy = domain.DefaultStagePolicy()
+	}
+	if config.MaxAttempts <= 0 {
+		config.MaxAttempts = defaultMaxAttempts
+	}
+	if config.RiskPolicy == (domain.RiskPolicy{}) {
+		config.RiskPolicy = domain.DefaultRiskPolicy()
+	}
+	if config.CalibrationMaxRisk <= 0 {
+		config.CalibrationMaxRisk = config.RiskPolicy.LowRisk
+	}
+	seeds := config.Seeds
+	if seeds == nil {
+		seeds = captcha.CryptoSeed
+	}
+
 	return &captchaUsecase{
 		challengeRepo: challengeRepo,
 		config:        config,
-		engine:        captcha.NewEngine(400, 300), // Default canvas size
+		engine:        captcha.NewEngineWithSeedSource(400, 300, seeds), // Default canvas size
 		logger:        logger,
+		locks:         newChallengeLocks(),
 	}
 }
 
 // CreateChallenge creates a new captcha challenge
 func (u *captchaUsecase) CreateChallenge(ctx context.Context, complexity int32) (*domain.Challenge, error) {
+	return u.CreateChallengeWithOptions(ctx, complexity, domain.ChallengeOptions{})
+}
+
+// CreateChallengeWithOptions creates a new captcha challenge meeting the caller's requirements
+func (u *captchaUsecase) CreateChallengeWithOptions(ctx context.Context, complexity int32, options domain.ChallengeOptions) (*domain.Challenge, error) {
 	// Check if we have too many active challenges
 	activeCount := u.challengeRepo.GetActiveCount(ctx)
 	if activeCount >= u.config.MaxActiveChallenges {
-		return nil, fmt.Errorf("maximum active challenges reached: %d", u.config.MaxActiveChallenges)
+		return nil, domain.NewError(domain.ErrorKindCapacity, domain.ReasonCapacityReached,
+			fmt.Sprintf("maximum active challenges reached: %d", u.config.MaxActiveChallenges)).
+			WithRetryAfter(capacityRetryAfter).
+			WithQuota("active_challenges", fmt.Sprintf("at most %d active challenges", u.config.MaxActiveChallenges))
 	}
 
-	// Generate challenge ID
-	challengeID := uuid.New().String()
+	// Let resource limits reject the challenge before any work is done
+	if u.config.Admit != nil {
+		if err := u.config.Admit(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	// Auto mode replaces the requested complexity with one following the client's
+	// risk, passive challenges only assess it once the probe reported
+	var auto *autoSelection
+	if options.Auto && !options.Passive {
+		auto = u.selectAuto(ctx)
+		complexity = auto.complexity
+	}
 
-	// Determine challenge type based on complexity
-	challengeType := u.determineChallengeType(complexity)
+	// Generate challenge ID
+	challengeID := u.newChallengeID()
+
+	// Type, level and content all follow from the seed, seed and level are kept
+	// in the metadata so the exact challenge can be regenerated
+	seed := u.engine.NextSeed()
+	rng := rand.New(rand.NewSource(seed))
+
+	// Determine challenge type based on complexity unless the client can only answer some types
+	var challengeType domain.ChallengeType
+	switch {
+	case options.Passive:
+		challengeType = domain.ChallengeTypePassive
+	case options.Accessible:
+		challengeType = domain.ChallengeTypeAudio
+	case options.KeyboardOnly:
+		challengeType = domain.ChallengeTypeText
+	case auto != nil:
+		challengeType = u.autoChallengeType(rng, auto)
+	default:
+		challengeType = u.determineChallengeType(rng, complexity)
+	}
+	level := u.calibratedLevel(rng, challengeType, complexity)
 
 	// Generate challenge content using engine
-	html, answer, err := u.engine.GenerateChallenge(string(challengeType), complexity)
+	html, answer, err := u.engine.GenerateChallengeContext(ctx, string(challengeType), level, seed)
 	if err != nil {
 		return nil, fmt.Errorf("failed to generate challenge content: %w", err)
 	}
@@ -83,7 +198,23 @@ func (u *captchaUsecase) CreateChallenge(ctx context.Context, complexity int32)
 		CreatedAt:  time.Now(),
 		ExpiresAt:  time.Now().Add(u.config.ChallengeTimeout),
 		Solved:     false,
-		Metadata:   make(map[string]string),
+		Metadata: map[string]string{
+			"seed":  strconv.FormatInt(seed, 10),
+			"level": strconv.FormatInt(int64(level), 10),
+		},
+	}
+	if options.Accessible {
+		challenge.Metadata["accessible"] = "true"
+	}
+	if options.KeyboardOnly {
+		challenge.Metadata["keyboard_only"] = "true"
+	}
+	if auto != nil {
+		challenge.Metadata["auto"] = "true"
+		auto.annotate(challenge.Metadata)
+	}
+	if options.Passive {
+		challenge.Metadata["passive"] = "true"
 	}
 
 	// Store challenge
@@ -96,6 +227,9 @@ func (u *captchaUsecase) CreateChallenge(ctx context.Context, complexity int32)
 
 // ValidateChallenge validates a challenge answer
 func (u *captchaUsecase) ValidateChallenge(ctx context.Context, challengeID string, answer interface{}) (*domain.ChallengeResult, error) {
+	// Concurrent answers to one challenge count one after another against its attempts
+	defer u.locks.lock(challengeID)()
+
 	// Get challenge
 	challenge, err := u.challengeRepo.Get(ctx, challengeID)
 	if err != nil {
@@ -108,7 +242,17 @@ func (u *captchaUsecase) ValidateChallenge(ctx context.Context, challengeID stri
 			ChallengeID:       challengeID,
 			Solved:            false,
 			ConfidencePercent: 0,
-			Error:             "challenge expired",
+			Error:             domain.ResultErrorExpired,
+		}, nil
+	}
+
+	// Check if the owner gave up on the challenge
+	if challenge.Abandoned {
+		return &domain.ChallengeResult{
+			ChallengeID:       challengeID,
+			Solved:            false,
+			ConfidencePercent: 0,
+			Error:             domain.ResultErrorAbandoned,
 		}, nil
 	}
 
@@ -121,15 +265,56 @@ func (u *captchaUsecase) ValidateChallenge(ctx context.Context, challengeID stri
 		}, nil
 	}
 
+	// A challenge the stage machine failed takes no more answers
+	if challenge.StagesFinished() {
+		return &domain.ChallengeResult{
+			ChallengeID:       challengeID,
+			Solved:            false,
+			ConfidencePercent: 0,
+			Error:             domain.ResultErrorExhausted,
+		}, nil
+	}
+
+	// A challenge needing several rounds, starting with a probe or already
+	// answered round by round is only answered over the event stream, a
+	// pushed round cannot be skipped with the answer to the first one
+	if challenge.MinStages() > 1 || challenge.Type == domain.ChallengeTypePassive || challenge.StagesAnswered() {
+		return &domain.ChallengeResult{
+			ChallengeID:       challengeID,
+			Solved:            false,
+			ConfidencePercent: 0,
+			Error:             domain.ResultErrorStagesRequired,
+		}, nil
+	}
+
+	// Every answer counts against the attempt limit
+	attempts := challenge.Attempts()
+	if attempts >= u.config.MaxAttempts {
+		return &domain.ChallengeResult{
+			ChallengeID:       challengeID,
+			Solved:            false,
+			ConfidencePercent: 0,
+			Attempts:          int32(attempts),
+			Error:             domain.ResultErrorExhausted,
+		}, nil
+	}
+	attempts++
+	if challenge.Metadata == nil {
+		challenge.Metadata = make(map[string]string)
+	}
+	challenge.Metadata["attempts"] = strconv.Itoa(attempts)
+
 	// Validate answer
-	isValid, confidence := u.validateAnswer(challenge, answer)
+	isValid, confidence := u.validateAnswer(challenge.Type, challenge.Answer, answer, time.Since(challenge.CreatedAt))
+	u.recordFirstAnswer(ctx, challenge, isValid)
+	u.notifyAnswer(ctx, challenge, isValid, time.Since(challenge.CreatedAt))
 
-	// Update challenge if solved
+	// Update challenge, the attempt is stored even when it failed
 	if isValid {
 		challenge.Solved = true
-		if err := u.challengeRepo.Update(ctx, challenge); err != nil {
-			u.logger.Errorf("Failed to update challenge: %v", err)
-		}
+	}
+	if err := u.challengeRepo.Update(ctx, challenge); err != nil {
+		u.logger.Errorf("Failed to update challenge: %v", err)
 	}
 
 	return &domain.ChallengeResult{
@@ -137,7 +322,7 @@ func (u *captchaUsecase) ValidateChallenge(ctx context.Context, challengeID stri
 		Solved:            isValid,
 		ConfidencePercent: confidence,
 		TimeToSolve:       time.Since(challenge.CreatedAt).Milliseconds(),
-		Attempts:          1, // First attempt
+		Attempts:          int32(attempts),
 	}, nil
 }
 
@@ -146,8 +331,83 @@ func (u *captchaUsecase) GetChallenge(ctx context.Context, challengeID string) (
 	return u.challengeRepo.Get(ctx, challengeID)
 }
 
+// VerifyChallenge reports to a backend whether a challenge was solved and
+// consumes a solved one, verifying it again fails so a solution cannot be replayed
+func (u *captchaUsecase) VerifyChallenge(ctx context.Context, challengeID string) (bool, error) {
+	// An answer still being validated finishes before the challenge is consumed
+	defer u.locks.lock(challengeID)()
+
+	challenge, err := u.challengeRepo.Get(ctx, challengeID)
+	if err != nil {
+		return false, err
+	}
+
+	if challenge.Verified() {
+		return false, domain.ConsumedError(challengeID)
+	}
+	if !challenge.Solved || challenge.Abandoned {
+		return false, nil
+	}
+
+	challenge.Metadata["verified"] = "true"
+	if err := u.challengeRepo.Update(ctx, challenge); err != nil {
+		return false, fmt.Errorf("failed to update challenge: %w", err)
+	}
+
+	return true, nil
+}
+
+// AbandonChallenge marks an unsolved challenge as abandoned and lets cleanup reclaim it
+func (u *captchaUsecase) AbandonChallenge(ctx context.Context, challengeID, reason string) error {
+	defer u.locks.lock(challengeID)()
+
+	challenge, err := u.challengeRepo.Get(ctx, challengeID)
+	if err != nil {
+		return fmt.Errorf("failed to get challenge: %w", err)
+	}
+
+	return u.abandon(ctx, challenge, reason)
+}
+
+// abandon marks a challenge abandoned and records abandonment analytics, the
+// caller holds the challenge lock
+func (u *captchaUsecase) abandon(ctx context.Context, challenge *domain.Challenge, reason string) error {
+	// Solved challenges stay around so backends can still verify them
+	if challenge.Solved || challenge.Abandoned {
+		return nil
+	}
+
+	challenge.Abandoned = true
+	challenge.ExpiresAt = time.Now()
+	if challenge.Metadata == nil {
+		challenge.Metadata = make(map[string]string)
+	}
+	challenge.Metadata["abandon_reason"] = reason
+
+	if err := u.challengeRepo.Update(ctx, challenge); err != nil {
+		return fmt.Errorf("failed to update challenge: %w", err)
+	}
+
+	u.logger.WithFields(logrus.Fields{
+		"challenge_id":   challenge.ID,
+		"challenge_type": challenge.Type,
+		"complexity":     challenge.Complexity,
+		"reason":         reason,
+		"age_ms":         time.Since(challenge.CreatedAt).Milliseconds(),
+	}).Info("Challenge abandoned")
+
+	if u.config.OnAbandon != nil {
+		u.config.OnAbandon(challenge, reason)
+	}
+
+	return nil
+}
+
 // ProcessEvent processes a client event
-func (u *captchaUsecase) ProcessEvent(ctx context.Context, event *domain.Event) (*domain.ServerEvent, error) {
+func (u *captchaUsecase) ProcessEvent(ctx context.Context, event *domain.Event) ([]*domain.ServerEvent, error) {
+	// Stage answers, probe reports and abandonment advance the challenge one at a time
+	defer u.locks.lock(event.ChallengeID)()
+
 	// Get challenge
 	challenge, err := u.challengeRepo.Get(ctx, event.ChallengeID)
 	if err != nil {
@@ -155,16 +415,22 @@ func (u *captchaUsecase) ProcessEvent(ctx context.Context, event *domain.Event)
 	}
 
 	// Process event based on type
+	var serverEvent *domain.ServerEvent
 	switch event.Type {
 	case domain.EventTypeFrontendEvent:
 		return u.processFrontendEvent(ctx, challenge, event)
 	case domain.EventTypeConnectionClosed:
-		return u.processConnectionClosed(ctx, challenge, event)
+		serverEvent, err = u.processConnectionClosed(ctx, challenge, event)
 	case domain.EventTypeBalancerEvent:
-		return u.processBalancerEvent(ctx, challenge, event)
+		serverEvent, err = u.processBalancerEvent(ctx, challenge, event)
 	default:
 		return nil, fmt.Errorf("unknown event type: %s", event.Type)
 	}
+
+	if err != nil {
+		return nil, err
+	}
+	return []*domain.ServerEvent{serverEvent}, nil
 }
 
 // CleanupExpiredChallenges removes expired challenges
@@ -177,49 +443,63 @@ func (u *captchaUsecase) GetActiveChallengesCount(ctx context.Context) int {
 	return u.challengeRepo.GetActiveCount(ctx)
 }
 
-// determineChallengeType determines the challenge type randomly with complexity influence
-func (u *captchaUsecase) determineChallengeType(complexity int32) domain.ChallengeType {
-	// Ultra-enhanced seed for maximum randomness
-	// Generate entropy for randomness (rand.Seed deprecated in Go 1.20+)
-	_ = time.Now().UnixNano() +
-		int64(rand.Intn(1000000)) +
-		int64(complexity*7919) +
-		int64(time.Now().Nanosecond()) +
-		int64(os.Getpid()*23) +
-		int64(time.Now().Second()*1000)
-
-	// Available challenge types (including game for high complexity)
+// newChallengeID generates a challenge ID, bound to this instance when affinity is enabled
+func (u *captchaUsecase) newChallengeID() string {
+	if u.config.IDCodec != nil {
+		return u.config.IDCodec.NewID()
+	}
+
+	return uuid.New().String()
+}
+
+// determineChallengeType determines the challenge type randomly with complexity
+// influence, rng is seeded from the challenge seed so the type is reproducible too
+func (u *captchaUsecase) determineChallengeType(rng *rand.Rand, complexity int32) domain.ChallengeType {
+	// Available challenge types (including game for high complexity), audio and
+	// text are only issued when the client asks for them in ChallengeOptions
 	challengeTypes := []domain.ChallengeType{
 		domain.ChallengeTypeClick,
 		domain.ChallengeTypeDragDrop,
 		domain.ChallengeTypeSwipe,
 		domain.ChallengeTypeGame,
+		domain.ChallengeTypeGrid,
+		domain.ChallengeTypeSlider,
+		domain.ChallengeTypeRotate,
 	}
 
 	// More balanced weights for better distribution
 	weights := make([]int, len(challengeTypes))
 
 	// Add some randomness to weights themselves
-	randomFactor := rand.Intn(20) - 10 // -10 to +10
+	randomFactor := rng.Intn(20) - 10 // -10 to +10
 
 	if complexity < 30 {
 		// Low complexity - slightly favor simple types, no games
-		weights[0] = 40 + randomFactor  // Click
-		weights[1] = 30 + rand.Intn(20) // Drag&Drop
-		weights[2] = 30 + rand.Intn(20) // Swipe
-		weights[3] = 0                  // No games for low complexity
+		weights[0] = 40 + randomFactor // Click
+		weights[1] = 30 + rng.Intn(20) // Drag&Drop
+		weights[2] = 30 + rng.Intn(20) // Swipe
+		weights[3] = 0                 // No games for low complexity
+		weights[4] = 20 + rng.Intn(15) // Grid
+		weights[5] = 25 + rng.Intn(15) // Slider
+		weights[6] = 25 + rng.Intn(15) // Rotate
 	} else if complexity < 60 {
 		// Medium complexity - balanced with occasional games
-		weights[0] = 30 + rand.Intn(15) // Click
-		weights[1] = 30 + rand.Intn(15) // Drag&Drop
-		weights[2] = 30 + rand.Intn(15) // Swipe
-		weights[3] = 10 + rand.Intn(10) // Some games
+		weights[0] = 30 + rng.Intn(15) // Click
+		weights[1] = 30 + rng.Intn(15) // Drag&Drop
+		weights[2] = 30 + rng.Intn(15) // Swipe
+		weights[3] = 10 + rng.Intn(10) // Some games
+		weights[4] = 25 + rng.Intn(15) // Grid
+		weights[5] = 25 + rng.Intn(15) // Slider
+		weights[6] = 20 + rng.Intn(15) // Rotate
 	} else {
 		// High complexity - favor games and complex types
-		weights[0] = 20 + rand.Intn(15) // Click
-		weights[1] = 25 + rand.Intn(15) // Drag&Drop
-		weights[2] = 25 + rand.Intn(15) // Swipe
-		weights[3] = 30 + rand.Intn(20) // More games for high complexity
+		weights[0] = 20 + rng.Intn(15) // Click
+		weights[1] = 25 + rng.Intn(15) // Drag&Drop
+		weights[2] = 25 + rng.Intn(15) // Swipe
+		weights[3] = 30 + rng.Intn(20) // More games for high complexity
+		weights[4] = 25 + rng.Intn(15) // Grid, denser and noisier
+		weights[5] = 20 + rng.Intn(15) // Slider, tighter tolerance and a decoy gap
+		weights[6] = 15 + rng.Intn(15) // Rotate, tighter angular tolerance
 	}
 
 	// Ensure all weights are positive
@@ -235,7 +515,7 @@ func (u *captchaUsecase) determineChallengeType(complexity int32) domain.Challen
 		totalWeight += weight
 	}
 
-	randomValue := rand.Intn(totalWeight)
+	randomValue := rng.Intn(totalWeight)
 	currentWeight := 0
 
 	for i, weight := range weights {
@@ -246,22 +526,33 @@ func (u *captchaUsecase) determineChallengeType(complexity int32) domain.Challen
 	}
 
 	// Fallback (should never reach here)
-	return challengeTypes[rand.Intn(len(challengeTypes))]
+	return challengeTypes[rng.Intn(len(challengeTypes))]
 }
 
-// validateAnswer validates a challenge answer
-func (u *captchaUsecase) validateAnswer(challenge *domain.Challenge, answer interface{}) (bool, int32) {
+// validateAnswer validates a challenge answer given elapsed after the challenge
+// was issued by the server clock
+func (u *captchaUsecase) validateAnswer(challengeType domain.ChallengeType, expected, answer interface{}, elapsed time.Duration) (bool, int32) {
 	// Validation logic based on challenge type
 
-	switch challenge.Type {
+	switch challengeType {
 	case domain.ChallengeTypeClick:
-		return u.validateClickAnswer(challenge.Answer, answer)
+		return u.validateClickAnswer(expected, answer)
 	case domain.ChallengeTypeDragDrop:
-		return u.validateDragDropAnswer(challenge.Answer, answer)
+		return u.validateDragDropAnswer(expected, answer)
 	case domain.ChallengeTypeSwipe:
-		return u.validateSwipeAnswer(challenge.Answer, answer)
+		return u.validateSwipeAnswer(expected, answer)
 	case domain.ChallengeTypeGame:
-		return u.validateGameAnswer(challenge.Answer, answer)
+		return u.validateGameAnswer(expected, answer, elapsed)
+	case domain.ChallengeTypeGrid:
+		return u.validateGridAnswer(expected, answer)
+	case domain.ChallengeTypeSlider:
+		return u.validateSliderAnswer(expected, answer)
+	case domain.ChallengeTypeRotate:
+		return u.validateRotateAnswer(expected, answer)
+	case domain.ChallengeTypeAudio:
+		return u.validateAudioAnswer(expected, answer)
+	case domain.ChallengeTypeText:
+		return u.validateTextAnswer(expected, answer)
 	default:
 		return false, 0
 	}
@@ -274,7 +565,7 @@ func (u *captchaUsecase) validateClickAnswer(expected, actual interface{}) (bool
 		return false, 0
 	}
 
-	actualSequence, ok := actual.([]string)
+	actualSequence, ok := toStringSlice(actual)
 	if !ok {
 		return false, 0
 	}
@@ -301,7 +592,7 @@ func (u *captchaUsecase) validateDragDropAnswer(expected, actual interface{}) (b
 		return false, 0
 	}
 
-	actualMap, ok := actual.(map[string]string)
+	actualMap, ok := toStringMap(actual)
 	if !ok {
 		return false, 0
 	}
@@ -323,12 +614,12 @@ func (u *captchaUsecase) validateDragDropAnswer(expected, actual interface{}) (b
 
 // validateSwipeAnswer validates a swipe challenge answer
 func (u *captchaUsecase) validateSwipeAnswer(expected, actual interface{}) (bool, int32) {
-	expectedSequence, ok := expected.([]map[string]interface{})
+	expectedSequence, ok := toMapSlice(expected)
 	if !ok {
 		return false, 0
 	}
 
-	actualSequence, ok := actual.([]map[string]interface{})
+	actualSequence, ok := toMapSlice(actual)
 	if !ok {
 		return false, 0
 	}
@@ -366,160 +657,141 @@ func (u *captchaUsecase) validateSwipeGesture(expected, actual map[string]interf
 	return expectedDirection == actualDirection
 }
 
-// validateGameAnswer validates a game challenge answer
-func (u *captchaUsecase) validateGameAnswer(expected, actual interface{}) (bool, int32) {
-	// Expected is a map with validation criteria
-	expectedMap, ok := expected.(map[string]interface{})
-	if !ok {
+// validateGridAnswer scores a tile selection by its overlap with the target
+// tiles, a wrong tile costs as much as a missed one; only an exact match solves it
+func (u *captchaUsecase) validateGridAnswer(expected, actual interface{}) (bool, int32) {
+	answer, ok := expected.(*captcha.GridAnswer)
+	if !ok || len(answer.Tiles) == 0 {
 		return false, 0
 	}
 
-	// Actual is the game result from client
-	actualMap, ok := actual.(map[string]interface{})
+	selected, ok := toIntSlice(actual)
 	if !ok {
 		return false, 0
 	}
 
-	// Get the game type to determine validation logic
-	gameType, exists := expectedMap["type"]
-	if !exists {
-		return false, 0
+	correct := make(map[int]bool, len(answer.Tiles))
+	for _, tile := range answer.Tiles {
+		correct[tile] = true
 	}
 
-	switch gameType {
-	case "snake_completion":
-		return u.validateSnakeGame(expectedMap, actualMap)
-	case "memory_sequence":
-		return u.validateMemoryGame(expectedMap, actualMap)
-	case "reaction_time":
-		return u.validateReactionGame(expectedMap, actualMap)
-	default:
-		return false, 0
-	}
-}
+	seen := make(map[int]bool, len(selected))
+	hits, misses := 0, 0
+	for _, tile := range selected {
+		if seen[tile] {
+			continue
+		}
+		seen[tile] = true
 
-// validateSnakeGame validates snake game completion
-func (u *captchaUsecase) validateSnakeGame(expected, actual map[string]interface{}) (bool, int32) {
-	targetFood, ok := expected["target_food"].(int)
-	if !ok {
-		return false, 0
+		if correct[tile] {
+			hits++
+		} else {
+			misses++
+		}
 	}
 
-	actualScore, ok := actual["score"].(float64)
+	confidence := int32(hits * 100 / (len(answer.Tiles) + misses))
+	return hits == len(answer.Tiles) && misses == 0, confidence
+}
+
+// validateSliderAnswer validates a slider position and the drag trajectory behind it
+func (u *captchaUsecase) validateSliderAnswer(expected, actual interface{}) (bool, int32) {
+	answer, ok := expected.(*captcha.SliderAnswer)
 	if !ok {
 		return false, 0
 	}
 
-	success, ok := actual["success"].(bool)
-	if !ok {
+	submission, err := captcha.ParseSliderSubmission(actual)
+	if err != nil {
 		return false, 0
 	}
 
-	if success && int(actualScore) >= targetFood {
-		return true, 100
+	verdict := answer.Verify(submission)
+	if len(verdict.Violations) > 0 {
+		u.logger.WithFields(logrus.Fields{
+			"violations": verdict.Violations,
+			"distance":   verdict.Distance,
+		}).Debug("Slider trajectory rejected")
 	}
 
-	// Partial credit based on food collected
-	confidence := int32((actualScore / float64(targetFood)) * 80)
-	if confidence > 80 {
-		confidence = 80
-	}
-	return false, confidence
+	return verdict.Solved, verdict.Confidence
 }
 
-// validateMemoryGame validates memory sequence game
-func (u *captchaUsecase) validateMemoryGame(expected, actual map[string]interface{}) (bool, int32) {
-	expectedSequence, ok := expected["sequence"].([]int)
+// validateRotateAnswer validates the rotation applied to a rotate challenge
+func (u *captchaUsecase) validateRotateAnswer(expected, actual interface{}) (bool, int32) {
+	answer, ok := expected.(*captcha.RotateAnswer)
 	if !ok {
 		return false, 0
 	}
 
-	actualSequence, ok := actual["sequence"].([]interface{})
+	rotation, ok := toFloat64(actual)
 	if !ok {
 		return false, 0
 	}
 
-	// Convert actual sequence to []int
-	actualInts := make([]int, len(actualSequence))
-	for i, v := range actualSequence {
-		if intVal, ok := v.(float64); ok {
-			actualInts[i] = int(intVal)
-		} else {
-			return false, 0
-		}
-	}
+	return answer.Verify(rotation)
+}
 
-	if len(expectedSequence) != len(actualInts) {
-		return false, 20
+// validateAudioAnswer validates a typed transcript of an audio challenge
+func (u *captchaUsecase) validateAudioAnswer(expected, actual interface{}) (bool, int32) {
+	answer, ok := expected.(*captcha.AudioAnswer)
+	if !ok {
+		return false, 0
 	}
 
-	correctCount := 0
-	for i, expected := range expectedSequence {
-		if i < len(actualInts) && actualInts[i] == expected {
-			correctCount++
-		}
+	typed, ok := actual.(string)
+	if !ok {
+		return false, 0
 	}
 
-	confidence := int32((correctCount * 100) / len(expectedSequence))
-	return correctCount == len(expectedSequence), confidence
+	return answer.Verify(typed)
 }
 
-// validateReactionGame validates reaction time game
-func (u *captchaUsecase) validateReactionGame(expected, actual map[string]interface{}) (bool, int32) {
-	targetTime, ok := expected["target_time"].(int)
+// validateTextAnswer validates a typed answer to a text challenge
+func (u *captchaUsecase) validateTextAnswer(expected, actual interface{}) (bool, int32) {
+	answer, ok := expected.(*captcha.TextAnswer)
 	if !ok {
 		return false, 0
 	}
 
-	tolerance, ok := expected["tolerance"].(int)
+	typed, ok := actual.(string)
 	if !ok {
 		return false, 0
 	}
 
-	actualTime, ok := actual["reaction_time"].(float64)
+	return answer.Verify(typed)
+}
+
+// validateGameAnswer replays the submitted input log of a game challenge, the
+// score and success reported by the client only have to agree with the replay
+// and the game cannot have lasted longer than elapsed
+func (u *captchaUsecase) validateGameAnswer(expected, actual interface{}, elapsed time.Duration) (bool, int32) {
+	answer, ok := expected.(captcha.GameAnswer)
 	if !ok {
 		return false, 0
 	}
 
-	// Check if reaction time is within acceptable range
-	diff := int(actualTime) - targetTime
-	if diff < 0 {
-		diff = -diff
-	}
-
-	if diff <= tolerance {
-		// Perfect reaction time
-		confidence := int32(100 - (diff*50)/tolerance)
-		if confidence < 70 {
-			confidence = 70
-		}
-		return true, confidence
-	}
-
-	// Too far from target, but give some credit if reasonable
-	if int(actualTime) < 150 {
-		// Too fast, likely cheating
+	submission, err := captcha.ParseGameSubmission(actual)
+	if err != nil {
 		return false, 0
 	}
 
-	if int(actualTime) > 5000 {
-		// Too slow, likely not paying attention
-		return false, 10
+	verdict := answer.Verify(submission, elapsed)
+	if len(verdict.Violations) > 0 {
+		u.logger.WithFields(logrus.Fields{
+			"violations":     verdict.Violations,
+			"replayed_score": verdict.Score,
+			"reported_score": submission.Score,
+		}).Debug("Game replay rejected")
 	}
 
-	// Some credit for reasonable reaction time
-	confidence := int32(50 - (diff*30)/1000)
-	if confidence < 0 {
-		confidence = 0
-	}
-	return false, confidence
+	return verdict.Solved, verdict.Confidence
 }
 
 // processFrontendEvent processes a frontend event
-func (u *captchaUsecase) processFrontendEvent(ctx context.Context, challenge *domain.Challenge, event *domain.Event) (*domain.ServerEvent, error) {
+func (u *captchaUsecase) processFrontendEvent(ctx context.Context, challenge *domain.Challenge, event *domain.Event) ([]*domain.ServerEvent, error) {
 	// Process different types of frontend events
 	var responseData []byte
-	var err error
 
 	// Try to parse event data as JSON to understand the event
 	var eventData map[string]interface{}
@@ -532,18 +804,15 @@ func (u *captchaUsecase) processFrontendEvent(ctx context.Context, challenge *do
 					// Track user interaction for bot detection
 					responseData = []byte(`{"type":"interaction_tracked","status":"ok"}`)
 				case "challenge_attempt":
-					// Process challenge attempt
+					// Process challenge attempt, the stage machine decides whether
+					// a follow-up round is pushed or the challenge is finished
 					if answer, exists := eventData["answer"]; exists {
-						valid, confidence := u.validateAnswer(challenge, answer)
-						result := map[string]interface{}{
-							"type":       "challenge_result",
-							"valid":      valid,
-							"confidence": confidence,
-						}
-						responseData, err = json.Marshal(result)
-						if err != nil {
-							return nil, fmt.Errorf("failed to marshal challenge result: %w", err)
-						}
+						return u.processStageAnswer(ctx, challenge, answer)
+					}
+				case "probe_signals":
+					// The passive probe reported, the challenge passes or escalates
+					if signals, exists := eventData["signals"]; exists && challenge.Type == domain.ChallengeTypePassive {
+						return u.processProbe(ctx, challenge, signals)
 					}
 				default:
 					responseData = []byte(`{"type":"event_acknowledged","status":"ok"}`)
@@ -556,18 +825,21 @@ func (u *captchaUsecase) processFrontendEvent(ctx context.Context, challenge *do
 		responseData = []byte(`{"type":"event_processed","status":"ok"}`)
 	}
 
-	return &domain.ServerEvent{
+	return []*domain.ServerEvent{{
 		Type:        domain.ServerEventTypeSendClientData,
 		ChallengeID: challenge.ID,
 		Data:        responseData,
 		Timestamp:   time.Now(),
-	}, nil
+	}}, nil
 }
 
 // processConnectionClosed processes a connection closed event
 func (u *captchaUsecase) processConnectionClosed(ctx context.Context, challenge *domain.Challenge, event *domain.Event) (*domain.ServerEvent, error) {
 	// When connection closes, we should clean up the challenge and mark it as incomplete
 	// This helps prevent memory leaks and provides analytics data
+	if err := u.abandon(ctx, challenge, AbandonReasonConnectionClosed); err != nil {
+		return nil, err
+	}
 
 	// Mark challenge as incomplete due to connection loss
 	result := map[string]interface{}{
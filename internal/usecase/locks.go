@@ -0,0 +1,46 @@
+package usecase
+
+import "sync"
+
+// challengeLocks serializes the read-check-write of one challenge, answers,
+// abandonment and verification of the same challenge never interleave
+type challengeLocks struct {
+	mu    sync.Mutex
+	locks map[string]*challengeLock
+}
+
+// challengeLock is the lock of one challenge and the callers waiting for it
+type challengeLock struct {
+	mu   sync.Mutex
+	refs int
+}
+
+// newChallengeLocks creates an empty lock table
+func newChallengeLocks() *challengeLocks {
+	return &challengeLocks{locks: make(map[string]*challengeLock)}
+}
+
+// lock locks a challenge and returns the unlock function, the entry is
+// dropped once nobody holds or waits for it
+func (l *challengeLocks) lock(challengeID string) func() {
+	l.mu.Lock()
+	entry, exists := l.locks[challengeID]
+	if !exists {
+		entry = &challengeLock{}
+		l.locks[challengeID] = entry
+	}
+	entry.refs++
+	l.mu.Unlock()
+
+	entry.mu.Lock()
+	return func() {
+		entry.mu.Unlock()
+
+		l.mu.Lock()
+		entry.refs--
+		if entry.refs == 0 {
+			delete(l.locks, challengeID)
+		}
+		l.mu.Unlock()
+	}
+}
@@ -0,0 +1,112 @@
+package usecase
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/rand"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/FlooooowY/SteelMount-Captcha-Service/internal/captcha"
+	"github.com/FlooooowY/SteelMount-Captcha-Service/internal/domain"
+)
+
+// Passive verdicts recorded in challenge metadata
+const (
+	passiveVerdictPass      = "pass"
+	passiveVerdictEscalated = "escalated"
+)
+
+// processProbe scores the signals a passive probe reported together with the
+// caller's risk. A low risk client passes without seeing a puzzle, others get
+// a visible challenge chosen like in auto mode. The caller holds the challenge lock
+func (u *captchaUsecase) processProbe(ctx context.Context, challenge *domain.Challenge, signals interface{}) ([]*domain.ServerEvent, error) {
+	if challenge.Solved {
+		return []*domain.ServerEvent{u.stageResultEvent(challenge)}, nil
+	}
+
+	if challenge.Abandoned {
+		return nil, domain.AbandonedError(challenge.ID)
+	}
+	if time.Now().After(challenge.ExpiresAt) {
+		return nil, domain.ExpiredError(challenge.ID)
+	}
+
+	// The probe is answered once, later reports cannot undo an escalation
+	stage := challenge.CurrentStage()
+	probe, ok := stage.Answer.(*captcha.ProbeAnswer)
+	if stage.Type != domain.ChallengeTypePassive || stage.Completed || !ok {
+		return nil, domain.ExhaustedError(challenge.ID)
+	}
+
+	// A report that does not decode misses the nonce and scores as automated
+	var report captcha.ProbeReport
+	if data, err := json.Marshal(signals); err == nil {
+		_ = json.Unmarshal(data, &report)
+	}
+	verdict := probe.Evaluate(&report, time.Since(stage.StartedAt))
+
+	// Without a risk source the probe decides alone
+	risk := verdict.Risk
+	if u.config.AssessRisk != nil {
+		risk = math.Max(risk, u.config.AssessRisk(ctx))
+	}
+
+	stage.Confidence = int32((1-math.Min(risk, 1))*100 + 0.5)
+	stage.Completed = true
+	challenge.Metadata["probe_risk"] = strconv.FormatFloat(verdict.Risk, 'f', 2, 64)
+	if len(verdict.Reasons) > 0 {
+		challenge.Metadata["probe_reasons"] = strings.Join(verdict.Reasons, ",")
+	}
+
+	selection := u.selectForRisk(risk)
+	selection.annotate(challenge.Metadata)
+
+	var events []*domain.ServerEvent
+	if selection.tier == domain.RiskTierLow {
+		challenge.Solved = true
+		challenge.Metadata["passive_verdict"] = passiveVerdictPass
+		events = []*domain.ServerEvent{u.stageResultEvent(challenge)}
+	} else {
+		challenge.Metadata["passive_verdict"] = passiveVerdictEscalated
+		escalation, err := u.escalate(ctx, challenge, selection)
+		if err != nil {
+			return nil, err
+		}
+		events = escalation
+	}
+
+	if err := u.challengeRepo.Update(ctx, challenge); err != nil {
+		return nil, fmt.Errorf("failed to update challenge: %w", err)
+	}
+
+	return events, nil
+}
+
+// escalate replaces the probe with a visible challenge for selection, the
+// types the client can answer still win over the tier
+func (u *captchaUsecase) escalate(ctx context.Context, challenge *domain.Challenge, selection *autoSelection) ([]*domain.ServerEvent, error) {
+	seed := u.engine.NextSeed()
+	rng := rand.New(rand.NewSource(seed))
+
+	var challengeType domain.ChallengeType
+	switch {
+	case challenge.Metadata["accessible"] == "true":
+		challengeType = domain.ChallengeTypeAudio
+	case challenge.Metadata["keyboard_only"] == "true":
+		challengeType = domain.ChallengeTypeText
+	default:
+		challengeType = u.autoChallengeType(rng, selection)
+	}
+
+	return u.pushStage(ctx, challenge, domain.ChallengeStage{
+		Type:       challengeType,
+		Complexity: selection.complexity,
+		Level:      u.calibratedLevel(rng, challengeType, selection.complexity),
+		Seed:       seed,
+		Reason:     domain.StageReasonEscalated,
+	}, u.stagePolicy(challenge))
+}
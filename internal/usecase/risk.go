@@ -0,0 +1,89 @@
+package usecase
+
+import (
+	"context"
+	"math/rand"
+	"strconv"
+	"time"
+
+	"github.com/FlooooowY/SteelMount-Captcha-Service/internal/domain"
+)
+
+// unknownRisk is assumed for auto mode challenges when no risk source is configured
+const unknownRisk = 0.5
+
+// Challenge types auto mode picks from outside the medium tier
+var (
+	// lowRiskTypes take a single interaction
+	lowRiskTypes = []domain.ChallengeType{
+		domain.ChallengeTypeClick,
+		domain.ChallengeTypeSlider,
+		domain.ChallengeTypeRotate,
+	}
+
+	// highRiskTypes are the hardest to automate, game answers are replayed on the server
+	highRiskTypes = []domain.ChallengeType{
+		domain.ChallengeTypeGame,
+		domain.ChallengeTypeDragDrop,
+		domain.ChallengeTypeGrid,
+	}
+)
+
+// autoSelection is what auto mode derived from the client's risk
+type autoSelection struct {
+	risk       float64
+	tier       domain.RiskTier
+	complexity int32
+	minStages  int
+}
+
+// selectAuto assesses the client behind ctx and maps its risk through the risk policy
+func (u *captchaUsecase) selectAuto(ctx context.Context) *autoSelection {
+	risk := unknownRisk
+	if u.config.AssessRisk != nil {
+		risk = u.config.AssessRisk(ctx)
+	}
+	return u.selectForRisk(risk)
+}
+
+// selectForRisk maps risk through the risk policy
+func (u *captchaUsecase) selectForRisk(risk float64) *autoSelection {
+	policy := u.config.RiskPolicy
+	selection := &autoSelection{
+		risk:       risk,
+		tier:       policy.Tier(risk),
+		complexity: policy.Complexity(risk),
+	}
+	if selection.tier == domain.RiskTierHigh {
+		selection.minStages = policy.HighRiskStages
+	}
+	return selection
+}
+
+// autoChallengeType picks the type for the tier, the medium tier selects by complexity as usual
+func (u *captchaUsecase) autoChallengeType(rng *rand.Rand, s *autoSelection) domain.ChallengeType {
+	switch s.tier {
+	case domain.RiskTierLow:
+		return lowRiskTypes[rng.Intn(len(lowRiskTypes))]
+	case domain.RiskTierHigh:
+		return highRiskTypes[rng.Intn(len(highRiskTypes))]
+	default:
+		return u.determineChallengeType(rng, s.complexity)
+	}
+}
+
+// annotate records the selection in challenge metadata
+func (s *autoSelection) annotate(metadata map[string]string) {
+	metadata["risk"] = strconv.FormatFloat(s.risk, 'f', 2, 64)
+	metadata["risk_tier"] = string(s.tier)
+	if s.minStages > 1 {
+		metadata["min_stages"] = strconv.Itoa(s.minStages)
+	}
+}
+
+// notifyAnswer reports an answer to the OnAnswer hook
+func (u *captchaUsecase) notifyAnswer(ctx context.Context, challenge *domain.Challenge, solved bool, elapsed time.Duration) {
+	if u.config.OnAnswer != nil {
+		u.config.OnAnswer(ctx, challenge, solved, elapsed)
+	}
+}
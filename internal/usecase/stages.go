@@ -0,0 +1,274 @@
+package usecase
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/rand"
+	"time"
+
+	"github.com/FlooooowY/SteelMount-Captcha-Service/internal/domain"
+)
+
+// stageStartedData is pushed as SendClientData when a follow-up round starts
+type stageStartedData struct {
+	Type          string               `json:"type"`
+	Stage         int                  `json:"stage"`
+	MaxStages     int                  `json:"max_stages"`
+	ChallengeType domain.ChallengeType `json:"challenge_type"`
+	Complexity    int32                `json:"complexity"`
+	Reason        string               `json:"reason"`
+}
+
+// processStageAnswer validates an answer for the current stage of a challenge
+// and returns the events to push: a follow-up round (SendClientData announcing
+// the stage and RunClientJS rendering it) or the final ChallengeResult. The
+// caller holds the challenge lock
+func (u *captchaUsecase) processStageAnswer(ctx context.Context, challenge *domain.Challenge, answer interface{}) ([]*domain.ServerEvent, error) {
+	if challenge.Solved {
+		return []*domain.ServerEvent{u.stageResultEvent(challenge)}, nil
+	}
+	if challenge.StagesFinished() {
+		return nil, domain.ExhaustedError(challenge.ID)
+	}
+
+	if challenge.Abandoned {
+		return nil, domain.AbandonedError(challenge.ID)
+	}
+	if time.Now().After(challenge.ExpiresAt) {
+		return nil, domain.ExpiredError(challenge.ID)
+	}
+
+	stage := challenge.CurrentStage()
+	elapsed := time.Since(stage.StartedAt)
+	valid, confidence := u.validateAnswer(stage.Type, stage.Answer, answer, elapsed)
+	u.recordAnswer(ctx, stage.Type, stage.Level, valid, elapsed)
+	u.notifyAnswer(ctx, challenge, valid, elapsed)
+	policy := u.stagePolicy(challenge)
+	outcome := challenge.RecordStageAnswer(valid, confidence, policy)
+
+	var events []*domain.ServerEvent
+	if outcome == domain.StageOutcomeNext {
+		// A confident answer only gets another round when the challenge requires it
+		reason := domain.StageReasonBorderline
+		if valid && confidence >= policy.PassConfidence {
+			reason = domain.StageReasonRequired
+		}
+		next, err := u.nextStage(ctx, challenge, stage, policy, reason)
+		if err != nil {
+			return nil, err
+		}
+		events = next
+	} else {
+		events = []*domain.ServerEvent{u.stageResultEvent(challenge)}
+	}
+
+	if err := u.challengeRepo.Update(ctx, challenge); err != nil {
+		return nil, fmt.Errorf("failed to update challenge: %w", err)
+	}
+
+	return events, nil
+}
+
+// stagePolicy returns the stage policy of a challenge, the configured policy
+// raised to the rounds the challenge requires
+func (u *captchaUsecase) stagePolicy(challenge *domain.Challenge) domain.StagePolicy {
+	policy := u.config.StagePolicy
+	if minStages := challenge.MinStages(); minStages > policy.MinStages {
+		policy.MinStages = minStages
+	}
+	if policy.MaxStages < policy.MinStages {
+		policy.MaxStages = policy.MinStages
+	}
+
+	// The probe of a passive challenge is a stage but not a round
+	if challenge.Type == domain.ChallengeTypePassive {
+		policy.MaxStages++
+		if policy.MinStages > 0 {
+			policy.MinStages++
+		}
+	}
+	return policy
+}
+
+// nextStage generates a harder follow-up round of the same type
+func (u *captchaUsecase) nextStage(ctx context.Context, challenge *domain.Challenge, previous *domain.ChallengeStage, policy domain.StagePolicy, reason string) ([]*domain.ServerEvent, error) {
+	complexity := previous.Complexity + policy.ComplexityIncrease
+	if complexity > 100 {
+		complexity = 100
+	}
+
+	seed := u.engine.NextSeed()
+	level := u.calibratedLevel(rand.New(rand.NewSource(seed)), previous.Type, complexity)
+
+	return u.pushStage(ctx, challenge, domain.ChallengeStage{
+		Type:       previous.Type,
+		Complexity: complexity,
+		Level:      level,
+		Seed:       seed,
+		Reason:     reason,
+	}, policy)
+}
+
+// pushStage generates the content of stage, adds it to the challenge and
+// returns the events starting it on the client
+func (u *captchaUsecase) pushStage(ctx context.Context, challenge *domain.Challenge, next domain.ChallengeStage, policy domain.StagePolicy) ([]*domain.ServerEvent, error) {
+	html, answer, err := u.engine.GenerateChallengeContext(ctx, string(next.Type), next.Level, next.Seed)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate challenge stage: %w", err)
+	}
+
+	next.Answer = answer
+	stage := challenge.AddStage(next)
+
+	data, err := json.Marshal(&stageStartedData{
+		Type:          "stage_started",
+		Stage:         stage.Index + 1,
+		MaxStages:     policy.MaxStages,
+		ChallengeType: stage.Type,
+		Complexity:    stage.Complexity,
+		Reason:        stage.Reason,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal stage data: %w", err)
+	}
+
+	jsCode, err := renderStageJS(html)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	return []*domain.ServerEvent{
+		{
+			Type:        domain.ServerEventTypeSendClientData,
+			ChallengeID: challenge.ID,
+			Data:        data,
+			Timestamp:   now,
+		},
+		{
+			Type:        domain.ServerEventTypeRunClientJS,
+			ChallengeID: challenge.ID,
+			JSCode:      jsCode,
+			Timestamp:   now,
+		},
+	}, nil
+}
+
+// stageResultEvent builds the final result of a progressive challenge
+func (u *captchaUsecase) stageResultEvent(challenge *domain.Challenge) *domain.ServerEvent {
+	return &domain.ServerEvent{
+		Type:              domain.ServerEventTypeChallengeResult,
+		ChallengeID:       challenge.ID,
+		ConfidencePercent: challenge.StageConfidence(),
+		Solved:            challenge.Solved,
+		Timestamp:         time.Now(),
+	}
+}
+
+// renderStageJS returns JavaScript replacing the captcha frame with the stage HTML
+func renderStageJS(html string) (string, error) {
+	encoded, err := json.Marshal(html)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode stage html: %w", err)
+	}
+
+	return fmt.Sprintf("(function(){document.open();document.write(%s);document.close();})();", encoded), nil
+}
+
+// toStringSlice accepts string slices as decoded from JSON
+func toStringSlice(value interface{}) ([]string, bool) {
+	switch v := value.(type) {
+	case []string:
+		return v, true
+	case []interface{}:
+		result := make([]string, len(v))
+		for i, item := range v {
+			s, ok := item.(string)
+			if !ok {
+				return nil, false
+			}
+			result[i] = s
+		}
+		return result, true
+	default:
+		return nil, false
+	}
+}
+
+// toIntSlice accepts integer slices as decoded from JSON
+func toIntSlice(value interface{}) ([]int, bool) {
+	switch v := value.(type) {
+	case []int:
+		return v, true
+	case []interface{}:
+		result := make([]int, len(v))
+		for i, item := range v {
+			f, ok := item.(float64)
+			if !ok || f != math.Trunc(f) {
+				return nil, false
+			}
+			result[i] = int(f)
+		}
+		return result, true
+	default:
+		return nil, false
+	}
+}
+
+// toFloat64 accepts a number as decoded from JSON or passed from Go
+func toFloat64(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case float64:
+		return v, true
+	case int:
+		return float64(v), true
+	case int32:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	default:
+		return 0, false
+	}
+}
+
+// toMapSlice accepts slices of objects as decoded from JSON
+func toMapSlice(value interface{}) ([]map[string]interface{}, bool) {
+	switch v := value.(type) {
+	case []map[string]interface{}:
+		return v, true
+	case []interface{}:
+		result := make([]map[string]interface{}, len(v))
+		for i, item := range v {
+			m, ok := item.(map[string]interface{})
+			if !ok {
+				return nil, false
+			}
+			result[i] = m
+		}
+		return result, true
+	default:
+		return nil, false
+	}
+}
+
+// toStringMap accepts string maps as decoded from JSON
+func toStringMap(value interface{}) (map[string]string, bool) {
+	switch v := value.(type) {
+	case map[string]string:
+		return v, true
+	case map[string]interface{}:
+		result := make(map[string]string, len(v))
+		for key, item := range v {
+			s, ok := item.(string)
+			if !ok {
+				return nil, false
+			}
+			result[key] = s
+		}
+		return result, true
+	default:
+		return nil, false
+	}
+}
@@ -0,0 +1,68 @@
+package websocket
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Connect token errors
+var (
+	ErrInvalidToken = fmt.Errorf("invalid connect token")
+	ErrTokenExpired = fmt.Errorf("connect token expired")
+)
+
+// NewConnectToken signs a short-lived token allowing clientID to open a connection
+//
+// Tokens have the form <client>.<expires>.<mac>, where <client> is the
+// base64url-encoded client ID, <expires> a Unix timestamp and <mac> a
+// HMAC-SHA256 over the first two parts. Backends mint them with the shared
+// secret and hand them to the browser.
+func NewConnectToken(secret []byte, clientID string, ttl time.Duration) string {
+	client := base64.RawURLEncoding.EncodeToString([]byte(clientID))
+	expires := strconv.FormatInt(time.Now().Add(ttl).Unix(), 10)
+
+	return client + "." + expires + "." + signToken(secret, client, expires)
+}
+
+// VerifyConnectToken checks a connect token and returns the client ID it was issued for
+func VerifyConnectToken(secret []byte, token string) (string, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return "", ErrInvalidToken
+	}
+
+	if !hmac.Equal([]byte(parts[2]), []byte(signToken(secret, parts[0], parts[1]))) {
+		return "", ErrInvalidToken
+	}
+
+	expires, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		return "", ErrInvalidToken
+	}
+
+	if time.Now().Unix() > expires {
+		return "", ErrTokenExpired
+	}
+
+	clientID, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil || len(clientID) == 0 {
+		return "", ErrInvalidToken
+	}
+
+	return string(clientID), nil
+}
+
+// signToken computes the token MAC
+func signToken(secret []byte, client, expires string) string {
+	h := hmac.New(sha256.New, secret)
+	h.Write([]byte(client))
+	h.Write([]byte("."))
+	h.Write([]byte(expires))
+	return hex.EncodeToString(h.Sum(nil))
+}
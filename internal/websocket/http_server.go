@@ -5,35 +5,78 @@ import (
 	"encoding/json"
 	"fmt"
 	"log"
+	"math"
+	"net"
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
 
+	"github.com/FlooooowY/SteelMount-Captcha-Service/internal/domain"
+	"github.com/FlooooowY/SteelMount-Captcha-Service/internal/security"
+	"github.com/FlooooowY/SteelMount-Captcha-Service/internal/tracing"
 	"github.com/gorilla/websocket"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // HTTPServer handles HTTP to WebSocket upgrades
 type HTTPServer struct {
-	wsService   *WebSocketService
-	upgrader    websocket.Upgrader
-	port        int
-	server      *http.Server
+	wsService       *WebSocketService
+	securityService *security.SecurityService
+	config          *HTTPServerConfig
+	limiter         *connectionLimiter
+	upgrader        websocket.Upgrader
+	port            int
+	server          *http.Server
+}
+
+// HTTPServerConfig contains WebSocket endpoint protection settings
+type HTTPServerConfig struct {
+	AllowedOrigins          []string // Empty allows every origin
+	AllowMissingOrigin      bool     // Accept clients without Origin despite AllowedOrigins
+	TokenSecret             string   // Enables signed connect tokens when set
+	RequireToken            bool
+	MaxMessageBytes         int64
+	MaxConnectionsPerIP     int // Zero disables the cap
+	MaxConnectionsPerClient int // Zero disables the cap
+	TrustForwardedFor       bool
+}
+
+// DefaultHTTPServerConfig returns the development defaults
+func DefaultHTTPServerConfig() *HTTPServerConfig {
+	return &HTTPServerConfig{
+		MaxMessageBytes: 64 * 1024,
+	}
 }
 
 // NewHTTPServer creates a new HTTP server for WebSocket connections
 func NewHTTPServer(wsService *WebSocketService, port int) *HTTPServer {
-	return &HTTPServer{
-		wsService: wsService,
-		upgrader: websocket.Upgrader{
-			CheckOrigin: func(r *http.Request) bool {
-				// Allow all origins for development
-				// In production, implement proper origin checking
-				return true
-			},
-			ReadBufferSize:  1024,
-			WriteBufferSize: 1024,
-		},
-		port: port,
+	return NewHTTPServerWithConfig(wsService, port, DefaultHTTPServerConfig(), nil)
+}
+
+// NewHTTPServerWithConfig creates a new HTTP server with endpoint protection,
+// securityService may be nil to skip per-message security checks
+func NewHTTPServerWithConfig(wsService *WebSocketService, port int, config *HTTPServerConfig, securityService *security.SecurityService) *HTTPServer {
+	if config == nil {
+		config = DefaultHTTPServerConfig()
 	}
+
+	s := &HTTPServer{
+		wsService:       wsService,
+		securityService: securityService,
+		config:          config,
+		limiter:         newConnectionLimiter(config.MaxConnectionsPerIP, config.MaxConnectionsPerClient),
+		port:            port,
+	}
+
+	s.upgrader = websocket.Upgrader{
+		CheckOrigin:     s.checkOrigin,
+		ReadBufferSize:  1024,
+		WriteBufferSize: 1024,
+	}
+
+	return s
 }
 
 // GetWebSocketService returns the WebSocket service
@@ -43,20 +86,9 @@ func (s *HTTPServer) GetWebSocketService() *WebSocketService {
 
 // Start starts the HTTP server
 func (s *HTTPServer) Start(ctx context.Context) error {
-	mux := http.NewServeMux()
-	
-	// WebSocket endpoint
-	mux.HandleFunc("/ws", s.handleWebSocket)
-	
-	// Health check endpoint
-	mux.HandleFunc("/health", s.handleHealth)
-	
-	// Stats endpoint
-	mux.HandleFunc("/stats", s.handleStats)
-	
 	s.server = &http.Server{
 		Addr:    fmt.Sprintf(":%d", s.port),
-		Handler: mux,
+		Handler: s.Handler(),
 	}
 	
 	// Start server in goroutine
@@ -70,6 +102,25 @@ func (s *HTTPServer) Start(ctx context.Context) error {
 	return nil
 }
 
+// Handler returns the HTTP handler serving the WebSocket endpoints
+func (s *HTTPServer) Handler() http.Handler {
+	mux := http.NewServeMux()
+	
+	// WebSocket endpoint
+	mux.HandleFunc("/ws", s.handleWebSocket)
+	
+	// Health check endpoint
+	mux.HandleFunc("/health", s.handleHealth)
+	
+	// Stats endpoint
+	mux.HandleFunc("/stats", s.handleStats)
+	
+	// Protocol schema endpoint for client developers
+	mux.HandleFunc("/ws/schema", s.handleSchema)
+	
+	return mux
+}
+
 // Stop stops the HTTP server
 func (s *HTTPServer) Stop(ctx context.Context) error {
 	if s.server != nil {
@@ -80,13 +131,42 @@ func (s *HTTPServer) Stop(ctx context.Context) error {
 
 // handleWebSocket handles WebSocket connections
 func (s *HTTPServer) handleWebSocket(w http.ResponseWriter, r *http.Request) {
-	// Extract client ID from query parameters
-	clientID := r.URL.Query().Get("client_id")
-	if clientID == "" {
-		http.Error(w, "client_id parameter required", http.StatusBadRequest)
+	ip := s.clientIP(r)
+	userAgent := r.UserAgent()
+	
+	// Continue the trace of the page that opened the socket
+	ctx, span := tracing.StartSpan(tracing.ExtractHTTP(r.Context(), r.Header), "websocket.connection",
+		attribute.String("client.address", ip),
+	)
+	defer span.End()
+	
+	// Resolve client ID, a signed token takes precedence over the query parameter
+	clientID, status, err := s.authenticate(r)
+	if err != nil {
+		http.Error(w, err.Error(), status)
 		return
 	}
 	
+	// Run the handshake through the same checks as gRPC requests
+	if s.securityService != nil {
+		result, err := s.securityService.CheckRequest(ctx, ip, userAgent, "/ws", 0, false)
+		if err != nil {
+			http.Error(w, "security check failed", http.StatusInternalServerError)
+			return
+		}
+		if !result.Allowed {
+			writeDenial(w, result.Err())
+			return
+		}
+	}
+	
+	// Enforce per-IP and per-client connection caps
+	if ok, reason := s.limiter.acquire(ip, clientID); !ok {
+		http.Error(w, reason, http.StatusTooManyRequests)
+		return
+	}
+	defer s.limiter.release(ip, clientID)
+	
 	// Upgrade to WebSocket
 	conn, err := s.upgrader.Upgrade(w, r, nil)
 	if err != nil {
@@ -95,22 +175,113 @@ func (s *HTTPServer) handleWebSocket(w http.ResponseWriter, r *http.Request) {
 	}
 	defer conn.Close()
 	
+	if s.config.MaxMessageBytes > 0 {
+		conn.SetReadLimit(s.config.MaxMessageBytes)
+	}
+	
 	// Create connection in service
 	wsConn := s.wsService.CreateConnection(clientID)
+	span.SetAttributes(attribute.String("websocket.connection_id", wsConn.ID))
+	
+	// Message spans link to the handshake trace but outlive the request context
+	connCtx := trace.ContextWithSpanContext(context.Background(), span.SpanContext())
 	
 	// Handle connection
-	s.handleConnection(wsConn, conn)
+	s.handleConnection(connCtx, wsConn, conn, ip, userAgent)
+}
+
+// authenticate resolves the client ID of a connection request
+func (s *HTTPServer) authenticate(r *http.Request) (string, int, error) {
+	clientID := r.URL.Query().Get("client_id")
+	token := r.URL.Query().Get("token")
+	
+	if s.config.TokenSecret != "" && token != "" {
+		tokenClientID, err := VerifyConnectToken([]byte(s.config.TokenSecret), token)
+		if err != nil {
+			return "", http.StatusUnauthorized, err
+		}
+		if clientID != "" && clientID != tokenClientID {
+			return "", http.StatusForbidden, fmt.Errorf("client_id does not match connect token")
+		}
+		return tokenClientID, http.StatusOK, nil
+	}
+	
+	if s.config.RequireToken {
+		return "", http.StatusUnauthorized, fmt.Errorf("token parameter required")
+	}
+	
+	if clientID == "" {
+		return "", http.StatusBadRequest, fmt.Errorf("client_id parameter required")
+	}
+	
+	return clientID, http.StatusOK, nil
+}
+
+// checkOrigin matches the request origin against the allow-list
+func (s *HTTPServer) checkOrigin(r *http.Request) bool {
+	if len(s.config.AllowedOrigins) == 0 {
+		return true
+	}
+	
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		// Non-browser clients do not send an origin, neither does a forged
+		// request, so accepting them is an explicit choice
+		return s.config.AllowMissingOrigin
+	}
+	
+	for _, allowed := range s.config.AllowedOrigins {
+		if allowed == "*" || strings.EqualFold(allowed, origin) {
+			return true
+		}
+	}
+	
+	return false
+}
+
+// clientIP extracts the client IP address of a request
+func (s *HTTPServer) clientIP(r *http.Request) string {
+	if s.config.TrustForwardedFor {
+		if forwarded := r.Header.Get("X-Forwarded-For"); forwarded != "" {
+			return strings.TrimSpace(strings.Split(forwarded, ",")[0])
+		}
+	}
+	
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	
+	return host
+}
+
+// writeDenial rejects a handshake refused by the security checks, rate
+// limited clients get 429 and every denial carries Retry-After when known
+func writeDenial(w http.ResponseWriter, err error) {
+	status := http.StatusForbidden
+	if domainErr, ok := domain.AsError(err); ok {
+		if domainErr.Kind == domain.ErrorKindRateLimited {
+			status = http.StatusTooManyRequests
+		}
+		if domainErr.RetryAfter > 0 {
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(domainErr.RetryAfter.Seconds()))))
+		}
+	}
+	
+	http.Error(w, err.Error(), status)
 }
 
 // handleConnection handles a WebSocket connection
-func (s *HTTPServer) handleConnection(wsConn *Connection, conn *websocket.Conn) {
+func (s *HTTPServer) handleConnection(ctx context.Context, wsConn *Connection, conn *websocket.Conn, ip, userAgent string) {
 	// Send connection established event
-	event := &Event{
-		ID:        fmt.Sprintf("conn_%d", time.Now().UnixNano()),
-		Type:      "connection_established",
-		Data:      map[string]interface{}{"connection_id": wsConn.ID},
-		Timestamp: time.Now(),
-		ClientID:  wsConn.ClientID,
+	event := NewEvent(EventTypeConnectionEstablished)
+	event.ClientID = wsConn.ClientID
+	if err := event.SetData(&ConnectionEstablishedPayload{
+		ConnectionID:    wsConn.ID,
+		ProtocolVersion: ProtocolVersion,
+	}); err != nil {
+		log.Printf("Error encoding connection event: %v", err)
+		return
 	}
 	
 	if err := s.sendEvent(conn, event); err != nil {
@@ -133,10 +304,7 @@ func (s *HTTPServer) handleConnection(wsConn *Connection, conn *websocket.Conn)
 				return
 			}
 			
-			// Process message
-			if err := s.wsService.ProcessMessage(context.Background(), wsConn.ID, message); err != nil {
-				log.Printf("Error processing message: %v", err)
-			}
+			s.handleMessage(ctx, wsConn, message, ip, userAgent)
 		}
 	}()
 	
@@ -152,6 +320,13 @@ func (s *HTTPServer) handleConnection(wsConn *Connection, conn *websocket.Conn)
 					log.Printf("Error sending event: %v", err)
 					return
 				}
+			case <-wsConn.Done():
+				// Removed by the service, e.g. as a slow consumer; closing the
+				// socket unblocks the reader
+				closeMsg := websocket.FormatCloseMessage(websocket.CloseTryAgainLater, "connection closed by server")
+				_ = conn.WriteControl(websocket.CloseMessage, closeMsg, time.Now().Add(time.Second))
+				conn.Close()
+				return
 			case <-ticker.C:
 				// Send ping
 				if err := conn.WriteMessage(websocket.PingMessage, nil); err != nil {
@@ -170,6 +345,34 @@ func (s *HTTPServer) handleConnection(wsConn *Connection, conn *websocket.Conn)
 	s.wsService.CloseConnection(wsConn.ID)
 }
 
+// handleMessage runs the security check for an inbound message and queues it
+func (s *HTTPServer) handleMessage(ctx context.Context, wsConn *Connection, message []byte, ip, userAgent string) {
+	ctx = security.ContextWithClient(ctx, ip, userAgent)
+	ctx, span := tracing.StartSpan(ctx, "websocket.message",
+		attribute.String("websocket.connection_id", wsConn.ID),
+		attribute.Int("websocket.message_bytes", len(message)),
+	)
+	defer span.End()
+	
+	// Every inbound message counts against the client's security budget
+	if s.securityService != nil {
+		result, err := s.securityService.CheckRequest(ctx, ip, userAgent, "/ws", 0, false)
+		if err != nil {
+			log.Printf("Security check failed: %v", err)
+			return
+		}
+		if !result.Allowed {
+			s.wsService.SendError(wsConn.ID, requestIDOf(message), result.Err())
+			return
+		}
+	}
+	
+	// Process message
+	if err := s.wsService.ProcessMessage(ctx, wsConn.ID, message); err != nil {
+		log.Printf("Error processing message: %v", err)
+	}
+}
+
 // sendEvent sends an event to the WebSocket connection
 func (s *HTTPServer) sendEvent(conn *websocket.Conn, event *Event) error {
 	// Set write deadline
@@ -208,7 +411,18 @@ func (s *HTTPServer) handleStats(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusOK)
 	
 	stats := s.wsService.GetConnectionStats()
+	stats["limiter"] = s.limiter.stats()
 	if err := json.NewEncoder(w).Encode(stats); err != nil {
 		log.Printf("Failed to encode stats: %v", err)
 	}
 }
+
+// handleSchema serves the JSON Schema of the WebSocket protocol
+func (s *HTTPServer) handleSchema(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/schema+json")
+	w.WriteHeader(http.StatusOK)
+	
+	if _, err := w.Write(ProtocolSchema); err != nil {
+		log.Printf("Failed to write schema: %v", err)
+	}
+}
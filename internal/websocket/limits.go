@@ -0,0 +1,68 @@
+package websocket
+
+import (
+	"sync"
+)
+
+// connectionLimiter caps concurrent connections per IP and per client
+type connectionLimiter struct {
+	maxPerIP     int
+	maxPerClient int
+
+	mu        sync.Mutex
+	perIP     map[string]int
+	perClient map[string]int
+}
+
+// newConnectionLimiter creates a new connection limiter, zero disables a cap
+func newConnectionLimiter(maxPerIP, maxPerClient int) *connectionLimiter {
+	return &connectionLimiter{
+		maxPerIP:     maxPerIP,
+		maxPerClient: maxPerClient,
+		perIP:        make(map[string]int),
+		perClient:    make(map[string]int),
+	}
+}
+
+// acquire reserves a connection slot, returning the exceeded cap name on failure
+func (l *connectionLimiter) acquire(ip, clientID string) (bool, string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.maxPerIP > 0 && l.perIP[ip] >= l.maxPerIP {
+		return false, "too many connections from this IP"
+	}
+
+	if l.maxPerClient > 0 && l.perClient[clientID] >= l.maxPerClient {
+		return false, "too many connections for this client"
+	}
+
+	l.perIP[ip]++
+	l.perClient[clientID]++
+	return true, ""
+}
+
+// release frees a connection slot
+func (l *connectionLimiter) release(ip, clientID string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.perIP[ip]--; l.perIP[ip] <= 0 {
+		delete(l.perIP, ip)
+	}
+
+	if l.perClient[clientID]--; l.perClient[clientID] <= 0 {
+		delete(l.perClient, clientID)
+	}
+}
+
+// stats returns limiter statistics
+func (l *connectionLimiter) stats() map[string]interface{} {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return map[string]interface{}{
+		"tracked_ips":     len(l.perIP),
+		"tracked_clients": len(l.perClient),
+	}
+}
@@ -0,0 +1,260 @@
+package websocket
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+
+	"github.com/FlooooowY/SteelMount-Captcha-Service/internal/domain"
+)
+
+// ProtocolVersion is the current WebSocket protocol version
+const ProtocolVersion = 1
+
+// maxRequestIDLength limits client supplied request IDs
+const maxRequestIDLength = 128
+
+// Event types sent by clients
+const (
+	EventTypeCreateChallenge   = "create_challenge"
+	EventTypeValidateChallenge = "validate_challenge"
+)
+
+// Event types sent by the server
+const (
+	EventTypeConnectionEstablished = "connection_established"
+	EventTypeChallengeCreated      = "challenge_created"
+	EventTypeChallengeValidated    = "challenge_validated"
+	EventTypeError                 = "error"
+)
+
+// Error codes carried by error events
+const (
+	ErrorCodeInvalidMessage     = "invalid_message"
+	ErrorCodeUnsupportedVersion = "unsupported_version"
+	ErrorCodeUnknownType        = "unknown_type"
+	ErrorCodeInvalidPayload     = "invalid_payload"
+	ErrorCodeChallengeNotOwned  = "challenge_not_owned"
+	ErrorCodeRequestBlocked     = "request_blocked"
+	ErrorCodeNotFound           = "not_found"
+	ErrorCodeExpired            = "challenge_expired"
+	ErrorCodeExhausted          = "attempts_exhausted"
+	ErrorCodeStagesRequired     = "stages_required"
+	ErrorCodeRateLimited        = "rate_limited"
+	ErrorCodeCapacity           = "capacity_reached"
+	ErrorCodeInternal           = "internal_error"
+)
+
+// ProtocolSchema is the JSON Schema describing protocol messages
+//
+//go:embed protocol.schema.json
+var ProtocolSchema []byte
+
+// ErrorPayload describes a failed request
+type ErrorPayload struct {
+	Code         string            `json:"code"`
+	Message      string            `json:"message"`
+	Reason       string            `json:"reason,omitempty"`         // Stable reason of a service error
+	RetryAfterMs int64             `json:"retry_after_ms,omitempty"` // Suggested retry delay
+	Metadata     map[string]string `json:"metadata,omitempty"`
+}
+
+// NewErrorPayload describes an error, service errors keep their
+// classification and retry hint as in gRPC and HTTP responses
+func NewErrorPayload(err error) *ErrorPayload {
+	if protoErr, ok := err.(*ProtocolError); ok {
+		return &ErrorPayload{Code: protoErr.Code, Message: protoErr.Message}
+	}
+
+	domainErr, ok := domain.AsError(err)
+	if !ok {
+		return &ErrorPayload{Code: ErrorCodeInternal, Message: err.Error()}
+	}
+
+	return &ErrorPayload{
+		Code:         errorCodeForKind(domainErr.Kind),
+		Message:      err.Error(),
+		Reason:       domainErr.Reason,
+		RetryAfterMs: domainErr.RetryAfter.Milliseconds(),
+		Metadata:     domainErr.Metadata,
+	}
+}
+
+// errorCodeForKind maps an error kind to a protocol error code
+func errorCodeForKind(kind domain.ErrorKind) string {
+	switch kind {
+	case domain.ErrorKindNotFound:
+		return ErrorCodeNotFound
+	case domain.ErrorKindExpired:
+		return ErrorCodeExpired
+	case domain.ErrorKindExhausted:
+		return ErrorCodeExhausted
+	case domain.ErrorKindPrecondition:
+		return ErrorCodeStagesRequired
+	case domain.ErrorKindRateLimited:
+		return ErrorCodeRateLimited
+	case domain.ErrorKindBlocked:
+		return ErrorCodeRequestBlocked
+	case domain.ErrorKindCapacity:
+		return ErrorCodeCapacity
+	default:
+		return ErrorCodeInternal
+	}
+}
+
+// ConnectionEstablishedPayload is sent once a connection is ready
+type ConnectionEstablishedPayload struct {
+	ConnectionID    string `json:"connection_id"`
+	ProtocolVersion int    `json:"protocol_version"`
+}
+
+// CreateChallengePayload is the payload of a create_challenge request
+type CreateChallengePayload struct {
+	Complexity   int32 `json:"complexity"`              // The server picks the type from complexity
+	Accessible   bool  `json:"accessible,omitempty"`    // Request an audio challenge usable without sight
+	KeyboardOnly bool  `json:"keyboard_only,omitempty"` // Request a typed-text challenge
+
+	// AutoComplexity is rejected, high risk auto mode challenges take several
+	// rounds and only the gRPC event stream answers round by round
+	AutoComplexity bool `json:"auto_complexity,omitempty"`
+
+	// Passive is rejected, the probe reports its signals and gets a visible
+	// challenge pushed over the gRPC event stream
+	Passive bool `json:"passive,omitempty"`
+}
+
+// Validate validates a create_challenge payload
+func (p *CreateChallengePayload) Validate() error {
+	if p.Complexity < 0 || p.Complexity > 100 {
+		return fmt.Errorf("complexity must be between 0 and 100")
+	}
+	if p.AutoComplexity {
+		return fmt.Errorf("auto_complexity requires the gRPC event stream")
+	}
+	if p.Passive {
+		return fmt.Errorf("passive requires the gRPC event stream")
+	}
+	return nil
+}
+
+// ChallengeCreatedPayload is the payload of a challenge_created reply
+type ChallengeCreatedPayload struct {
+	ChallengeID   string `json:"challenge_id"`
+	ChallengeType string `json:"challenge_type"`
+	HTMLContent   string `json:"html_content"`
+	Complexity    int32  `json:"complexity"`
+}
+
+// ValidateChallengePayload is the payload of a validate_challenge request
+type ValidateChallengePayload struct {
+	ChallengeID string      `json:"challenge_id"`
+	Answer      interface{} `json:"answer"`
+}
+
+// Validate validates a validate_challenge payload
+func (p *ValidateChallengePayload) Validate() error {
+	if p.ChallengeID == "" {
+		return fmt.Errorf("challenge_id is required")
+	}
+	if p.Answer == nil {
+		return fmt.Errorf("answer is required")
+	}
+	return nil
+}
+
+// ChallengeValidatedPayload is the payload of a challenge_validated reply
+type ChallengeValidatedPayload struct {
+	ChallengeID string `json:"challenge_id"`
+	Solved      bool   `json:"solved"`
+	Confidence  int32  `json:"confidence"`
+	TimeTaken   int64  `json:"time_taken"`
+}
+
+// payloadValidator is implemented by payloads with validation rules
+type payloadValidator interface {
+	Validate() error
+}
+
+// ProtocolError is an error reported to the client as an error event
+type ProtocolError struct {
+	Code    string
+	Message string
+}
+
+// NewProtocolError creates a new protocol error
+func NewProtocolError(code, format string, args ...interface{}) *ProtocolError {
+	return &ProtocolError{
+		Code:    code,
+		Message: fmt.Sprintf(format, args...),
+	}
+}
+
+func (e *ProtocolError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Code, e.Message)
+}
+
+// DecodeData strictly decodes the event data into a typed payload and validates it
+func (e *Event) DecodeData(v interface{}) error {
+	if len(e.Data) == 0 {
+		return NewProtocolError(ErrorCodeInvalidPayload, "data is required for %s", e.Type)
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(e.Data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(v); err != nil {
+		return NewProtocolError(ErrorCodeInvalidPayload, "invalid %s data: %v", e.Type, err)
+	}
+
+	if validator, ok := v.(payloadValidator); ok {
+		if err := validator.Validate(); err != nil {
+			return NewProtocolError(ErrorCodeInvalidPayload, "invalid %s data: %v", e.Type, err)
+		}
+	}
+
+	return nil
+}
+
+// SetData encodes a typed payload into the event data
+func (e *Event) SetData(v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("failed to marshal %s data: %w", e.Type, err)
+	}
+
+	e.Data = data
+	return nil
+}
+
+// requestIDOf extracts the request ID from a raw message, best effort
+func requestIDOf(message []byte) string {
+	var envelope struct {
+		ID string `json:"id"`
+	}
+	if err := json.Unmarshal(message, &envelope); err != nil || len(envelope.ID) > maxRequestIDLength {
+		return ""
+	}
+	return envelope.ID
+}
+
+// validateInbound checks the envelope of a message received from a client
+func validateInbound(event *Event) error {
+	if event.Version == 0 {
+		// Clients written before versioning was introduced speak version 1
+		event.Version = ProtocolVersion
+	}
+
+	if event.Version > ProtocolVersion {
+		return NewProtocolError(ErrorCodeUnsupportedVersion, "protocol version %d is not supported, max is %d", event.Version, ProtocolVersion)
+	}
+
+	if event.Type == "" {
+		return NewProtocolError(ErrorCodeInvalidMessage, "type is required")
+	}
+
+	if len(event.ID) > maxRequestIDLength {
+		return NewProtocolError(ErrorCodeInvalidMessage, "id must not exceed %d characters", maxRequestIDLength)
+	}
+
+	return nil
+}
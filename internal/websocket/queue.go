@@ -0,0 +1,131 @@
+package websocket
+
+import (
+	"fmt"
+	"sync/atomic"
+)
+
+// SlowConsumerPolicy decides what happens when a connection's send queue is full
+type SlowConsumerPolicy string
+
+// Slow consumer policies
+const (
+	// PolicyDropNewest discards the event being sent
+	PolicyDropNewest SlowConsumerPolicy = "drop_newest"
+	// PolicyDropOldest discards the oldest queued event to make room
+	PolicyDropOldest SlowConsumerPolicy = "drop_oldest"
+	// PolicyDisconnect closes the connection, the client is expected to reconnect
+	PolicyDisconnect SlowConsumerPolicy = "disconnect"
+)
+
+// ParseSlowConsumerPolicy parses a policy name
+func ParseSlowConsumerPolicy(name string) (SlowConsumerPolicy, error) {
+	switch policy := SlowConsumerPolicy(name); policy {
+	case PolicyDropNewest, PolicyDropOldest, PolicyDisconnect:
+		return policy, nil
+	default:
+		return "", fmt.Errorf("unknown slow consumer policy: %s", name)
+	}
+}
+
+// Send queue errors
+var (
+	ErrConnectionClosed = fmt.Errorf("connection not found or inactive")
+	ErrSlowConsumer     = fmt.Errorf("send queue full, slow consumer")
+)
+
+// QueueObserver receives send queue and inbound event measurements, implemented by monitoring.Metrics
+type QueueObserver interface {
+	RecordWebSocketEvent(eventType string)
+	RecordWebSocketQueueDepth(depth int)
+	RecordWebSocketDroppedEvent(eventType, policy string)
+	RecordWebSocketSlowConsumer()
+}
+
+// Done returns a channel closed when the connection is removed from the service
+func (c *Connection) Done() <-chan struct{} {
+	return c.done
+}
+
+// QueueDepth returns the number of events waiting to be written
+func (c *Connection) QueueDepth() int {
+	return len(c.Events)
+}
+
+// DroppedEvents returns the number of events dropped for this connection
+func (c *Connection) DroppedEvents() uint64 {
+	return atomic.LoadUint64(&c.dropped)
+}
+
+// closeDone signals writers that the connection is gone
+func (c *Connection) closeDone() {
+	c.closeOnce.Do(func() {
+		close(c.done)
+	})
+}
+
+// enqueue puts an event on a connection's send queue without blocking,
+// applying the slow consumer policy when the queue is full
+func (ws *WebSocketService) enqueue(conn *Connection, event *Event) error {
+	select {
+	case <-conn.done:
+		return ErrConnectionClosed
+	default:
+	}
+
+	select {
+	case conn.Events <- event:
+		ws.observeDepth(len(conn.Events))
+		return nil
+	default:
+	}
+
+	switch ws.config.SlowConsumerPolicy {
+	case PolicyDropOldest:
+		select {
+		case oldest := <-conn.Events:
+			ws.recordDrop(conn, oldest)
+		default:
+		}
+
+		select {
+		case conn.Events <- event:
+			ws.observeDepth(len(conn.Events))
+			return nil
+		default:
+			// Another sender took the freed slot
+			ws.recordDrop(conn, event)
+			return ErrSlowConsumer
+		}
+
+	case PolicyDisconnect:
+		ws.recordDrop(conn, event)
+		atomic.AddUint64(&ws.slowConsumerDisconnects, 1)
+		if ws.config.Observer != nil {
+			ws.config.Observer.RecordWebSocketSlowConsumer()
+		}
+		ws.CloseConnection(conn.ID)
+		return ErrSlowConsumer
+
+	default:
+		ws.recordDrop(conn, event)
+		return ErrSlowConsumer
+	}
+}
+
+// recordDrop accounts for a dropped event
+func (ws *WebSocketService) recordDrop(conn *Connection, event *Event) {
+	atomic.AddUint64(&conn.dropped, 1)
+	atomic.AddUint64(&ws.droppedEvents, 1)
+
+	if ws.config.Observer != nil {
+		ws.config.Observer.RecordWebSocketDroppedEvent(event.Type, string(ws.config.SlowConsumerPolicy))
+	}
+}
+
+// observeDepth reports the queue depth after an enqueue
+func (ws *WebSocketService) observeDepth(depth int) {
+	if ws.config.Observer != nil {
+		ws.config.Observer.RecordWebSocketQueueDepth(depth)
+	}
+}
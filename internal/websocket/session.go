@@ -0,0 +1,162 @@
+package websocket
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Session holds per-connection state shared by event handlers
+type Session struct {
+	ConnectionID string
+	ClientID     string
+	CreatedAt    time.Time
+
+	mu         sync.RWMutex
+	challenges map[string]time.Time // challenge ID -> bind time
+}
+
+// SessionCloseHandler is called after a session's connection is closed
+type SessionCloseHandler func(ctx context.Context, session *Session)
+
+// sessionContextKey is the context key for the current session
+type sessionContextKey struct{}
+
+// newSession creates a new session for a connection
+func newSession(connID, clientID string) *Session {
+	return &Session{
+		ConnectionID: connID,
+		ClientID:     clientID,
+		CreatedAt:    time.Now(),
+		challenges:   make(map[string]time.Time),
+	}
+}
+
+// Challenges returns the IDs of the challenges owned by the session
+func (s *Session) Challenges() []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	ids := make([]string, 0, len(s.challenges))
+	for id := range s.challenges {
+		ids = append(ids, id)
+	}
+
+	return ids
+}
+
+// OwnsChallenge reports whether the session owns a challenge
+func (s *Session) OwnsChallenge(challengeID string) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	_, exists := s.challenges[challengeID]
+	return exists
+}
+
+// bind adds a challenge to the session
+func (s *Session) bind(challengeID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.challenges[challengeID] = time.Now()
+}
+
+// unbind removes a challenge from the session
+func (s *Session) unbind(challengeID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.challenges, challengeID)
+}
+
+// SessionFromContext returns the session of the connection that sent the event
+func SessionFromContext(ctx context.Context) (*Session, bool) {
+	session, ok := ctx.Value(sessionContextKey{}).(*Session)
+	return session, ok
+}
+
+// contextWithSession attaches a session to a context
+func contextWithSession(ctx context.Context, session *Session) context.Context {
+	return context.WithValue(ctx, sessionContextKey{}, session)
+}
+
+// OnSessionClosed registers a handler called when a session ends
+func (ws *WebSocketService) OnSessionClosed(handler SessionCloseHandler) {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+
+	ws.closeHandlers = append(ws.closeHandlers, handler)
+}
+
+// BindChallenge makes a session the owner of a challenge
+func (ws *WebSocketService) BindChallenge(session *Session, challengeID string) {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+
+	session.bind(challengeID)
+	ws.challengeOwners[challengeID] = session.ConnectionID
+}
+
+// UnbindChallenge releases a challenge from its owning session
+func (ws *WebSocketService) UnbindChallenge(challengeID string) {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+
+	connID, exists := ws.challengeOwners[challengeID]
+	if !exists {
+		return
+	}
+
+	if conn, ok := ws.connections[connID]; ok {
+		conn.Session.unbind(challengeID)
+	}
+	delete(ws.challengeOwners, challengeID)
+}
+
+// ChallengeOwner returns the connection ID owning a challenge
+func (ws *WebSocketService) ChallengeOwner(challengeID string) (string, bool) {
+	ws.mu.RLock()
+	defer ws.mu.RUnlock()
+
+	connID, exists := ws.challengeOwners[challengeID]
+	return connID, exists
+}
+
+// removeConnectionLocked drops a connection and its indexes, the caller holds ws.mu
+func (ws *WebSocketService) removeConnectionLocked(conn *Connection) {
+	conn.Active = false
+	conn.closeDone()
+	delete(ws.connections, conn.ID)
+
+	if clientConns, exists := ws.clientConnections[conn.ClientID]; exists {
+		delete(clientConns, conn.ID)
+		if len(clientConns) == 0 {
+			delete(ws.clientConnections, conn.ClientID)
+		}
+	}
+
+	for _, challengeID := range conn.Session.Challenges() {
+		if ws.challengeOwners[challengeID] == conn.ID {
+			delete(ws.challengeOwners, challengeID)
+		}
+	}
+}
+
+// notifySessionClosed runs close handlers for ended sessions
+func (ws *WebSocketService) notifySessionClosed(sessions []*Session) {
+	if len(sessions) == 0 {
+		return
+	}
+
+	ws.mu.RLock()
+	handlers := make([]SessionCloseHandler, len(ws.closeHandlers))
+	copy(handlers, ws.closeHandlers)
+	ws.mu.RUnlock()
+
+	for _, session := range sessions {
+		for _, handler := range handlers {
+			handler(context.Background(), session)
+		}
+	}
+}
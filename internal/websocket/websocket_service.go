@@ -5,17 +5,51 @@ import (
 	"encoding/json"
 	"fmt"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/FlooooowY/SteelMount-Captcha-Service/internal/logger"
+	"github.com/FlooooowY/SteelMount-Captcha-Service/internal/security"
+	"github.com/FlooooowY/SteelMount-Captcha-Service/internal/tracing"
 )
 
 // WebSocketService handles WebSocket connections and events
 type WebSocketService struct {
-	mu          sync.RWMutex
-	connections map[string]*Connection
-	eventBus    chan *Event
-	handlers    map[string]EventHandler
+	mu                sync.RWMutex
+	connections       map[string]*Connection
+	clientConnections map[string]map[string]struct{} // client ID -> connection IDs
+	challengeOwners   map[string]string              // challenge ID -> connection ID
+	eventBus          chan *Event
+	handlers          map[string]EventHandler
+	closeHandlers     []SessionCloseHandler
+	config            *ServiceConfig
+	logger            *logrus.Logger
+
+	droppedEvents           uint64
+	slowConsumerDisconnects uint64
+}
+
+// ServiceConfig contains WebSocket service queueing settings
+type ServiceConfig struct {
+	EventBusSize       int // Inbound events waiting for handlers
+	SendQueueSize      int // Outbound events buffered per connection
+	SlowConsumerPolicy SlowConsumerPolicy
+	Observer           QueueObserver  // Optional
+	Logger             *logrus.Logger // Optional, the shared logger when nil
+}
+
+// DefaultServiceConfig returns the default queueing settings
+func DefaultServiceConfig() *ServiceConfig {
+	return &ServiceConfig{
+		EventBusSize:       1000,
+		SendQueueSize:      100,
+		SlowConsumerPolicy: PolicyDisconnect,
+	}
 }
 
 // Connection represents a WebSocket connection
@@ -25,27 +59,73 @@ type Connection struct {
 	CreatedAt time.Time
 	LastSeen  time.Time
 	Active    bool
-	Events    chan *Event
+	Events    chan *Event // Bounded send queue drained by the connection writer
+	Session   *Session
+
+	done      chan struct{}
+	closeOnce sync.Once
+	dropped   uint64
 }
 
-// Event represents a WebSocket event
+// Event represents a WebSocket protocol message
 type Event struct {
-	ID        string                 `json:"id"`
-	Type      string                 `json:"type"`
-	Data      map[string]interface{} `json:"data"`
-	Timestamp time.Time              `json:"timestamp"`
-	ClientID  string                 `json:"client_id,omitempty"`
+	Version   int             `json:"version,omitempty"`
+	ID        string          `json:"id"`
+	RequestID string          `json:"request_id,omitempty"` // ID of the request this event answers
+	Type      string          `json:"type"`
+	Data      json.RawMessage `json:"data,omitempty"`
+	Error     *ErrorPayload   `json:"error,omitempty"`
+	Timestamp time.Time       `json:"timestamp"`
+	ClientID  string          `json:"client_id,omitempty"`
+
+	// ConnectionID is the connection an inbound event arrived on, never sent to clients
+	ConnectionID string `json:"-"`
+
+	// spanContext is the span the inbound event was received in, handlers continue its trace
+	spanContext trace.SpanContext
+
+	// clientIP and userAgent identify the client the inbound event came from,
+	// handlers see them through security.ExtractIPFromRequest
+	clientIP  string
+	userAgent string
 }
 
-// EventHandler handles specific event types
-type EventHandler func(ctx context.Context, event *Event) error
+// EventHandler handles specific event types and returns the reply event
+type EventHandler func(ctx context.Context, event *Event) (*Event, error)
 
 // NewWebSocketService creates a new WebSocket service
 func NewWebSocketService() *WebSocketService {
+	return NewWebSocketServiceWithConfig(DefaultServiceConfig())
+}
+
+// NewWebSocketServiceWithConfig creates a new WebSocket service with custom queueing
+func NewWebSocketServiceWithConfig(config *ServiceConfig) *WebSocketService {
+	defaults := DefaultServiceConfig()
+	if config == nil {
+		config = defaults
+	}
+	if config.EventBusSize <= 0 {
+		config.EventBusSize = defaults.EventBusSize
+	}
+	if config.SendQueueSize <= 0 {
+		config.SendQueueSize = defaults.SendQueueSize
+	}
+	if config.SlowConsumerPolicy == "" {
+		config.SlowConsumerPolicy = defaults.SlowConsumerPolicy
+	}
+	serviceLogger := config.Logger
+	if serviceLogger == nil {
+		serviceLogger = logger.GetLogger()
+	}
+
 	ws := &WebSocketService{
-		connections: make(map[string]*Connection),
-		eventBus:    make(chan *Event, 1000),
-		handlers:    make(map[string]EventHandler),
+		connections:       make(map[string]*Connection),
+		clientConnections: make(map[string]map[string]struct{}),
+		challengeOwners:   make(map[string]string),
+		eventBus:          make(chan *Event, config.EventBusSize),
+		handlers:          make(map[string]EventHandler),
+		config:            config,
+		logger:            serviceLogger,
 	}
 	
 	// Start event processing
@@ -74,10 +154,16 @@ func (ws *WebSocketService) CreateConnection(clientID string) *Connection {
 		CreatedAt: time.Now(),
 		LastSeen:  time.Now(),
 		Active:    true,
-		Events:    make(chan *Event, 100),
+		Events:    make(chan *Event, ws.config.SendQueueSize),
+		Session:   newSession(connID, clientID),
+		done:      make(chan struct{}),
 	}
 	
 	ws.connections[connID] = conn
+	if _, exists := ws.clientConnections[clientID]; !exists {
+		ws.clientConnections[clientID] = make(map[string]struct{})
+	}
+	ws.clientConnections[clientID][connID] = struct{}{}
 	
 	return conn
 }
@@ -98,58 +184,57 @@ func (ws *WebSocketService) SendEvent(connID string, event *Event) error {
 	ws.mu.RUnlock()
 	
 	if !exists || !conn.Active {
-		return fmt.Errorf("connection not found or inactive")
+		return ErrConnectionClosed
 	}
 	
-	select {
-	case conn.Events <- event:
-		return nil
-	case <-time.After(5 * time.Second):
-		return fmt.Errorf("timeout sending event")
-	}
+	return ws.enqueue(conn, event)
 }
 
 // BroadcastEvent broadcasts an event to all active connections
 func (ws *WebSocketService) BroadcastEvent(event *Event) {
 	ws.mu.RLock()
-	defer ws.mu.RUnlock()
-	
+	targets := make([]*Connection, 0, len(ws.connections))
 	for _, conn := range ws.connections {
 		if conn.Active {
-			select {
-			case conn.Events <- event:
-			default:
-				// Skip if channel is full
-			}
+			targets = append(targets, conn)
 		}
 	}
+	ws.mu.RUnlock()
+	
+	// Enqueue outside the lock, the disconnect policy removes connections
+	for _, conn := range targets {
+		_ = ws.enqueue(conn, event)
+	}
 }
 
 // BroadcastToClient broadcasts an event to all connections of a specific client
 func (ws *WebSocketService) BroadcastToClient(clientID string, event *Event) {
 	ws.mu.RLock()
-	defer ws.mu.RUnlock()
-	
-	for _, conn := range ws.connections {
-		if conn.Active && conn.ClientID == clientID {
-			select {
-			case conn.Events <- event:
-			default:
-				// Skip if channel is full
-			}
+	targets := make([]*Connection, 0, len(ws.clientConnections[clientID]))
+	for connID := range ws.clientConnections[clientID] {
+		conn := ws.connections[connID]
+		if conn != nil && conn.Active {
+			targets = append(targets, conn)
 		}
 	}
+	ws.mu.RUnlock()
+	
+	for _, conn := range targets {
+		_ = ws.enqueue(conn, event)
+	}
 }
 
 // CloseConnection closes a WebSocket connection
 func (ws *WebSocketService) CloseConnection(connID string) {
 	ws.mu.Lock()
-	defer ws.mu.Unlock()
+	conn, exists := ws.connections[connID]
+	if exists {
+		ws.removeConnectionLocked(conn)
+	}
+	ws.mu.Unlock()
 	
-	if conn, exists := ws.connections[connID]; exists {
-		conn.Active = false
-		close(conn.Events)
-		delete(ws.connections, connID)
+	if exists {
+		ws.notifySessionClosed([]*Session{conn.Session})
 	}
 }
 
@@ -157,12 +242,47 @@ func (ws *WebSocketService) CloseConnection(connID string) {
 func (ws *WebSocketService) ProcessMessage(ctx context.Context, connID string, message []byte) error {
 	var event Event
 	if err := json.Unmarshal(message, &event); err != nil {
-		return fmt.Errorf("failed to unmarshal message: %w", err)
+		protoErr := NewProtocolError(ErrorCodeInvalidMessage, "malformed message: %v", err)
+		ws.sendError(connID, "", protoErr)
+		return protoErr
 	}
-	
-	// Set connection ID
-	event.ClientID = connID
-	
+
+	if err := validateInbound(&event); err != nil {
+		ws.sendError(connID, event.ID, err)
+		return err
+	}
+
+	ws.mu.RLock()
+	_, exists := ws.handlers[event.Type]
+	conn, connExists := ws.connections[connID]
+	ws.mu.RUnlock()
+
+	if !connExists {
+		return fmt.Errorf("connection not found: %s", connID)
+	}
+
+	if !exists {
+		protoErr := NewProtocolError(ErrorCodeUnknownType, "unknown event type: %s", event.Type)
+		ws.sendError(connID, event.ID, protoErr)
+		return protoErr
+	}
+
+	// Only registered types are counted, so the type label stays bounded
+	if ws.config.Observer != nil {
+		ws.config.Observer.RecordWebSocketEvent(event.Type)
+	}
+
+	// Bind the event to the connection it arrived on, clients cannot choose either ID
+	event.ConnectionID = connID
+	event.ClientID = conn.ClientID
+	event.spanContext = trace.SpanContextFromContext(ctx)
+	event.clientIP = security.ExtractIPFromRequest(ctx)
+	event.userAgent = security.ExtractUserAgentFromRequest(ctx)
+
+	ws.mu.Lock()
+	conn.LastSeen = time.Now()
+	ws.mu.Unlock()
+
 	// Add to event bus
 	select {
 	case ws.eventBus <- &event:
@@ -170,7 +290,9 @@ func (ws *WebSocketService) ProcessMessage(ctx context.Context, connID string, m
 	case <-ctx.Done():
 		return ctx.Err()
 	default:
-		return fmt.Errorf("event bus is full")
+		protoErr := NewProtocolError(ErrorCodeInternal, "event bus is full")
+		ws.sendError(connID, event.ID, protoErr)
+		return protoErr
 	}
 }
 
@@ -181,21 +303,86 @@ func (ws *WebSocketService) processEvents() {
 	}
 }
 
-// handleEvent handles a specific event
+// handleEvent handles a specific event and replies to its connection
 func (ws *WebSocketService) handleEvent(ctx context.Context, event *Event) {
 	ws.mu.RLock()
 	handler, exists := ws.handlers[event.Type]
+	conn, connExists := ws.connections[event.ConnectionID]
 	ws.mu.RUnlock()
-	
+
+	if !connExists {
+		// Connection closed while the event was queued
+		return
+	}
+
 	if !exists {
-		// No handler for this event type
+		ws.sendError(event.ConnectionID, event.ID, NewProtocolError(ErrorCodeUnknownType, "unknown event type: %s", event.Type))
 		return
 	}
-	
+
+	if event.clientIP != "" {
+		ctx = security.ContextWithClient(ctx, event.clientIP, event.userAgent)
+	}
+	ctx, span := tracing.StartSpan(trace.ContextWithSpanContext(ctx, event.spanContext), "websocket.handle/"+event.Type,
+		attribute.String("websocket.connection_id", event.ConnectionID),
+		attribute.String("websocket.event_id", event.ID),
+	)
+
 	// Execute handler
-	if err := handler(ctx, event); err != nil {
-		// Log error or handle it appropriately
-		fmt.Printf("Error handling event %s: %v\n", event.Type, err)
+	reply, err := handler(contextWithSession(ctx, conn.Session), event)
+	tracing.EndSpan(span, err)
+	if err != nil {
+		// The client gets the error event, the log only keeps it for debugging
+		ws.logger.WithFields(logrus.Fields{
+			"connection_id": event.ConnectionID,
+			"error":         err,
+		}).Debug("WebSocket event handler failed")
+		ws.sendError(event.ConnectionID, event.ID, err)
+		return
+	}
+
+	if reply == nil {
+		return
+	}
+
+	reply.RequestID = event.ID
+	if reply.ClientID == "" {
+		reply.ClientID = event.ClientID
+	}
+	if err := ws.SendEvent(event.ConnectionID, reply); err != nil {
+		ws.logger.WithFields(logrus.Fields{
+			"connection_id": event.ConnectionID,
+			"error":         err,
+		}).Warn("Failed to send WebSocket reply")
+	}
+}
+
+// SendError sends an error event answering the given request
+func (ws *WebSocketService) SendError(connID, requestID string, err error) {
+	ws.sendError(connID, requestID, err)
+}
+
+// sendError sends an error event answering the given request
+func (ws *WebSocketService) sendError(connID, requestID string, err error) {
+	event := NewEvent(EventTypeError)
+	event.RequestID = requestID
+	event.Error = NewErrorPayload(err)
+
+	if sendErr := ws.SendEvent(connID, event); sendErr != nil {
+		ws.logger.WithFields(logrus.Fields{
+			"connection_id": connID,
+			"error":         sendErr,
+		}).Warn("Failed to send WebSocket error event")
+	}
+}
+
+// NewEvent creates a server event of the given type
+func NewEvent(eventType string) *Event {
+	return &Event{
+		Version:   ProtocolVersion,
+		ID:        uuid.New().String(),
+		Type:      eventType,
+		Timestamp: time.Now(),
 	}
 }
 
@@ -206,33 +393,51 @@ func (ws *WebSocketService) GetConnectionStats() map[string]interface{} {
 	
 	activeCount := 0
 	totalCount := len(ws.connections)
+	queuedEvents := 0
+	maxQueueDepth := 0
 	
 	for _, conn := range ws.connections {
 		if conn.Active {
 			activeCount++
 		}
+		depth := conn.QueueDepth()
+		queuedEvents += depth
+		if depth > maxQueueDepth {
+			maxQueueDepth = depth
+		}
 	}
 	
 	return map[string]interface{}{
-		"total_connections":  totalCount,
-		"active_connections": activeCount,
-		"event_bus_size":    len(ws.eventBus),
+		"total_connections":         totalCount,
+		"active_connections":        activeCount,
+		"unique_clients":            len(ws.clientConnections),
+		"owned_challenges":          len(ws.challengeOwners),
+		"event_bus_size":            len(ws.eventBus),
+		"queued_events":             queuedEvents,
+		"max_queue_depth":           maxQueueDepth,
+		"send_queue_size":           ws.config.SendQueueSize,
+		"slow_consumer_policy":      string(ws.config.SlowConsumerPolicy),
+		"dropped_events":            atomic.LoadUint64(&ws.droppedEvents),
+		"slow_consumer_disconnects": atomic.LoadUint64(&ws.slowConsumerDisconnects),
 	}
 }
 
 // CleanupInactiveConnections removes inactive connections
 func (ws *WebSocketService) CleanupInactiveConnections() {
 	ws.mu.Lock()
-	defer ws.mu.Unlock()
 	
+	var closed []*Session
 	now := time.Now()
-	for connID, conn := range ws.connections {
+	for _, conn := range ws.connections {
 		// Remove connections inactive for more than 1 hour
 		if !conn.Active || now.Sub(conn.LastSeen) > time.Hour {
-			close(conn.Events)
-			delete(ws.connections, connID)
+			ws.removeConnectionLocked(conn)
+			closed = append(closed, conn.Session)
 		}
 	}
+	ws.mu.Unlock()
+	
+	ws.notifySessionClosed(closed)
 }
 
 // StartCleanupRoutine starts a background cleanup routine
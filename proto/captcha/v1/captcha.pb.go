@@ -27,6 +27,12 @@ const (
 	ClientEvent_FRONTEND_EVENT    ClientEvent_EventType = 0
 	ClientEvent_CONNECTION_CLOSED ClientEvent_EventType = 1
 	ClientEvent_BALANCER_EVENT    ClientEvent_EventType = 2
+	// Reattaches a new stream to session_id and replays server events after ack
+	ClientEvent_RESUME ClientEvent_EventType = 3
+	// Creates a challenge owned by this stream, answered with ChallengeCreated
+	ClientEvent_CREATE_CHALLENGE ClientEvent_EventType = 4
+	// Validates the answer in data (JSON) for a challenge owned by this stream
+	ClientEvent_VALIDATE_CHALLENGE ClientEvent_EventType = 5
 )
 
 // Enum value maps for ClientEvent_EventType.
@@ -35,11 +41,17 @@ var (
 		0: "FRONTEND_EVENT",
 		1: "CONNECTION_CLOSED",
 		2: "BALANCER_EVENT",
+		3: "RESUME",
+		4: "CREATE_CHALLENGE",
+		5: "VALIDATE_CHALLENGE",
 	}
 	ClientEvent_EventType_value = map[string]int32{
-		"FRONTEND_EVENT":    0,
-		"CONNECTION_CLOSED": 1,
-		"BALANCER_EVENT":    2,
+		"FRONTEND_EVENT":     0,
+		"CONNECTION_CLOSED":  1,
+		"BALANCER_EVENT":     2,
+		"RESUME":             3,
+		"CREATE_CHALLENGE":   4,
+		"VALIDATE_CHALLENGE": 5,
 	}
 )
 
@@ -71,8 +83,20 @@ func (ClientEvent_EventType) EnumDescriptor() ([]byte, []int) {
 }
 
 type ChallengeRequest struct {
-	state         protoimpl.MessageState `protogen:"open.v1"`
-	Complexity    int32                  `protobuf:"varint,1,opt,name=complexity,proto3" json:"complexity,omitempty"`
+	state      protoimpl.MessageState `protogen:"open.v1"`
+	Complexity int32                  `protobuf:"varint,1,opt,name=complexity,proto3" json:"complexity,omitempty"`
+	// Requests a challenge usable without sight, an audio challenge
+	Accessible bool `protobuf:"varint,2,opt,name=accessible,proto3" json:"accessible,omitempty"`
+	// Requests a challenge answered by typing, for clients without pointer or touch input
+	KeyboardOnly bool `protobuf:"varint,3,opt,name=keyboard_only,json=keyboardOnly,proto3" json:"keyboard_only,omitempty"`
+	// Derives complexity and type from the caller's risk signals, complexity is
+	// ignored. High risk challenges take several rounds and are answered with
+	// challenge_attempt frontend events on the event stream
+	AutoComplexity bool `protobuf:"varint,4,opt,name=auto_complexity,json=autoComplexity,proto3" json:"auto_complexity,omitempty"`
+	// Starts with an invisible probe reporting probe_signals frontend events on
+	// the event stream. Low risk clients pass without a puzzle, others get a
+	// visible challenge pushed on the stream, complexity is ignored
+	Passive       bool `protobuf:"varint,5,opt,name=passive,proto3" json:"passive,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
@@ -114,6 +138,34 @@ func (x *ChallengeRequest) GetComplexity() int32 {
 	return 0
 }
 
+func (x *ChallengeRequest) GetAccessible() bool {
+	if x != nil {
+		return x.Accessible
+	}
+	return false
+}
+
+func (x *ChallengeRequest) GetKeyboardOnly() bool {
+	if x != nil {
+		return x.KeyboardOnly
+	}
+	return false
+}
+
+func (x *ChallengeRequest) GetAutoComplexity() bool {
+	if x != nil {
+		return x.AutoComplexity
+	}
+	return false
+}
+
+func (x *ChallengeRequest) GetPassive() bool {
+	if x != nil {
+		return x.Passive
+	}
+	return false
+}
+
 type ChallengeResponse struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	ChallengeId   string                 `protobuf:"bytes,1,opt,name=challenge_id,json=challengeId,proto3" json:"challenge_id,omitempty"`
@@ -167,10 +219,26 @@ func (x *ChallengeResponse) GetHtml() string {
 }
 
 type ClientEvent struct {
-	state         protoimpl.MessageState `protogen:"open.v1"`
-	EventType     ClientEvent_EventType  `protobuf:"varint,1,opt,name=event_type,json=eventType,proto3,enum=captcha.v1.ClientEvent_EventType" json:"event_type,omitempty"`
-	ChallengeId   string                 `protobuf:"bytes,2,opt,name=challenge_id,json=challengeId,proto3" json:"challenge_id,omitempty"`
-	Data          []byte                 `protobuf:"bytes,3,opt,name=data,proto3" json:"data,omitempty"`
+	state       protoimpl.MessageState `protogen:"open.v1"`
+	EventType   ClientEvent_EventType  `protobuf:"varint,1,opt,name=event_type,json=eventType,proto3,enum=captcha.v1.ClientEvent_EventType" json:"event_type,omitempty"`
+	ChallengeId string                 `protobuf:"bytes,2,opt,name=challenge_id,json=challengeId,proto3" json:"challenge_id,omitempty"`
+	Data        []byte                 `protobuf:"bytes,3,opt,name=data,proto3" json:"data,omitempty"`
+	// Stream session the event belongs to, assigned by the server on the first event
+	SessionId string `protobuf:"bytes,4,opt,name=session_id,json=sessionId,proto3" json:"session_id,omitempty"`
+	// Monotonic client sequence number, events at or below the last processed one are ignored; 0 disables deduplication
+	Seq uint64 `protobuf:"varint,5,opt,name=seq,proto3" json:"seq,omitempty"`
+	// Highest server sequence number the client has received
+	Ack uint64 `protobuf:"varint,6,opt,name=ack,proto3" json:"ack,omitempty"`
+	// Complexity of a CREATE_CHALLENGE event
+	Complexity int32 `protobuf:"varint,7,opt,name=complexity,proto3" json:"complexity,omitempty"`
+	// Requests an accessible challenge in a CREATE_CHALLENGE event
+	Accessible bool `protobuf:"varint,8,opt,name=accessible,proto3" json:"accessible,omitempty"`
+	// Requests a keyboard-only challenge in a CREATE_CHALLENGE event
+	KeyboardOnly bool `protobuf:"varint,9,opt,name=keyboard_only,json=keyboardOnly,proto3" json:"keyboard_only,omitempty"`
+	// Requests a risk-based complexity in a CREATE_CHALLENGE event
+	AutoComplexity bool `protobuf:"varint,10,opt,name=auto_complexity,json=autoComplexity,proto3" json:"auto_complexity,omitempty"`
+	// Requests a passive probe in a CREATE_CHALLENGE event
+	Passive       bool `protobuf:"varint,11,opt,name=passive,proto3" json:"passive,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
@@ -226,6 +294,62 @@ func (x *ClientEvent) GetData() []byte {
 	return nil
 }
 
+func (x *ClientEvent) GetSessionId() string {
+	if x != nil {
+		return x.SessionId
+	}
+	return ""
+}
+
+func (x *ClientEvent) GetSeq() uint64 {
+	if x != nil {
+		return x.Seq
+	}
+	return 0
+}
+
+func (x *ClientEvent) GetAck() uint64 {
+	if x != nil {
+		return x.Ack
+	}
+	return 0
+}
+
+func (x *ClientEvent) GetComplexity() int32 {
+	if x != nil {
+		return x.Complexity
+	}
+	return 0
+}
+
+func (x *ClientEvent) GetAccessible() bool {
+	if x != nil {
+		return x.Accessible
+	}
+	return false
+}
+
+func (x *ClientEvent) GetKeyboardOnly() bool {
+	if x != nil {
+		return x.KeyboardOnly
+	}
+	return false
+}
+
+func (x *ClientEvent) GetAutoComplexity() bool {
+	if x != nil {
+		return x.AutoComplexity
+	}
+	return false
+}
+
+func (x *ClientEvent) GetPassive() bool {
+	if x != nil {
+		return x.Passive
+	}
+	return false
+}
+
 type ServerEvent struct {
 	state protoimpl.MessageState `protogen:"open.v1"`
 	// Types that are valid to be assigned to Event:
@@ -233,7 +357,14 @@ type ServerEvent struct {
 	//	*ServerEvent_Result
 	//	*ServerEvent_ClientJs
 	//	*ServerEvent_ClientData
-	Event         isServerEvent_Event `protobuf_oneof:"event"`
+	//	*ServerEvent_Error_
+	//	*ServerEvent_Resumed
+	//	*ServerEvent_Created
+	//	*ServerEvent_Established
+	Event     isServerEvent_Event `protobuf_oneof:"event"`
+	SessionId string              `protobuf:"bytes,10,opt,name=session_id,json=sessionId,proto3" json:"session_id,omitempty"`
+	// Monotonic server sequence number within the session
+	Seq           uint64 `protobuf:"varint,11,opt,name=seq,proto3" json:"seq,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
@@ -302,6 +433,56 @@ func (x *ServerEvent) GetClientData() *ServerEvent_SendClientData {
 	return nil
 }
 
+func (x *ServerEvent) GetError() *ServerEvent_Error {
+	if x != nil {
+		if x, ok := x.Event.(*ServerEvent_Error_); ok {
+			return x.Error
+		}
+	}
+	return nil
+}
+
+func (x *ServerEvent) GetResumed() *ServerEvent_SessionResumed {
+	if x != nil {
+		if x, ok := x.Event.(*ServerEvent_Resumed); ok {
+			return x.Resumed
+		}
+	}
+	return nil
+}
+
+func (x *ServerEvent) GetCreated() *ServerEvent_ChallengeCreated {
+	if x != nil {
+		if x, ok := x.Event.(*ServerEvent_Created); ok {
+			return x.Created
+		}
+	}
+	return nil
+}
+
+func (x *ServerEvent) GetEstablished() *ServerEvent_SessionEstablished {
+	if x != nil {
+		if x, ok := x.Event.(*ServerEvent_Established); ok {
+			return x.Established
+		}
+	}
+	return nil
+}
+
+func (x *ServerEvent) GetSessionId() string {
+	if x != nil {
+		return x.SessionId
+	}
+	return ""
+}
+
+func (x *ServerEvent) GetSeq() uint64 {
+	if x != nil {
+		return x.Seq
+	}
+	return 0
+}
+
 type isServerEvent_Event interface {
 	isServerEvent_Event()
 }
@@ -318,16 +499,41 @@ type ServerEvent_ClientData struct {
 	ClientData *ServerEvent_SendClientData `protobuf:"bytes,3,opt,name=client_data,json=clientData,proto3,oneof"`
 }
 
+type ServerEvent_Error_ struct {
+	Error *ServerEvent_Error `protobuf:"bytes,4,opt,name=error,proto3,oneof"`
+}
+
+type ServerEvent_Resumed struct {
+	Resumed *ServerEvent_SessionResumed `protobuf:"bytes,5,opt,name=resumed,proto3,oneof"`
+}
+
+type ServerEvent_Created struct {
+	Created *ServerEvent_ChallengeCreated `protobuf:"bytes,6,opt,name=created,proto3,oneof"`
+}
+
+type ServerEvent_Established struct {
+	Established *ServerEvent_SessionEstablished `protobuf:"bytes,7,opt,name=established,proto3,oneof"`
+}
+
 func (*ServerEvent_Result) isServerEvent_Event() {}
 
 func (*ServerEvent_ClientJs) isServerEvent_Event() {}
 
 func (*ServerEvent_ClientData) isServerEvent_Event() {}
 
+func (*ServerEvent_Error_) isServerEvent_Event() {}
+
+func (*ServerEvent_Resumed) isServerEvent_Event() {}
+
+func (*ServerEvent_Created) isServerEvent_Event() {}
+
+func (*ServerEvent_Established) isServerEvent_Event() {}
+
 type ServerEvent_ChallengeResult struct {
 	state             protoimpl.MessageState `protogen:"open.v1"`
 	ChallengeId       string                 `protobuf:"bytes,1,opt,name=challenge_id,json=challengeId,proto3" json:"challenge_id,omitempty"`
 	ConfidencePercent int32                  `protobuf:"varint,2,opt,name=confidence_percent,json=confidencePercent,proto3" json:"confidence_percent,omitempty"`
+	Solved            bool                   `protobuf:"varint,3,opt,name=solved,proto3" json:"solved,omitempty"`
 	unknownFields     protoimpl.UnknownFields
 	sizeCache         protoimpl.SizeCache
 }
@@ -376,6 +582,65 @@ func (x *ServerEvent_ChallengeResult) GetConfidencePercent() int32 {
 	return 0
 }
 
+func (x *ServerEvent_ChallengeResult) GetSolved() bool {
+	if x != nil {
+		return x.Solved
+	}
+	return false
+}
+
+type ServerEvent_ChallengeCreated struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	ChallengeId   string                 `protobuf:"bytes,1,opt,name=challenge_id,json=challengeId,proto3" json:"challenge_id,omitempty"`
+	Html          string                 `protobuf:"bytes,2,opt,name=html,proto3" json:"html,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ServerEvent_ChallengeCreated) Reset() {
+	*x = ServerEvent_ChallengeCreated{}
+	mi := &file_proto_captcha_v1_captcha_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ServerEvent_ChallengeCreated) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ServerEvent_ChallengeCreated) ProtoMessage() {}
+
+func (x *ServerEvent_ChallengeCreated) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_captcha_v1_captcha_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ServerEvent_ChallengeCreated.ProtoReflect.Descriptor instead.
+func (*ServerEvent_ChallengeCreated) Descriptor() ([]byte, []int) {
+	return file_proto_captcha_v1_captcha_proto_rawDescGZIP(), []int{3, 1}
+}
+
+func (x *ServerEvent_ChallengeCreated) GetChallengeId() string {
+	if x != nil {
+		return x.ChallengeId
+	}
+	return ""
+}
+
+func (x *ServerEvent_ChallengeCreated) GetHtml() string {
+	if x != nil {
+		return x.Html
+	}
+	return ""
+}
+
 type ServerEvent_RunClientJS struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	ChallengeId   string                 `protobuf:"bytes,1,opt,name=challenge_id,json=challengeId,proto3" json:"challenge_id,omitempty"`
@@ -386,7 +651,7 @@ type ServerEvent_RunClientJS struct {
 
 func (x *ServerEvent_RunClientJS) Reset() {
 	*x = ServerEvent_RunClientJS{}
-	mi := &file_proto_captcha_v1_captcha_proto_msgTypes[5]
+	mi := &file_proto_captcha_v1_captcha_proto_msgTypes[6]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -398,7 +663,7 @@ func (x *ServerEvent_RunClientJS) String() string {
 func (*ServerEvent_RunClientJS) ProtoMessage() {}
 
 func (x *ServerEvent_RunClientJS) ProtoReflect() protoreflect.Message {
-	mi := &file_proto_captcha_v1_captcha_proto_msgTypes[5]
+	mi := &file_proto_captcha_v1_captcha_proto_msgTypes[6]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -411,7 +676,7 @@ func (x *ServerEvent_RunClientJS) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use ServerEvent_RunClientJS.ProtoReflect.Descriptor instead.
 func (*ServerEvent_RunClientJS) Descriptor() ([]byte, []int) {
-	return file_proto_captcha_v1_captcha_proto_rawDescGZIP(), []int{3, 1}
+	return file_proto_captcha_v1_captcha_proto_rawDescGZIP(), []int{3, 2}
 }
 
 func (x *ServerEvent_RunClientJS) GetChallengeId() string {
@@ -438,7 +703,7 @@ type ServerEvent_SendClientData struct {
 
 func (x *ServerEvent_SendClientData) Reset() {
 	*x = ServerEvent_SendClientData{}
-	mi := &file_proto_captcha_v1_captcha_proto_msgTypes[6]
+	mi := &file_proto_captcha_v1_captcha_proto_msgTypes[7]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -450,7 +715,7 @@ func (x *ServerEvent_SendClientData) String() string {
 func (*ServerEvent_SendClientData) ProtoMessage() {}
 
 func (x *ServerEvent_SendClientData) ProtoReflect() protoreflect.Message {
-	mi := &file_proto_captcha_v1_captcha_proto_msgTypes[6]
+	mi := &file_proto_captcha_v1_captcha_proto_msgTypes[7]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -463,7 +728,7 @@ func (x *ServerEvent_SendClientData) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use ServerEvent_SendClientData.ProtoReflect.Descriptor instead.
 func (*ServerEvent_SendClientData) Descriptor() ([]byte, []int) {
-	return file_proto_captcha_v1_captcha_proto_rawDescGZIP(), []int{3, 2}
+	return file_proto_captcha_v1_captcha_proto_rawDescGZIP(), []int{3, 3}
 }
 
 func (x *ServerEvent_SendClientData) GetChallengeId() string {
@@ -480,42 +745,292 @@ func (x *ServerEvent_SendClientData) GetData() []byte {
 	return nil
 }
 
+// Reports a failed client event, the stream stays open
+type ServerEvent_Error struct {
+	state       protoimpl.MessageState `protogen:"open.v1"`
+	ChallengeId string                 `protobuf:"bytes,1,opt,name=challenge_id,json=challengeId,proto3" json:"challenge_id,omitempty"`
+	ClientSeq   uint64                 `protobuf:"varint,2,opt,name=client_seq,json=clientSeq,proto3" json:"client_seq,omitempty"`
+	// google.rpc.Code value
+	Code    int32  `protobuf:"varint,3,opt,name=code,proto3" json:"code,omitempty"`
+	Message string `protobuf:"bytes,4,opt,name=message,proto3" json:"message,omitempty"`
+	// Stable machine readable reason, as in google.rpc.ErrorInfo
+	Reason string `protobuf:"bytes,5,opt,name=reason,proto3" json:"reason,omitempty"`
+	// Suggested retry delay, 0 when retrying will not help
+	RetryAfterMs  int64             `protobuf:"varint,6,opt,name=retry_after_ms,json=retryAfterMs,proto3" json:"retry_after_ms,omitempty"`
+	Metadata      map[string]string `protobuf:"bytes,7,rep,name=metadata,proto3" json:"metadata,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ServerEvent_Error) Reset() {
+	*x = ServerEvent_Error{}
+	mi := &file_proto_captcha_v1_captcha_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ServerEvent_Error) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ServerEvent_Error) ProtoMessage() {}
+
+func (x *ServerEvent_Error) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_captcha_v1_captcha_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ServerEvent_Error.ProtoReflect.Descriptor instead.
+func (*ServerEvent_Error) Descriptor() ([]byte, []int) {
+	return file_proto_captcha_v1_captcha_proto_rawDescGZIP(), []int{3, 4}
+}
+
+func (x *ServerEvent_Error) GetChallengeId() string {
+	if x != nil {
+		return x.ChallengeId
+	}
+	return ""
+}
+
+func (x *ServerEvent_Error) GetClientSeq() uint64 {
+	if x != nil {
+		return x.ClientSeq
+	}
+	return 0
+}
+
+func (x *ServerEvent_Error) GetCode() int32 {
+	if x != nil {
+		return x.Code
+	}
+	return 0
+}
+
+func (x *ServerEvent_Error) GetMessage() string {
+	if x != nil {
+		return x.Message
+	}
+	return ""
+}
+
+func (x *ServerEvent_Error) GetReason() string {
+	if x != nil {
+		return x.Reason
+	}
+	return ""
+}
+
+func (x *ServerEvent_Error) GetRetryAfterMs() int64 {
+	if x != nil {
+		return x.RetryAfterMs
+	}
+	return 0
+}
+
+func (x *ServerEvent_Error) GetMetadata() map[string]string {
+	if x != nil {
+		return x.Metadata
+	}
+	return nil
+}
+
+// Answers a RESUME event before unacknowledged events are replayed
+type ServerEvent_SessionResumed struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	LastClientSeq uint64                 `protobuf:"varint,1,opt,name=last_client_seq,json=lastClientSeq,proto3" json:"last_client_seq,omitempty"`
+	Replayed      uint32                 `protobuf:"varint,2,opt,name=replayed,proto3" json:"replayed,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ServerEvent_SessionResumed) Reset() {
+	*x = ServerEvent_SessionResumed{}
+	mi := &file_proto_captcha_v1_captcha_proto_msgTypes[9]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ServerEvent_SessionResumed) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ServerEvent_SessionResumed) ProtoMessage() {}
+
+func (x *ServerEvent_SessionResumed) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_captcha_v1_captcha_proto_msgTypes[9]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ServerEvent_SessionResumed.ProtoReflect.Descriptor instead.
+func (*ServerEvent_SessionResumed) Descriptor() ([]byte, []int) {
+	return file_proto_captcha_v1_captcha_proto_rawDescGZIP(), []int{3, 5}
+}
+
+func (x *ServerEvent_SessionResumed) GetLastClientSeq() uint64 {
+	if x != nil {
+		return x.LastClientSeq
+	}
+	return 0
+}
+
+func (x *ServerEvent_SessionResumed) GetReplayed() uint32 {
+	if x != nil {
+		return x.Replayed
+	}
+	return 0
+}
+
+// Sent as soon as a stream starts a new session, carries the session ID
+// needed to RESUME it
+type ServerEvent_SessionEstablished struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// How long the session stays resumable after its stream drops
+	ResumeTtlMs   int64 `protobuf:"varint,1,opt,name=resume_ttl_ms,json=resumeTtlMs,proto3" json:"resume_ttl_ms,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ServerEvent_SessionEstablished) Reset() {
+	*x = ServerEvent_SessionEstablished{}
+	mi := &file_proto_captcha_v1_captcha_proto_msgTypes[10]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ServerEvent_SessionEstablished) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ServerEvent_SessionEstablished) ProtoMessage() {}
+
+func (x *ServerEvent_SessionEstablished) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_captcha_v1_captcha_proto_msgTypes[10]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ServerEvent_SessionEstablished.ProtoReflect.Descriptor instead.
+func (*ServerEvent_SessionEstablished) Descriptor() ([]byte, []int) {
+	return file_proto_captcha_v1_captcha_proto_rawDescGZIP(), []int{3, 6}
+}
+
+func (x *ServerEvent_SessionEstablished) GetResumeTtlMs() int64 {
+	if x != nil {
+		return x.ResumeTtlMs
+	}
+	return 0
+}
+
 var File_proto_captcha_v1_captcha_proto protoreflect.FileDescriptor
 
 const file_proto_captcha_v1_captcha_proto_rawDesc = "" +
 	"\n" +
 	"\x1eproto/captcha/v1/captcha.proto\x12\n" +
-	"captcha.v1\"2\n" +
+	"captcha.v1\"\xba\x01\n" +
 	"\x10ChallengeRequest\x12\x1e\n" +
 	"\n" +
 	"complexity\x18\x01 \x01(\x05R\n" +
-	"complexity\"J\n" +
+	"complexity\x12\x1e\n" +
+	"\n" +
+	"accessible\x18\x02 \x01(\bR\n" +
+	"accessible\x12#\n" +
+	"\rkeyboard_only\x18\x03 \x01(\bR\fkeyboardOnly\x12'\n" +
+	"\x0fauto_complexity\x18\x04 \x01(\bR\x0eautoComplexity\x12\x18\n" +
+	"\apassive\x18\x05 \x01(\bR\apassive\"J\n" +
 	"\x11ChallengeResponse\x12!\n" +
 	"\fchallenge_id\x18\x01 \x01(\tR\vchallengeId\x12\x12\n" +
-	"\x04html\x18\x02 \x01(\tR\x04html\"\xd2\x01\n" +
+	"\x04html\x18\x02 \x01(\tR\x04html\"\xf8\x03\n" +
 	"\vClientEvent\x12@\n" +
 	"\n" +
 	"event_type\x18\x01 \x01(\x0e2!.captcha.v1.ClientEvent.EventTypeR\teventType\x12!\n" +
 	"\fchallenge_id\x18\x02 \x01(\tR\vchallengeId\x12\x12\n" +
-	"\x04data\x18\x03 \x01(\fR\x04data\"J\n" +
+	"\x04data\x18\x03 \x01(\fR\x04data\x12\x1d\n" +
+	"\n" +
+	"session_id\x18\x04 \x01(\tR\tsessionId\x12\x10\n" +
+	"\x03seq\x18\x05 \x01(\x04R\x03seq\x12\x10\n" +
+	"\x03ack\x18\x06 \x01(\x04R\x03ack\x12\x1e\n" +
+	"\n" +
+	"complexity\x18\a \x01(\x05R\n" +
+	"complexity\x12\x1e\n" +
+	"\n" +
+	"accessible\x18\b \x01(\bR\n" +
+	"accessible\x12#\n" +
+	"\rkeyboard_only\x18\t \x01(\bR\fkeyboardOnly\x12'\n" +
+	"\x0fauto_complexity\x18\n" +
+	" \x01(\bR\x0eautoComplexity\x12\x18\n" +
+	"\apassive\x18\v \x01(\bR\apassive\"\x84\x01\n" +
 	"\tEventType\x12\x12\n" +
 	"\x0eFRONTEND_EVENT\x10\x00\x12\x15\n" +
 	"\x11CONNECTION_CLOSED\x10\x01\x12\x12\n" +
-	"\x0eBALANCER_EVENT\x10\x02\"\xe1\x03\n" +
+	"\x0eBALANCER_EVENT\x10\x02\x12\n" +
+	"\n" +
+	"\x06RESUME\x10\x03\x12\x14\n" +
+	"\x10CREATE_CHALLENGE\x10\x04\x12\x16\n" +
+	"\x12VALIDATE_CHALLENGE\x10\x05\"\xd4\n" +
+	"\n" +
 	"\vServerEvent\x12A\n" +
 	"\x06result\x18\x01 \x01(\v2'.captcha.v1.ServerEvent.ChallengeResultH\x00R\x06result\x12B\n" +
 	"\tclient_js\x18\x02 \x01(\v2#.captcha.v1.ServerEvent.RunClientJSH\x00R\bclientJs\x12I\n" +
 	"\vclient_data\x18\x03 \x01(\v2&.captcha.v1.ServerEvent.SendClientDataH\x00R\n" +
-	"clientData\x1ac\n" +
+	"clientData\x125\n" +
+	"\x05error\x18\x04 \x01(\v2\x1d.captcha.v1.ServerEvent.ErrorH\x00R\x05error\x12B\n" +
+	"\aresumed\x18\x05 \x01(\v2&.captcha.v1.ServerEvent.SessionResumedH\x00R\aresumed\x12D\n" +
+	"\acreated\x18\x06 \x01(\v2(.captcha.v1.ServerEvent.ChallengeCreatedH\x00R\acreated\x12N\n" +
+	"\vestablished\x18\a \x01(\v2*.captcha.v1.ServerEvent.SessionEstablishedH\x00R\vestablished\x12\x1d\n" +
+	"\n" +
+	"session_id\x18\n" +
+	" \x01(\tR\tsessionId\x12\x10\n" +
+	"\x03seq\x18\v \x01(\x04R\x03seq\x1a{\n" +
 	"\x0fChallengeResult\x12!\n" +
 	"\fchallenge_id\x18\x01 \x01(\tR\vchallengeId\x12-\n" +
-	"\x12confidence_percent\x18\x02 \x01(\x05R\x11confidencePercent\x1aI\n" +
+	"\x12confidence_percent\x18\x02 \x01(\x05R\x11confidencePercent\x12\x16\n" +
+	"\x06solved\x18\x03 \x01(\bR\x06solved\x1aI\n" +
+	"\x10ChallengeCreated\x12!\n" +
+	"\fchallenge_id\x18\x01 \x01(\tR\vchallengeId\x12\x12\n" +
+	"\x04html\x18\x02 \x01(\tR\x04html\x1aI\n" +
 	"\vRunClientJS\x12!\n" +
 	"\fchallenge_id\x18\x01 \x01(\tR\vchallengeId\x12\x17\n" +
 	"\ajs_code\x18\x02 \x01(\tR\x06jsCode\x1aG\n" +
 	"\x0eSendClientData\x12!\n" +
 	"\fchallenge_id\x18\x01 \x01(\tR\vchallengeId\x12\x12\n" +
-	"\x04data\x18\x02 \x01(\fR\x04dataB\a\n" +
+	"\x04data\x18\x02 \x01(\fR\x04data\x1a\xbb\x02\n" +
+	"\x05Error\x12!\n" +
+	"\fchallenge_id\x18\x01 \x01(\tR\vchallengeId\x12\x1d\n" +
+	"\n" +
+	"client_seq\x18\x02 \x01(\x04R\tclientSeq\x12\x12\n" +
+	"\x04code\x18\x03 \x01(\x05R\x04code\x12\x18\n" +
+	"\amessage\x18\x04 \x01(\tR\amessage\x12\x16\n" +
+	"\x06reason\x18\x05 \x01(\tR\x06reason\x12$\n" +
+	"\x0eretry_after_ms\x18\x06 \x01(\x03R\fretryAfterMs\x12G\n" +
+	"\bmetadata\x18\a \x03(\v2+.captcha.v1.ServerEvent.Error.MetadataEntryR\bmetadata\x1a;\n" +
+	"\rMetadataEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\x1aT\n" +
+	"\x0eSessionResumed\x12&\n" +
+	"\x0flast_client_seq\x18\x01 \x01(\x04R\rlastClientSeq\x12\x1a\n" +
+	"\breplayed\x18\x02 \x01(\rR\breplayed\x1a8\n" +
+	"\x12SessionEstablished\x12\"\n" +
+	"\rresume_ttl_ms\x18\x01 \x01(\x03R\vresumeTtlMsB\a\n" +
 	"\x05event2\xaa\x01\n" +
 	"\x0eCaptchaService\x12M\n" +
 	"\fNewChallenge\x12\x1c.captcha.v1.ChallengeRequest\x1a\x1d.captcha.v1.ChallengeResponse\"\x00\x12I\n" +
@@ -534,31 +1049,41 @@ func file_proto_captcha_v1_captcha_proto_rawDescGZIP() []byte {
 }
 
 var file_proto_captcha_v1_captcha_proto_enumTypes = make([]protoimpl.EnumInfo, 1)
-var file_proto_captcha_v1_captcha_proto_msgTypes = make([]protoimpl.MessageInfo, 7)
+var file_proto_captcha_v1_captcha_proto_msgTypes = make([]protoimpl.MessageInfo, 12)
 var file_proto_captcha_v1_captcha_proto_goTypes = []any{
-	(ClientEvent_EventType)(0),          // 0: captcha.v1.ClientEvent.EventType
-	(*ChallengeRequest)(nil),            // 1: captcha.v1.ChallengeRequest
-	(*ChallengeResponse)(nil),           // 2: captcha.v1.ChallengeResponse
-	(*ClientEvent)(nil),                 // 3: captcha.v1.ClientEvent
-	(*ServerEvent)(nil),                 // 4: captcha.v1.ServerEvent
-	(*ServerEvent_ChallengeResult)(nil), // 5: captcha.v1.ServerEvent.ChallengeResult
-	(*ServerEvent_RunClientJS)(nil),     // 6: captcha.v1.ServerEvent.RunClientJS
-	(*ServerEvent_SendClientData)(nil),  // 7: captcha.v1.ServerEvent.SendClientData
+	(ClientEvent_EventType)(0),             // 0: captcha.v1.ClientEvent.EventType
+	(*ChallengeRequest)(nil),               // 1: captcha.v1.ChallengeRequest
+	(*ChallengeResponse)(nil),              // 2: captcha.v1.ChallengeResponse
+	(*ClientEvent)(nil),                    // 3: captcha.v1.ClientEvent
+	(*ServerEvent)(nil),                    // 4: captcha.v1.ServerEvent
+	(*ServerEvent_ChallengeResult)(nil),    // 5: captcha.v1.ServerEvent.ChallengeResult
+	(*ServerEvent_ChallengeCreated)(nil),   // 6: captcha.v1.ServerEvent.ChallengeCreated
+	(*ServerEvent_RunClientJS)(nil),        // 7: captcha.v1.ServerEvent.RunClientJS
+	(*ServerEvent_SendClientData)(nil),     // 8: captcha.v1.ServerEvent.SendClientData
+	(*ServerEvent_Error)(nil),              // 9: captcha.v1.ServerEvent.Error
+	(*ServerEvent_SessionResumed)(nil),     // 10: captcha.v1.ServerEvent.SessionResumed
+	(*ServerEvent_SessionEstablished)(nil), // 11: captcha.v1.ServerEvent.SessionEstablished
+	nil,                                    // 12: captcha.v1.ServerEvent.Error.MetadataEntry
 }
 var file_proto_captcha_v1_captcha_proto_depIdxs = []int32{
-	0, // 0: captcha.v1.ClientEvent.event_type:type_name -> captcha.v1.ClientEvent.EventType
-	5, // 1: captcha.v1.ServerEvent.result:type_name -> captcha.v1.ServerEvent.ChallengeResult
-	6, // 2: captcha.v1.ServerEvent.client_js:type_name -> captcha.v1.ServerEvent.RunClientJS
-	7, // 3: captcha.v1.ServerEvent.client_data:type_name -> captcha.v1.ServerEvent.SendClientData
-	1, // 4: captcha.v1.CaptchaService.NewChallenge:input_type -> captcha.v1.ChallengeRequest
-	3, // 5: captcha.v1.CaptchaService.MakeEventStream:input_type -> captcha.v1.ClientEvent
-	2, // 6: captcha.v1.CaptchaService.NewChallenge:output_type -> captcha.v1.ChallengeResponse
-	4, // 7: captcha.v1.CaptchaService.MakeEventStream:output_type -> captcha.v1.ServerEvent
-	6, // [6:8] is the sub-list for method output_type
-	4, // [4:6] is the sub-list for method input_type
-	4, // [4:4] is the sub-list for extension type_name
-	4, // [4:4] is the sub-list for extension extendee
-	0, // [0:4] is the sub-list for field type_name
+	0,  // 0: captcha.v1.ClientEvent.event_type:type_name -> captcha.v1.ClientEvent.EventType
+	5,  // 1: captcha.v1.ServerEvent.result:type_name -> captcha.v1.ServerEvent.ChallengeResult
+	7,  // 2: captcha.v1.ServerEvent.client_js:type_name -> captcha.v1.ServerEvent.RunClientJS
+	8,  // 3: captcha.v1.ServerEvent.client_data:type_name -> captcha.v1.ServerEvent.SendClientData
+	9,  // 4: captcha.v1.ServerEvent.error:type_name -> captcha.v1.ServerEvent.Error
+	10, // 5: captcha.v1.ServerEvent.resumed:type_name -> captcha.v1.ServerEvent.SessionResumed
+	6,  // 6: captcha.v1.ServerEvent.created:type_name -> captcha.v1.ServerEvent.ChallengeCreated
+	11, // 7: captcha.v1.ServerEvent.established:type_name -> captcha.v1.ServerEvent.SessionEstablished
+	12, // 8: captcha.v1.ServerEvent.Error.metadata:type_name -> captcha.v1.ServerEvent.Error.MetadataEntry
+	1,  // 9: captcha.v1.CaptchaService.NewChallenge:input_type -> captcha.v1.ChallengeRequest
+	3,  // 10: captcha.v1.CaptchaService.MakeEventStream:input_type -> captcha.v1.ClientEvent
+	2,  // 11: captcha.v1.CaptchaService.NewChallenge:output_type -> captcha.v1.ChallengeResponse
+	4,  // 12: captcha.v1.CaptchaService.MakeEventStream:output_type -> captcha.v1.ServerEvent
+	11, // [11:13] is the sub-list for method output_type
+	9,  // [9:11] is the sub-list for method input_type
+	9,  // [9:9] is the sub-list for extension type_name
+	9,  // [9:9] is the sub-list for extension extendee
+	0,  // [0:9] is the sub-list for field type_name
 }
 
 func init() { file_proto_captcha_v1_captcha_proto_init() }
@@ -570,6 +1095,10 @@ func file_proto_captcha_v1_captcha_proto_init() {
 		(*ServerEvent_Result)(nil),
 		(*ServerEvent_ClientJs)(nil),
 		(*ServerEvent_ClientData)(nil),
+		(*ServerEvent_Error_)(nil),
+		(*ServerEvent_Resumed)(nil),
+		(*ServerEvent_Created)(nil),
+		(*ServerEvent_Established)(nil),
 	}
 	type x struct{}
 	out := protoimpl.TypeBuilder{
@@ -577,7 +1106,7 @@ func file_proto_captcha_v1_captcha_proto_init() {
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: unsafe.Slice(unsafe.StringData(file_proto_captcha_v1_captcha_proto_rawDesc), len(file_proto_captcha_v1_captcha_proto_rawDesc)),
 			NumEnums:      1,
-			NumMessages:   7,
+			NumMessages:   12,
 			NumExtensions: 0,
 			NumServices:   1,
 		},
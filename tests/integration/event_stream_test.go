@@ -0,0 +1,610 @@
+package integration
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/test/bufconn"
+
+	"github.com/FlooooowY/SteelMount-Captcha-Service/internal/domain"
+	"github.com/FlooooowY/SteelMount-Captcha-Service/internal/repository"
+	"github.com/FlooooowY/SteelMount-Captcha-Service/internal/routing"
+	grpcTransport "github.com/FlooooowY/SteelMount-Captcha-Service/internal/transport/grpc"
+	"github.com/FlooooowY/SteelMount-Captcha-Service/internal/usecase"
+	pb "github.com/FlooooowY/SteelMount-Captcha-Service/proto/captcha/v1"
+)
+
+// startCaptchaService serves the captcha service over an in-memory listener
+func startCaptchaService(t *testing.T) (pb.CaptchaServiceClient, usecase.CaptchaUsecase) {
+	t.Helper()
+
+	captchaUsecase := usecase.NewCaptchaUsecase(repository.NewInMemoryChallengeRepository(), &usecase.Config{
+		MaxActiveChallenges: 100,
+		ChallengeTimeout:    time.Minute,
+		CleanupInterval:     time.Minute,
+	})
+
+	return serveCaptchaService(t, grpcTransport.NewCaptchaService(captchaUsecase)), captchaUsecase
+}
+
+// serveCaptchaService serves a captcha service with the given server options over an in-memory listener
+func serveCaptchaService(t *testing.T, service *grpcTransport.CaptchaService, opts ...grpc.ServerOption) pb.CaptchaServiceClient {
+	t.Helper()
+
+	listener := bufconn.Listen(1024 * 1024)
+	server := grpc.NewServer(opts...)
+	pb.RegisterCaptchaServiceServer(server, service)
+	go server.Serve(listener)
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return listener.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatalf("Failed to dial: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	return pb.NewCaptchaServiceClient(conn)
+}
+
+// startSession sends the first event of a stream, expects the frame announcing
+// the new session before the reply and returns the session ID with the reply
+func startSession(t *testing.T, stream pb.CaptchaService_MakeEventStreamClient, event *pb.ClientEvent) (string, *pb.ServerEvent) {
+	t.Helper()
+
+	if err := stream.Send(event); err != nil {
+		t.Fatalf("Send failed: %v", err)
+	}
+	established, err := stream.Recv()
+	if err != nil {
+		t.Fatalf("Recv failed: %v", err)
+	}
+	if established.GetEstablished() == nil || established.SessionId == "" || established.Seq != 0 {
+		t.Fatalf("Expected session established frame, got %v", established)
+	}
+	reply, err := stream.Recv()
+	if err != nil {
+		t.Fatalf("Recv failed: %v", err)
+	}
+	return established.SessionId, reply
+}
+
+func TestEventStream_ErrorFramesAndResume(t *testing.T) {
+	client, _ := startCaptchaService(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	challenge, err := client.NewChallenge(ctx, &pb.ChallengeRequest{Complexity: 20})
+	if err != nil {
+		t.Fatalf("Failed to create challenge: %v", err)
+	}
+
+	streamCtx, dropConnection := context.WithCancel(ctx)
+	stream, err := client.MakeEventStream(streamCtx)
+	if err != nil {
+		t.Fatalf("Failed to open stream: %v", err)
+	}
+
+	// An unknown challenge yields an error frame instead of ending the stream
+	sessionID, errorFrame := startSession(t, stream, &pb.ClientEvent{ChallengeId: "unknown", Seq: 1})
+	if errorFrame.GetError() == nil || codes.Code(errorFrame.GetError().Code) != codes.NotFound {
+		t.Fatalf("Expected NotFound error frame, got %v", errorFrame)
+	}
+	if errorFrame.GetError().ClientSeq != 1 || errorFrame.Seq != 1 || errorFrame.SessionId != sessionID {
+		t.Errorf("Unexpected error frame sequencing: %v", errorFrame)
+	}
+
+	// The stream is still usable
+	event := &pb.ClientEvent{ChallengeId: challenge.ChallengeId, Seq: 2, Data: []byte(`{"type":"click"}`)}
+	if err := stream.Send(event); err != nil {
+		t.Fatalf("Send failed: %v", err)
+	}
+	reply, err := stream.Recv()
+	if err != nil {
+		t.Fatalf("Recv failed: %v", err)
+	}
+	if reply.GetClientData() == nil || reply.Seq != 2 {
+		t.Fatalf("Expected client data with seq 2, got %v", reply)
+	}
+
+	// Drop the connection having acknowledged only the error frame
+	dropConnection()
+
+	// The server may not have noticed the dropped stream yet, resuming takes it over regardless
+	resumed, err := client.MakeEventStream(ctx)
+	if err != nil {
+		t.Fatalf("Failed to reopen stream: %v", err)
+	}
+	if err := resumed.Send(&pb.ClientEvent{EventType: pb.ClientEvent_RESUME, SessionId: sessionID, Ack: 1}); err != nil {
+		t.Fatalf("Send failed: %v", err)
+	}
+	handshake, err := resumed.Recv()
+	if handshake.GetResumed() == nil {
+		t.Fatalf("Expected resume handshake, got %v (%v)", handshake, err)
+	}
+	if handshake.GetResumed().LastClientSeq != 2 || handshake.GetResumed().Replayed != 1 {
+		t.Errorf("Unexpected handshake: %v", handshake.GetResumed())
+	}
+
+	replayed, err := resumed.Recv()
+	if err != nil {
+		t.Fatalf("Recv failed: %v", err)
+	}
+	if replayed.Seq != 2 || replayed.GetClientData() == nil {
+		t.Errorf("Expected replay of seq 2, got %v", replayed)
+	}
+
+	// Resending an already processed event is ignored, the next one is answered
+	_ = resumed.Send(event)
+	if err := resumed.Send(&pb.ClientEvent{ChallengeId: challenge.ChallengeId, Seq: 3, Ack: 2, Data: []byte(`{"type":"click"}`)}); err != nil {
+		t.Fatalf("Send failed: %v", err)
+	}
+	next, err := resumed.Recv()
+	if err != nil {
+		t.Fatalf("Recv failed: %v", err)
+	}
+	if next.Seq != 3 {
+		t.Errorf("Expected seq 3 after duplicate, got %d", next.Seq)
+	}
+}
+
+func TestEventStream_ResumeUnknownSession(t *testing.T) {
+	client, _ := startCaptchaService(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	stream, err := client.MakeEventStream(ctx)
+	if err != nil {
+		t.Fatalf("Failed to open stream: %v", err)
+	}
+
+	// The stream is kept on a fresh session
+	sessionID, reply := startSession(t, stream, &pb.ClientEvent{EventType: pb.ClientEvent_RESUME, SessionId: "missing", Seq: 1})
+	if sessionID == "missing" {
+		t.Fatalf("Expected a fresh session")
+	}
+	if reply.GetError() == nil || codes.Code(reply.GetError().Code) != codes.NotFound || reply.GetError().Reason != domain.ReasonSessionExpired {
+		t.Fatalf("Expected a SESSION_EXPIRED error frame, got %v", reply)
+	}
+
+	if err := stream.Send(&pb.ClientEvent{EventType: pb.ClientEvent_CREATE_CHALLENGE, Seq: 2, Complexity: 10}); err != nil {
+		t.Fatalf("Send failed: %v", err)
+	}
+	created, err := stream.Recv()
+	if err != nil || created.GetCreated() == nil || created.SessionId != sessionID {
+		t.Errorf("Expected the fresh session to serve new events, got %v (%v)", created, err)
+	}
+}
+
+func TestEventStream_ChallengeIDChecked(t *testing.T) {
+	client, _ := startCaptchaService(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	stream, err := client.MakeEventStream(ctx)
+	if err != nil {
+		t.Fatalf("Failed to open stream: %v", err)
+	}
+
+	// Start the session up front, so every case gets a single reply
+	if _, reply := startSession(t, stream, &pb.ClientEvent{EventType: pb.ClientEvent_CREATE_CHALLENGE, Complexity: 10}); reply.GetCreated() == nil {
+		t.Fatalf("Expected created event, got %v", reply)
+	}
+
+	tests := []struct {
+		name  string
+		event *pb.ClientEvent
+		code  codes.Code
+	}{
+		{"frontend event without id", &pb.ClientEvent{Data: []byte(`{"type":"click"}`)}, codes.InvalidArgument},
+		{"validation without id", &pb.ClientEvent{EventType: pb.ClientEvent_VALIDATE_CHALLENGE, Data: []byte(`{}`)}, codes.InvalidArgument},
+		{"closing without id", &pb.ClientEvent{EventType: pb.ClientEvent_CONNECTION_CLOSED}, codes.InvalidArgument},
+		{"validation of unknown id", &pb.ClientEvent{EventType: pb.ClientEvent_VALIDATE_CHALLENGE, ChallengeId: "unknown", Data: []byte(`{}`)}, codes.NotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := stream.Send(tt.event); err != nil {
+				t.Fatalf("Send failed: %v", err)
+			}
+			reply, err := stream.Recv()
+			if err != nil {
+				t.Fatalf("Recv failed: %v", err)
+			}
+			if reply.GetError() == nil || codes.Code(reply.GetError().Code) != tt.code {
+				t.Errorf("Expected %v error frame, got %v", tt.code, reply)
+			}
+		})
+	}
+}
+
+func TestEventStream_ForeignChallengeKeepsStream(t *testing.T) {
+	captchaUsecase := usecase.NewCaptchaUsecase(repository.NewInMemoryChallengeRepository(), &usecase.Config{
+		MaxActiveChallenges: 100,
+		ChallengeTimeout:    time.Minute,
+		CleanupInterval:     time.Minute,
+	})
+	secret := []byte("secret")
+	router := routing.NewRouter(routing.NewIDCodec("captcha-1", secret), map[string]string{"captcha-2": "10.0.0.2:38000"})
+	affinity := grpcTransport.NewAffinityMiddleware(router)
+	client := serveCaptchaService(t, grpcTransport.NewCaptchaService(captchaUsecase),
+		grpc.ChainStreamInterceptor(affinity.StreamInterceptor()))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	stream, err := client.MakeEventStream(ctx)
+	if err != nil {
+		t.Fatalf("Failed to open stream: %v", err)
+	}
+	exchange := func(event *pb.ClientEvent) *pb.ServerEvent {
+		t.Helper()
+		if err := stream.Send(event); err != nil {
+			t.Fatalf("Send failed: %v", err)
+		}
+		reply, err := stream.Recv()
+		if err != nil {
+			t.Fatalf("Recv failed: %v", err)
+		}
+		return reply
+	}
+
+	_, reply := startSession(t, stream, &pb.ClientEvent{EventType: pb.ClientEvent_CREATE_CHALLENGE, Complexity: 10})
+	created := reply.GetCreated()
+	if created == nil {
+		t.Fatalf("Expected a local stream to create challenges")
+	}
+
+	// Challenges of a peer and malformed IDs get error frames, the stream stays
+	peerChallenge := routing.NewIDCodec("captcha-2", secret).NewID()
+	reply = exchange(&pb.ClientEvent{ChallengeId: peerChallenge, Data: []byte(`{"type":"click"}`)})
+	if reply.GetError() == nil || codes.Code(reply.GetError().Code) != codes.FailedPrecondition || reply.GetError().Metadata["owner_instance"] != "captcha-2" {
+		t.Errorf("Expected an error frame naming the owner, got %v", reply)
+	}
+	tampered := peerChallenge[:len(peerChallenge)-1] + "x"
+	reply = exchange(&pb.ClientEvent{EventType: pb.ClientEvent_VALIDATE_CHALLENGE, ChallengeId: tampered, Data: []byte(`{}`)})
+	if reply.GetError() == nil || codes.Code(reply.GetError().Code) != codes.InvalidArgument {
+		t.Errorf("Expected an InvalidArgument error frame, got %v", reply)
+	}
+
+	reply = exchange(&pb.ClientEvent{ChallengeId: created.ChallengeId, Data: []byte(`{"type":"click"}`)})
+	if reply.GetClientData() == nil {
+		t.Errorf("Expected the local challenge still served, got %v", reply)
+	}
+}
+
+func TestEventStream_ResumeRoutedToSessionOwner(t *testing.T) {
+	secret := []byte("secret")
+	newService := func(instance string) *grpcTransport.CaptchaService {
+		captchaUsecase := usecase.NewCaptchaUsecase(repository.NewInMemoryChallengeRepository(), &usecase.Config{
+			MaxActiveChallenges: 100,
+			ChallengeTimeout:    time.Minute,
+			CleanupInterval:     time.Minute,
+		})
+		sessionConfig := grpcTransport.DefaultStreamSessionConfig()
+		sessionConfig.IDCodec = routing.NewIDCodec(instance, secret)
+		return grpcTransport.NewCaptchaServiceWithConfig(captchaUsecase, sessionConfig)
+	}
+
+	// The owner instance is dialed by address, so it listens on TCP
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	owner := grpc.NewServer()
+	pb.RegisterCaptchaServiceServer(owner, newService("captcha-2"))
+	go owner.Serve(listener)
+	t.Cleanup(owner.Stop)
+
+	conn, err := grpc.NewClient(listener.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatalf("Failed to dial: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	router := routing.NewRouter(routing.NewIDCodec("captcha-1", secret), map[string]string{"captcha-2": listener.Addr().String()})
+	affinity := grpcTransport.NewAffinityMiddleware(router)
+	t.Cleanup(func() { affinity.Close() })
+	front := serveCaptchaService(t, newService("captcha-1"), grpc.ChainStreamInterceptor(affinity.StreamInterceptor()))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	// The session is created on the owner, then the connection drops
+	streamCtx, dropConnection := context.WithCancel(ctx)
+	stream, err := pb.NewCaptchaServiceClient(conn).MakeEventStream(streamCtx)
+	if err != nil {
+		t.Fatalf("Failed to open stream: %v", err)
+	}
+	sessionID, created := startSession(t, stream, &pb.ClientEvent{EventType: pb.ClientEvent_CREATE_CHALLENGE, Seq: 1, Complexity: 10})
+	if created.GetCreated() == nil {
+		t.Fatalf("Expected a created challenge, got %v", created)
+	}
+	if info, err := router.Codec().Parse(sessionID); err != nil || info.InstanceID != "captcha-2" {
+		t.Fatalf("Expected the session ID to carry the owner instance, got %q (%v)", sessionID, err)
+	}
+	dropConnection()
+
+	// Resuming through another instance reaches the owner's session
+	resumed, err := front.MakeEventStream(ctx)
+	if err != nil {
+		t.Fatalf("Failed to reopen stream: %v", err)
+	}
+	if err := resumed.Send(&pb.ClientEvent{EventType: pb.ClientEvent_RESUME, SessionId: sessionID, Ack: 1}); err != nil {
+		t.Fatalf("Send failed: %v", err)
+	}
+	handshake, err := resumed.Recv()
+	if err != nil || handshake.GetResumed() == nil {
+		t.Fatalf("Expected resume handshake, got %v (%v)", handshake, err)
+	}
+	if handshake.GetResumed().LastClientSeq != 1 {
+		t.Errorf("Unexpected handshake: %v", handshake.GetResumed())
+	}
+}
+
+// writeTestCertificate writes a self-signed certificate for 127.0.0.1 and its key
+func writeTestCertificate(t *testing.T) grpcTransport.TLSConfig {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "captcha"},
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("Failed to create certificate: %v", err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatalf("Failed to marshal key: %v", err)
+	}
+
+	dir := t.TempDir()
+	config := grpcTransport.TLSConfig{
+		CertFile: filepath.Join(dir, "cert.pem"),
+		KeyFile:  filepath.Join(dir, "key.pem"),
+		CAFile:   filepath.Join(dir, "cert.pem"),
+	}
+	if err := os.WriteFile(config.CertFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600); err != nil {
+		t.Fatalf("Failed to write certificate: %v", err)
+	}
+	if err := os.WriteFile(config.KeyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600); err != nil {
+		t.Fatalf("Failed to write key: %v", err)
+	}
+	return config
+}
+
+func TestEventStream_ForwardedOverTLS(t *testing.T) {
+	serverCreds, peerCreds, err := grpcTransport.LoadTLSCredentials(writeTestCertificate(t))
+	if err != nil {
+		t.Fatalf("Failed to load credentials: %v", err)
+	}
+	secret := []byte("secret")
+
+	// The owner only accepts TLS connections
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	owner := grpc.NewServer(grpc.Creds(serverCreds))
+	pb.RegisterCaptchaServiceServer(owner, newCaptchaService())
+	go owner.Serve(listener)
+	t.Cleanup(owner.Stop)
+
+	router := routing.NewRouter(routing.NewIDCodec("captcha-1", secret), map[string]string{"captcha-2": listener.Addr().String()})
+	affinity := grpcTransport.NewAffinityMiddlewareWithConfig(router, grpcTransport.AffinityConfig{PeerCredentials: peerCreds})
+	t.Cleanup(func() { affinity.Close() })
+	client := serveCaptchaService(t, newCaptchaService(), grpc.ChainStreamInterceptor(affinity.StreamInterceptor()))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	stream, err := client.MakeEventStream(ctx)
+	if err != nil {
+		t.Fatalf("Failed to open stream: %v", err)
+	}
+	// The owner answers with a NotFound error frame for its unknown challenge
+	challengeID := routing.NewIDCodec("captcha-2", secret).NewID()
+	_, reply := startSession(t, stream, &pb.ClientEvent{ChallengeId: challengeID, Seq: 1, Data: []byte(`{"type":"click"}`)})
+	if reply.GetError() == nil || codes.Code(reply.GetError().Code) != codes.NotFound {
+		t.Errorf("Expected a NotFound error frame from the owner, got %v", reply)
+	}
+}
+
+func TestEventStream_MultiplexedChallenges(t *testing.T) {
+	client, captchaUsecase := startCaptchaService(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	stream, err := client.MakeEventStream(ctx)
+	if err != nil {
+		t.Fatalf("Failed to open stream: %v", err)
+	}
+
+	exchange := func(stream pb.CaptchaService_MakeEventStreamClient, event *pb.ClientEvent) *pb.ServerEvent {
+		t.Helper()
+		if err := stream.Send(event); err != nil {
+			t.Fatalf("Send failed: %v", err)
+		}
+		reply, err := stream.Recv()
+		if err != nil {
+			t.Fatalf("Recv failed: %v", err)
+		}
+		return reply
+	}
+
+	// One stream creates and drives several challenges
+	var challengeIDs []string
+	for i := 0; i < 3; i++ {
+		createEvent := &pb.ClientEvent{EventType: pb.ClientEvent_CREATE_CHALLENGE, Complexity: 10}
+		var reply *pb.ServerEvent
+		if i == 0 {
+			_, reply = startSession(t, stream, createEvent)
+		} else {
+			reply = exchange(stream, createEvent)
+		}
+		if reply.GetCreated() == nil {
+			t.Fatalf("Expected created event, got %v", reply)
+		}
+		challengeIDs = append(challengeIDs, reply.GetCreated().ChallengeId)
+	}
+	for _, challengeID := range challengeIDs {
+		reply := exchange(stream, &pb.ClientEvent{ChallengeId: challengeID, Data: []byte(`{"type":"click"}`)})
+		if reply.GetClientData() == nil {
+			t.Errorf("Expected client data for %s, got %v", challengeID, reply)
+		}
+	}
+
+	// Another stream cannot touch them
+	other, err := client.MakeEventStream(ctx)
+	if err != nil {
+		t.Fatalf("Failed to open stream: %v", err)
+	}
+	_, reply := startSession(t, other, &pb.ClientEvent{EventType: pb.ClientEvent_VALIDATE_CHALLENGE, ChallengeId: challengeIDs[0], Data: []byte(`{}`)})
+	if reply.GetError() == nil || codes.Code(reply.GetError().Code) != codes.PermissionDenied {
+		t.Errorf("Expected PermissionDenied, got %v", reply)
+	}
+
+	// Validating answers the owning stream with a result
+	reply = exchange(stream, &pb.ClientEvent{EventType: pb.ClientEvent_VALIDATE_CHALLENGE, ChallengeId: challengeIDs[0], Data: []byte(`{"x":1}`)})
+	if reply.GetResult() == nil || reply.GetResult().ChallengeId != challengeIDs[0] {
+		t.Errorf("Expected challenge result, got %v", reply)
+	}
+
+	// Ending the stream abandons the owned challenges
+	if err := stream.CloseSend(); err != nil {
+		t.Fatalf("CloseSend failed: %v", err)
+	}
+	if _, err := stream.Recv(); err == nil {
+		t.Fatalf("Expected stream to end")
+	}
+
+	for _, challengeID := range challengeIDs[1:] {
+		challenge, err := captchaUsecase.GetChallenge(ctx, challengeID)
+		if err != nil {
+			t.Fatalf("Failed to get challenge: %v", err)
+		}
+		if !challenge.Abandoned || challenge.Metadata["abandon_reason"] != usecase.AbandonReasonStreamClosed {
+			t.Errorf("Expected %s to be abandoned on stream end, got %+v", challengeID, challenge)
+		}
+	}
+}
+
+func TestEventStream_ProgressiveStages(t *testing.T) {
+	client, captchaUsecase := startCaptchaService(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	stream, err := client.MakeEventStream(ctx)
+	if err != nil {
+		t.Fatalf("Failed to open stream: %v", err)
+	}
+
+	exchange := func(event *pb.ClientEvent, replies int) []*pb.ServerEvent {
+		t.Helper()
+		if err := stream.Send(event); err != nil {
+			t.Fatalf("Send failed: %v", err)
+		}
+		var events []*pb.ServerEvent
+		for i := 0; i < replies; i++ {
+			reply, err := stream.Recv()
+			if err != nil {
+				t.Fatalf("Recv failed: %v", err)
+			}
+			events = append(events, reply)
+		}
+		return events
+	}
+
+	// Click challenges allow partially correct, borderline answers
+	var challenge *domain.Challenge
+	replies := 2 // The first event is also answered with the session established frame
+	for attempt := 0; attempt < 100 && challenge == nil; attempt++ {
+		events := exchange(&pb.ClientEvent{EventType: pb.ClientEvent_CREATE_CHALLENGE, Complexity: 10}, replies)
+		replies = 1
+		created := events[len(events)-1].GetCreated()
+		candidate, err := captchaUsecase.GetChallenge(ctx, created.ChallengeId)
+		if err != nil {
+			t.Fatalf("Failed to get challenge: %v", err)
+		}
+		if candidate.Type == domain.ChallengeTypeClick {
+			challenge = candidate
+		}
+	}
+	if challenge == nil {
+		t.Fatalf("No click challenge generated")
+	}
+
+	attempt := func(answer []string) []byte {
+		data, _ := json.Marshal(map[string]interface{}{"type": "challenge_attempt", "answer": answer})
+		return data
+	}
+
+	// Borderline answer: half of the sequence right
+	expected := challenge.Answer.([]string)
+	borderline := append([]string(nil), expected...)
+	for i := len(borderline) / 2; i < len(borderline); i++ {
+		borderline[i] = "wrong"
+	}
+
+	events := exchange(&pb.ClientEvent{ChallengeId: challenge.ID, Data: attempt(borderline)}, 2)
+	if events[0].GetClientData() == nil || events[1].GetClientJs() == nil {
+		t.Fatalf("Expected stage announcement and RunClientJS, got %v", events)
+	}
+	var started map[string]interface{}
+	if err := json.Unmarshal(events[0].GetClientData().Data, &started); err != nil || started["type"] != "stage_started" || started["stage"] != float64(2) {
+		t.Errorf("Unexpected stage data: %s", events[0].GetClientData().Data)
+	}
+	if events[1].GetClientJs().JsCode == "" {
+		t.Errorf("Expected stage JavaScript")
+	}
+
+	// The follow-up round is harder and solved correctly
+	stored, err := captchaUsecase.GetChallenge(ctx, challenge.ID)
+	if err != nil {
+		t.Fatalf("Failed to get challenge: %v", err)
+	}
+	stage := stored.CurrentStage()
+	if stage.Index != 1 || stage.Complexity <= challenge.Complexity {
+		t.Errorf("Expected a harder second stage, got %+v", stage)
+	}
+
+	final := exchange(&pb.ClientEvent{ChallengeId: challenge.ID, Data: attempt(stage.Answer.([]string))}, 1)[0]
+	result := final.GetResult()
+	if result == nil || !result.Solved {
+		t.Fatalf("Expected solved challenge result, got %v", final)
+	}
+	if result.ConfidencePercent <= 0 || result.ConfidencePercent >= 100 {
+		t.Errorf("Expected confidence averaged over stages, got %d", result.ConfidencePercent)
+	}
+}
@@ -0,0 +1,111 @@
+package integration
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/FlooooowY/SteelMount-Captcha-Service/internal/monitoring"
+	"github.com/FlooooowY/SteelMount-Captcha-Service/internal/tracing"
+)
+
+// scrape fetches the metrics endpoint with the given Accept header
+func scrape(t *testing.T, url, accept string) (string, string) {
+	t.Helper()
+
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		t.Fatalf("Failed to build request: %v", err)
+	}
+	if accept != "" {
+		req.Header.Set("Accept", accept)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Scrape failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("Failed to read body: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", resp.StatusCode, body)
+	}
+
+	return resp.Header.Get("Content-Type"), string(body)
+}
+
+func TestMetricsEndpoint_ServesRegistryWithExemplars(t *testing.T) {
+	setupTracing(t)
+
+	labels, err := monitoring.InstanceLabels("instance-1", map[string]string{"region": "eu"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	registry := prometheus.NewRegistry()
+	registerer := prometheus.WrapRegistererWith(labels, registry)
+	metrics := monitoring.NewMetricsWithRegistry(registerer)
+	if err := monitoring.RegisterRuntimeCollectors(registerer); err != nil {
+		t.Fatalf("Failed to register runtime collectors: %v", err)
+	}
+
+	ctx, span := tracing.StartSpan(context.Background(), "request")
+	metrics.RecordRequest(ctx, "grpc", "/captcha.v1.CaptchaService/NewChallenge", "OK", 3*time.Millisecond)
+	span.End()
+	traceID := span.SpanContext().TraceID().String()
+
+	server := httptest.NewServer(monitoring.NewPrometheusServerWithRegistry(0, metrics, registry).Handler())
+	defer server.Close()
+
+	// Classic text format serves our metrics and runtime collectors
+	contentType, body := scrape(t, server.URL+"/metrics", "")
+	if !strings.HasPrefix(contentType, "text/plain") {
+		t.Errorf("Expected text format, got %s", contentType)
+	}
+	for _, expected := range []string{
+		`captcha_requests_total{endpoint="/captcha.v1.CaptchaService/NewChallenge",instance_id="instance-1",method="grpc",region="eu",status="OK"} 1`,
+		`go_goroutines{instance_id="instance-1",region="eu"}`,
+	} {
+		if !strings.Contains(body, expected) {
+			t.Errorf("Expected %s in text scrape", expected)
+		}
+	}
+	if strings.Contains(body, traceID) {
+		t.Errorf("Text format must not carry exemplars")
+	}
+
+	// OpenMetrics carries the trace ID exemplar on the latency histogram
+	contentType, body = scrape(t, server.URL+"/metrics", "application/openmetrics-text; version=1.0.0")
+	if !strings.HasPrefix(contentType, "application/openmetrics-text") {
+		t.Fatalf("Expected OpenMetrics format, got %s", contentType)
+	}
+	exemplar := false
+	for _, line := range strings.Split(body, "\n") {
+		if strings.HasPrefix(line, "captcha_request_duration_seconds_bucket{") && strings.Contains(line, `# {trace_id="`+traceID+`"}`) {
+			exemplar = true
+		}
+	}
+	if !exemplar {
+		t.Errorf("Expected trace ID exemplar on captcha_request_duration_seconds")
+	}
+	if !strings.HasSuffix(strings.TrimSpace(body), "# EOF") {
+		t.Errorf("Expected OpenMetrics terminator")
+	}
+}
+
+func TestMetricsEndpoint_InstanceLabelsValidation(t *testing.T) {
+	for _, name := range []string{"instance_id", "1zone", "__reserved", "bad-name"} {
+		if _, err := monitoring.InstanceLabels("instance-1", map[string]string{name: "x"}); err == nil {
+			t.Errorf("Expected label %q to be rejected", name)
+		}
+	}
+}
@@ -0,0 +1,267 @@
+package integration
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/FlooooowY/SteelMount-Captcha-Service/internal/captcha"
+	"github.com/FlooooowY/SteelMount-Captcha-Service/internal/domain"
+	"github.com/FlooooowY/SteelMount-Captcha-Service/internal/repository"
+	httpTransport "github.com/FlooooowY/SteelMount-Captcha-Service/internal/transport/http"
+	"github.com/FlooooowY/SteelMount-Captcha-Service/internal/usecase"
+)
+
+// startGateway serves the REST gateway over an in-memory usecase
+func startGateway(t *testing.T, config *httpTransport.GatewayConfig) (*httptest.Server, usecase.CaptchaUsecase) {
+	t.Helper()
+
+	captchaUsecase := usecase.NewCaptchaUsecase(repository.NewInMemoryChallengeRepository(), &usecase.Config{
+		MaxActiveChallenges: 100,
+		ChallengeTimeout:    time.Minute,
+		CleanupInterval:     time.Minute,
+	})
+
+	gateway := httpTransport.NewGateway(captchaUsecase, nil, nil, 0, config)
+	ts := httptest.NewServer(gateway.Handler())
+	t.Cleanup(ts.Close)
+
+	return ts, captchaUsecase
+}
+
+// doJSON sends a JSON request and decodes the JSON response
+func doJSON(t *testing.T, method, url string, body interface{}, out interface{}) int {
+	t.Helper()
+
+	var reader *bytes.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			t.Fatalf("Failed to marshal body: %v", err)
+		}
+		reader = bytes.NewReader(data)
+	} else {
+		reader = bytes.NewReader(nil)
+	}
+
+	req, err := http.NewRequest(method, url, reader)
+	if err != nil {
+		t.Fatalf("Failed to create request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if out != nil {
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+	}
+
+	return resp.StatusCode
+}
+
+func TestRESTGateway_ChallengeLifecycle(t *testing.T) {
+	ts, captchaUsecase := startGateway(t, nil)
+
+	// Create challenges until a click challenge comes up, its answer is a plain sequence
+	var created httpTransport.ChallengeResponse
+	for i := 0; i < 50; i++ {
+		if code := doJSON(t, http.MethodPost, ts.URL+"/v1/challenges", map[string]int{"complexity": 10}, &created); code != http.StatusCreated {
+			t.Fatalf("Expected 201, got %d", code)
+		}
+		if created.Type == string(domain.ChallengeTypeClick) {
+			break
+		}
+	}
+	if created.Type != string(domain.ChallengeTypeClick) {
+		t.Fatalf("No click challenge created")
+	}
+	if created.HTML == "" {
+		t.Errorf("Expected challenge HTML on creation")
+	}
+
+	var fetched httpTransport.ChallengeResponse
+	if code := doJSON(t, http.MethodGet, ts.URL+"/v1/challenges/"+created.ID, nil, &fetched); code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d", code)
+	}
+	if fetched.ID != created.ID || fetched.HTML != "" {
+		t.Errorf("Unexpected challenge: %+v", fetched)
+	}
+
+	var verify httpTransport.VerifyResponse
+	doJSON(t, http.MethodPost, ts.URL+"/v1/challenges/"+created.ID+"/verify", nil, &verify)
+	if verify.Solved {
+		t.Errorf("Expected unsolved challenge before answering")
+	}
+
+	challenge, err := captchaUsecase.GetChallenge(context.Background(), created.ID)
+	if err != nil {
+		t.Fatalf("Failed to get challenge: %v", err)
+	}
+
+	var answer httpTransport.AnswerResponse
+	code := doJSON(t, http.MethodPost, ts.URL+"/v1/challenges/"+created.ID+"/answer",
+		map[string]interface{}{"answer": challenge.Answer}, &answer)
+	if code != http.StatusOK || !answer.Solved {
+		t.Fatalf("Expected solved answer, got %d %+v", code, answer)
+	}
+
+	doJSON(t, http.MethodPost, ts.URL+"/v1/challenges/"+created.ID+"/verify", nil, &verify)
+	if !verify.Solved {
+		t.Errorf("Expected solved challenge after answering")
+	}
+
+	// Verifying consumes the solution, a replay is refused
+	var replayed httpTransport.ErrorBody
+	code = doJSON(t, http.MethodPost, ts.URL+"/v1/challenges/"+created.ID+"/verify", nil, &replayed)
+	if code != http.StatusConflict || replayed.Error.Reason != domain.ReasonChallengeConsumed {
+		t.Errorf("Expected 409 %s on a second verification, got %d %+v", domain.ReasonChallengeConsumed, code, replayed)
+	}
+}
+
+func TestRESTGateway_AccessibleChallenge(t *testing.T) {
+	ts, captchaUsecase := startGateway(t, nil)
+
+	var created httpTransport.ChallengeResponse
+	code := doJSON(t, http.MethodPost, ts.URL+"/v1/challenges",
+		map[string]interface{}{"complexity": 30, "accessible": true}, &created)
+	if code != http.StatusCreated {
+		t.Fatalf("Expected 201, got %d", code)
+	}
+	if created.Type != string(domain.ChallengeTypeAudio) || !strings.Contains(created.HTML, "data:audio/wav;base64,") {
+		t.Fatalf("Expected an audio challenge, got %s", created.Type)
+	}
+
+	challenge, err := captchaUsecase.GetChallenge(context.Background(), created.ID)
+	if err != nil {
+		t.Fatalf("Failed to get challenge: %v", err)
+	}
+
+	var answer httpTransport.AnswerResponse
+	code = doJSON(t, http.MethodPost, ts.URL+"/v1/challenges/"+created.ID+"/answer",
+		map[string]interface{}{"answer": challenge.Answer.(*captcha.AudioAnswer).Transcript}, &answer)
+	if code != http.StatusOK || !answer.Solved {
+		t.Fatalf("Expected solved answer, got %d %+v", code, answer)
+	}
+}
+
+func TestRESTGateway_Errors(t *testing.T) {
+	ts, _ := startGateway(t, nil)
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		body   interface{}
+		status int
+		code   string
+	}{
+		{"unknown challenge", http.MethodGet, "/v1/challenges/missing", nil, http.StatusNotFound, httpTransport.CodeNotFound},
+		{"complexity out of range", http.MethodPost, "/v1/challenges", map[string]int{"complexity": 150}, http.StatusBadRequest, httpTransport.CodeInvalidArgument},
+		{"unknown field", http.MethodPost, "/v1/challenges", map[string]int{"difficulty": 10}, http.StatusBadRequest, httpTransport.CodeInvalidArgument},
+		{"auto complexity", http.MethodPost, "/v1/challenges", map[string]bool{"auto_complexity": true}, http.StatusBadRequest, httpTransport.CodeInvalidArgument},
+		{"passive", http.MethodPost, "/v1/challenges", map[string]bool{"passive": true}, http.StatusBadRequest, httpTransport.CodeInvalidArgument},
+		{"missing answer", http.MethodPost, "/v1/challenges/missing/answer", map[string]int{}, http.StatusBadRequest, httpTransport.CodeInvalidArgument},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var body httpTransport.ErrorBody
+			if status := doJSON(t, tt.method, ts.URL+tt.path, tt.body, &body); status != tt.status {
+				t.Errorf("Expected status %d, got %d", tt.status, status)
+			}
+			if body.Error.Code != tt.code || body.Error.Message == "" {
+				t.Errorf("Expected error code %s with a message, got %+v", tt.code, body.Error)
+			}
+		})
+	}
+}
+
+func TestRESTGateway_CORSAndOpenAPI(t *testing.T) {
+	config := httpTransport.DefaultGatewayConfig()
+	config.AllowedOrigins = []string{"https://shop.example"}
+	ts, _ := startGateway(t, config)
+
+	preflight := func(origin string) *http.Response {
+		req, _ := http.NewRequest(http.MethodOptions, ts.URL+"/v1/challenges", nil)
+		req.Header.Set("Origin", origin)
+		req.Header.Set("Access-Control-Request-Method", http.MethodPost)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("Preflight failed: %v", err)
+		}
+		resp.Body.Close()
+		return resp
+	}
+
+	resp := preflight("https://shop.example")
+	if resp.StatusCode != http.StatusNoContent || resp.Header.Get("Access-Control-Allow-Origin") != "https://shop.example" {
+		t.Errorf("Expected allowed preflight, got %d %q", resp.StatusCode, resp.Header.Get("Access-Control-Allow-Origin"))
+	}
+	if resp := preflight("https://evil.example"); resp.Header.Get("Access-Control-Allow-Origin") != "" {
+		t.Errorf("Expected no CORS headers for a foreign origin")
+	}
+
+	var spec struct {
+		OpenAPI string                                `json:"openapi"`
+		Paths   map[string]map[string]json.RawMessage `json:"paths"`
+	}
+	if code := doJSON(t, http.MethodGet, ts.URL+"/openapi.json", nil, &spec); code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d", code)
+	}
+	if spec.OpenAPI != httpTransport.OpenAPIVersion {
+		t.Errorf("Expected OpenAPI %s, got %q", httpTransport.OpenAPIVersion, spec.OpenAPI)
+	}
+	for path, method := range map[string]string{
+		"/v1/challenges":             "post",
+		"/v1/challenges/{id}":        "get",
+		"/v1/challenges/{id}/answer": "post",
+		"/v1/challenges/{id}/verify": "post",
+	} {
+		if _, ok := spec.Paths[path][method]; !ok {
+			t.Errorf("Expected %s %s in the OpenAPI document", method, path)
+		}
+	}
+}
+
+func TestRESTGateway_CapacityError(t *testing.T) {
+	captchaUsecase := usecase.NewCaptchaUsecase(repository.NewInMemoryChallengeRepository(), &usecase.Config{
+		MaxActiveChallenges: 1,
+		ChallengeTimeout:    time.Minute,
+		CleanupInterval:     time.Minute,
+	})
+	ts := httptest.NewServer(httpTransport.NewGateway(captchaUsecase, nil, nil, 0, nil).Handler())
+	defer ts.Close()
+
+	if code := doJSON(t, http.MethodPost, ts.URL+"/v1/challenges", map[string]int{"complexity": 10}, &httpTransport.ChallengeResponse{}); code != http.StatusCreated {
+		t.Fatalf("Expected 201, got %d", code)
+	}
+
+	resp, err := http.Post(ts.URL+"/v1/challenges", "application/json", bytes.NewReader([]byte(`{"complexity":10}`)))
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	var body httpTransport.ErrorBody
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+
+	if resp.StatusCode != http.StatusServiceUnavailable || resp.Header.Get("Retry-After") == "" {
+		t.Errorf("Expected 503 with Retry-After, got %d %q", resp.StatusCode, resp.Header.Get("Retry-After"))
+	}
+	if body.Error.Code != httpTransport.CodeUnavailable || body.Error.Reason != domain.ReasonCapacityReached || body.Error.RetryAfterMs == 0 {
+		t.Errorf("Unexpected error body: %+v", body.Error)
+	}
+}
@@ -7,6 +7,7 @@ import (
 
 	"github.com/FlooooowY/SteelMount-Captcha-Service/internal/config"
 	"github.com/FlooooowY/SteelMount-Captcha-Service/internal/server"
+	"github.com/FlooooowY/SteelMount-Captcha-Service/internal/websocket"
 )
 
 // createTestConfig creates a test configuration
@@ -197,3 +198,49 @@ func TestServer_PortDiscovery(t *testing.T) {
 	t.Logf("Server 2 - gRPC: %d, WebSocket: %d, Metrics: %d",
 		srv2.GetPort(), srv2.GetWebSocketPort(), srv2.GetMetricsPort())
 }
+
+func TestServer_WebSocketValidateReportsFailedResults(t *testing.T) {
+	srv, err := server.New(createTestConfig())
+	if err != nil {
+		t.Fatalf("Failed to create server: %v", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	defer srv.Stop(ctx)
+
+	wsService := srv.GetWebSocketService()
+	conn := wsService.CreateConnection("client-1")
+	exchange := func(message string) *websocket.Event {
+		t.Helper()
+		if err := wsService.ProcessMessage(ctx, conn.ID, []byte(message)); err != nil {
+			t.Fatalf("Failed to process message: %v", err)
+		}
+		select {
+		case event := <-conn.Events:
+			return event
+		case <-ctx.Done():
+			t.Fatalf("Timed out waiting for reply")
+			return nil
+		}
+	}
+
+	created := exchange(`{"version":1,"id":"create","type":"create_challenge","data":{"complexity":30}}`)
+	var challenge websocket.ChallengeCreatedPayload
+	if err := created.DecodeData(&challenge); err != nil || challenge.ChallengeID == "" {
+		t.Fatalf("Expected a created challenge, got %+v (%v)", created, err)
+	}
+
+	// Wrong answers use up the attempts, the failed result then arrives as an error event
+	validate := `{"version":1,"id":"validate","type":"validate_challenge","data":{"challenge_id":"` + challenge.ChallengeID + `","answer":"wrong"}}`
+	for i := 0; i < 10; i++ {
+		reply := exchange(validate)
+		if reply.Type != websocket.EventTypeError {
+			continue
+		}
+		if reply.Error.Code == websocket.ErrorCodeInternal || reply.Error.Reason == "" {
+			t.Errorf("Expected a mapped error code, got %+v", reply.Error)
+		}
+		return
+	}
+	t.Fatalf("Expected an error event once the challenge takes no more answers")
+}
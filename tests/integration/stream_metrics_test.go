@@ -0,0 +1,104 @@
+package integration
+
+import (
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"google.golang.org/grpc"
+
+	"github.com/FlooooowY/SteelMount-Captcha-Service/internal/monitoring"
+	"github.com/FlooooowY/SteelMount-Captcha-Service/internal/repository"
+	grpcTransport "github.com/FlooooowY/SteelMount-Captcha-Service/internal/transport/grpc"
+	"github.com/FlooooowY/SteelMount-Captcha-Service/internal/usecase"
+	pb "github.com/FlooooowY/SteelMount-Captcha-Service/proto/captcha/v1"
+)
+
+func TestEventStream_Metrics(t *testing.T) {
+	metrics := monitoring.NewMetricsWithRegistry(prometheus.NewRegistry())
+	metricsMW := monitoring.NewMetricsMiddleware(metrics)
+
+	captchaUsecase := usecase.NewCaptchaUsecase(repository.NewInMemoryChallengeRepository(), &usecase.Config{
+		MaxActiveChallenges: 100,
+		ChallengeTimeout:    time.Minute,
+		CleanupInterval:     time.Minute,
+	})
+	service := grpcTransport.NewCaptchaService(captchaUsecase)
+	service.SetEventObserver(metrics)
+
+	client := serveCaptchaService(t, service,
+		grpc.ChainStreamInterceptor(metricsMW.GRPCStreamMetricsInterceptor(grpcTransport.StreamMessageType)))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	stream, err := client.MakeEventStream(ctx)
+	if err != nil {
+		t.Fatalf("Failed to open stream: %v", err)
+	}
+
+	// One created challenge and two error frames, one for an event type the
+	// server does not know
+	startSession(t, stream, &pb.ClientEvent{EventType: pb.ClientEvent_CREATE_CHALLENGE, Complexity: 10, Seq: 1})
+	for _, event := range []*pb.ClientEvent{
+		{ChallengeId: "unknown", Seq: 2},
+		{EventType: pb.ClientEvent_EventType(99), Seq: 3},
+	} {
+		if err := stream.Send(event); err != nil {
+			t.Fatalf("Send failed: %v", err)
+		}
+		if _, err := stream.Recv(); err != nil {
+			t.Fatalf("Recv failed: %v", err)
+		}
+	}
+
+	method := "/captcha.v1.CaptchaService/MakeEventStream"
+	if active := testutil.ToFloat64(metrics.GRPCStreamsActive.WithLabelValues(method)); active != 1 {
+		t.Errorf("Expected 1 active stream, got %v", active)
+	}
+
+	if err := stream.CloseSend(); err != nil {
+		t.Fatalf("CloseSend failed: %v", err)
+	}
+	if _, err := stream.Recv(); err != io.EOF {
+		t.Fatalf("Expected clean end of stream, got %v", err)
+	}
+
+	// The interceptor records the terminal status after the handler returns
+	deadline := time.Now().Add(2 * time.Second)
+	for testutil.ToFloat64(metrics.GRPCStreamsTotal.WithLabelValues(method, "OK")) != 1 {
+		if time.Now().After(deadline) {
+			t.Fatalf("Stream end was not recorded")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	checks := []struct {
+		name     string
+		counter  prometheus.Counter
+		expected float64
+	}{
+		{"created in", metrics.GRPCStreamMessages.WithLabelValues(method, "in", "create_challenge"), 1},
+		{"frontend in", metrics.GRPCStreamMessages.WithLabelValues(method, "in", "frontend_event"), 1},
+		{"created out", metrics.GRPCStreamMessages.WithLabelValues(method, "out", "created"), 1},
+		{"established out", metrics.GRPCStreamMessages.WithLabelValues(method, "out", "established"), 1},
+		{"unknown in", metrics.GRPCStreamMessages.WithLabelValues(method, "in", "unknown"), 1},
+		{"error out", metrics.GRPCStreamMessages.WithLabelValues(method, "out", "error"), 2},
+	}
+	for _, check := range checks {
+		if got := testutil.ToFloat64(check.counter); got != check.expected {
+			t.Errorf("%s: expected %v, got %v", check.name, check.expected, got)
+		}
+	}
+
+	if active := testutil.ToFloat64(metrics.GRPCStreamsActive.WithLabelValues(method)); active != 0 {
+		t.Errorf("Expected no active streams, got %v", active)
+	}
+
+	if count := testutil.CollectAndCount(metrics.GRPCStreamEventLatency); count != 3 {
+		t.Errorf("Expected latency series for ok and error outcomes, got %d", count)
+	}
+}
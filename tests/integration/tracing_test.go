@@ -0,0 +1,367 @@
+package integration
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	goredis "github.com/go-redis/redis/v8"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+
+	"github.com/FlooooowY/SteelMount-Captcha-Service/internal/redis"
+	"github.com/FlooooowY/SteelMount-Captcha-Service/internal/repository"
+	"github.com/FlooooowY/SteelMount-Captcha-Service/internal/routing"
+	"github.com/FlooooowY/SteelMount-Captcha-Service/internal/security"
+	"github.com/FlooooowY/SteelMount-Captcha-Service/internal/tracing"
+	grpcTransport "github.com/FlooooowY/SteelMount-Captcha-Service/internal/transport/grpc"
+	"github.com/FlooooowY/SteelMount-Captcha-Service/internal/usecase"
+	"github.com/FlooooowY/SteelMount-Captcha-Service/internal/websocket"
+	pb "github.com/FlooooowY/SteelMount-Captcha-Service/proto/captcha/v1"
+)
+
+// Trace and parent span of the W3C traceparent sent by the test client
+const (
+	remoteTraceID  = "4bf92f3577b34da6a3ce929d0e0e4736"
+	remoteParentID = "00f067aa0ba902b7"
+	traceparent    = "00-" + remoteTraceID + "-" + remoteParentID + "-01"
+)
+
+// setupTracing installs an in-memory exporter for the duration of a test
+func setupTracing(t *testing.T) *tracetest.InMemoryExporter {
+	t.Helper()
+
+	exporter := tracetest.NewInMemoryExporter()
+	provider := tracing.NewProviderWithExporter(exporter, "captcha-service-test")
+	t.Cleanup(func() {
+		_ = provider.Shutdown(context.Background())
+		otel.SetTracerProvider(noop.NewTracerProvider())
+	})
+
+	return exporter
+}
+
+// findSpan returns the first exported span with the given name
+func findSpan(t *testing.T, spans tracetest.SpanStubs, name string) tracetest.SpanStub {
+	t.Helper()
+
+	for _, span := range spans {
+		if span.Name == name {
+			return span
+		}
+	}
+
+	names := make([]string, 0, len(spans))
+	for _, span := range spans {
+		names = append(names, span.Name)
+	}
+	t.Fatalf("Span %q not exported, got %v", name, names)
+	return tracetest.SpanStub{}
+}
+
+func newTracedCaptchaClient(t *testing.T) pb.CaptchaServiceClient {
+	t.Helper()
+
+	return serveCaptchaService(t, newCaptchaService(),
+		grpc.ChainUnaryInterceptor(tracing.UnaryServerInterceptor()),
+		grpc.ChainStreamInterceptor(tracing.StreamServerInterceptor()))
+}
+
+// newCaptchaService creates a captcha service backed by an in-memory repository
+func newCaptchaService() *grpcTransport.CaptchaService {
+	captchaUsecase := usecase.NewCaptchaUsecase(repository.NewInMemoryChallengeRepository(), &usecase.Config{
+		MaxActiveChallenges: 100,
+		ChallengeTimeout:    time.Minute,
+		CleanupInterval:     time.Minute,
+	})
+
+	return grpcTransport.NewCaptchaService(captchaUsecase)
+}
+
+func TestTracing_NewChallengeContinuesIncomingTrace(t *testing.T) {
+	exporter := setupTracing(t)
+	client := newTracedCaptchaClient(t)
+
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "traceparent", traceparent)
+	resp, err := client.NewChallenge(ctx, &pb.ChallengeRequest{Complexity: 30})
+	if err != nil {
+		t.Fatalf("NewChallenge failed: %v", err)
+	}
+
+	spans := exporter.GetSpans()
+	server := findSpan(t, spans, "/captcha.v1.CaptchaService/NewChallenge")
+	if server.SpanKind != trace.SpanKindServer {
+		t.Errorf("Expected server span, got %v", server.SpanKind)
+	}
+	if server.SpanContext.TraceID().String() != remoteTraceID {
+		t.Errorf("Expected trace %s, got %s", remoteTraceID, server.SpanContext.TraceID())
+	}
+	if server.Parent.SpanID().String() != remoteParentID || !server.Parent.IsRemote() {
+		t.Errorf("Expected remote parent %s, got %s", remoteParentID, server.Parent.SpanID())
+	}
+
+	found := false
+	for _, attr := range server.Attributes {
+		if attr.Key == "captcha.challenge_id" && attr.Value.AsString() == resp.ChallengeId {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("Expected challenge ID attribute, got %v", server.Attributes)
+	}
+
+	generate := findSpan(t, spans, "captcha.Engine.GenerateChallenge")
+	if generate.Parent.SpanID() != server.SpanContext.SpanID() {
+		t.Errorf("Expected generator span to be a child of the RPC span")
+	}
+	// The seed reproduces the answer, it must not reach the trace backend
+	for _, attr := range generate.Attributes {
+		if attr.Key == "captcha.seed" {
+			t.Errorf("Expected no seed attribute on the generator span")
+		}
+	}
+}
+
+func TestTracing_EventStreamSpanPerEvent(t *testing.T) {
+	exporter := setupTracing(t)
+	client := newTracedCaptchaClient(t)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	stream, err := client.MakeEventStream(ctx)
+	if err != nil {
+		t.Fatalf("Failed to open stream: %v", err)
+	}
+
+	startSession(t, stream, &pb.ClientEvent{EventType: pb.ClientEvent_CREATE_CHALLENGE, Complexity: 10, Seq: 1})
+	if err := stream.Send(&pb.ClientEvent{ChallengeId: "unknown", Seq: 2}); err != nil {
+		t.Fatalf("Send failed: %v", err)
+	}
+	if _, err := stream.Recv(); err != nil {
+		t.Fatalf("Recv failed: %v", err)
+	}
+	if err := stream.CloseSend(); err != nil {
+		t.Fatalf("CloseSend failed: %v", err)
+	}
+	_, _ = stream.Recv()
+
+	// The stream span ends after the handler returns
+	deadline := time.Now().Add(2 * time.Second)
+	streamName := "/captcha.v1.CaptchaService/MakeEventStream"
+	for {
+		spans := exporter.GetSpans()
+		exported := false
+		for _, span := range spans {
+			exported = exported || span.Name == streamName
+		}
+		if exported {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Stream span was not exported")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	spans := exporter.GetSpans()
+	streamSpan := findSpan(t, spans, streamName)
+
+	created := findSpan(t, spans, "MakeEventStream/create_challenge")
+	if created.Parent.SpanID() != streamSpan.SpanContext.SpanID() {
+		t.Errorf("Expected event span to be a child of the stream span")
+	}
+
+	failed := findSpan(t, spans, "MakeEventStream/frontend_event")
+	if failed.Status.Code != codes.Error {
+		t.Errorf("Expected error status on failed event, got %v", failed.Status)
+	}
+}
+
+func TestTracing_ForwardedStreamContinuesInstanceSpan(t *testing.T) {
+	exporter := setupTracing(t)
+	secret := []byte("secret")
+
+	// The owner instance is dialed by address, so it listens on TCP
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	owner := grpc.NewServer(grpc.ChainStreamInterceptor(tracing.StreamServerInterceptor()))
+	pb.RegisterCaptchaServiceServer(owner, newCaptchaService())
+	go owner.Serve(listener)
+	t.Cleanup(owner.Stop)
+
+	router := routing.NewRouter(routing.NewIDCodec("captcha-1", secret), map[string]string{"captcha-2": listener.Addr().String()})
+	affinity := grpcTransport.NewAffinityMiddleware(router)
+	t.Cleanup(func() { affinity.Close() })
+	client := serveCaptchaService(t, newCaptchaService(),
+		grpc.ChainStreamInterceptor(tracing.StreamServerInterceptor(), affinity.StreamInterceptor()))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	stream, err := client.MakeEventStream(metadata.AppendToOutgoingContext(ctx, "traceparent", traceparent))
+	if err != nil {
+		t.Fatalf("Failed to open stream: %v", err)
+	}
+
+	// A challenge of the peer sends the stream to it, the peer answers with an error frame
+	challengeID := routing.NewIDCodec("captcha-2", secret).NewID()
+	if _, reply := startSession(t, stream, &pb.ClientEvent{ChallengeId: challengeID, Seq: 1, Data: []byte(`{"type":"click"}`)}); reply.GetError() == nil {
+		t.Fatalf("Expected a forwarded error frame, got %v", reply)
+	}
+	if err := stream.CloseSend(); err != nil {
+		t.Fatalf("CloseSend failed: %v", err)
+	}
+	_, _ = stream.Recv()
+
+	// Both instances export a stream span once their handlers return
+	streamName := "/captcha.v1.CaptchaService/MakeEventStream"
+	var streamSpans tracetest.SpanStubs
+	deadline := time.Now().Add(2 * time.Second)
+	for len(streamSpans) < 2 {
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected two stream spans, got %d", len(streamSpans))
+		}
+		time.Sleep(10 * time.Millisecond)
+
+		streamSpans = nil
+		for _, span := range exporter.GetSpans() {
+			if span.Name == streamName {
+				streamSpans = append(streamSpans, span)
+			}
+		}
+	}
+
+	var front, forwarded tracetest.SpanStub
+	for _, span := range streamSpans {
+		if span.Parent.SpanID().String() == remoteParentID {
+			front = span
+		} else {
+			forwarded = span
+		}
+	}
+	if !front.SpanContext.IsValid() {
+		t.Fatalf("Expected the receiving instance to continue the client's span")
+	}
+	if forwarded.SpanContext.TraceID().String() != remoteTraceID {
+		t.Errorf("Expected the owner to stay in trace %s, got %s", remoteTraceID, forwarded.SpanContext.TraceID())
+	}
+	if forwarded.Parent.SpanID() != front.SpanContext.SpanID() {
+		t.Errorf("Expected the owner's span to be a child of the forwarding span, got parent %s", forwarded.Parent.SpanID())
+	}
+}
+
+func TestTracing_WebSocketHandlerContinuesMessageTrace(t *testing.T) {
+	exporter := setupTracing(t)
+
+	ws := websocket.NewWebSocketService()
+	ws.RegisterHandler(websocket.EventTypeCreateChallenge, func(ctx context.Context, event *websocket.Event) (*websocket.Event, error) {
+		return websocket.NewEvent(websocket.EventTypeChallengeCreated), nil
+	})
+
+	conn := ws.CreateConnection("client-1")
+
+	ctx, parent := tracing.StartSpan(context.Background(), "websocket.message")
+	message := []byte(`{"version":1,"id":"req-1","type":"create_challenge","data":{"complexity":30}}`)
+	if err := ws.ProcessMessage(ctx, conn.ID, message); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	parent.End()
+
+	select {
+	case <-conn.Events:
+	case <-time.After(2 * time.Second):
+		t.Fatalf("Timed out waiting for reply")
+	}
+
+	handler := findSpan(t, exporter.GetSpans(), "websocket.handle/create_challenge")
+	if handler.Parent.SpanID() != parent.SpanContext().SpanID() {
+		t.Errorf("Expected handler span to continue the message trace")
+	}
+}
+
+func TestTracing_SecurityCheck(t *testing.T) {
+	exporter := setupTracing(t)
+
+	securityService := security.NewSecurityService(nil, &security.SecurityConfig{
+		RateLimitConfig: security.RateLimitConfig{
+			Enabled:           true,
+			RequestsPerMinute: 1,
+			Window:            time.Minute,
+		},
+	})
+
+	ctx, parent := tracing.StartSpan(context.Background(), "parent")
+	for i := 0; i < 2; i++ {
+		if _, err := securityService.CheckRequest(ctx, "10.0.0.1", "Mozilla/5.0", "/test", 0, false); err != nil {
+			t.Fatalf("CheckRequest failed: %v", err)
+		}
+	}
+	parent.End()
+
+	var checks tracetest.SpanStubs
+	for _, span := range exporter.GetSpans() {
+		if span.Name == "security.CheckRequest" {
+			checks = append(checks, span)
+		}
+	}
+	if len(checks) != 2 {
+		t.Fatalf("Expected 2 security spans, got %d", len(checks))
+	}
+	if checks[0].Parent.SpanID() != parent.SpanContext().SpanID() {
+		t.Errorf("Expected security span to be a child of the caller span")
+	}
+
+	denied := false
+	for _, attr := range checks[1].Attributes {
+		if attr.Key == "security.denial_reason" && attr.Value.AsString() == "RATE_LIMITED" {
+			denied = true
+		}
+	}
+	if !denied {
+		t.Errorf("Expected rate limit denial attribute, got %v", checks[1].Attributes)
+	}
+}
+
+func TestTracing_RedisHook(t *testing.T) {
+	exporter := setupTracing(t)
+	hook := redis.TracingHook{}
+
+	ctx, parent := tracing.StartSpan(context.Background(), "parent")
+
+	// A missing key is not an error
+	get := goredis.NewStringCmd(ctx, "get", "missing")
+	get.SetErr(goredis.Nil)
+	cmdCtx, _ := hook.BeforeProcess(ctx, get)
+	_ = hook.AfterProcess(cmdCtx, get)
+
+	incr := goredis.NewIntCmd(ctx, "incr", "counter")
+	expire := goredis.NewBoolCmd(ctx, "expire", "counter", 60)
+	pipeline := []goredis.Cmder{incr, expire}
+	pipeCtx, _ := hook.BeforeProcessPipeline(ctx, pipeline)
+	expire.SetErr(context.DeadlineExceeded)
+	_ = hook.AfterProcessPipeline(pipeCtx, pipeline)
+	parent.End()
+
+	spans := exporter.GetSpans()
+	getSpan := findSpan(t, spans, "redis.get")
+	if getSpan.Parent.SpanID() != parent.SpanContext().SpanID() {
+		t.Errorf("Expected command span to be a child of the caller span")
+	}
+	if getSpan.Status.Code == codes.Error {
+		t.Errorf("Expected redis.Nil to leave the span status unset")
+	}
+
+	pipeSpan := findSpan(t, spans, "redis.pipeline")
+	if pipeSpan.Status.Code != codes.Error {
+		t.Errorf("Expected failed pipeline to set error status")
+	}
+}
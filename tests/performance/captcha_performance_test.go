@@ -292,7 +292,7 @@ func TestConcurrentGeneration(t *testing.T) {
 // BenchmarkChallengeGeneration benchmarks challenge generation
 func BenchmarkChallengeGeneration(b *testing.B) {
 	engine := captcha.NewEngine(400, 300)
-	challengeTypes := []string{"click", "drag_drop", "swipe", "game"}
+	challengeTypes := []string{"click", "drag_drop", "swipe", "game", "grid", "slider", "rotate", "audio", "text"}
 	
 	b.ResetTimer()
 	
@@ -310,7 +310,7 @@ func BenchmarkChallengeGeneration(b *testing.B) {
 // BenchmarkParallelGeneration benchmarks parallel challenge generation
 func BenchmarkParallelGeneration(b *testing.B) {
 	engine := captcha.NewEngine(400, 300)
-	challengeTypes := []string{"click", "drag_drop", "swipe", "game"}
+	challengeTypes := []string{"click", "drag_drop", "swipe", "game", "grid", "slider", "rotate", "audio", "text"}
 	
 	b.ResetTimer()
 	
@@ -0,0 +1,134 @@
+package performance
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/FlooooowY/SteelMount-Captcha-Service/internal/websocket"
+)
+
+const (
+	wsConnections     = 10000
+	wsSlowConsumerPct = 10
+)
+
+// startConsumers creates connections and drains all except every slow one,
+// slow consumers never read their queue
+func startConsumers(ws *websocket.WebSocketService, count int) (func(), int) {
+	ctx, cancel := context.WithCancel(context.Background())
+	var wg sync.WaitGroup
+	slow := 0
+
+	for i := 0; i < count; i++ {
+		conn := ws.CreateConnection("client")
+		if i%(100/wsSlowConsumerPct) == 0 {
+			slow++
+			continue
+		}
+
+		wg.Add(1)
+		go func(conn *websocket.Connection) {
+			defer wg.Done()
+			for {
+				select {
+				case <-conn.Events:
+				case <-conn.Done():
+					return
+				case <-ctx.Done():
+					return
+				}
+			}
+		}(conn)
+	}
+
+	return func() {
+		cancel()
+		wg.Wait()
+	}, slow
+}
+
+// TestWebSocketBackpressure10k verifies broadcasts never block on slow consumers
+// and that slow consumers are disconnected at 10k concurrent connections
+func TestWebSocketBackpressure10k(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping 10k connection test in short mode")
+	}
+
+	const queueSize = 16
+
+	ws := websocket.NewWebSocketServiceWithConfig(&websocket.ServiceConfig{
+		SendQueueSize:      queueSize,
+		SlowConsumerPolicy: websocket.PolicyDisconnect,
+	})
+
+	var fast []*websocket.Connection
+	slow := 0
+	for i := 0; i < wsConnections; i++ {
+		conn := ws.CreateConnection("client")
+		if i%(100/wsSlowConsumerPct) == 0 {
+			slow++
+			continue
+		}
+		fast = append(fast, conn)
+	}
+
+	start := time.Now()
+	for round := 0; round < 4; round++ {
+		// Fill every queue, then let only the fast consumers catch up
+		for i := 0; i < queueSize; i++ {
+			ws.BroadcastEvent(websocket.NewEvent("tick"))
+		}
+		for _, conn := range fast {
+			for conn.QueueDepth() > 0 {
+				<-conn.Events
+			}
+		}
+	}
+	elapsed := time.Since(start)
+
+	stats := ws.GetConnectionStats()
+	t.Logf("%d broadcasts to %d connections took %v, stats: %v", 4*queueSize, wsConnections, elapsed, stats)
+
+	if elapsed > 10*time.Second {
+		t.Errorf("Broadcasts blocked on slow consumers: %v", elapsed)
+	}
+	if disconnects := stats["slow_consumer_disconnects"].(uint64); disconnects != uint64(slow) {
+		t.Errorf("Expected %d slow consumer disconnects, got %d", slow, disconnects)
+	}
+	if active := stats["active_connections"].(int); active != wsConnections-slow {
+		t.Errorf("Expected %d remaining connections, got %d", wsConnections-slow, active)
+	}
+}
+
+// BenchmarkWebSocketBroadcast10k benchmarks broadcasts to 10k connections
+// for each slow consumer policy
+func BenchmarkWebSocketBroadcast10k(b *testing.B) {
+	policies := []websocket.SlowConsumerPolicy{
+		websocket.PolicyDropNewest,
+		websocket.PolicyDropOldest,
+		websocket.PolicyDisconnect,
+	}
+
+	for _, policy := range policies {
+		b.Run(string(policy), func(b *testing.B) {
+			ws := websocket.NewWebSocketServiceWithConfig(&websocket.ServiceConfig{
+				SendQueueSize:      64,
+				SlowConsumerPolicy: policy,
+			})
+			stop, _ := startConsumers(ws, wsConnections)
+			defer stop()
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				ws.BroadcastEvent(websocket.NewEvent("tick"))
+			}
+			b.StopTimer()
+
+			stats := ws.GetConnectionStats()
+			b.ReportMetric(float64(stats["dropped_events"].(uint64))/float64(b.N), "dropped/op")
+			b.ReportMetric(float64(stats["max_queue_depth"].(int)), "max_depth")
+		})
+	}
+}
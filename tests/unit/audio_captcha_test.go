@@ -0,0 +1,156 @@
+package unit
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"math/rand"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/FlooooowY/SteelMount-Captcha-Service/internal/captcha"
+	"github.com/FlooooowY/SteelMount-Captcha-Service/internal/domain"
+	"github.com/FlooooowY/SteelMount-Captcha-Service/internal/repository"
+	"github.com/FlooooowY/SteelMount-Captcha-Service/internal/usecase"
+)
+
+func TestAudioGenerator_Generate(t *testing.T) {
+	generator := captcha.NewAudioGenerator(4, 6)
+
+	for _, tt := range []struct {
+		complexity int32
+		digits     int
+	}{
+		{complexity: 0, digits: 4},
+		{complexity: 50, digits: 5},
+		{complexity: 100, digits: 6},
+	} {
+		audio, raw, err := generator.Generate(rand.New(rand.NewSource(1)), tt.complexity)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		answer, ok := raw.(*captcha.AudioAnswer)
+		if !ok {
+			t.Fatalf("Expected *AudioAnswer, got %T", raw)
+		}
+
+		if len(answer.Transcript) != tt.digits || audio.Length != tt.digits {
+			t.Errorf("Complexity %d: expected %d digits, got %q", tt.complexity, tt.digits, answer.Transcript)
+		}
+		if strings.Trim(answer.Transcript, "0123456789") != "" {
+			t.Errorf("Expected a digit transcript, got %q", answer.Transcript)
+		}
+
+		// The payload is a playable PCM WAV file
+		data, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(audio.Audio, "data:audio/wav;base64,"))
+		if err != nil {
+			t.Fatalf("Invalid audio encoding: %v", err)
+		}
+		var header struct {
+			Riff          [4]byte
+			Size          uint32
+			Wave          [4]byte
+			Fmt           [4]byte
+			FmtSize       uint32
+			Format        uint16
+			Channels      uint16
+			SampleRate    uint32
+			ByteRate      uint32
+			BlockAlign    uint16
+			BitsPerSample uint16
+			Data          [4]byte
+			DataSize      uint32
+		}
+		if err := binary.Read(bytes.NewReader(data), binary.LittleEndian, &header); err != nil {
+			t.Fatalf("Invalid WAV header: %v", err)
+		}
+		if string(header.Riff[:]) != "RIFF" || string(header.Wave[:]) != "WAVE" || header.Format != 1 || header.Channels != 1 {
+			t.Errorf("Expected mono PCM WAV, got %+v", header)
+		}
+		if int(header.Size)+8 != len(data) {
+			t.Errorf("RIFF size %d does not match %d bytes", header.Size, len(data))
+		}
+		duration := time.Duration(header.DataSize) * time.Second / time.Duration(header.SampleRate)
+		if duration < time.Duration(tt.digits)*500*time.Millisecond || duration > 15*time.Second {
+			t.Errorf("Unexpected duration %v for %d digits", duration, tt.digits)
+		}
+
+		html, err := generator.GenerateHTML(audio)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if strings.Contains(html, "transcript") {
+			t.Errorf("HTML must not contain the transcript")
+		}
+	}
+}
+
+func TestAudioAnswer_Verify(t *testing.T) {
+	answer := &captcha.AudioAnswer{Transcript: "40718"}
+
+	tests := []struct {
+		name       string
+		input      string
+		solved     bool
+		confidence int32
+	}{
+		{name: "digits", input: "40718", solved: true, confidence: 100},
+		{name: "spaces and punctuation", input: " 4-0 7.1, 8 ", solved: true, confidence: 100},
+		{name: "spelled out", input: "Four ZERO seven one eight", solved: true, confidence: 100},
+		{name: "homophones", input: "for oh seven won ate", solved: true, confidence: 100},
+		{name: "russian", input: "четыре ноль семь один восемь", solved: true, confidence: 100},
+		{name: "mixed", input: "4 zero 7 one8", solved: true, confidence: 100},
+		{name: "one digit misheard", input: "40719", confidence: 60},
+		{name: "one digit missed", input: "4078", confidence: 60},
+		{name: "two digits wrong", input: "41719 2", confidence: 0},
+		{name: "empty", input: "", confidence: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			solved, confidence := answer.Verify(tt.input)
+			if solved != tt.solved || confidence != tt.confidence {
+				t.Errorf("Verify(%q) = %v, %d, expected %v, %d", tt.input, solved, confidence, tt.solved, tt.confidence)
+			}
+		})
+	}
+}
+
+func TestCaptchaUsecase_AccessibleChallenge(t *testing.T) {
+	captchaUsecase := usecase.NewCaptchaUsecase(repository.NewInMemoryChallengeRepository(), &usecase.Config{
+		MaxActiveChallenges: 100,
+		ChallengeTimeout:    time.Minute,
+		CleanupInterval:     time.Minute,
+	})
+	ctx := context.Background()
+
+	// Audio and text are never picked for callers who did not ask for them
+	for i := 0; i < 50; i++ {
+		challenge, err := captchaUsecase.CreateChallenge(ctx, int32(i*2))
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if challenge.Type == domain.ChallengeTypeAudio || challenge.Type == domain.ChallengeTypeText {
+			t.Fatalf("Unexpected %s challenge without a request for it", challenge.Type)
+		}
+	}
+
+	challenge, err := captchaUsecase.CreateChallengeWithOptions(ctx, 80, domain.ChallengeOptions{Accessible: true})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if challenge.Type != domain.ChallengeTypeAudio || challenge.Metadata["accessible"] != "true" {
+		t.Fatalf("Expected an accessible audio challenge, got %s %v", challenge.Type, challenge.Metadata)
+	}
+
+	transcript := challenge.Answer.(*captcha.AudioAnswer).Transcript
+	result, err := captchaUsecase.ValidateChallenge(ctx, challenge.ID, strings.Join(strings.Split(transcript, ""), " "))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !result.Solved {
+		t.Errorf("Expected spaced transcript to solve the challenge, got %+v", result)
+	}
+}
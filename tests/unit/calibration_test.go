@@ -0,0 +1,299 @@
+package unit
+
+import (
+	"context"
+	"encoding/json"
+	"math"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/FlooooowY/SteelMount-Captcha-Service/internal/calibration"
+	"github.com/FlooooowY/SteelMount-Captcha-Service/internal/captcha"
+	"github.com/FlooooowY/SteelMount-Captcha-Service/internal/domain"
+	"github.com/FlooooowY/SteelMount-Captcha-Service/internal/repository"
+	"github.com/FlooooowY/SteelMount-Captcha-Service/internal/usecase"
+)
+
+// recordPassRates feeds 100 answers per level bucket passing at passRate(level)
+func recordPassRates(calibrator *calibration.Calibrator, challengeType string, passRate func(level float64) float64) {
+	for level := int32(5); level < 100; level += 10 {
+		solved := int(math.Round(passRate(float64(level)) * 100))
+		for i := 0; i < 100; i++ {
+			calibrator.Record(challengeType, level, i < solved, 2*time.Second)
+		}
+	}
+}
+
+func TestCalibrator_Uncalibrated(t *testing.T) {
+	calibrator := calibration.NewCalibratorWithConfig(&calibration.Config{MinSamples: 10})
+
+	for _, complexity := range []int32{0, 35, 100} {
+		if level := calibrator.Level("click", complexity, nil); level != complexity {
+			t.Errorf("Expected level %d without data, got %d", complexity, level)
+		}
+	}
+
+	// One measured bucket is not a curve yet
+	for i := 0; i < 20; i++ {
+		calibrator.Record("click", 50, i%2 == 0, time.Second)
+	}
+	if level := calibrator.Level("click", 80, nil); level != 80 {
+		t.Errorf("Expected level 80 with a single bucket, got %d", level)
+	}
+	if level := calibrator.Level("click", -5, nil); level != 0 {
+		t.Errorf("Expected negative complexity to clamp to 0, got %d", level)
+	}
+}
+
+func TestCalibrator_EqualizesPassRates(t *testing.T) {
+	calibrator := calibration.NewCalibratorWithConfig(&calibration.Config{
+		MinSamples:   50,
+		EasyPassRate: 0.95,
+		HardPassRate: 0.5,
+		Exploration:  0,
+	})
+
+	// Click gets hard quickly, grid barely changes with its level
+	recordPassRates(calibrator, "click", func(level float64) float64 { return 0.99 - 0.006*level })
+	recordPassRates(calibrator, "grid", func(level float64) float64 { return 0.9 - 0.002*level })
+
+	report := calibrator.Report()
+	for _, challengeType := range []string{"click", "grid"} {
+		typeReport := report.Types[challengeType]
+		if typeReport == nil || !typeReport.Calibrated {
+			t.Fatalf("Expected %s to be calibrated, got %+v", challengeType, typeReport)
+		}
+		if len(typeReport.Buckets) != 10 || len(typeReport.Curve) != 11 {
+			t.Fatalf("Expected 10 buckets and 11 curve points, got %d and %d", len(typeReport.Buckets), len(typeReport.Curve))
+		}
+		if typeReport.Buckets[9].MaxLevel != 100 || typeReport.Buckets[0].MeanSolveMs != 2000 {
+			t.Errorf("Unexpected bucket report %+v", typeReport.Buckets[0])
+		}
+	}
+
+	// Where the target is reachable both types are expected to pass at it
+	for _, complexity := range []int32{20, 50} {
+		target := 0.95 - 0.45*float64(complexity)/100
+		click := calibrator.Level("click", complexity, nil)
+		if rate := 0.99 - 0.006*float64(click); math.Abs(rate-target) > 0.03 {
+			t.Errorf("Click at complexity %d: level %d passes %.2f, want %.2f", complexity, click, rate, target)
+		}
+		for _, point := range report.Types["click"].Curve {
+			if point.Complexity == complexity && (point.Level != click || math.Abs(point.ExpectedPassRate-target) > 0.03) {
+				t.Errorf("Report curve %+v disagrees with level %d", point, click)
+			}
+		}
+	}
+
+	// Grid is never as easy as the easiest target nor as hard as the hardest
+	if level := calibrator.Level("grid", 0, nil); level != 0 {
+		t.Errorf("Expected the easiest grid level, got %d", level)
+	}
+	if level := calibrator.Level("grid", 100, nil); level != 100 {
+		t.Errorf("Expected the hardest grid level, got %d", level)
+	}
+	if grid, click := calibrator.Level("grid", 50, nil), calibrator.Level("click", 50, nil); grid <= click {
+		t.Errorf("Expected grid to need a higher level than click for the same pass rate, got %d and %d", grid, click)
+	}
+}
+
+func TestCalibrator_PoolsNonMonotoneBuckets(t *testing.T) {
+	calibrator := calibration.NewCalibratorWithConfig(&calibration.Config{MinSamples: 50})
+
+	// Noise makes some harder buckets pass more often
+	rates := []float64{0.9, 0.7, 0.8, 0.6, 0.65, 0.4, 0.5, 0.3, 0.35, 0.2}
+	recordPassRates(calibrator, "rotate", func(level float64) float64 { return rates[int(level)/10] })
+
+	buckets := calibrator.Report().Types["rotate"].Buckets
+	for i := 1; i < len(buckets); i++ {
+		if buckets[i].FittedPassRate > buckets[i-1].FittedPassRate {
+			t.Errorf("Fitted pass rate rises from bucket %d to %d: %v > %v", i-1, i, buckets[i].FittedPassRate, buckets[i-1].FittedPassRate)
+		}
+	}
+	if buckets[1].FittedPassRate != buckets[2].FittedPassRate || math.Abs(buckets[1].FittedPassRate-0.75) > 0.01 {
+		t.Errorf("Expected buckets 1 and 2 pooled at 0.75, got %v and %v", buckets[1].FittedPassRate, buckets[2].FittedPassRate)
+	}
+}
+
+func TestCalibrator_Exploration(t *testing.T) {
+	calibrator := calibration.NewCalibratorWithConfig(&calibration.Config{Exploration: 1})
+	rng := rand.New(rand.NewSource(3))
+
+	levels := map[int32]bool{}
+	for i := 0; i < 50; i++ {
+		level := calibrator.Level("slider", 50, rng)
+		if level < 0 || level > 100 {
+			t.Fatalf("Explored level out of range: %d", level)
+		}
+		levels[level] = true
+	}
+	if len(levels) < 10 {
+		t.Errorf("Expected exploration to spread levels, got %v", levels)
+	}
+}
+
+func TestCalibrator_MaxSamples(t *testing.T) {
+	calibrator := calibration.NewCalibratorWithConfig(&calibration.Config{MinSamples: 10, MaxSamples: 100})
+
+	for i := 0; i < 101; i++ {
+		calibrator.Record("text", 30, i%4 == 0, time.Second)
+	}
+
+	bucket := calibrator.Report().Types["text"].Buckets[3]
+	if bucket.Attempts != 50 || bucket.Solved != 13 || bucket.MeanSolveMs != 1000 {
+		t.Errorf("Expected counts halved past max samples, got %+v", bucket)
+	}
+}
+
+func TestCalibrator_Handler(t *testing.T) {
+	calibrator := calibration.NewCalibrator()
+	calibrator.Record("click", 40, true, time.Second)
+
+	recorder := httptest.NewRecorder()
+	calibrator.Handler().ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/captcha/calibration", nil))
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d", recorder.Code)
+	}
+
+	var report calibration.Report
+	if err := json.Unmarshal(recorder.Body.Bytes(), &report); err != nil {
+		t.Fatalf("Failed to decode report: %v", err)
+	}
+	if report.Types["click"] == nil || report.Types["click"].Buckets[4].Attempts != 1 || report.Types["click"].Calibrated {
+		t.Errorf("Unexpected report %+v", report.Types["click"])
+	}
+
+	recorder = httptest.NewRecorder()
+	calibrator.Handler().ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/captcha/calibration", nil))
+	if recorder.Code != http.StatusMethodNotAllowed {
+		t.Errorf("Expected 405, got %d", recorder.Code)
+	}
+}
+
+// fakeCalibrator issues complexity+7 and remembers every answer
+type fakeCalibrator struct {
+	answers []fakeAnswer
+}
+
+type fakeAnswer struct {
+	challengeType string
+	level         int32
+	solved        bool
+}
+
+func (f *fakeCalibrator) Level(challengeType string, complexity int32, rng *rand.Rand) int32 {
+	return complexity + 7
+}
+
+func (f *fakeCalibrator) Record(challengeType string, level int32, solved bool, elapsed time.Duration) {
+	f.answers = append(f.answers, fakeAnswer{challengeType, level, solved})
+}
+
+func TestCaptchaUsecase_Calibration(t *testing.T) {
+	calibrator := &fakeCalibrator{}
+	captchaUsecase := usecase.NewCaptchaUsecase(repository.NewInMemoryChallengeRepository(), &usecase.Config{
+		MaxActiveChallenges: 10,
+		ChallengeTimeout:    time.Minute,
+		CleanupInterval:     time.Minute,
+		Seeds:               func() int64 { return 99 },
+		Calibrator:          calibrator,
+	})
+	ctx := context.Background()
+
+	challenge, err := captchaUsecase.CreateChallengeWithOptions(ctx, 40, domain.ChallengeOptions{KeyboardOnly: true})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if challenge.Complexity != 40 || challenge.Level() != 47 || challenge.Metadata["level"] != "47" {
+		t.Fatalf("Expected complexity 40 generated at level 47, got %d and %v", challenge.Complexity, challenge.Metadata)
+	}
+
+	// The recorded level and seed regenerate the challenge
+	html, _, err := captcha.NewEngine(400, 300).GenerateChallengeWithSeed("text", challenge.Level(), challenge.Seed())
+	if err != nil || html != challenge.HTML {
+		t.Errorf("Expected level and seed to regenerate the challenge, err=%v", err)
+	}
+
+	// Only the first answer of a single-shot challenge is counted
+	for i := 0; i < 3; i++ {
+		if _, err := captchaUsecase.ValidateChallenge(ctx, challenge.ID, "wrong answer"); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	}
+	if len(calibrator.answers) != 1 || calibrator.answers[0] != (fakeAnswer{"text", 47, false}) {
+		t.Fatalf("Expected one failed answer at level 47, got %+v", calibrator.answers)
+	}
+
+	// Every stage answer is counted at the level of its stage
+	staged, err := captchaUsecase.CreateChallengeWithOptions(ctx, 10, domain.ChallengeOptions{KeyboardOnly: true})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	data, _ := json.Marshal(map[string]interface{}{"type": "challenge_attempt", "answer": "zzzzzzz"})
+	if _, err := captchaUsecase.ProcessEvent(ctx, &domain.Event{Type: domain.EventTypeFrontendEvent, ChallengeID: staged.ID, Data: data}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(calibrator.answers) != 2 || calibrator.answers[1] != (fakeAnswer{"text", 17, false}) {
+		t.Errorf("Expected a failed stage answer at level 17, got %+v", calibrator.answers)
+	}
+}
+
+func TestCalibrator_MaxShift(t *testing.T) {
+	calibrator := calibration.NewCalibratorWithConfig(&calibration.Config{MinSamples: 50, MaxShift: 30})
+
+	// Clients failing every challenge on purpose would push every level to 0
+	recordPassRates(calibrator, "click", func(level float64) float64 { return 0.01 })
+	if level := calibrator.Level("click", 80, nil); level != 50 {
+		t.Errorf("Expected the level held within 30 of complexity 80, got %d", level)
+	}
+	for _, point := range calibrator.Report().Types["click"].Curve {
+		if point.Level < point.Complexity-30 {
+			t.Errorf("Report curve %+v moved past the shift limit", point)
+		}
+	}
+}
+
+func TestCaptchaUsecase_CalibrationIgnoresRiskyClients(t *testing.T) {
+	calibrator := calibration.NewCalibratorWithConfig(&calibration.Config{MinSamples: 10})
+	risk := 0.9
+	captchaUsecase := usecase.NewCaptchaUsecase(repository.NewInMemoryChallengeRepository(), &usecase.Config{
+		MaxActiveChallenges: 1000,
+		ChallengeTimeout:    time.Minute,
+		CleanupInterval:     time.Minute,
+		Calibrator:          calibrator,
+		AssessRisk:          func(ctx context.Context) float64 { return risk },
+	})
+	ctx := context.Background()
+
+	// A bot answering wrong at every level does not reach the statistics
+	poison := func() {
+		for complexity := int32(0); complexity <= 100; complexity += 10 {
+			for i := 0; i < 20; i++ {
+				challenge, err := captchaUsecase.CreateChallengeWithOptions(ctx, complexity, domain.ChallengeOptions{KeyboardOnly: true})
+				if err != nil {
+					t.Fatalf("Unexpected error: %v", err)
+				}
+				if _, err := captchaUsecase.ValidateChallenge(ctx, challenge.ID, "wrong answer"); err != nil {
+					t.Fatalf("Unexpected error: %v", err)
+				}
+			}
+		}
+	}
+	poison()
+	if answers := calibrator.GetStats()["answers"]; answers != int64(0) {
+		t.Fatalf("Expected no answers of a risky client recorded, got %v", answers)
+	}
+	if level := calibrator.Level("text", 80, nil); level != 80 {
+		t.Errorf("Expected complexity 80 unchanged, got %d", level)
+	}
+
+	// The same answers from a trusted client count
+	risk = 0.1
+	poison()
+	if answers := calibrator.GetStats()["answers"]; answers == int64(0) {
+		t.Errorf("Expected answers of a trusted client recorded")
+	}
+}
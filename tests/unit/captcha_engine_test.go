@@ -35,6 +35,36 @@ func TestEngine_GenerateChallenge(t *testing.T) {
 			complexity:  70,
 			expectError: false,
 		},
+		{
+			name:        "valid grid captcha",
+			captchaType: "grid",
+			complexity:  60,
+			expectError: false,
+		},
+		{
+			name:        "valid slider captcha",
+			captchaType: "slider",
+			complexity:  80,
+			expectError: false,
+		},
+		{
+			name:        "valid rotate captcha",
+			captchaType: "rotate",
+			complexity:  40,
+			expectError: false,
+		},
+		{
+			name:        "valid audio captcha",
+			captchaType: "audio",
+			complexity:  60,
+			expectError: false,
+		},
+		{
+			name:        "valid text captcha",
+			captchaType: "text",
+			complexity:  70,
+			expectError: false,
+		},
 		{
 			name:        "invalid captcha type",
 			captchaType: "invalid",
@@ -0,0 +1,112 @@
+package unit
+
+import (
+	"context"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/FlooooowY/SteelMount-Captcha-Service/internal/captcha"
+	"github.com/FlooooowY/SteelMount-Captcha-Service/internal/domain"
+	"github.com/FlooooowY/SteelMount-Captcha-Service/internal/repository"
+	"github.com/FlooooowY/SteelMount-Captcha-Service/internal/usecase"
+)
+
+var seededChallengeTypes = []string{"drag_drop", "click", "swipe", "game", "grid", "slider", "rotate", "audio", "text", "passive"}
+
+func TestEngine_GenerateChallengeWithSeed(t *testing.T) {
+	engine := captcha.NewEngine(400, 300)
+
+	for _, challengeType := range seededChallengeTypes {
+		t.Run(challengeType, func(t *testing.T) {
+			html, answer, err := engine.GenerateChallengeWithSeed(challengeType, 50, 42)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			again, againAnswer, err := engine.GenerateChallengeWithSeed(challengeType, 50, 42)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if html != again || !reflect.DeepEqual(answer, againAnswer) {
+				t.Errorf("Expected the same seed to regenerate the same challenge")
+			}
+
+			other, _, err := engine.GenerateChallengeWithSeed(challengeType, 50, 43)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if html == other {
+				t.Errorf("Expected a different seed to generate a different challenge")
+			}
+		})
+	}
+}
+
+func TestEngine_SeedSource(t *testing.T) {
+	seeds := []int64{7, 7, 8}
+	engine := captcha.NewEngineWithSeedSource(400, 300, func() int64 {
+		seed := seeds[0]
+		seeds = seeds[1:]
+		return seed
+	})
+
+	first, _, _ := engine.GenerateChallenge("grid", 50)
+	second, _, _ := engine.GenerateChallenge("grid", 50)
+	third, _, _ := engine.GenerateChallenge("grid", 50)
+	if first != second {
+		t.Error("Expected equal seeds from the source to generate the same challenge")
+	}
+	if first == third {
+		t.Error("Expected a new seed from the source to generate a new challenge")
+	}
+}
+
+func TestCaptchaUsecase_RecordsSeed(t *testing.T) {
+	captchaUsecase := usecase.NewCaptchaUsecase(repository.NewInMemoryChallengeRepository(), &usecase.Config{
+		MaxActiveChallenges: 10,
+		ChallengeTimeout:    time.Minute,
+		CleanupInterval:     time.Minute,
+		Seeds:               func() int64 { return 1234567890123 },
+	})
+
+	challenge, err := captchaUsecase.CreateChallenge(context.Background(), 40)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if challenge.Metadata["seed"] != "1234567890123" || challenge.Seed() != 1234567890123 {
+		t.Fatalf("Expected the seed in the metadata, got %v", challenge.Metadata)
+	}
+	if stage := challenge.CurrentStage(); stage.Seed != challenge.Seed() {
+		t.Errorf("Expected the first stage to carry the challenge seed, got %d", stage.Seed)
+	}
+
+	// Support regenerates the challenge from what the metadata recorded
+	seed, _ := strconv.ParseInt(challenge.Metadata["seed"], 10, 64)
+	html, answer, err := captcha.NewEngine(400, 300).GenerateChallengeWithSeed(string(challenge.Type), challenge.Level(), seed)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if html != challenge.HTML || !reflect.DeepEqual(answer, challenge.Answer) {
+		t.Errorf("Expected the recorded seed to regenerate the %s challenge", challenge.Type)
+	}
+
+	// The type is drawn from the seed as well
+	again, err := captchaUsecase.CreateChallenge(context.Background(), 40)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if again.Type != challenge.Type || again.HTML != challenge.HTML {
+		t.Errorf("Expected the same seed to pick the same challenge, got %s and %s", challenge.Type, again.Type)
+	}
+}
+
+func TestChallenge_Seed(t *testing.T) {
+	if seed := (&domain.Challenge{}).Seed(); seed != 0 {
+		t.Errorf("Expected 0 without a recorded seed, got %d", seed)
+	}
+	if seed := (&domain.Challenge{Metadata: map[string]string{"seed": "-5"}}).Seed(); seed != -5 {
+		t.Errorf("Expected -5, got %d", seed)
+	}
+}
@@ -0,0 +1,102 @@
+package unit
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/FlooooowY/SteelMount-Captcha-Service/internal/routing"
+	"github.com/google/uuid"
+)
+
+func TestIDCodec_RoundTrip(t *testing.T) {
+	codec := routing.NewIDCodec("captcha-1", []byte("secret"))
+
+	id := codec.NewID()
+	info, err := codec.Parse(id)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if !info.Encoded {
+		t.Errorf("Expected encoded ID")
+	}
+
+	if info.InstanceID != "captcha-1" {
+		t.Errorf("Expected instance captcha-1, got %s", info.InstanceID)
+	}
+
+	local, err := codec.IsLocal(id)
+	if err != nil || !local {
+		t.Errorf("Expected ID to be local, got local=%v err=%v", local, err)
+	}
+}
+
+func TestIDCodec_RejectsTampering(t *testing.T) {
+	codec := routing.NewIDCodec("captcha-1", []byte("secret"))
+	other := routing.NewIDCodec("captcha-2", []byte("other-secret"))
+
+	tests := []struct {
+		name string
+		id   string
+	}{
+		{
+			name: "foreign secret",
+			id:   other.NewID(),
+		},
+		{
+			name: "swapped instance",
+			id: func() string {
+				parts := strings.Split(codec.NewID(), ".")
+				forged := strings.Split(other.NewID(), ".")
+				return parts[0] + "." + forged[1] + "." + parts[2]
+			}(),
+		},
+		{
+			name: "garbage",
+			id:   "not-a-challenge-id",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := codec.Parse(tt.id); err == nil {
+				t.Errorf("Expected error for %s", tt.id)
+			}
+		})
+	}
+}
+
+func TestRouter_Route(t *testing.T) {
+	secret := []byte("shared-secret")
+	local := routing.NewIDCodec("captcha-1", secret)
+	peer := routing.NewIDCodec("captcha-2", secret)
+	unknown := routing.NewIDCodec("captcha-3", secret)
+
+	router := routing.NewRouter(local, map[string]string{"captcha-2": "10.0.0.2:38000"})
+
+	route, err := router.Route(local.NewID())
+	if err != nil || !route.Local {
+		t.Errorf("Expected local route, got %+v err=%v", route, err)
+	}
+
+	route, err = router.Route(uuid.New().String())
+	if err != nil || !route.Local {
+		t.Errorf("Expected plain UUID to route locally, got %+v err=%v", route, err)
+	}
+
+	route, err = router.Route(peer.NewID())
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if route.Local || route.Address != "10.0.0.2:38000" {
+		t.Errorf("Expected route to peer, got %+v", route)
+	}
+
+	route, err = router.Route(unknown.NewID())
+	if err != routing.ErrUnknownInstance {
+		t.Errorf("Expected ErrUnknownInstance, got %v", err)
+	}
+	if route == nil || route.InstanceID != "captcha-3" {
+		t.Errorf("Expected route to name owner instance, got %+v", route)
+	}
+}
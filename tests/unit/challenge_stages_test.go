@@ -0,0 +1,165 @@
+package unit
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"testing"
+
+	"github.com/FlooooowY/SteelMount-Captcha-Service/internal/captcha"
+	"github.com/FlooooowY/SteelMount-Captcha-Service/internal/domain"
+)
+
+// stageAnswer is a validated answer fed to the stage machine
+type stageAnswer struct {
+	valid      bool
+	confidence int32
+}
+
+func TestChallengeStages_Outcomes(t *testing.T) {
+	policy := domain.DefaultStagePolicy()
+
+	tests := []struct {
+		name       string
+		answers    []stageAnswer
+		outcomes   []domain.StageOutcome
+		solved     bool
+		confidence int32
+	}{
+		{
+			name:       "correct first answer",
+			answers:    []stageAnswer{{true, 100}},
+			outcomes:   []domain.StageOutcome{domain.StageOutcomePassed},
+			solved:     true,
+			confidence: 100,
+		},
+		{
+			name:       "clearly wrong answer",
+			answers:    []stageAnswer{{false, 20}},
+			outcomes:   []domain.StageOutcome{domain.StageOutcomeFailed},
+			confidence: 20,
+		},
+		{
+			name:       "borderline then correct",
+			answers:    []stageAnswer{{false, 60}, {true, 100}},
+			outcomes:   []domain.StageOutcome{domain.StageOutcomeNext, domain.StageOutcomePassed},
+			solved:     true,
+			confidence: 80,
+		},
+		{
+			name:       "borderline until out of rounds",
+			answers:    []stageAnswer{{false, 60}, {false, 60}, {false, 60}},
+			outcomes:   []domain.StageOutcome{domain.StageOutcomeNext, domain.StageOutcomeNext, domain.StageOutcomeFailed},
+			confidence: 60,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			challenge := &domain.Challenge{ID: "c1", Type: domain.ChallengeTypeClick, Complexity: 10}
+
+			for i, answer := range tt.answers {
+				outcome := challenge.RecordStageAnswer(answer.valid, answer.confidence, policy)
+				if outcome != tt.outcomes[i] {
+					t.Fatalf("Stage %d: expected %s, got %s", i, tt.outcomes[i], outcome)
+				}
+				if outcome == domain.StageOutcomeNext {
+					challenge.AddStage(domain.ChallengeStage{
+						Type:       challenge.Type,
+						Complexity: challenge.CurrentStage().Complexity + policy.ComplexityIncrease,
+						Reason:     domain.StageReasonBorderline,
+					})
+				}
+			}
+
+			if challenge.Solved != tt.solved {
+				t.Errorf("Expected solved=%v, got %v", tt.solved, challenge.Solved)
+			}
+			if !challenge.StagesFinished() {
+				t.Errorf("Expected stage machine to be finished")
+			}
+			if got := challenge.StageConfidence(); got != tt.confidence {
+				t.Errorf("Expected confidence %d, got %d", tt.confidence, got)
+			}
+		})
+	}
+}
+
+func TestCaptchaUsecase_ValidateAfterStages(t *testing.T) {
+	ctx := context.Background()
+	var answers []bool
+	captchaUsecase := newAutoUsecase(0, &answers)
+
+	// A borderline answer on the stream pushes a harder round
+	challenge, err := captchaUsecase.CreateChallengeWithOptions(ctx, 10, domain.ChallengeOptions{KeyboardOnly: true})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	text := challenge.Answer.(*captcha.TextAnswer).Text
+	events := submitStageAnswer(t, captchaUsecase, challenge.ID, text[:len(text)-1])
+	if len(events) != 2 {
+		t.Fatalf("Expected a follow-up round, got %+v", events)
+	}
+
+	// The first round's answer cannot skip it
+	result, err := captchaUsecase.ValidateChallenge(ctx, challenge.ID, text)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if result.Solved || result.Error != domain.ResultErrorStagesRequired {
+		t.Fatalf("Expected the pushed round to be required, got %+v", result)
+	}
+
+	// A challenge the stage machine failed cannot be retried
+	challenge, err = captchaUsecase.CreateChallengeWithOptions(ctx, 10, domain.ChallengeOptions{KeyboardOnly: true})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	events = submitStageAnswer(t, captchaUsecase, challenge.ID, "")
+	if len(events) != 1 || events[0].Solved {
+		t.Fatalf("Expected the challenge failed, got %+v", events)
+	}
+
+	result, err = captchaUsecase.ValidateChallenge(ctx, challenge.ID, challenge.Answer.(*captcha.TextAnswer).Text)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if result.Solved || result.Error != domain.ResultErrorExhausted {
+		t.Fatalf("Expected no attempts left, got %+v", result)
+	}
+	if domainErr, ok := domain.AsError(result.Err()); !ok || domainErr.Reason != domain.ReasonAttemptsExhausted {
+		t.Errorf("Expected ATTEMPTS_EXHAUSTED, got %v", result.Err())
+	}
+}
+
+func TestCaptchaUsecase_ConcurrentStageAnswers(t *testing.T) {
+	ctx := context.Background()
+	var answers []bool
+	captchaUsecase := newAutoUsecase(0, &answers)
+
+	challenge, err := captchaUsecase.CreateChallengeWithOptions(ctx, 10, domain.ChallengeOptions{KeyboardOnly: true})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	// Answers racing on one stage are taken one at a time, the first solves the
+	// challenge and the others only get its result
+	data, _ := json.Marshal(map[string]interface{}{"type": "challenge_attempt", "answer": challenge.Answer.(*captcha.TextAnswer).Text})
+	var wg sync.WaitGroup
+	for i := 0; i < 16; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			events, err := captchaUsecase.ProcessEvent(ctx, &domain.Event{Type: domain.EventTypeFrontendEvent, ChallengeID: challenge.ID, Data: data})
+			if err != nil || len(events) != 1 || !events[0].Solved {
+				t.Errorf("Expected the solved result, got %+v (%v)", events, err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	stored, _ := captchaUsecase.GetChallenge(ctx, challenge.ID)
+	if len(stored.Stages) != 1 || len(answers) != 1 {
+		t.Errorf("Expected one stage answered once, got %d stages and answers %v", len(stored.Stages), answers)
+	}
+}
@@ -0,0 +1,106 @@
+package unit
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+
+	"github.com/FlooooowY/SteelMount-Captcha-Service/internal/domain"
+	"github.com/FlooooowY/SteelMount-Captcha-Service/internal/repository"
+	grpcTransport "github.com/FlooooowY/SteelMount-Captcha-Service/internal/transport/grpc"
+	"github.com/FlooooowY/SteelMount-Captcha-Service/internal/websocket"
+)
+
+func TestErrorMapping_Kinds(t *testing.T) {
+	tests := []struct {
+		name    string
+		err     error
+		code    codes.Code
+		wsCode  string
+		retry   bool
+		quota   bool
+		matches error
+	}{
+		{"not found", repository.ErrChallengeNotFound, codes.NotFound, websocket.ErrorCodeNotFound, false, false, domain.ErrNotFound},
+		{"expired", domain.ExpiredError("c1"), codes.FailedPrecondition, websocket.ErrorCodeExpired, false, false, domain.ErrExpired},
+		{"abandoned", domain.AbandonedError("c1"), codes.FailedPrecondition, websocket.ErrorCodeExpired, false, false, domain.ErrExpired},
+		{"consumed", domain.ConsumedError("c1"), codes.FailedPrecondition, websocket.ErrorCodeExpired, false, false, domain.ErrExpired},
+		{"exhausted", domain.ExhaustedError("c1"), codes.ResourceExhausted, websocket.ErrorCodeExhausted, false, true, domain.ErrExhausted},
+		{"stages required", domain.StagesRequiredError("c1"), codes.FailedPrecondition, websocket.ErrorCodeStagesRequired, false, false, domain.ErrPrecondition},
+		{
+			"rate limited",
+			domain.NewError(domain.ErrorKindRateLimited, domain.ReasonRateLimited, "rate limit exceeded").WithRetryAfter(time.Minute).WithQuota("ip:1.2.3.4", "10 requests per minute"),
+			codes.ResourceExhausted, websocket.ErrorCodeRateLimited, true, true, domain.ErrRateLimited,
+		},
+		{"blocked", domain.NewError(domain.ErrorKindBlocked, domain.ReasonIPBlocked, "IP blocked"), codes.PermissionDenied, websocket.ErrorCodeRequestBlocked, false, false, domain.ErrBlocked},
+		{"capacity", domain.NewError(domain.ErrorKindCapacity, domain.ReasonCapacityReached, "full").WithRetryAfter(5 * time.Second), codes.Unavailable, websocket.ErrorCodeCapacity, true, false, domain.ErrCapacity},
+		{"unknown result", (&domain.ChallengeResult{ChallengeID: "c1", Error: "unmapped"}).Err(), codes.Internal, websocket.ErrorCodeInternal, false, false, domain.ErrInternal},
+		{"wrapped", fmt.Errorf("failed to get challenge: %w", repository.ErrChallengeNotFound), codes.NotFound, websocket.ErrorCodeNotFound, false, false, domain.ErrNotFound},
+		{"unclassified", errors.New("boom"), codes.Internal, websocket.ErrorCodeInternal, false, false, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.matches != nil && !errors.Is(tt.err, tt.matches) {
+				t.Errorf("Expected error to match its kind")
+			}
+
+			st := grpcTransport.StatusFromError(tt.err)
+			if st.Code() != tt.code {
+				t.Errorf("Expected code %s, got %s", tt.code, st.Code())
+			}
+
+			var info *errdetails.ErrorInfo
+			var retry *errdetails.RetryInfo
+			var quota *errdetails.QuotaFailure
+			for _, detail := range st.Details() {
+				switch d := detail.(type) {
+				case *errdetails.ErrorInfo:
+					info = d
+				case *errdetails.RetryInfo:
+					retry = d
+				case *errdetails.QuotaFailure:
+					quota = d
+				}
+			}
+			if tt.matches != nil && (info == nil || info.Domain != domain.ErrorDomain) {
+				t.Errorf("Expected ErrorInfo detail, got %v", st.Details())
+			}
+			if (retry != nil) != tt.retry {
+				t.Errorf("Expected RetryInfo=%v, got %v", tt.retry, retry)
+			}
+			if (quota != nil) != tt.quota {
+				t.Errorf("Expected QuotaFailure=%v, got %v", tt.quota, quota)
+			}
+
+			payload := websocket.NewErrorPayload(tt.err)
+			if payload.Code != tt.wsCode {
+				t.Errorf("Expected WebSocket code %s, got %s", tt.wsCode, payload.Code)
+			}
+			if tt.retry && payload.RetryAfterMs == 0 {
+				t.Errorf("Expected WebSocket retry hint")
+			}
+		})
+	}
+}
+
+func TestErrorMapping_StagesRequiredPrecondition(t *testing.T) {
+	st := grpcTransport.StatusFromError(domain.StagesRequiredError("c1"))
+
+	var precondition *errdetails.PreconditionFailure
+	for _, detail := range st.Details() {
+		if d, ok := detail.(*errdetails.PreconditionFailure); ok {
+			precondition = d
+		}
+	}
+	if precondition == nil || len(precondition.Violations) != 1 {
+		t.Fatalf("Expected a PreconditionFailure detail, got %v", st.Details())
+	}
+	if violation := precondition.Violations[0]; violation.Type != "EVENT_STREAM" || violation.Subject != "challenge:c1" {
+		t.Errorf("Unexpected violation: %v", violation)
+	}
+}
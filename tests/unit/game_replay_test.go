@@ -0,0 +1,392 @@
+package unit
+
+import (
+	"context"
+	"encoding/json"
+	"math/rand"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/FlooooowY/SteelMount-Captcha-Service/internal/captcha"
+	"github.com/FlooooowY/SteelMount-Captcha-Service/internal/domain"
+	"github.com/FlooooowY/SteelMount-Captcha-Service/internal/repository"
+	"github.com/FlooooowY/SteelMount-Captcha-Service/internal/usecase"
+)
+
+// gameElapsed is the server time since the game was issued, long enough for every log here
+const gameElapsed = time.Hour
+
+func hasGameViolation(verdict *captcha.GameVerdict, violation string) bool {
+	for _, v := range verdict.Violations {
+		if v == violation {
+			return true
+		}
+	}
+	return false
+}
+
+// memoryLog clicks the given cells at uneven intervals once the sequence has been shown
+func memoryLog(answer *captcha.MemoryGameAnswer, cells []int) *captcha.GameSubmission {
+	t := float64(1000 + answer.Length*(answer.ShowTime+200) + 450)
+	submission := &captcha.GameSubmission{}
+	for i, cell := range cells {
+		submission.Log = append(submission.Log, captcha.GameInput{T: t, Cell: cell})
+		t += 280 + float64(i*53%110)
+	}
+	submission.Duration = t
+	return submission
+}
+
+// reactionLog starts the game and reacts to each signal after the given time
+func reactionLog(answer *captcha.ReactionGameAnswer, reactions []float64) *captcha.GameSubmission {
+	delays := answer.Delays()
+	t := 600.0
+	submission := &captcha.GameSubmission{Log: []captcha.GameInput{{T: t}}}
+	for i, reaction := range reactions {
+		delay := 2000
+		if i < len(delays) {
+			delay = delays[i]
+		}
+		t += float64(delay) + reaction
+		submission.Log = append(submission.Log, captcha.GameInput{T: t})
+	}
+	submission.Duration = t + 5
+	return submission
+}
+
+func TestGameGenerator_Generate(t *testing.T) {
+	generator := captcha.NewGameGenerator(400, 300)
+
+	seen := map[string]bool{}
+	for i := 0; i < 100 && len(seen) < 3; i++ {
+		game, raw, err := generator.Generate(rand.New(rand.NewSource(int64(i))), 60)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		answer, ok := raw.(captcha.GameAnswer)
+		if !ok {
+			t.Fatalf("Expected a GameAnswer, got %T", raw)
+		}
+		seen[game.GameType] = true
+
+		switch answer := answer.(type) {
+		case *captcha.SnakeGameAnswer:
+			data := game.GameData.(*captcha.SnakeGameData)
+			if data.Seed != answer.Seed || answer.Cols != 20 || answer.Rows != 15 || answer.Speed != 140 {
+				t.Errorf("Snake data %+v does not match answer %+v", data, answer)
+			}
+		case *captcha.MemoryGameAnswer:
+			data := game.GameData.(*captcha.MemoryGameData)
+			sequence := answer.Sequence()
+			if len(sequence) != 6 || len(data.Sequence) != len(sequence) {
+				t.Fatalf("Expected a 6 cell sequence, got %v", data.Sequence)
+			}
+			for i := range sequence {
+				if sequence[i] != data.Sequence[i] || sequence[i] < 0 || sequence[i] >= answer.Cells {
+					t.Errorf("Page sequence %v differs from replay %v", data.Sequence, sequence)
+				}
+			}
+		case *captcha.ReactionGameAnswer:
+			data := game.GameData.(*captcha.ReactionGameData)
+			if data.Seed != answer.Seed || answer.Rounds != 4 || answer.MaxReaction != 660 {
+				t.Errorf("Reaction data %+v does not match answer %+v", data, answer)
+			}
+		default:
+			t.Fatalf("Unexpected answer type %T", answer)
+		}
+
+		// A bare claim of victory without a log never passes
+		if verdict := answer.Verify(&captcha.GameSubmission{Success: true, Score: 8}, gameElapsed); verdict.Solved || verdict.Confidence != 0 {
+			t.Errorf("Expected an empty log to fail, got %+v", verdict)
+		}
+
+		html, err := generator.GenerateHTML(game)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if !strings.Contains(html, "function gameRandom(seed)") || !strings.Contains(html, "log: gameState.log") {
+			t.Errorf("Expected the %s page to seed its generator and submit the input log", game.GameType)
+		}
+	}
+
+	if len(seen) != 3 {
+		t.Errorf("Expected all game types, got %v", seen)
+	}
+}
+
+func TestSnakeGameAnswer_Verify(t *testing.T) {
+	// In a one row field the food is either left or right of the snake
+	solved := 0
+	for _, key := range []string{"ArrowLeft", "ArrowRight"} {
+		answer := &captcha.SnakeGameAnswer{Seed: 7, Cols: 3, Rows: 1, TargetFood: 1, Speed: 100}
+		verdict := answer.Verify(&captcha.GameSubmission{
+			Success:  true,
+			Score:    1,
+			Duration: 250,
+			Log:      []captcha.GameInput{{T: 45, Key: key}},
+		}, gameElapsed)
+		if verdict.Solved {
+			solved++
+			if verdict.Confidence != 100 || len(verdict.Violations) > 0 {
+				t.Errorf("Expected a clean win, got %+v", verdict)
+			}
+		} else if !hasGameViolation(verdict, captcha.GameViolationOutcomeMismatch) {
+			t.Errorf("Expected hitting the wall to contradict the reported win, got %+v", verdict)
+		}
+	}
+	if solved != 1 {
+		t.Errorf("Expected exactly one direction to reach the food, %d did", solved)
+	}
+
+	// The snake starts in the middle, moves down twice and then left into the wall
+	answer := &captcha.SnakeGameAnswer{Seed: 11, Cols: 10, Rows: 10, TargetFood: 7, Speed: 100}
+	crash := []captcha.GameInput{{T: 120, Key: "ArrowDown"}, {T: 260, Key: "ArrowLeft", Tick: 2}}
+
+	forged := answer.Verify(&captcha.GameSubmission{Success: true, Score: 7, Duration: 900, Log: crash}, gameElapsed)
+	if forged.Solved || !hasGameViolation(forged, captcha.GameViolationOutcomeMismatch) {
+		t.Fatalf("Expected a forged score to be rejected, got %+v", forged)
+	}
+	honest := answer.Verify(&captcha.GameSubmission{Score: forged.Score, Duration: 900, Log: crash}, gameElapsed)
+	if honest.Solved || len(honest.Violations) > 0 || honest.Confidence != int32(forged.Score*80/7) {
+		t.Errorf("Expected an honest crash to fail cleanly, got %+v", honest)
+	}
+
+	tests := []struct {
+		name      string
+		duration  float64
+		log       []captcha.GameInput
+		violation string
+	}{
+		{"no input", 900, nil, captcha.GameViolationEmptyLog},
+		{"faster than the game clock", 900, []captcha.GameInput{{T: 20, Key: "ArrowDown"}, {T: 30, Key: "ArrowLeft", Tick: 2}}, captcha.GameViolationTooFast},
+		{"game finished too early", 300, crash, captcha.GameViolationTooFast},
+		{"time goes back", 900, []captcha.GameInput{{T: 220, Key: "ArrowDown", Tick: 2}, {T: 210, Key: "ArrowLeft", Tick: 2}}, captcha.GameViolationTimestamps},
+		{"ticks go back", 900, []captcha.GameInput{{T: 320, Key: "ArrowDown", Tick: 3}, {T: 330, Key: "ArrowLeft", Tick: 2}}, captcha.GameViolationTimestamps},
+		{"unknown key", 900, []captcha.GameInput{{T: 50, Key: "KeyW"}}, captcha.GameViolationInvalidInput},
+		{"key after the crash", 2000, append(crash, captcha.GameInput{T: 1500, Key: "ArrowUp", Tick: 15}), captcha.GameViolationInputAfterEnd},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			verdict := answer.Verify(&captcha.GameSubmission{Score: forged.Score, Duration: tt.duration, Log: tt.log}, gameElapsed)
+			if verdict.Solved || verdict.Confidence != 0 || !hasGameViolation(verdict, tt.violation) {
+				t.Errorf("Expected %s, got %+v", tt.violation, verdict)
+			}
+		})
+	}
+
+	// Circling in place with a key exactly every tick is a script
+	var circle []captcha.GameInput
+	for tick, key := range []string{"ArrowUp", "ArrowLeft", "ArrowDown", "ArrowRight", "ArrowUp", "ArrowLeft", "ArrowDown", "ArrowRight"} {
+		circle = append(circle, captcha.GameInput{T: float64(tick*100 + 30), Key: key, Tick: tick})
+	}
+	scripted := answer.Verify(&captcha.GameSubmission{Duration: 5000, Log: circle}, gameElapsed)
+	if scripted.Solved || !hasGameViolation(scripted, captcha.GameViolationConstantTiming) {
+		t.Errorf("Expected constant key cadence to be flagged, got %+v", scripted)
+	}
+
+	// The page clock cannot run past the server's, a 30 s game posted 5 ms after issue is forged
+	stretched := answer.Verify(&captcha.GameSubmission{Score: forged.Score, Duration: 30000, Log: crash}, 5*time.Millisecond)
+	if stretched.Solved || !hasGameViolation(stretched, captcha.GameViolationClockMismatch) {
+		t.Errorf("Expected a game longer than the server saw to be rejected, got %+v", stretched)
+	}
+	if lagging := answer.Verify(&captcha.GameSubmission{Score: forged.Score, Duration: 900, Log: crash}, 0); len(lagging.Violations) > 0 {
+		t.Errorf("Expected transport delay to be allowed for, got %+v", lagging)
+	}
+}
+
+func TestMemoryGameAnswer_Verify(t *testing.T) {
+	answer := &captcha.MemoryGameAnswer{Seed: 2024, Cells: 16, Length: 5, ShowTime: 500}
+	sequence := answer.Sequence()
+	wrongCell := func(step int) int {
+		return (sequence[step] + 1) % answer.Cells
+	}
+
+	good := memoryLog(answer, sequence)
+	good.Success, good.Score = true, 5
+	if verdict := answer.Verify(good, gameElapsed); !verdict.Solved || verdict.Confidence != 100 {
+		t.Errorf("Expected the sequence to solve the game, got %+v", verdict)
+	}
+
+	// Two right cells then a wrong one is an honest failure with partial credit
+	mistake := memoryLog(answer, []int{sequence[0], sequence[1], wrongCell(2)})
+	mistake.Score = 2
+	if verdict := answer.Verify(mistake, gameElapsed); verdict.Solved || verdict.Confidence != 40 || len(verdict.Violations) > 0 {
+		t.Errorf("Expected partial credit for a mistake, got %+v", verdict)
+	}
+
+	early := memoryLog(answer, sequence)
+	early.Success, early.Score = true, 5
+	for i := range early.Log {
+		early.Log[i].T -= 1500
+	}
+
+	fast := memoryLog(answer, sequence)
+	fast.Success, fast.Score = true, 5
+	for i := range fast.Log {
+		fast.Log[i].T = fast.Log[0].T + float64(i*40)
+	}
+
+	metronome := memoryLog(answer, sequence)
+	metronome.Success, metronome.Score = true, 5
+	for i := range metronome.Log {
+		metronome.Log[i].T = metronome.Log[0].T + float64(i*300)
+	}
+
+	forged := memoryLog(answer, []int{sequence[0], sequence[1], wrongCell(2)})
+	forged.Success, forged.Score = true, 5
+
+	afterEnd := memoryLog(answer, []int{sequence[0], wrongCell(1), sequence[2]})
+	afterEnd.Score = 1
+
+	unfinished := memoryLog(answer, sequence[:3])
+	unfinished.Score = 3
+
+	invalid := memoryLog(answer, []int{sequence[0], 99})
+	invalid.Score = 1
+
+	tests := []struct {
+		name       string
+		submission *captcha.GameSubmission
+		violation  string
+	}{
+		{"clicks while the sequence is shown", early, captcha.GameViolationEarlyInput},
+		{"clicks faster than a person", fast, captcha.GameViolationTooFast},
+		{"even click cadence", metronome, captcha.GameViolationConstantTiming},
+		{"score the replay does not reach", forged, captcha.GameViolationOutcomeMismatch},
+		{"clicks after a mistake", afterEnd, captcha.GameViolationInputAfterEnd},
+		{"sequence not completed", unfinished, captcha.GameViolationUnfinished},
+		{"cell outside the grid", invalid, captcha.GameViolationInvalidInput},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			verdict := answer.Verify(tt.submission, gameElapsed)
+			if verdict.Solved || verdict.Confidence != 0 || !hasGameViolation(verdict, tt.violation) {
+				t.Errorf("Expected %s, got %+v", tt.violation, verdict)
+			}
+		})
+	}
+}
+
+func TestReactionGameAnswer_Verify(t *testing.T) {
+	answer := &captcha.ReactionGameAnswer{Seed: 99, Rounds: 3, MaxReaction: 700}
+	for _, delay := range answer.Delays() {
+		if delay < 1500 || delay >= 4000 {
+			t.Errorf("Delay %dms is outside the signal range", delay)
+		}
+	}
+
+	good := reactionLog(answer, []float64{231, 268, 247})
+	good.Success, good.Score = true, 3
+	if verdict := answer.Verify(good, gameElapsed); !verdict.Solved || verdict.Confidence < 90 {
+		t.Errorf("Expected human reactions to pass, got %+v", verdict)
+	}
+
+	// One slow round out of three is a plain failure
+	slow := reactionLog(answer, []float64{231, 1100, 247})
+	slow.Score = 2
+	if verdict := answer.Verify(slow, gameElapsed); verdict.Solved || verdict.Confidence != 46 || len(verdict.Violations) > 0 {
+		t.Errorf("Expected a slow round to fail without violations, got %+v", verdict)
+	}
+
+	// Clicking before the signal ends the game
+	falseStart := reactionLog(answer, []float64{231, -400})
+	falseStart.Score = 1
+	if verdict := answer.Verify(falseStart, gameElapsed); verdict.Solved || verdict.Confidence != 0 || len(verdict.Violations) > 0 {
+		t.Errorf("Expected a false start to fail without violations, got %+v", verdict)
+	}
+
+	// A client that reports its own reaction times gets nothing for it
+	claimed := reactionLog(answer, []float64{2500, 2600, 2400})
+	claimed.Success, claimed.Score = true, 3
+
+	anticipated := reactionLog(answer, []float64{231, 40, 247})
+	anticipated.Score = 2
+
+	robotic := reactionLog(answer, []float64{180, 181, 180})
+	robotic.Success, robotic.Score = true, 3
+
+	extra := reactionLog(answer, []float64{231, 268, 247, 250})
+	extra.Success, extra.Score = true, 3
+
+	unfinished := reactionLog(answer, []float64{231, 268})
+	unfinished.Score = 2
+
+	tests := []struct {
+		name       string
+		submission *captcha.GameSubmission
+		violation  string
+	}{
+		{"reported success the replay does not reach", claimed, captcha.GameViolationOutcomeMismatch},
+		{"click anticipating the signal", anticipated, captcha.GameViolationTooFast},
+		{"identical reaction times", robotic, captcha.GameViolationConstantTiming},
+		{"more clicks than rounds", extra, captcha.GameViolationInputAfterEnd},
+		{"rounds missing", unfinished, captcha.GameViolationUnfinished},
+		{"only the start click", &captcha.GameSubmission{Log: []captcha.GameInput{{T: 600}}, Duration: 700}, captcha.GameViolationEmptyLog},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			verdict := answer.Verify(tt.submission, gameElapsed)
+			if verdict.Solved || verdict.Confidence != 0 || !hasGameViolation(verdict, tt.violation) {
+				t.Errorf("Expected %s, got %+v", tt.violation, verdict)
+			}
+		})
+	}
+}
+
+func TestCaptchaUsecase_GameAnswerFromJSON(t *testing.T) {
+	repo := repository.NewInMemoryChallengeRepository()
+	captchaUsecase := usecase.NewCaptchaUsecase(repo, &usecase.Config{
+		MaxActiveChallenges: 10,
+		ChallengeTimeout:    time.Minute,
+		CleanupInterval:     time.Minute,
+	})
+
+	answer := &captcha.MemoryGameAnswer{Seed: 31337, Cells: 16, Length: 4, ShowTime: 500}
+	submission := memoryLog(answer, answer.Sequence())
+	submission.Success, submission.Score = true, 4
+
+	// Answers arrive as decoded JSON, like from an event stream or the gateway
+	data, err := json.Marshal(submission)
+	if err != nil {
+		t.Fatalf("Failed to marshal submission: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		answer string
+		issued time.Duration // Before the answer arrives
+		solved bool
+	}{
+		{"replayed log", string(data), time.Minute, true},
+		{"bare score", `{"score": 4, "success": true}`, time.Minute, false},
+		{"replayed log right after issue", string(data), 0, false},
+	}
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			challenge := &domain.Challenge{
+				ID:        "game-" + string(rune('a'+i)),
+				Type:      domain.ChallengeTypeGame,
+				Answer:    answer,
+				CreatedAt: time.Now().Add(-tt.issued),
+				ExpiresAt: time.Now().Add(time.Minute),
+			}
+			if err := repo.Create(context.Background(), challenge); err != nil {
+				t.Fatalf("Failed to store challenge: %v", err)
+			}
+
+			var decoded interface{}
+			if err := json.Unmarshal([]byte(tt.answer), &decoded); err != nil {
+				t.Fatalf("Failed to decode answer: %v", err)
+			}
+
+			result, err := captchaUsecase.ValidateChallenge(context.Background(), challenge.ID, decoded)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if result.Solved != tt.solved {
+				t.Errorf("Expected solved=%v, got %+v", tt.solved, result)
+			}
+		})
+	}
+}
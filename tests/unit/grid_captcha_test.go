@@ -0,0 +1,127 @@
+package unit
+
+import (
+	"context"
+	"encoding/json"
+	"math/rand"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/FlooooowY/SteelMount-Captcha-Service/internal/captcha"
+	"github.com/FlooooowY/SteelMount-Captcha-Service/internal/domain"
+	"github.com/FlooooowY/SteelMount-Captcha-Service/internal/repository"
+	"github.com/FlooooowY/SteelMount-Captcha-Service/internal/usecase"
+)
+
+func TestGridGenerator_Generate(t *testing.T) {
+	generator := captcha.NewGridGenerator(400, 300, 3, 4)
+
+	for _, tt := range []struct {
+		complexity int32
+		size       int
+	}{
+		{complexity: 10, size: 3},
+		{complexity: 90, size: 4},
+	} {
+		grid, raw, err := generator.Generate(rand.New(rand.NewSource(1)), tt.complexity)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		answer, ok := raw.(*captcha.GridAnswer)
+		if !ok {
+			t.Fatalf("Expected *GridAnswer, got %T", raw)
+		}
+
+		if grid.Rows != tt.size || grid.Cols != tt.size || answer.TotalTiles != tt.size*tt.size {
+			t.Errorf("Complexity %d: expected %dx%d grid, got %dx%d", tt.complexity, tt.size, tt.size, grid.Rows, grid.Cols)
+		}
+		if len(answer.Tiles) == 0 || len(answer.Tiles) >= answer.TotalTiles {
+			t.Errorf("Expected some but not all tiles to hold the target, got %v", answer.Tiles)
+		}
+		for i, tile := range answer.Tiles {
+			if tile < 0 || tile >= answer.TotalTiles || (i > 0 && tile <= answer.Tiles[i-1]) {
+				t.Errorf("Expected ascending tile indices in range, got %v", answer.Tiles)
+			}
+		}
+		if !strings.Contains(grid.Instructions, answer.Target) {
+			t.Errorf("Expected instructions to name the target %s: %s", answer.Target, grid.Instructions)
+		}
+
+		// The image is a real raster of the whole grid
+		img := decodePNG(t, grid.Image)
+		if width := img.Bounds().Dx(); width != tt.size*grid.TileSize {
+			t.Errorf("Expected %dpx wide image, got %d", tt.size*grid.TileSize, width)
+		}
+
+		// The solution never reaches the client
+		html, err := generator.GenerateHTML(grid)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if strings.Contains(html, `"tiles"`) {
+			t.Errorf("HTML must not contain the solution")
+		}
+	}
+
+	if _, _, err := captcha.NewGridGenerator(60, 60, 3, 4).Generate(rand.New(rand.NewSource(1)), 50); err == nil {
+		t.Errorf("Expected a too small canvas to be rejected")
+	}
+}
+
+func TestCaptchaUsecase_GridPartialCredit(t *testing.T) {
+	repo := repository.NewInMemoryChallengeRepository()
+	captchaUsecase := usecase.NewCaptchaUsecase(repo, &usecase.Config{
+		MaxActiveChallenges: 10,
+		ChallengeTimeout:    time.Minute,
+		CleanupInterval:     time.Minute,
+	})
+
+	// Answers arrive as decoded JSON
+	decode := func(tiles string) interface{} {
+		var answer interface{}
+		if err := json.Unmarshal([]byte(tiles), &answer); err != nil {
+			t.Fatalf("Invalid answer: %v", err)
+		}
+		return answer
+	}
+
+	tests := []struct {
+		name       string
+		answer     interface{}
+		solved     bool
+		confidence int32
+	}{
+		{name: "exact selection", answer: decode(`[7, 2, 4]`), solved: true, confidence: 100},
+		{name: "duplicates ignored", answer: decode(`[2, 2, 4, 7]`), solved: true, confidence: 100},
+		{name: "one tile missed", answer: decode(`[2, 4]`), confidence: 66},
+		{name: "one extra tile", answer: decode(`[2, 4, 7, 8]`), confidence: 75},
+		{name: "all wrong", answer: decode(`[0, 1]`), confidence: 0},
+		{name: "fractional index", answer: decode(`[2.5]`), confidence: 0},
+		{name: "not a list", answer: "2,4,7", confidence: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			challenge := &domain.Challenge{
+				ID:        "grid-" + strings.ReplaceAll(tt.name, " ", "-"),
+				Type:      domain.ChallengeTypeGrid,
+				Answer:    &captcha.GridAnswer{Target: "triangle", Tiles: []int{2, 4, 7}, TotalTiles: 9},
+				CreatedAt: time.Now(),
+				ExpiresAt: time.Now().Add(time.Minute),
+			}
+			if err := repo.Create(context.Background(), challenge); err != nil {
+				t.Fatalf("Failed to store challenge: %v", err)
+			}
+
+			result, err := captchaUsecase.ValidateChallenge(context.Background(), challenge.ID, tt.answer)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if result.Solved != tt.solved || result.ConfidencePercent != tt.confidence {
+				t.Errorf("Expected solved=%v confidence=%d, got solved=%v confidence=%d",
+					tt.solved, tt.confidence, result.Solved, result.ConfidencePercent)
+			}
+		})
+	}
+}
@@ -0,0 +1,28 @@
+package unit
+
+import (
+	"bytes"
+	"encoding/base64"
+	"image"
+	"image/png"
+	"strings"
+	"testing"
+)
+
+// decodePNG decodes a PNG data URL produced by an image generator
+func decodePNG(t *testing.T, dataURL string) image.Image {
+	t.Helper()
+
+	if !strings.HasPrefix(dataURL, "data:image/png;base64,") {
+		t.Fatalf("Expected a PNG data URL, got %.40q", dataURL)
+	}
+	data, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(dataURL, "data:image/png;base64,"))
+	if err != nil {
+		t.Fatalf("Invalid image encoding: %v", err)
+	}
+	img, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("Invalid PNG: %v", err)
+	}
+	return img
+}
@@ -0,0 +1,183 @@
+package unit
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	"github.com/FlooooowY/SteelMount-Captcha-Service/internal/monitoring"
+	"github.com/FlooooowY/SteelMount-Captcha-Service/internal/security"
+)
+
+func TestOffenderTracker_FindsHeavyHittersAmongNoise(t *testing.T) {
+	config := &monitoring.OffenderConfig{Capacity: 20, Window: time.Hour}
+	tracker := monitoring.NewOffenderTrackerWithConfig(config)
+
+	// Three attackers hidden among 10000 addresses seen once each
+	heavy := map[string]int{"203.0.113.1": 3000, "203.0.113.2": 2000, "203.0.113.3": 1000}
+	for i := 0; i < 10000; i++ {
+		tracker.Record(monitoring.OffenseRateLimited, fmt.Sprintf("10.%d.%d.%d", i>>16&0xff, i>>8&0xff, i&0xff))
+		for ip, count := range heavy {
+			if i < count {
+				tracker.Record(monitoring.OffenseRateLimited, ip)
+			}
+		}
+	}
+
+	top := tracker.Top(monitoring.OffenseRateLimited, 3)
+	if len(top) != 3 {
+		t.Fatalf("Expected 3 offenders, got %d", len(top))
+	}
+	for i, ip := range []string{"203.0.113.1", "203.0.113.2", "203.0.113.3"} {
+		if top[i].Key != ip {
+			t.Errorf("Expected %s at rank %d, got %s", ip, i+1, top[i].Key)
+		}
+		// The sketch never undercounts and bounds its overestimate
+		if top[i].Count < int64(heavy[ip]) || top[i].Count-top[i].Error > int64(heavy[ip]) {
+			t.Errorf("Count %d (error %d) does not bound true count %d of %s", top[i].Count, top[i].Error, heavy[ip], ip)
+		}
+	}
+
+	stats := tracker.GetStats()
+	if tracked := stats["tracked"].(map[string]int)[monitoring.OffenseRateLimited]; tracked != 20 {
+		t.Errorf("Expected memory bounded to 20 counters, got %d", tracked)
+	}
+}
+
+func TestOffenderTracker_RotatesWindows(t *testing.T) {
+	now := time.Unix(1000, 0)
+	tracker := monitoring.NewOffenderTrackerWithClock(&monitoring.OffenderConfig{Capacity: 10, Window: time.Minute},
+		func() time.Time { return now })
+
+	tracker.Record(monitoring.OffenseBot, "198.51.100.7")
+	now = now.Add(time.Minute)
+
+	snapshot := tracker.Snapshot(10)
+	if len(snapshot.Current.Categories) != 0 {
+		t.Errorf("Expected empty current window, got %v", snapshot.Current.Categories)
+	}
+	if snapshot.Previous == nil || len(snapshot.Previous.Categories[monitoring.OffenseBot]) != 1 {
+		t.Fatalf("Expected previous window to keep the offender, got %+v", snapshot.Previous)
+	}
+
+	// After two idle windows nothing is left
+	now = now.Add(2 * time.Minute)
+	if snapshot := tracker.Snapshot(10); snapshot.Previous != nil {
+		t.Errorf("Expected previous window to be dropped, got %+v", snapshot.Previous)
+	}
+}
+
+func TestOffenderTracker_Handler(t *testing.T) {
+	tracker := monitoring.NewOffenderTracker()
+	for i := 0; i < 5; i++ {
+		tracker.Record(monitoring.OffenseBlocked, "192.0.2.1")
+	}
+	tracker.Record(monitoring.OffenseBlocked, "192.0.2.2")
+
+	server := httptest.NewServer(tracker.Handler())
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "?limit=1")
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	var snapshot monitoring.OffenderSnapshot
+	if err := json.NewDecoder(resp.Body).Decode(&snapshot); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	blocked := snapshot.Current.Categories[monitoring.OffenseBlocked]
+	if len(blocked) != 1 || blocked[0].Key != "192.0.2.1" || blocked[0].Count != 5 {
+		t.Errorf("Expected 192.0.2.1 with 5 offenses, got %+v", blocked)
+	}
+
+	resp, err = http.Get(server.URL + "?limit=abc")
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("Expected 400 for invalid limit, got %d", resp.StatusCode)
+	}
+}
+
+func TestNetworkClassifier_Buckets(t *testing.T) {
+	classifier, err := monitoring.NewNetworkClassifier(map[string][]string{
+		"as64500":    {"203.0.113.0/24"},
+		"as64500-ru": {"203.0.113.128/25"},
+		"as64501":    {"2001:db8::/32"},
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	cases := map[string]string{
+		"203.0.113.5":           "as64500",
+		"203.0.113.200":         "as64500-ru", // Longest prefix wins
+		"::ffff:203.0.113.5":    "as64500",
+		"[2001:db8::1]:443":     "as64501",
+		"198.51.100.1":          monitoring.NetworkPublicIPv4,
+		"2606:4700::1":          monitoring.NetworkPublicIPv6,
+		"10.1.2.3":              monitoring.NetworkPrivate,
+		"127.0.0.1":             monitoring.NetworkLoopback,
+		"not-an-ip":             monitoring.NetworkInvalid,
+		"192.168.1.1:8080":      monitoring.NetworkPrivate,
+		"fe80::1":               monitoring.NetworkPrivate,
+		"::1":                   monitoring.NetworkLoopback,
+		"unknown, 203.0.113.10": monitoring.NetworkInvalid,
+	}
+	for ip, expected := range cases {
+		if bucket := classifier.Classify(ip); bucket != expected {
+			t.Errorf("Classify(%q) = %s, expected %s", ip, bucket, expected)
+		}
+	}
+
+	if _, err := monitoring.NewNetworkClassifier(map[string][]string{"bad": {"300.0.0.0/8"}}); err == nil {
+		t.Errorf("Expected invalid CIDR to be rejected")
+	}
+}
+
+func TestSecurityRecorder_BoundedLabels(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	metrics := monitoring.NewMetricsWithRegistry(registry)
+	classifier, err := monitoring.NewNetworkClassifier(map[string][]string{"as64500": {"203.0.113.0/24"}})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	tracker := monitoring.NewOffenderTracker()
+
+	var observer security.Observer = monitoring.NewSecurityRecorder(metrics, classifier, tracker)
+
+	// An attack from 1000 addresses adds a handful of series, not one per IP
+	for i := 0; i < 1000; i++ {
+		observer.RecordRateLimited(fmt.Sprintf("203.0.113.%d", i%256), "/captcha.v1.CaptchaService/NewChallenge")
+		observer.RecordRateLimited(fmt.Sprintf("198.51.%d.%d", i/256, i%256), "/ws")
+		observer.RecordBotDetected(fmt.Sprintf("198.51.%d.%d", i/256, i%256), security.SignalUserAgent)
+	}
+	observer.RecordBlockedRequest("192.0.2.1", "/ws")
+
+	if series := testutil.CollectAndCount(metrics.RateLimitHits); series != 2 {
+		t.Errorf("Expected 2 rate limit series, got %d", series)
+	}
+	if series := testutil.CollectAndCount(metrics.BotDetections); series != 1 {
+		t.Errorf("Expected 1 bot detection series, got %d", series)
+	}
+	if hits := testutil.ToFloat64(metrics.RateLimitHits.WithLabelValues("as64500", "/captcha.v1.CaptchaService/NewChallenge")); hits != 1000 {
+		t.Errorf("Expected 1000 hits from as64500, got %v", hits)
+	}
+	if hits := testutil.ToFloat64(metrics.BotDetections.WithLabelValues(monitoring.NetworkPublicIPv4, security.SignalUserAgent)); hits != 1000 {
+		t.Errorf("Expected 1000 bot detections, got %v", hits)
+	}
+
+	// Individual addresses are still available from the tracker
+	if blocked := tracker.Top(monitoring.OffenseBlocked, 10); len(blocked) != 1 || blocked[0].Key != "192.0.2.1" {
+		t.Errorf("Expected blocked offender to be tracked, got %+v", blocked)
+	}
+}
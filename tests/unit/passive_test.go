@@ -0,0 +1,212 @@
+package unit
+
+import (
+	"context"
+	"encoding/json"
+	"math"
+	"math/rand"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/FlooooowY/SteelMount-Captcha-Service/internal/captcha"
+	"github.com/FlooooowY/SteelMount-Captcha-Service/internal/domain"
+	"github.com/FlooooowY/SteelMount-Captcha-Service/internal/usecase"
+)
+
+// browserReport returns the signals of an ordinary browser for probe
+func browserReport(probe *captcha.ProbeAnswer) *captcha.ProbeReport {
+	intervals := make([]float64, probe.Frames)
+	for i := range intervals {
+		intervals[i] = 16.6 + float64(i%3)*0.4
+	}
+
+	return &captcha.ProbeReport{
+		Nonce:               probe.Nonce,
+		UserAgent:           "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36",
+		Languages:           2,
+		Plugins:             3,
+		HardwareConcurrency: 8,
+		ScreenWidth:         1920,
+		ScreenHeight:        1080,
+		Timezone:            "Europe/Moscow",
+		DwellMs:             400,
+		FrameIntervals:      intervals,
+	}
+}
+
+func TestProbeGenerator_Generate(t *testing.T) {
+	generator := captcha.NewProbeGenerator()
+
+	probe, answer, err := generator.Generate(rand.New(rand.NewSource(42)), 50)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	probeAnswer := answer.(*captcha.ProbeAnswer)
+	if probe.Nonce == "" || probe.Nonce != probeAnswer.Nonce || probe.Frames != probeAnswer.Frames {
+		t.Fatalf("Expected the page to carry the answer's nonce, got %+v and %+v", probe, probeAnswer)
+	}
+
+	html, err := generator.GenerateHTML(probe)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !strings.Contains(html, "probe_signals") || !strings.Contains(html, probe.Nonce) {
+		t.Errorf("Expected the page to report probe signals with its nonce")
+	}
+}
+
+func TestProbeAnswer_Evaluate(t *testing.T) {
+	_, answer, _ := captcha.NewProbeGenerator().Generate(rand.New(rand.NewSource(1)), 0)
+	probe := answer.(*captcha.ProbeAnswer)
+
+	tests := []struct {
+		name    string
+		modify  func(report *captcha.ProbeReport)
+		elapsed time.Duration
+		risk    float64
+		reason  string
+	}{
+		{"browser", func(report *captcha.ProbeReport) {}, 500 * time.Millisecond, 0, ""},
+		{"foreign nonce", func(report *captcha.ProbeReport) { report.Nonce = "replayed" }, 500 * time.Millisecond, 1, captcha.ProbeReasonNonceMismatch},
+		{"webdriver", func(report *captcha.ProbeReport) { report.Webdriver = true }, 500 * time.Millisecond, 0.6, captcha.ProbeReasonWebdriver},
+		{"headless", func(report *captcha.ProbeReport) { report.UserAgent = "Mozilla/5.0 HeadlessChrome/120.0" }, 500 * time.Millisecond, 0.6, captcha.ProbeReasonHeadlessAgent},
+		{"too fast", func(report *captcha.ProbeReport) {}, 50 * time.Millisecond, 0.3, captcha.ProbeReasonTooFast},
+		{"claimed dwell", func(report *captcha.ProbeReport) { report.DwellMs = 10000 }, 500 * time.Millisecond, 0.3, captcha.ProbeReasonClockMismatch},
+		{"empty environment", func(report *captcha.ProbeReport) { report.Plugins, report.Languages = 0, 0 }, 500 * time.Millisecond, 0.2, captcha.ProbeReasonEmptyEnvironment},
+		{"no screen", func(report *captcha.ProbeReport) { report.ScreenWidth = 0 }, 500 * time.Millisecond, 0.2, captcha.ProbeReasonNoScreen},
+		{"no frames", func(report *captcha.ProbeReport) { report.FrameIntervals = nil }, 500 * time.Millisecond, 0.2, captcha.ProbeReasonNoFrames},
+		{"uniform frames", func(report *captcha.ProbeReport) {
+			for i := range report.FrameIntervals {
+				report.FrameIntervals[i] = 16
+			}
+		}, 500 * time.Millisecond, 0.2, captcha.ProbeReasonUniformFrames},
+		{"everything", func(report *captcha.ProbeReport) {
+			report.Webdriver = true
+			report.UserAgent = "HeadlessChrome"
+			report.FrameIntervals = nil
+		}, 500 * time.Millisecond, 1, captcha.ProbeReasonWebdriver},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report := browserReport(probe)
+			tt.modify(report)
+
+			verdict := probe.Evaluate(report, tt.elapsed)
+			if math.Abs(verdict.Risk-tt.risk) > 1e-9 {
+				t.Errorf("Expected risk %v, got %+v", tt.risk, verdict)
+			}
+			if tt.reason == "" && len(verdict.Reasons) != 0 || tt.reason != "" && (len(verdict.Reasons) == 0 || verdict.Reasons[0] != tt.reason) {
+				t.Errorf("Expected reason %q, got %v", tt.reason, verdict.Reasons)
+			}
+		})
+	}
+}
+
+func TestCaptchaUsecase_PassivePass(t *testing.T) {
+	ctx := context.Background()
+	var answers []bool
+	captchaUsecase := newAutoUsecase(0.1, &answers)
+
+	challenge, err := captchaUsecase.CreateChallengeWithOptions(ctx, 50, domain.ChallengeOptions{Passive: true})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if challenge.Type != domain.ChallengeTypePassive || challenge.Metadata["passive"] != "true" {
+		t.Fatalf("Expected a passive probe, got %s with %v", challenge.Type, challenge.Metadata)
+	}
+
+	// The probe is only answered over the event stream
+	result, err := captchaUsecase.ValidateChallenge(ctx, challenge.ID, "anything")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if result.Solved || result.Error != domain.ResultErrorStagesRequired {
+		t.Fatalf("Expected a single answer to be refused, got %+v", result)
+	}
+
+	// A browser needs its frames, reports sooner count as too fast
+	time.Sleep(300 * time.Millisecond)
+	events := submitProbe(t, captchaUsecase, challenge.ID, browserReport(challenge.Answer.(*captcha.ProbeAnswer)))
+	if len(events) != 1 || events[0].Type != domain.ServerEventTypeChallengeResult || !events[0].Solved {
+		t.Fatalf("Expected a pass without a puzzle, got %+v", events)
+	}
+
+	stored, _ := captchaUsecase.GetChallenge(ctx, challenge.ID)
+	if !stored.Solved || stored.Metadata["passive_verdict"] != "pass" || stored.Metadata["probe_risk"] != "0.00" || stored.Metadata["risk_tier"] != "low" {
+		t.Errorf("Unexpected metadata %v", stored.Metadata)
+	}
+
+	// The probe is not an answer to a puzzle
+	if len(answers) != 0 {
+		t.Errorf("Expected the probe not to reach the answer hook, got %v", answers)
+	}
+}
+
+func TestCaptchaUsecase_PassiveEscalation(t *testing.T) {
+	ctx := context.Background()
+	var answers []bool
+	captchaUsecase := newAutoUsecase(0.1, &answers)
+
+	challenge, err := captchaUsecase.CreateChallengeWithOptions(ctx, 50, domain.ChallengeOptions{Passive: true, KeyboardOnly: true})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	// A trusted caller driving a webdriver browser gets the hardest tier
+	report := browserReport(challenge.Answer.(*captcha.ProbeAnswer))
+	report.Webdriver = true
+	events := submitProbe(t, captchaUsecase, challenge.ID, report)
+	if len(events) != 2 || events[0].Type != domain.ServerEventTypeSendClientData || events[1].Type != domain.ServerEventTypeRunClientJS {
+		t.Fatalf("Expected a visible challenge pushed, got %+v", events)
+	}
+
+	var started map[string]interface{}
+	if err := json.Unmarshal(events[0].Data, &started); err != nil {
+		t.Fatalf("Failed to decode stage data: %v", err)
+	}
+	if started["reason"] != domain.StageReasonEscalated || started["challenge_type"] != string(domain.ChallengeTypeText) || started["stage"] != float64(2) {
+		t.Fatalf("Expected an escalated text stage, got %v", started)
+	}
+
+	stored, _ := captchaUsecase.GetChallenge(ctx, challenge.ID)
+	if stored.Metadata["passive_verdict"] != "escalated" || stored.Metadata["risk_tier"] != "high" || stored.MinStages() != 2 || stored.CurrentStage().Complexity != 82 {
+		t.Fatalf("Unexpected metadata %v", stored.Metadata)
+	}
+
+	// The probe is answered once
+	data, _ := json.Marshal(map[string]interface{}{"type": "probe_signals", "signals": report})
+	if _, err := captchaUsecase.ProcessEvent(ctx, &domain.Event{Type: domain.EventTypeFrontendEvent, ChallengeID: challenge.ID, Data: data}); err == nil {
+		t.Fatalf("Expected a second probe report to be refused")
+	}
+
+	// High risk takes both rounds, the probe is not one of them
+	events = submitStageAnswer(t, captchaUsecase, stored.ID, stored.CurrentStage().Answer.(*captcha.TextAnswer).Text)
+	if err := json.Unmarshal(events[0].Data, &started); err != nil {
+		t.Fatalf("Failed to decode stage data: %v", err)
+	}
+	if started["reason"] != domain.StageReasonRequired {
+		t.Fatalf("Expected a required second round, got %v", started)
+	}
+
+	stored, _ = captchaUsecase.GetChallenge(ctx, challenge.ID)
+	events = submitStageAnswer(t, captchaUsecase, stored.ID, stored.CurrentStage().Answer.(*captcha.TextAnswer).Text)
+	if len(events) != 1 || events[0].Type != domain.ServerEventTypeChallengeResult || !events[0].Solved {
+		t.Fatalf("Expected the challenge solved after two rounds, got %+v", events)
+	}
+	if len(answers) != 2 {
+		t.Errorf("Expected the two rounds at the answer hook, got %v", answers)
+	}
+}
+
+func submitProbe(t *testing.T, captchaUsecase usecase.CaptchaUsecase, challengeID string, report *captcha.ProbeReport) []*domain.ServerEvent {
+	t.Helper()
+
+	data, _ := json.Marshal(map[string]interface{}{"type": "probe_signals", "signals": report})
+	events, err := captchaUsecase.ProcessEvent(context.Background(), &domain.Event{Type: domain.EventTypeFrontendEvent, ChallengeID: challengeID, Data: data})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	return events
+}
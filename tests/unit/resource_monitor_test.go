@@ -0,0 +1,152 @@
+package unit
+
+import (
+	"context"
+	"errors"
+	"os"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	"github.com/FlooooowY/SteelMount-Captcha-Service/internal/domain"
+	"github.com/FlooooowY/SteelMount-Captcha-Service/internal/monitoring"
+	"github.com/FlooooowY/SteelMount-Captcha-Service/internal/repository"
+	"github.com/FlooooowY/SteelMount-Captcha-Service/internal/usecase"
+)
+
+func TestRateWindow_SlidingRate(t *testing.T) {
+	now := time.Unix(1000, 0)
+	window := monitoring.NewRateWindowWithClock(10*time.Second, func() time.Time { return now })
+
+	// 50 events in each of 4 seconds
+	for second := 0; second < 4; second++ {
+		for i := 0; i < 50; i++ {
+			window.Observe()
+		}
+		now = now.Add(time.Second)
+	}
+
+	// The current, still empty second is excluded
+	if rate := window.Rate(); rate != 20 {
+		t.Errorf("Expected 200 events over 10s = 20/s, got %v", rate)
+	}
+
+	// Events in the current second do not count until it completes
+	window.Add(100)
+	if rate := window.Rate(); rate != 20 {
+		t.Errorf("Expected partial second to be excluded, got %v", rate)
+	}
+
+	// Ten completed seconds later the window is empty again
+	now = now.Add(11 * time.Second)
+	if rate := window.Rate(); rate != 0 {
+		t.Errorf("Expected expired window to report 0, got %v", rate)
+	}
+}
+
+func TestProcessStats_ParseProcStat(t *testing.T) {
+	// Command names may contain spaces and parentheses
+	stat := "4242 (captcha (svc) x) S 1 4242 4242 0 -1 4194560 1000 0 0 0 250 50 0 0 20 0 12 0 100 1000000 512 18446744073709551615"
+	start := time.Unix(100, 0)
+
+	stats, err := monitoring.ParseProcStat(stat, start)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if stats.CPUTime != 3*time.Second {
+		t.Errorf("Expected 300 ticks = 3s CPU time, got %v", stats.CPUTime)
+	}
+	if stats.ResidentBytes != 512*int64(os.Getpagesize()) {
+		t.Errorf("Expected 512 resident pages, got %d bytes", stats.ResidentBytes)
+	}
+
+	// 1.5s of CPU over 1s of wall time is 150% of one core
+	later := &monitoring.ProcessStats{CPUTime: stats.CPUTime + 1500*time.Millisecond, Time: start.Add(time.Second)}
+	if cpu := monitoring.CPUPercent(stats, later); cpu != 150 {
+		t.Errorf("Expected 150%%, got %v", cpu)
+	}
+
+	if _, err := monitoring.ParseProcStat("garbage", start); err == nil {
+		t.Errorf("Expected malformed stat to fail")
+	}
+}
+
+func TestResourceMonitor_MemoryLimitRejectsNewChallenges(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	metrics := monitoring.NewMetricsWithRegistry(registry)
+
+	config := monitoring.DefaultResourceMonitorConfig()
+	config.MemoryLimitBytes = 1 // Any running process is above it
+	config.SetGoMemoryLimit = false
+	monitor := monitoring.NewResourceMonitorWithConfig(metrics, config)
+	monitor.Sample()
+
+	if monitor.MemoryUsage() <= 0 {
+		t.Fatalf("Expected memory usage to be sampled, got %d", monitor.MemoryUsage())
+	}
+
+	captchaUsecase := usecase.NewCaptchaUsecase(repository.NewInMemoryChallengeRepository(), &usecase.Config{
+		MaxActiveChallenges: 100,
+		ChallengeTimeout:    time.Minute,
+		CleanupInterval:     time.Minute,
+		Admit:               monitor.AdmitChallenge,
+	})
+
+	_, err := captchaUsecase.CreateChallenge(context.Background(), 50)
+	if !errors.Is(err, domain.ErrCapacity) {
+		t.Fatalf("Expected capacity error, got %v", err)
+	}
+	domainErr, _ := domain.AsError(err)
+	if domainErr.Reason != domain.ReasonMemoryPressure || domainErr.RetryAfter == 0 {
+		t.Errorf("Expected MEMORY_PRESSURE with retry hint, got %+v", domainErr)
+	}
+	if rejections := testutil.ToFloat64(metrics.MemoryRejections); rejections != 1 {
+		t.Errorf("Expected 1 recorded rejection, got %v", rejections)
+	}
+
+	// Below the watermark challenges are admitted
+	config = monitoring.DefaultResourceMonitorConfig()
+	config.MemoryLimitBytes = 1 << 50
+	config.SetGoMemoryLimit = false
+	roomy := monitoring.NewResourceMonitorWithConfig(monitoring.NewMetricsWithRegistry(prometheus.NewRegistry()), config)
+	roomy.Sample()
+	if err := roomy.AdmitChallenge(context.Background()); err != nil {
+		t.Errorf("Expected challenge to be admitted, got %v", err)
+	}
+}
+
+func TestResourceMonitor_SampleFeedsMetrics(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	metrics := monitoring.NewMetricsWithRegistry(registry)
+	if err := monitoring.RegisterRuntimeCollectors(registry); err != nil {
+		t.Fatalf("Failed to register runtime collectors: %v", err)
+	}
+
+	monitor := monitoring.NewResourceMonitor(metrics)
+	monitor.Sample()
+
+	if memory := testutil.ToFloat64(metrics.MemoryUsage); memory <= 0 {
+		t.Errorf("Expected memory gauge to be set, got %v", memory)
+	}
+
+	families, err := registry.Gather()
+	if err != nil {
+		t.Fatalf("Gather failed: %v", err)
+	}
+	names := map[string]bool{}
+	for _, family := range families {
+		names[family.GetName()] = true
+	}
+	expected := []string{"go_goroutines", "go_memstats_heap_alloc_bytes", "captcha_requests_per_second"}
+	if runtime.GOOS == "linux" {
+		expected = append(expected, "process_cpu_seconds_total")
+	}
+	for _, name := range expected {
+		if !names[name] {
+			t.Errorf("Expected %s on the custom registry", name)
+		}
+	}
+}
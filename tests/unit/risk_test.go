@@ -0,0 +1,237 @@
+package unit
+
+import (
+	"context"
+	"encoding/json"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/FlooooowY/SteelMount-Captcha-Service/internal/captcha"
+	"github.com/FlooooowY/SteelMount-Captcha-Service/internal/domain"
+	"github.com/FlooooowY/SteelMount-Captcha-Service/internal/repository"
+	"github.com/FlooooowY/SteelMount-Captcha-Service/internal/security"
+	"github.com/FlooooowY/SteelMount-Captcha-Service/internal/usecase"
+)
+
+func newRiskSecurityService() *security.SecurityService {
+	return security.NewSecurityService(nil, &security.SecurityConfig{
+		RateLimitConfig: security.RateLimitConfig{
+			Enabled:           true,
+			RequestsPerMinute: 1000,
+			Window:            time.Minute,
+		},
+		IPBlockingConfig: security.IPBlockingConfig{
+			Enabled:           true,
+			MaxFailedAttempts: 10,
+			BlockDuration:     time.Hour,
+		},
+	})
+}
+
+func TestSecurityService_AssessRisk(t *testing.T) {
+	securityService := newRiskSecurityService()
+	ctx := context.Background()
+	ip := "10.0.0.1"
+
+	// An unseen client only carries the neutral distrust, 0.25 * 0.5
+	assessment := securityService.AssessRisk(ctx, ip)
+	if math.Abs(assessment.Score-0.125) > 1e-9 || assessment.TrustScore != 0.5 || len(assessment.Reasons) != 0 {
+		t.Fatalf("Unexpected risk of an unseen client: %+v", assessment)
+	}
+	if unknown := securityService.AssessRisk(ctx, ""); unknown.Score != assessment.Score {
+		t.Errorf("Expected an unknown client to score like an unseen one, got %v", unknown.Score)
+	}
+
+	// Five failed challenges saturate the failure signal, solved ones do not count
+	for i := 0; i < 5; i++ {
+		securityService.RecordChallengeResult(ip, false, time.Second)
+		securityService.RecordChallengeResult(ip, true, time.Second)
+	}
+	assessment = securityService.AssessRisk(ctx, ip)
+	if assessment.ChallengeFailures != 5 || math.Abs(assessment.Score-0.325) > 1e-9 {
+		t.Fatalf("Expected failures to add 0.2, got %+v", assessment)
+	}
+
+	// A block counts fully while it lasts and as history afterwards
+	if err := securityService.BlockIP(ctx, ip, "test", time.Hour); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	assessment = securityService.AssessRisk(ctx, ip)
+	if !assessment.Blocked || assessment.PastBlocks != 1 || math.Abs(assessment.Score-0.525) > 1e-9 {
+		t.Fatalf("Expected a blocked client to add 0.2, got %+v", assessment)
+	}
+	if err := securityService.UnblockIP(ctx, ip); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	assessment = securityService.AssessRisk(ctx, ip)
+	if assessment.Blocked || assessment.PastBlocks != 1 || math.Abs(assessment.Score-(0.325+0.2/3)) > 1e-9 {
+		t.Errorf("Expected one past block of three to add 0.2/3, got %+v", assessment)
+	}
+}
+
+func TestSecurityService_AssessRiskBotScore(t *testing.T) {
+	securityService := newRiskSecurityService()
+	ctx := context.Background()
+
+	if _, err := securityService.CheckRequest(ctx, "10.0.0.2", "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36", "/captcha", 100*time.Millisecond, false); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if _, err := securityService.CheckRequest(ctx, "10.0.0.3", "python-requests/2.31", "/captcha", 100*time.Millisecond, false); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	human := securityService.AssessRisk(ctx, "10.0.0.2")
+	bot := securityService.AssessRisk(ctx, "10.0.0.3")
+	if bot.BotScore <= human.BotScore || bot.Score <= human.Score {
+		t.Errorf("Expected the scripted client to be riskier, got %+v and %+v", bot, human)
+	}
+}
+
+func TestSecurity_ContextWithClient(t *testing.T) {
+	if ip := security.ExtractIPFromRequest(context.Background()); ip != "" {
+		t.Errorf("Expected no client without a transport, got %q", ip)
+	}
+
+	ctx := security.ContextWithClient(context.Background(), "10.0.0.4", "test-agent")
+	if security.ExtractIPFromRequest(ctx) != "10.0.0.4" || security.ExtractUserAgentFromRequest(ctx) != "test-agent" {
+		t.Errorf("Expected the client recorded by the transport")
+	}
+}
+
+func TestRiskPolicy(t *testing.T) {
+	policy := domain.DefaultRiskPolicy()
+
+	tests := []struct {
+		risk       float64
+		tier       domain.RiskTier
+		complexity int32
+	}{
+		{-1, domain.RiskTierLow, 10},
+		{0.1, domain.RiskTierLow, 18},
+		{0.3, domain.RiskTierMedium, 34},
+		{0.6, domain.RiskTierHigh, 58},
+		{2, domain.RiskTierHigh, 90},
+	}
+	for _, tt := range tests {
+		if tier, complexity := policy.Tier(tt.risk), policy.Complexity(tt.risk); tier != tt.tier || complexity != tt.complexity {
+			t.Errorf("Risk %v: expected %s at %d, got %s at %d", tt.risk, tt.tier, tt.complexity, tier, complexity)
+		}
+	}
+}
+
+// newAutoUsecase creates a usecase assessing every client at risk and recording answers
+func newAutoUsecase(risk float64, answers *[]bool) usecase.CaptchaUsecase {
+	return usecase.NewCaptchaUsecase(repository.NewInMemoryChallengeRepository(), &usecase.Config{
+		MaxActiveChallenges: 100,
+		ChallengeTimeout:    time.Minute,
+		CleanupInterval:     time.Minute,
+		AssessRisk:          func(ctx context.Context) float64 { return risk },
+		OnAnswer: func(ctx context.Context, challenge *domain.Challenge, solved bool, elapsed time.Duration) {
+			*answers = append(*answers, solved)
+		},
+	})
+}
+
+func TestCaptchaUsecase_AutoComplexity(t *testing.T) {
+	ctx := context.Background()
+	low := map[domain.ChallengeType]bool{domain.ChallengeTypeClick: true, domain.ChallengeTypeSlider: true, domain.ChallengeTypeRotate: true}
+	high := map[domain.ChallengeType]bool{domain.ChallengeTypeGame: true, domain.ChallengeTypeDragDrop: true, domain.ChallengeTypeGrid: true}
+
+	var answers []bool
+	trusted := newAutoUsecase(0.1, &answers)
+	suspicious := newAutoUsecase(0.9, &answers)
+	for i := 0; i < 20; i++ {
+		challenge, err := trusted.CreateChallengeWithOptions(ctx, 100, domain.ChallengeOptions{Auto: true})
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if !low[challenge.Type] || challenge.Complexity != 18 || challenge.MinStages() != 0 {
+			t.Fatalf("Expected a quick challenge at complexity 18, got %s at %d", challenge.Type, challenge.Complexity)
+		}
+		if challenge.Metadata["risk"] != "0.10" || challenge.Metadata["risk_tier"] != "low" || challenge.Metadata["auto"] != "true" {
+			t.Fatalf("Unexpected metadata %v", challenge.Metadata)
+		}
+
+		challenge, err = suspicious.CreateChallengeWithOptions(ctx, 0, domain.ChallengeOptions{Auto: true})
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if !high[challenge.Type] || challenge.Complexity != 82 || challenge.MinStages() != 2 {
+			t.Fatalf("Expected a hard two round challenge at complexity 82, got %s at %d over %d", challenge.Type, challenge.Complexity, challenge.MinStages())
+		}
+	}
+
+	// Without auto the requested complexity stands
+	challenge, err := suspicious.CreateChallenge(ctx, 40)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if challenge.Complexity != 40 || challenge.Metadata["auto"] != "" {
+		t.Errorf("Expected complexity 40 without auto metadata, got %d and %v", challenge.Complexity, challenge.Metadata)
+	}
+}
+
+func TestCaptchaUsecase_AutoComplexityRequiresStages(t *testing.T) {
+	ctx := context.Background()
+	var answers []bool
+	captchaUsecase := newAutoUsecase(0.9, &answers)
+
+	// Keyboard-only clients still get text, over the rounds high risk requires
+	challenge, err := captchaUsecase.CreateChallengeWithOptions(ctx, 0, domain.ChallengeOptions{Auto: true, KeyboardOnly: true})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if challenge.Type != domain.ChallengeTypeText || challenge.MinStages() != 2 {
+		t.Fatalf("Expected a two round text challenge, got %s over %d", challenge.Type, challenge.MinStages())
+	}
+
+	// A single answer cannot solve it
+	result, err := captchaUsecase.ValidateChallenge(ctx, challenge.ID, challenge.Answer.(*captcha.TextAnswer).Text)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if result.Solved || result.Error != domain.ResultErrorStagesRequired {
+		t.Fatalf("Expected a single answer to be refused, got %+v", result)
+	}
+	if domainErr, ok := domain.AsError(result.Err()); !ok || domainErr.Reason != domain.ReasonStagesRequired {
+		t.Errorf("Expected STAGES_REQUIRED, got %v", result.Err())
+	}
+
+	// A correct first round pushes the required second one
+	stored, _ := captchaUsecase.GetChallenge(ctx, challenge.ID)
+	events := submitStageAnswer(t, captchaUsecase, stored.ID, stored.CurrentStage().Answer.(*captcha.TextAnswer).Text)
+	if len(events) != 2 || events[0].Type != domain.ServerEventTypeSendClientData {
+		t.Fatalf("Expected a follow-up round, got %+v", events)
+	}
+	var started map[string]interface{}
+	if err := json.Unmarshal(events[0].Data, &started); err != nil {
+		t.Fatalf("Failed to decode stage data: %v", err)
+	}
+	if started["reason"] != domain.StageReasonRequired || started["max_stages"] != float64(3) {
+		t.Errorf("Expected a required stage, got %v", started)
+	}
+
+	// The second correct round solves it
+	stored, _ = captchaUsecase.GetChallenge(ctx, challenge.ID)
+	events = submitStageAnswer(t, captchaUsecase, stored.ID, stored.CurrentStage().Answer.(*captcha.TextAnswer).Text)
+	if len(events) != 1 || events[0].Type != domain.ServerEventTypeChallengeResult || !events[0].Solved {
+		t.Fatalf("Expected the challenge solved after two rounds, got %+v", events)
+	}
+
+	// Only the two stage answers reached the hook, the refused single answer did not
+	if len(answers) != 2 || !answers[0] || !answers[1] {
+		t.Errorf("Expected two solved answers, got %v", answers)
+	}
+}
+
+func submitStageAnswer(t *testing.T, captchaUsecase usecase.CaptchaUsecase, challengeID, answer string) []*domain.ServerEvent {
+	t.Helper()
+
+	data, _ := json.Marshal(map[string]interface{}{"type": "challenge_attempt", "answer": answer})
+	events, err := captchaUsecase.ProcessEvent(context.Background(), &domain.Event{Type: domain.EventTypeFrontendEvent, ChallengeID: challengeID, Data: data})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	return events
+}
@@ -0,0 +1,282 @@
+package unit
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/FlooooowY/SteelMount-Captcha-Service/internal/captcha"
+	"github.com/FlooooowY/SteelMount-Captcha-Service/internal/domain"
+	"github.com/FlooooowY/SteelMount-Captcha-Service/internal/repository"
+	"github.com/FlooooowY/SteelMount-Captcha-Service/internal/usecase"
+)
+
+func TestRotateGenerator_Generate(t *testing.T) {
+	generator := captcha.NewRotateGenerator(400, 300)
+
+	for _, tt := range []struct {
+		complexity int32
+		tolerance  int
+	}{
+		{complexity: 0, tolerance: 20},
+		{complexity: 50, tolerance: 14},
+		{complexity: 100, tolerance: 8},
+	} {
+		rotate, raw, err := generator.Generate(rand.New(rand.NewSource(1)), tt.complexity)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		answer, ok := raw.(*captcha.RotateAnswer)
+		if !ok {
+			t.Fatalf("Expected *RotateAnswer, got %T", raw)
+		}
+
+		if answer.Tolerance != tt.tolerance {
+			t.Errorf("Complexity %d: expected tolerance %d, got %d", tt.complexity, tt.tolerance, answer.Tolerance)
+		}
+		// Submitting without rotating must not earn any credit
+		if solved, confidence := answer.Verify(0); solved || confidence != 0 {
+			t.Errorf("Expected unrotated picture to fail, angle %d got confidence %d", answer.Angle, confidence)
+		}
+		if !strings.Contains(rotate.Instructions, answer.Object) {
+			t.Errorf("Expected instructions to name the %s: %s", answer.Object, rotate.Instructions)
+		}
+
+		img := decodePNG(t, rotate.Image)
+		if img.Bounds().Dx() != rotate.Size || img.Bounds().Dy() != rotate.Size {
+			t.Errorf("Expected %dpx square image, got %v", rotate.Size, img.Bounds())
+		}
+
+		html, err := generator.GenerateHTML(rotate)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if strings.Contains(html, `"angle"`) {
+			t.Errorf("HTML must not contain the rotation angle")
+		}
+	}
+
+	if _, _, err := captcha.NewRotateGenerator(100, 100).Generate(rand.New(rand.NewSource(1)), 50); err == nil {
+		t.Errorf("Expected a too small canvas to be rejected")
+	}
+}
+
+func TestRotateAnswer_Verify(t *testing.T) {
+	answer := &captcha.RotateAnswer{Object: "house", Angle: 100, Tolerance: 10}
+
+	tests := []struct {
+		name       string
+		rotation   float64
+		solved     bool
+		confidence int32
+	}{
+		{name: "exact", rotation: 260, solved: true, confidence: 100},
+		{name: "within tolerance", rotation: 265, solved: true, confidence: 95},
+		{name: "counter-clockwise", rotation: -100, solved: true, confidence: 100},
+		{name: "extra full turn", rotation: 620, solved: true, confidence: 100},
+		{name: "near miss", rotation: 240, confidence: 60},
+		{name: "upside down", rotation: 80, confidence: 0},
+		{name: "not rotated", rotation: 0, confidence: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			solved, confidence := answer.Verify(tt.rotation)
+			if solved != tt.solved || confidence != tt.confidence {
+				t.Errorf("Expected solved=%v confidence=%d, got solved=%v confidence=%d",
+					tt.solved, tt.confidence, solved, confidence)
+			}
+		})
+	}
+}
+
+func TestCaptchaUsecase_RotateAnswer(t *testing.T) {
+	repo := repository.NewInMemoryChallengeRepository()
+	captchaUsecase := usecase.NewCaptchaUsecase(repo, &usecase.Config{
+		MaxActiveChallenges: 10,
+		ChallengeTimeout:    time.Minute,
+		CleanupInterval:     time.Minute,
+	})
+
+	tests := []struct {
+		name   string
+		answer interface{}
+		solved bool
+	}{
+		{name: "JSON number", answer: float64(45), solved: true},
+		{name: "integer", answer: 50, solved: true},
+		{name: "not a number", answer: "45", solved: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			challenge := &domain.Challenge{
+				ID:        "rotate-" + strings.ReplaceAll(tt.name, " ", "-"),
+				Type:      domain.ChallengeTypeRotate,
+				Answer:    &captcha.RotateAnswer{Object: "tree", Angle: 315, Tolerance: 12},
+				CreatedAt: time.Now(),
+				ExpiresAt: time.Now().Add(time.Minute),
+			}
+			if err := repo.Create(context.Background(), challenge); err != nil {
+				t.Fatalf("Failed to store challenge: %v", err)
+			}
+
+			result, err := captchaUsecase.ValidateChallenge(context.Background(), challenge.ID, tt.answer)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if result.Solved != tt.solved {
+				t.Errorf("Expected solved=%v, got %+v", tt.solved, result)
+			}
+		})
+	}
+}
+
+func TestCaptchaUsecase_AttemptsLimitedConcurrently(t *testing.T) {
+	repo := repository.NewInMemoryChallengeRepository()
+	captchaUsecase := usecase.NewCaptchaUsecase(repo, &usecase.Config{
+		MaxActiveChallenges: 10,
+		ChallengeTimeout:    time.Minute,
+		CleanupInterval:     time.Minute,
+		MaxAttempts:         3,
+	})
+	ctx := context.Background()
+
+	challenge := &domain.Challenge{
+		ID:        "rotate-concurrent",
+		Type:      domain.ChallengeTypeRotate,
+		Answer:    &captcha.RotateAnswer{Object: "tree", Angle: 160, Tolerance: 20},
+		CreatedAt: time.Now(),
+		ExpiresAt: time.Now().Add(time.Minute),
+	}
+	if err := repo.Create(ctx, challenge); err != nil {
+		t.Fatalf("Failed to store challenge: %v", err)
+	}
+
+	// Answers racing each other still count one by one against the limit
+	var accepted atomic.Int32
+	var wg sync.WaitGroup
+	for i := 0; i < 32; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			result, err := captchaUsecase.ValidateChallenge(ctx, challenge.ID, float64(0))
+			if err != nil {
+				t.Errorf("Unexpected error: %v", err)
+				return
+			}
+			if result.Error == "" {
+				accepted.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if got := accepted.Load(); got != 3 {
+		t.Errorf("Expected 3 answers accepted, got %d", got)
+	}
+	stored, _ := captchaUsecase.GetChallenge(ctx, challenge.ID)
+	if stored.Attempts() != 3 {
+		t.Errorf("Expected 3 recorded attempts, got %d", stored.Attempts())
+	}
+}
+
+func TestCaptchaUsecase_VerifyConsumesOnce(t *testing.T) {
+	repo := repository.NewInMemoryChallengeRepository()
+	captchaUsecase := usecase.NewCaptchaUsecase(repo, &usecase.Config{
+		MaxActiveChallenges: 10,
+		ChallengeTimeout:    time.Minute,
+		CleanupInterval:     time.Minute,
+		MaxAttempts:         3,
+	})
+	ctx := context.Background()
+
+	challenge := &domain.Challenge{
+		ID:        "rotate-verified",
+		Type:      domain.ChallengeTypeRotate,
+		Answer:    &captcha.RotateAnswer{Object: "tree", Angle: 160, Tolerance: 20},
+		CreatedAt: time.Now(),
+		ExpiresAt: time.Now().Add(time.Minute),
+	}
+	if err := repo.Create(ctx, challenge); err != nil {
+		t.Fatalf("Failed to store challenge: %v", err)
+	}
+
+	// Backends verifying while the answer is validated consume the solution once
+	var verified atomic.Int32
+	var wg sync.WaitGroup
+	for i := 0; i < 16; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			if _, err := captchaUsecase.ValidateChallenge(ctx, challenge.ID, float64(200)); err != nil {
+				t.Errorf("Unexpected error: %v", err)
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			solved, err := captchaUsecase.VerifyChallenge(ctx, challenge.ID)
+			if solved {
+				verified.Add(1)
+			} else if err != nil && !errors.Is(err, domain.ErrExpired) {
+				t.Errorf("Unexpected error: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	// A solution none of them saw yet is consumed now
+	if solved, _ := captchaUsecase.VerifyChallenge(ctx, challenge.ID); solved {
+		verified.Add(1)
+	}
+	if got := verified.Load(); got != 1 {
+		t.Errorf("Expected the solution verified once, got %d", got)
+	}
+	if _, err := captchaUsecase.VerifyChallenge(ctx, challenge.ID); !errors.Is(err, domain.ErrExpired) {
+		t.Errorf("Expected a consumed challenge, got %v", err)
+	}
+}
+
+func TestCaptchaUsecase_RotateAttemptsLimited(t *testing.T) {
+	repo := repository.NewInMemoryChallengeRepository()
+	captchaUsecase := usecase.NewCaptchaUsecase(repo, &usecase.Config{
+		MaxActiveChallenges: 10,
+		ChallengeTimeout:    time.Minute,
+		CleanupInterval:     time.Minute,
+		MaxAttempts:         3,
+	})
+	ctx := context.Background()
+
+	challenge := &domain.Challenge{
+		ID:        "rotate-stepped",
+		Type:      domain.ChallengeTypeRotate,
+		Answer:    &captcha.RotateAnswer{Object: "tree", Angle: 160, Tolerance: 20},
+		CreatedAt: time.Now(),
+		ExpiresAt: time.Now().Add(time.Minute),
+	}
+	if err := repo.Create(ctx, challenge); err != nil {
+		t.Fatalf("Failed to store challenge: %v", err)
+	}
+
+	// Stepping the angle by twice the tolerance would hit the answer within 9 tries
+	for step := 0; step < 9; step++ {
+		result, err := captchaUsecase.ValidateChallenge(ctx, challenge.ID, float64(step*40))
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if step < 3 {
+			if result.Attempts != int32(step+1) || result.Error != "" {
+				t.Fatalf("Attempt %d: unexpected result %+v", step+1, result)
+			}
+			continue
+		}
+		if result.Solved || result.Error != domain.ResultErrorExhausted || result.Attempts != 3 {
+			t.Fatalf("Attempt %d: expected no attempts left, got %+v", step+1, result)
+		}
+	}
+}
@@ -0,0 +1,197 @@
+package unit
+
+import (
+	"context"
+	"encoding/json"
+	"math"
+	"math/rand"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/FlooooowY/SteelMount-Captcha-Service/internal/captcha"
+	"github.com/FlooooowY/SteelMount-Captcha-Service/internal/domain"
+	"github.com/FlooooowY/SteelMount-Captcha-Service/internal/repository"
+	"github.com/FlooooowY/SteelMount-Captcha-Service/internal/usecase"
+)
+
+// humanDrag eases in and out towards target over the duration with a wobbling
+// hand and uneven sampling, like a pointer drag
+func humanDrag(target float64, duration time.Duration) *captcha.SliderSubmission {
+	total := float64(duration.Milliseconds())
+	var points []captcha.SliderPoint
+	for i, t := 0, 0.0; t < total; i++ {
+		u := t / total
+		points = append(points, captcha.SliderPoint{
+			X: target * (3*u*u - 2*u*u*u),
+			Y: 1.5*math.Sin(float64(i)*0.7) + 0.5*math.Cos(float64(i)*1.9),
+			T: t,
+		})
+		t += 14 + float64(i%5)
+	}
+	points = append(points, captcha.SliderPoint{X: target, Y: 0.8, T: total})
+
+	return &captcha.SliderSubmission{Position: target, Trajectory: points}
+}
+
+// scriptedDrag moves at constant speed in a straight line
+func scriptedDrag(target float64, samples int, step time.Duration) *captcha.SliderSubmission {
+	points := make([]captcha.SliderPoint, samples)
+	for i := range points {
+		points[i] = captcha.SliderPoint{
+			X: target * float64(i) / float64(samples-1),
+			T: float64(i) * float64(step.Milliseconds()),
+		}
+	}
+
+	return &captcha.SliderSubmission{Position: target, Trajectory: points}
+}
+
+func hasViolation(verdict *captcha.SliderVerdict, violation string) bool {
+	for _, v := range verdict.Violations {
+		if v == violation {
+			return true
+		}
+	}
+	return false
+}
+
+func TestSliderGenerator_Generate(t *testing.T) {
+	generator := captcha.NewSliderGenerator(400, 300, 44)
+
+	slider, raw, err := generator.Generate(rand.New(rand.NewSource(1)), 80)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	answer, ok := raw.(*captcha.SliderAnswer)
+	if !ok {
+		t.Fatalf("Expected *SliderAnswer, got %T", raw)
+	}
+
+	if answer.GapX < slider.PieceWidth || answer.GapX+slider.PieceWidth > slider.CanvasWidth {
+		t.Errorf("Gap at %d is outside the track", answer.GapX)
+	}
+	if answer.Tolerance <= 0 || answer.TrackWidth != slider.CanvasWidth-slider.PieceWidth {
+		t.Errorf("Unexpected answer limits: %+v", answer)
+	}
+
+	decode := func(dataURL string) (int, int, bool) {
+		img := decodePNG(t, dataURL)
+		_, _, _, alpha := img.At(0, 0).RGBA()
+		return img.Bounds().Dx(), img.Bounds().Dy(), alpha == 0
+	}
+
+	if width, height, _ := decode(slider.Background); width != slider.CanvasWidth || height != slider.CanvasHeight {
+		t.Errorf("Expected %dx%d background, got %dx%d", slider.CanvasWidth, slider.CanvasHeight, width, height)
+	}
+	width, height, transparentCorner := decode(slider.Piece)
+	if width != slider.PieceWidth || height != slider.PieceHeight {
+		t.Errorf("Expected %dx%d piece, got %dx%d", slider.PieceWidth, slider.PieceHeight, width, height)
+	}
+	if !transparentCorner {
+		t.Errorf("Expected the piece to be transparent outside its outline")
+	}
+
+	html, err := generator.GenerateHTML(slider)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if strings.Contains(html, "gap_x") {
+		t.Errorf("HTML must not contain the gap position")
+	}
+}
+
+func TestSliderAnswer_Verify(t *testing.T) {
+	answer := &captcha.SliderAnswer{GapX: 200, Tolerance: 5, MinDuration: 300 * time.Millisecond, TrackWidth: 350}
+
+	human := answer.Verify(humanDrag(202, 900*time.Millisecond))
+	if !human.Solved || human.Confidence < 90 || len(human.Violations) > 0 {
+		t.Errorf("Expected human drag to solve the slider, got %+v", human)
+	}
+
+	// A narrow miss by a human is borderline, a wide one fails
+	nearMiss := answer.Verify(humanDrag(212, 900*time.Millisecond))
+	if nearMiss.Solved || nearMiss.Confidence < 50 || nearMiss.Confidence > 70 {
+		t.Errorf("Expected borderline confidence for a near miss, got %+v", nearMiss)
+	}
+	if wide := answer.Verify(humanDrag(120, 900*time.Millisecond)); wide.Solved || wide.Confidence != 0 {
+		t.Errorf("Expected a wide miss to fail, got %+v", wide)
+	}
+
+	// Precise but scripted drags are rejected outright
+	linear := answer.Verify(scriptedDrag(200, 20, 40*time.Millisecond))
+	if linear.Solved || linear.Confidence != 0 {
+		t.Errorf("Expected linear drag to be rejected, got %+v", linear)
+	}
+	for _, violation := range []string{
+		captcha.SliderViolationConstantVelocity,
+		captcha.SliderViolationNoJitter,
+		captcha.SliderViolationNoDeceleration,
+	} {
+		if !hasViolation(linear, violation) {
+			t.Errorf("Expected %s for a linear drag, got %v", violation, linear.Violations)
+		}
+	}
+
+	tests := []struct {
+		name       string
+		submission *captcha.SliderSubmission
+		violation  string
+	}{
+		{"teleport", scriptedDrag(200, 2, 500*time.Millisecond), captcha.SliderViolationTooFewPoints},
+		{"too fast", humanDrag(200, 150*time.Millisecond), captcha.SliderViolationTooFast},
+		{"position does not match the drag", &captcha.SliderSubmission{Position: 200, Trajectory: humanDrag(150, time.Second).Trajectory}, captcha.SliderViolationEnd},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			verdict := answer.Verify(tt.submission)
+			if verdict.Solved || !hasViolation(verdict, tt.violation) {
+				t.Errorf("Expected %s, got %+v", tt.violation, verdict)
+			}
+		})
+	}
+
+	jump := humanDrag(200, time.Second)
+	jump.Trajectory[len(jump.Trajectory)/2].X += 150
+	if verdict := answer.Verify(jump); !hasViolation(verdict, captcha.SliderViolationJump) {
+		t.Errorf("Expected a jump to be flagged, got %+v", verdict)
+	}
+}
+
+func TestCaptchaUsecase_SliderAnswerFromJSON(t *testing.T) {
+	repo := repository.NewInMemoryChallengeRepository()
+	captchaUsecase := usecase.NewCaptchaUsecase(repo, &usecase.Config{
+		MaxActiveChallenges: 10,
+		ChallengeTimeout:    time.Minute,
+		CleanupInterval:     time.Minute,
+	})
+
+	challenge := &domain.Challenge{
+		ID:        "slider-1",
+		Type:      domain.ChallengeTypeSlider,
+		Answer:    &captcha.SliderAnswer{GapX: 180, Tolerance: 5, MinDuration: 300 * time.Millisecond, TrackWidth: 350},
+		CreatedAt: time.Now(),
+		ExpiresAt: time.Now().Add(time.Minute),
+	}
+	if err := repo.Create(context.Background(), challenge); err != nil {
+		t.Fatalf("Failed to store challenge: %v", err)
+	}
+
+	// The answer arrives as decoded JSON, like from an event stream or the gateway
+	data, err := json.Marshal(humanDrag(181, 800*time.Millisecond))
+	if err != nil {
+		t.Fatalf("Failed to marshal drag: %v", err)
+	}
+	var answer interface{}
+	if err := json.Unmarshal(data, &answer); err != nil {
+		t.Fatalf("Failed to decode drag: %v", err)
+	}
+
+	result, err := captchaUsecase.ValidateChallenge(context.Background(), challenge.ID, answer)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !result.Solved {
+		t.Errorf("Expected slider to be solved, got %+v", result)
+	}
+}
@@ -0,0 +1,80 @@
+package unit
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/FlooooowY/SteelMount-Captcha-Service/internal/domain"
+	"github.com/FlooooowY/SteelMount-Captcha-Service/internal/repository"
+	"github.com/FlooooowY/SteelMount-Captcha-Service/internal/usecase"
+)
+
+func TestCaptchaUsecase_SwipeAnswers(t *testing.T) {
+	repo := repository.NewInMemoryChallengeRepository()
+	captchaUsecase := usecase.NewCaptchaUsecase(repo, &usecase.Config{
+		MaxActiveChallenges: 10,
+		ChallengeTimeout:    time.Minute,
+		CleanupInterval:     time.Minute,
+	})
+
+	// Answers arrive as decoded JSON, the frontend also reports the swipe distance
+	decode := func(swipes string) interface{} {
+		var answer interface{}
+		if err := json.Unmarshal([]byte(swipes), &answer); err != nil {
+			t.Fatalf("Invalid answer: %v", err)
+		}
+		return answer
+	}
+
+	tests := []struct {
+		name       string
+		answer     interface{}
+		solved     bool
+		confidence int32
+	}{
+		{
+			name:       "json decoded",
+			answer:     decode(`[{"areaId":"area_0","direction":"left","distance":120},{"areaId":"area_1","direction":"up","distance":95}]`),
+			solved:     true,
+			confidence: 100,
+		},
+		{
+			name:       "go values",
+			answer:     []map[string]interface{}{{"areaId": "area_0", "direction": "left"}, {"areaId": "area_1", "direction": "up"}},
+			solved:     true,
+			confidence: 100,
+		},
+		{name: "one wrong direction", answer: decode(`[{"areaId":"area_0","direction":"left"},{"areaId":"area_1","direction":"down"}]`), confidence: 50},
+		{name: "missing swipe", answer: decode(`[{"areaId":"area_0","direction":"left"}]`), confidence: 20},
+		{name: "not objects", answer: decode(`["left", "up"]`), confidence: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			challenge := &domain.Challenge{
+				ID:   "swipe-" + strings.ReplaceAll(tt.name, " ", "-"),
+				Type: domain.ChallengeTypeSwipe,
+				Answer: []map[string]interface{}{
+					{"areaId": "area_0", "direction": "left"},
+					{"areaId": "area_1", "direction": "up"},
+				},
+				CreatedAt: time.Now(),
+				ExpiresAt: time.Now().Add(time.Minute),
+			}
+			if err := repo.Create(context.Background(), challenge); err != nil {
+				t.Fatalf("Failed to store challenge: %v", err)
+			}
+
+			result, err := captchaUsecase.ValidateChallenge(context.Background(), challenge.ID, tt.answer)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if result.Solved != tt.solved || result.ConfidencePercent != tt.confidence {
+				t.Errorf("Expected solved=%v confidence=%d, got solved=%v confidence=%d", tt.solved, tt.confidence, result.Solved, result.ConfidencePercent)
+			}
+		})
+	}
+}
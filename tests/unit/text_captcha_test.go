@@ -0,0 +1,127 @@
+package unit
+
+import (
+	"context"
+	"math/rand"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/FlooooowY/SteelMount-Captcha-Service/internal/captcha"
+	"github.com/FlooooowY/SteelMount-Captcha-Service/internal/domain"
+	"github.com/FlooooowY/SteelMount-Captcha-Service/internal/repository"
+	"github.com/FlooooowY/SteelMount-Captcha-Service/internal/usecase"
+)
+
+func TestTextGenerator_Generate(t *testing.T) {
+	generator := captcha.NewTextGenerator(400, 300, 4, 7)
+
+	for _, tt := range []struct {
+		complexity int32
+		length     int
+	}{
+		{complexity: 0, length: 4},
+		{complexity: 50, length: 5},
+		{complexity: 100, length: 7},
+	} {
+		text, raw, err := generator.Generate(rand.New(rand.NewSource(1)), tt.complexity)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		answer, ok := raw.(*captcha.TextAnswer)
+		if !ok {
+			t.Fatalf("Expected *TextAnswer, got %T", raw)
+		}
+
+		if len(answer.Text) != tt.length || text.Length != tt.length {
+			t.Errorf("Complexity %d: expected %d characters, got %q", tt.complexity, tt.length, answer.Text)
+		}
+		// Rendered characters survive normalization unchanged, look-alikes are never drawn
+		if captcha.NormalizeText(answer.Text) != answer.Text {
+			t.Errorf("Expected only unambiguous characters, got %q", answer.Text)
+		}
+
+		img := decodePNG(t, text.Image)
+		if img.Bounds().Dx() != text.CanvasWidth || img.Bounds().Dy() != text.CanvasHeight {
+			t.Errorf("Expected %dx%d image, got %v", text.CanvasWidth, text.CanvasHeight, img.Bounds())
+		}
+
+		html, err := generator.GenerateHTML(text)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if strings.Contains(html, `"text":`) {
+			t.Errorf("HTML must not contain the answer")
+		}
+	}
+
+	if _, _, err := captcha.NewTextGenerator(60, 300, 4, 7).Generate(rand.New(rand.NewSource(1)), 100); err == nil {
+		t.Errorf("Expected a too narrow canvas to be rejected")
+	}
+}
+
+func TestTextAnswer_Verify(t *testing.T) {
+	answer := &captcha.TextAnswer{Text: "K0P1X5"}
+
+	tests := []struct {
+		name       string
+		input      string
+		solved     bool
+		confidence int32
+	}{
+		{name: "exact", input: "K0P1X5", solved: true, confidence: 100},
+		{name: "lower case", input: "k0p1x5", solved: true, confidence: 100},
+		{name: "spaces", input: " K0 P1 X5 ", solved: true, confidence: 100},
+		{name: "letter O for zero", input: "KOP1X5", solved: true, confidence: 100},
+		{name: "l and i for one", input: "kopl x5", solved: true, confidence: 100},
+		{name: "capital I for one", input: "K0PIXS", solved: true, confidence: 100},
+		{name: "one character wrong", input: "K0P1Y5", confidence: 60},
+		{name: "one character missing", input: "K0P15", confidence: 60},
+		{name: "two characters wrong", input: "K0R1Y5", confidence: 0},
+		{name: "empty", input: "", confidence: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			solved, confidence := answer.Verify(tt.input)
+			if solved != tt.solved || confidence != tt.confidence {
+				t.Errorf("Verify(%q) = %v, %d, expected %v, %d", tt.input, solved, confidence, tt.solved, tt.confidence)
+			}
+		})
+	}
+}
+
+func TestCaptchaUsecase_KeyboardOnlyChallenge(t *testing.T) {
+	captchaUsecase := usecase.NewCaptchaUsecase(repository.NewInMemoryChallengeRepository(), &usecase.Config{
+		MaxActiveChallenges: 10,
+		ChallengeTimeout:    time.Minute,
+		CleanupInterval:     time.Minute,
+	})
+	ctx := context.Background()
+
+	challenge, err := captchaUsecase.CreateChallengeWithOptions(ctx, 40, domain.ChallengeOptions{KeyboardOnly: true})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if challenge.Type != domain.ChallengeTypeText || challenge.Metadata["keyboard_only"] != "true" {
+		t.Fatalf("Expected a keyboard-only text challenge, got %s %v", challenge.Type, challenge.Metadata)
+	}
+
+	typed := strings.ToLower(challenge.Answer.(*captcha.TextAnswer).Text)
+	result, err := captchaUsecase.ValidateChallenge(ctx, challenge.ID, typed)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !result.Solved {
+		t.Errorf("Expected lower case answer to solve the challenge, got %+v", result)
+	}
+
+	// Audio only needs a keyboard as well, so accessibility wins
+	challenge, err = captchaUsecase.CreateChallengeWithOptions(ctx, 40, domain.ChallengeOptions{Accessible: true, KeyboardOnly: true})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if challenge.Type != domain.ChallengeTypeAudio {
+		t.Errorf("Expected an audio challenge, got %s", challenge.Type)
+	}
+}
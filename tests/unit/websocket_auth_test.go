@@ -0,0 +1,112 @@
+package unit
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	gorilla "github.com/gorilla/websocket"
+
+	"github.com/FlooooowY/SteelMount-Captcha-Service/internal/websocket"
+)
+
+func TestConnectToken_RoundTrip(t *testing.T) {
+	secret := []byte("ws-secret")
+
+	token := websocket.NewConnectToken(secret, "client-1", time.Minute)
+	clientID, err := websocket.VerifyConnectToken(secret, token)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if clientID != "client-1" {
+		t.Errorf("Expected client-1, got %q", clientID)
+	}
+
+	if _, err := websocket.VerifyConnectToken([]byte("other-secret"), token); err != websocket.ErrInvalidToken {
+		t.Errorf("Expected ErrInvalidToken for wrong secret, got %v", err)
+	}
+
+	expired := websocket.NewConnectToken(secret, "client-1", -time.Minute)
+	if _, err := websocket.VerifyConnectToken(secret, expired); err != websocket.ErrTokenExpired {
+		t.Errorf("Expected ErrTokenExpired, got %v", err)
+	}
+}
+
+func TestHTTPServer_AllowMissingOrigin(t *testing.T) {
+	config := websocket.DefaultHTTPServerConfig()
+	config.AllowedOrigins = []string{"https://shop.example"}
+	config.AllowMissingOrigin = true
+
+	server := websocket.NewHTTPServerWithConfig(websocket.NewWebSocketService(), 0, config, nil)
+	ts := httptest.NewServer(server.Handler())
+	defer ts.Close()
+
+	wsURL := "ws" + strings.TrimPrefix(ts.URL, "http") + "/ws?client_id=backend-1"
+
+	conn, _, err := gorilla.DefaultDialer.Dial(wsURL, nil)
+	if err != nil {
+		t.Fatalf("Expected a client without origin to connect, got %v", err)
+	}
+	conn.Close()
+
+	// A browser still has to come from an allowed origin
+	if _, resp, err := gorilla.DefaultDialer.Dial(wsURL, http.Header{"Origin": []string{"https://evil.example"}}); err == nil || resp == nil || resp.StatusCode != http.StatusForbidden {
+		t.Errorf("Expected 403 for foreign origin, got %v", err)
+	}
+}
+
+func TestHTTPServer_ConnectionChecks(t *testing.T) {
+	secret := "ws-secret"
+	config := websocket.DefaultHTTPServerConfig()
+	config.AllowedOrigins = []string{"https://shop.example"}
+	config.TokenSecret = secret
+	config.RequireToken = true
+	config.MaxConnectionsPerClient = 1
+
+	server := websocket.NewHTTPServerWithConfig(websocket.NewWebSocketService(), 0, config, nil)
+	ts := httptest.NewServer(server.Handler())
+	defer ts.Close()
+
+	wsURL := "ws" + strings.TrimPrefix(ts.URL, "http") + "/ws"
+	token := websocket.NewConnectToken([]byte(secret), "client-1", time.Minute)
+	origin := http.Header{"Origin": []string{"https://shop.example"}}
+
+	dial := func(query string, header http.Header) (*gorilla.Conn, int) {
+		conn, resp, err := gorilla.DefaultDialer.Dial(wsURL+query, header)
+		if err != nil {
+			if resp == nil {
+				t.Fatalf("Dial failed: %v", err)
+			}
+			return nil, resp.StatusCode
+		}
+		return conn, http.StatusSwitchingProtocols
+	}
+
+	if _, code := dial("?client_id=client-1", origin); code != http.StatusUnauthorized {
+		t.Errorf("Expected 401 without token, got %d", code)
+	}
+
+	if _, code := dial("?token="+token, http.Header{"Origin": []string{"https://evil.example"}}); code != http.StatusForbidden {
+		t.Errorf("Expected 403 for foreign origin, got %d", code)
+	}
+
+	if _, code := dial("?token="+token, nil); code != http.StatusForbidden {
+		t.Errorf("Expected 403 without origin, got %d", code)
+	}
+
+	if _, code := dial("?client_id=client-2&token="+token, origin); code != http.StatusForbidden {
+		t.Errorf("Expected 403 for mismatched client_id, got %d", code)
+	}
+
+	conn, code := dial("?token="+token, origin)
+	if code != http.StatusSwitchingProtocols {
+		t.Fatalf("Expected upgrade with valid token, got %d", code)
+	}
+	defer conn.Close()
+
+	if _, code := dial("?token="+token, origin); code != http.StatusTooManyRequests {
+		t.Errorf("Expected 429 above per-client cap, got %d", code)
+	}
+}
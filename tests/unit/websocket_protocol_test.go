@@ -0,0 +1,230 @@
+package unit
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	logtest "github.com/sirupsen/logrus/hooks/test"
+
+	"github.com/FlooooowY/SteelMount-Captcha-Service/internal/websocket"
+)
+
+// receiveEvent waits for the next event delivered to a connection
+func receiveEvent(t *testing.T, conn *websocket.Connection) *websocket.Event {
+	t.Helper()
+
+	select {
+	case event := <-conn.Events:
+		return event
+	case <-time.After(2 * time.Second):
+		t.Fatalf("Timed out waiting for event")
+		return nil
+	}
+}
+
+func TestWebSocketProtocol_ReplyEchoesRequestID(t *testing.T) {
+	ws := websocket.NewWebSocketService()
+	ws.RegisterHandler(websocket.EventTypeCreateChallenge, func(ctx context.Context, event *websocket.Event) (*websocket.Event, error) {
+		var request websocket.CreateChallengePayload
+		if err := event.DecodeData(&request); err != nil {
+			return nil, err
+		}
+
+		reply := websocket.NewEvent(websocket.EventTypeChallengeCreated)
+		err := reply.SetData(&websocket.ChallengeCreatedPayload{ChallengeID: "c1", Complexity: request.Complexity})
+		return reply, err
+	})
+
+	conn := ws.CreateConnection("client-1")
+	message := []byte(`{"version":1,"id":"req-42","type":"create_challenge","data":{"complexity":30}}`)
+	if err := ws.ProcessMessage(context.Background(), conn.ID, message); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	reply := receiveEvent(t, conn)
+	if reply.Type != websocket.EventTypeChallengeCreated {
+		t.Fatalf("Expected challenge_created, got %s (%+v)", reply.Type, reply.Error)
+	}
+	if reply.RequestID != "req-42" {
+		t.Errorf("Expected request_id req-42, got %q", reply.RequestID)
+	}
+	if reply.Version != websocket.ProtocolVersion {
+		t.Errorf("Expected version %d, got %d", websocket.ProtocolVersion, reply.Version)
+	}
+}
+
+func TestWebSocketProtocol_ErrorFrames(t *testing.T) {
+	ws := websocket.NewWebSocketService()
+	ws.RegisterHandler(websocket.EventTypeCreateChallenge, func(ctx context.Context, event *websocket.Event) (*websocket.Event, error) {
+		var request websocket.CreateChallengePayload
+		return nil, event.DecodeData(&request)
+	})
+
+	tests := []struct {
+		name      string
+		message   string
+		requestID string
+		code      string
+	}{
+		{
+			name:    "malformed json",
+			message: `{"type":`,
+			code:    websocket.ErrorCodeInvalidMessage,
+		},
+		{
+			name:      "unsupported version",
+			message:   `{"version":99,"id":"r1","type":"create_challenge","data":{}}`,
+			requestID: "r1",
+			code:      websocket.ErrorCodeUnsupportedVersion,
+		},
+		{
+			name:      "unknown type",
+			message:   `{"id":"r2","type":"launch_rockets"}`,
+			requestID: "r2",
+			code:      websocket.ErrorCodeUnknownType,
+		},
+		{
+			name:      "invalid payload",
+			message:   `{"id":"r3","type":"create_challenge","data":{"complexity":500}}`,
+			requestID: "r3",
+			code:      websocket.ErrorCodeInvalidPayload,
+		},
+		{
+			name:      "unknown field",
+			message:   `{"id":"r4","type":"create_challenge","data":{"complexity":5,"color":"red"}}`,
+			requestID: "r4",
+			code:      websocket.ErrorCodeInvalidPayload,
+		},
+		{
+			name:      "challenge type",
+			message:   `{"id":"r7","type":"create_challenge","data":{"complexity":5,"challenge_type":"click"}}`,
+			requestID: "r7",
+			code:      websocket.ErrorCodeInvalidPayload,
+		},
+		{
+			name:      "auto complexity",
+			message:   `{"id":"r5","type":"create_challenge","data":{"complexity":0,"auto_complexity":true}}`,
+			requestID: "r5",
+			code:      websocket.ErrorCodeInvalidPayload,
+		},
+		{
+			name:      "passive",
+			message:   `{"id":"r6","type":"create_challenge","data":{"complexity":0,"passive":true}}`,
+			requestID: "r6",
+			code:      websocket.ErrorCodeInvalidPayload,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conn := ws.CreateConnection("client-1")
+			defer ws.CloseConnection(conn.ID)
+
+			_ = ws.ProcessMessage(context.Background(), conn.ID, []byte(tt.message))
+
+			event := receiveEvent(t, conn)
+			if event.Type != websocket.EventTypeError || event.Error == nil {
+				t.Fatalf("Expected error event, got %+v", event)
+			}
+			if event.Error.Code != tt.code {
+				t.Errorf("Expected code %s, got %s", tt.code, event.Error.Code)
+			}
+			if event.RequestID != tt.requestID {
+				t.Errorf("Expected request_id %q, got %q", tt.requestID, event.RequestID)
+			}
+		})
+	}
+}
+
+func TestWebSocketProtocol_HandlerErrorsLogged(t *testing.T) {
+	log, hook := logtest.NewNullLogger()
+	log.SetLevel(logrus.DebugLevel)
+
+	config := websocket.DefaultServiceConfig()
+	config.Logger = log
+	ws := websocket.NewWebSocketServiceWithConfig(config)
+	ws.RegisterHandler(websocket.EventTypeCreateChallenge, func(ctx context.Context, event *websocket.Event) (*websocket.Event, error) {
+		return nil, errors.New("generator failed")
+	})
+
+	conn := ws.CreateConnection("client-1")
+	if err := ws.ProcessMessage(context.Background(), conn.ID, []byte(`{"id":"r1","type":"create_challenge","data":{"complexity":30}}`)); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if event := receiveEvent(t, conn); event.Type != websocket.EventTypeError {
+		t.Fatalf("Expected error event, got %+v", event)
+	}
+
+	entry := hook.LastEntry()
+	if entry == nil {
+		t.Fatalf("Expected the handler error to be logged")
+	}
+	if entry.Data["connection_id"] != conn.ID {
+		t.Errorf("Expected connection_id field, got %v", entry.Data)
+	}
+	if strings.Contains(entry.Message, "create_challenge") {
+		t.Errorf("Expected no client event type in the log message, got %q", entry.Message)
+	}
+}
+
+func TestWebSocketSessions_RoutingAndCleanup(t *testing.T) {
+	ws := websocket.NewWebSocketService()
+	ws.RegisterHandler(websocket.EventTypeCreateChallenge, func(ctx context.Context, event *websocket.Event) (*websocket.Event, error) {
+		session, ok := websocket.SessionFromContext(ctx)
+		if !ok {
+			t.Errorf("Expected session in handler context")
+			return nil, nil
+		}
+		ws.BindChallenge(session, "challenge-"+session.ConnectionID)
+		return websocket.NewEvent(websocket.EventTypeChallengeCreated), nil
+	})
+
+	closed := make(chan []string, 1)
+	ws.OnSessionClosed(func(ctx context.Context, session *websocket.Session) {
+		closed <- session.Challenges()
+	})
+
+	// Two tabs of the same client
+	tab1 := ws.CreateConnection("client-1")
+	tab2 := ws.CreateConnection("client-1")
+
+	message := []byte(`{"id":"req-1","type":"create_challenge","data":{"complexity":10}}`)
+	if err := ws.ProcessMessage(context.Background(), tab2.ID, message); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	reply := receiveEvent(t, tab2)
+	if reply.ClientID != "client-1" {
+		t.Errorf("Expected reply for client-1, got %q", reply.ClientID)
+	}
+
+	select {
+	case event := <-tab1.Events:
+		t.Errorf("Reply leaked to the other tab: %+v", event)
+	default:
+	}
+
+	owner, owned := ws.ChallengeOwner("challenge-" + tab2.ID)
+	if !owned || owner != tab2.ID {
+		t.Errorf("Expected challenge to be owned by %s, got %s", tab2.ID, owner)
+	}
+
+	ws.CloseConnection(tab2.ID)
+
+	select {
+	case challenges := <-closed:
+		if len(challenges) != 1 || challenges[0] != "challenge-"+tab2.ID {
+			t.Errorf("Unexpected challenges on close: %v", challenges)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("Session close handler was not called")
+	}
+
+	if _, owned := ws.ChallengeOwner("challenge-" + tab2.ID); owned {
+		t.Errorf("Expected challenge ownership to be released")
+	}
+}
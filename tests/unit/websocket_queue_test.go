@@ -0,0 +1,65 @@
+package unit
+
+import (
+	"testing"
+
+	"github.com/FlooooowY/SteelMount-Captcha-Service/internal/websocket"
+)
+
+func TestWebSocketSendQueue_SlowConsumerPolicies(t *testing.T) {
+	newEvent := func(id string) *websocket.Event {
+		event := websocket.NewEvent("tick")
+		event.ID = id
+		return event
+	}
+
+	t.Run("drop newest", func(t *testing.T) {
+		ws := websocket.NewWebSocketServiceWithConfig(&websocket.ServiceConfig{SendQueueSize: 2, SlowConsumerPolicy: websocket.PolicyDropNewest})
+		conn := ws.CreateConnection("client-1")
+
+		for _, id := range []string{"e1", "e2", "e3"} {
+			_ = ws.SendEvent(conn.ID, newEvent(id))
+		}
+
+		if got := receiveEvent(t, conn).ID; got != "e1" {
+			t.Errorf("Expected e1 to be kept, got %s", got)
+		}
+		if conn.DroppedEvents() != 1 {
+			t.Errorf("Expected 1 dropped event, got %d", conn.DroppedEvents())
+		}
+	})
+
+	t.Run("drop oldest", func(t *testing.T) {
+		ws := websocket.NewWebSocketServiceWithConfig(&websocket.ServiceConfig{SendQueueSize: 2, SlowConsumerPolicy: websocket.PolicyDropOldest})
+		conn := ws.CreateConnection("client-1")
+
+		for _, id := range []string{"e1", "e2", "e3"} {
+			if err := ws.SendEvent(conn.ID, newEvent(id)); err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+		}
+
+		if got := receiveEvent(t, conn).ID; got != "e2" {
+			t.Errorf("Expected e1 to be dropped, got %s first", got)
+		}
+	})
+
+	t.Run("disconnect", func(t *testing.T) {
+		ws := websocket.NewWebSocketServiceWithConfig(&websocket.ServiceConfig{SendQueueSize: 1, SlowConsumerPolicy: websocket.PolicyDisconnect})
+		conn := ws.CreateConnection("client-1")
+
+		_ = ws.SendEvent(conn.ID, newEvent("e1"))
+		if err := ws.SendEvent(conn.ID, newEvent("e2")); err != websocket.ErrSlowConsumer {
+			t.Fatalf("Expected ErrSlowConsumer, got %v", err)
+		}
+
+		select {
+		case <-conn.Done():
+		default:
+			t.Fatalf("Expected slow consumer to be disconnected")
+		}
+		if _, exists := ws.GetConnection(conn.ID); exists {
+			t.Errorf("Expected connection to be removed")
+		}
+	})
+}